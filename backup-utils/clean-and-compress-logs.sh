@@ -75,6 +75,20 @@ else
     fi
   done
 
+# Delta snapshots are only useful on top of a full board that we still have,
+# so delete any that are older than the oldest board we kept.
+oldest_kept_ts=$(printf '%s\n' board-ts:*-seq:*.bin | awk -F '[:-]' '{print $3}' | sort -n | head -n 1)
+if [[ -n "$oldest_kept_ts" && "$oldest_kept_ts" != "*" ]]; then
+  printf '%s\n' delta-ts:*-seq:*-baseseq:*.bin |
+    awk -F '[:-]' -v oldest="$oldest_kept_ts" '$3 != "*" && $3 < oldest {print $0}' |
+    while IFS= read -r file_to_delete; do
+      if [[ -n "$file_to_delete" && -f "$file_to_delete" ]]; then
+        echo "[Remote] Deleting old remote delta: $file_to_delete"
+        rm -- "$file_to_delete" || echo "[Remote] Warning: Failed to delete remote delta file $file_to_delete"
+      fi
+    done
+fi
+
 # Go back to the original directory (optional, good practice)
 cd - > /dev/null
 
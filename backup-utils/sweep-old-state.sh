@@ -46,10 +46,11 @@ ssh "${SECONDARY_HOST}" "mkdir -p '${SECONDARY_BOARD_DIR}' '${SECONDARY_MOVES_DI
 
 # --- Transfer Files ---
 
-# 1. Transfer BOARD files (no source removal yet)
+# 1. Transfer BOARD files and delta snapshots (no source removal yet)
 echo "Transferring board files to ${SECONDARY_HOST}:${SECONDARY_BOARD_DIR}..."
 rsync ${RSYNC_OPTS} \
   --include='board-*.bin' \
+  --include='delta-*.bin' \
   --exclude='*' \
   "${MAIN_DATA_DIR}/" \
   "${SECONDARY_HOST}:${SECONDARY_BOARD_DIR}/"
@@ -59,6 +60,7 @@ echo "Transferring moves files to ${SECONDARY_HOST}:${SECONDARY_MOVES_DIR} (and
 rsync ${RSYNC_OPTS} \
   --remove-source-files \
   --include='moves-*.bin' \
+  --include='moves-*.bin.zst' \
   --exclude='*' \
   "${MAIN_DATA_DIR}/" \
   "${SECONDARY_HOST}:${SECONDARY_MOVES_DIR}/"
@@ -92,6 +94,20 @@ printf '%s\n' board-ts:*-seq:*.bin |
     fi
   done
 
+# Delta snapshots are only useful on top of a full board that we still have,
+# so delete any that are older than the oldest board we kept.
+oldest_kept_ts=$(printf '%s\n' board-ts:*-seq:*.bin | awk -F '[:-]' '{print $3}' | sort -n | head -n 1)
+if [[ -n "$oldest_kept_ts" && "$oldest_kept_ts" != "*" ]]; then
+  printf '%s\n' delta-ts:*-seq:*-baseseq:*.bin |
+    awk -F '[:-]' -v oldest="$oldest_kept_ts" '$3 != "*" && $3 < oldest {print $0}' |
+    while IFS= read -r file_to_delete; do
+      if [[ -n "$file_to_delete" && -f "$file_to_delete" ]]; then
+        echo "Deleting old local delta: $file_to_delete"
+        rm -- "$file_to_delete" || echo "Warning: Failed to delete local file $file_to_delete"
+      fi
+    done
+fi
+
 # Go back to the original directory (optional, good practice)
 cd - > /dev/null
 
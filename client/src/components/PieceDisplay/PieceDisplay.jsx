@@ -13,7 +13,7 @@ import {
   easeInOutSquare,
   computeAnimationDuration,
   getZoomedInScreenAbsoluteCoords,
-  TYPE_TO_NAME,
+  isPromotedPieceType,
 } from "../../utils";
 import { RESPECT_COLOR_REQUIREMENT } from "../../constants";
 
@@ -383,16 +383,14 @@ function PieceDisplay({ boardSizeParams, hidden, opacity }) {
             // move = { ...move, fromX: oldPiece.x, fromY: oldPiece.y };
             // oldPiece.x = move.piece.x;
             // oldPiece.y = move.piece.y;
-            if (TYPE_TO_NAME[movedPiece.type] === "promotedPawn") {
-              const alreadyPromoted =
-                TYPE_TO_NAME[oldPiece.type] === "promotedPawn";
+            if (isPromotedPieceType(movedPiece.type)) {
+              const alreadyPromoted = isPromotedPieceType(oldPiece.type);
               if (!alreadyPromoted) {
                 doUpdate = true;
               }
             }
-            if (TYPE_TO_NAME[oldPiece.type] === "promotedPawn") {
-              const notPromotedNow =
-                TYPE_TO_NAME[movedPiece.type] !== "promotedPawn";
+            if (isPromotedPieceType(oldPiece.type)) {
+              const notPromotedNow = !isPromotedPieceType(movedPiece.type);
               if (notPromotedNow) {
                 doUpdate = true;
               }
@@ -1,7 +1,7 @@
 import React from "react";
 import SelectedPieceAndSquaresContext from "../SelectedPieceAndSquaresContext";
 import {
-  TYPE_TO_NAME,
+  isPromotedPieceType,
   humanNameForPieceType,
   frameImageForPieceType,
 } from "../../utils";
@@ -238,7 +238,7 @@ function SelectedPieceDisplay() {
     if (!selectedPiece) {
       return null;
     }
-    if (TYPE_TO_NAME[selectedPiece.type] !== "promotedPawn") {
+    if (!isPromotedPieceType(selectedPiece.type)) {
       return null;
     }
     const idNoX = (selectedPiece.id - 1) % 32000;
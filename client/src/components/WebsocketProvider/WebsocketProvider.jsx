@@ -1,7 +1,11 @@
 import React from "react";
 import HandlersContext from "../HandlersContext/HandlersContext";
 import CurrentColorContext from "../CurrentColorProvider/CurrentColorProvider";
-import { computeInitialArguments, isZstd } from "../../utils";
+import {
+  computeInitialArguments,
+  isZstd,
+  storePlayerToken,
+} from "../../utils";
 import CoordsContext from "../CoordsContext/CoordsContext";
 import { decompress } from "fzstd";
 import { chess } from "../../protoCompiled.js";
@@ -91,6 +95,8 @@ function useWebsocket({
     let connecting = false;
     let pongTimeout = null;
     let killed = false;
+    // Lets us pick up where we left off if we reconnect
+    let sessionToken = null;
 
     function connect() {
       if (connecting) {
@@ -114,6 +120,14 @@ function useWebsocket({
       if (initialArgs.colorPref !== null) {
         args.push(`colorPref=${initialArgs.colorPref}`);
       }
+      if (initialArgs.playerToken !== null) {
+        args.push(`player=${encodeURIComponent(initialArgs.playerToken)}`);
+      }
+      const lastSeenSeqnum = pieceHandler.current.getLastSeenSeqnum();
+      if (sessionToken !== null && lastSeenSeqnum > 0) {
+        args.push(`session=${encodeURIComponent(sessionToken)}`);
+        args.push(`seqnum=${lastSeenSeqnum}`);
+      }
       if (args.length > 0) {
         wsUrl += `?${args.join("&")}`;
       }
@@ -145,10 +159,23 @@ function useWebsocket({
           const initialState = data.initialState;
           setCoords({ x: initialState.position.x, y: initialState.position.y });
           setCurrentColor({ playingWhite: initialState.playingWhite });
+          storePlayerToken({ playerToken: initialState.playerToken });
+          sessionToken = initialState.sessionToken || null;
           pieceHandler.current.handleSnapshot({
             snapshot: initialState.snapshot,
             newConnection: true,
           });
+        } else if (data.resume) {
+          // We reconnected recently enough that the server sent what we
+          // missed instead of a new snapshot
+          const resume = data.resume;
+          sessionToken = resume.sessionToken || null;
+          setCoords({ x: resume.position.x, y: resume.position.y });
+          setCurrentColor({ playingWhite: resume.playingWhite });
+          pieceHandler.current.handleMoves({
+            moves: resume.moves,
+            captures: resume.captures,
+          });
         } else if (data.snapshot) {
           pieceHandler.current.handleSnapshot({
             snapshot: data.snapshot,
@@ -183,6 +210,15 @@ function useWebsocket({
             capturedIds: bulkCapture.capturedIds,
             seqnum: bulkCapture.seqnum,
           });
+        } else if (data.setPieces) {
+          const setPieces = data.setPieces;
+          pieceHandler.current.handleSetPieces({
+            placed: setPieces.placed,
+            removedIds: setPieces.removedIds,
+            seqnum: setPieces.seqnum,
+          });
+        } else if (data.boardOutcome) {
+          // Not shown anywhere yet
         } else if (data.pong) {
         } else {
           console.debug("unknown message type", data);
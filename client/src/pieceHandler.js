@@ -488,6 +488,9 @@ class PieceHandler {
     this.piecesById = new Map();
     this.optimisticStateHandler = new OptimisticState();
     this.snapshotSeqnum = -1;
+    // The newest seqnum that we've heard about, so we can resume from it
+    // if our connection drops
+    this.lastSeenSeqnum = -1;
 
     this.moveToken = 1;
     this.subscribers = [];
@@ -1089,6 +1092,9 @@ class PieceHandler {
     this.activeCapturesByPieceId = newActiveCapturesByPieceId;
     this.piecesById = newPiecesById;
     this.snapshotSeqnum = Math.max(this.snapshotSeqnum, snapshot.seqnum);
+    this.lastSeenSeqnum = FORCE_KEEP_SNAPSHOT_VALUES
+      ? snapshot.seqnum
+      : Math.max(this.lastSeenSeqnum, snapshot.seqnum);
 
     const { processedAnimationsByPieceId } = this.processGroundTruthAnimations({
       animationsByPieceId,
@@ -1124,6 +1130,21 @@ class PieceHandler {
     this.handleMoves({ moves: [], captures });
   }
 
+  // Placed pieces are new, so they appear like any piece that moves into
+  // view; removed pieces go away like captures
+  handleSetPieces({ placed, removedIds, seqnum }) {
+    const placedIds = new Set(placed.map((p) => p.piece.id));
+    const captures = removedIds
+      .filter((id) => !placedIds.has(id))
+      .map((id) => {
+        return {
+          capturedPieceId: id,
+          seqnum,
+        };
+      });
+    this.handleMoves({ moves: placed, captures });
+  }
+
   handleMoves({ moves, captures }) {
     const receivedAt = performance.now();
     const animationsByPieceId = new Map();
@@ -1145,6 +1166,7 @@ class PieceHandler {
       piece.y = y;
       const currentPiece = this.piecesById.get(piece.id);
       moveSeqnumsForStatsHandler.push(seqnum);
+      this.lastSeenSeqnum = Math.max(this.lastSeenSeqnum, seqnum);
       if (this.activeCapturesByPieceId.has(piece.id)) {
         // nothing to do here; this could happen if we've already receive an ack
         // for our move but haven't received the actual capture update yet
@@ -1201,6 +1223,7 @@ class PieceHandler {
         });
       }
 
+      this.lastSeenSeqnum = Math.max(this.lastSeenSeqnum, capture.seqnum);
       if (capture.seqnum <= this.snapshotSeqnum) {
         // do nothing
       } else {
@@ -1233,6 +1256,10 @@ class PieceHandler {
     });
   }
 
+  getLastSeenSeqnum() {
+    return this.lastSeenSeqnum;
+  }
+
   getAllPieceIds() {
     return new Set(this.piecesById.keys());
   }
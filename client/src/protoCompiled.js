@@ -43,6 +43,12 @@ export const chess = $root.chess = (() => {
      * @property {number} PIECE_TYPE_QUEEN=4 PIECE_TYPE_QUEEN value
      * @property {number} PIECE_TYPE_KING=5 PIECE_TYPE_KING value
      * @property {number} PIECE_TYPE_PROMOTED_PAWN=6 PIECE_TYPE_PROMOTED_PAWN value
+     * @property {number} PIECE_TYPE_PROMOTED_KNIGHT=7 PIECE_TYPE_PROMOTED_KNIGHT value
+     * @property {number} PIECE_TYPE_PROMOTED_BISHOP=8 PIECE_TYPE_PROMOTED_BISHOP value
+     * @property {number} PIECE_TYPE_PROMOTED_ROOK=9 PIECE_TYPE_PROMOTED_ROOK value
+     * @property {number} PIECE_TYPE_ARCHBISHOP=10 PIECE_TYPE_ARCHBISHOP value
+     * @property {number} PIECE_TYPE_CHANCELLOR=11 PIECE_TYPE_CHANCELLOR value
+     * @property {number} PIECE_TYPE_AMAZON=12 PIECE_TYPE_AMAZON value
      */
     chess.PieceType = (function() {
         const valuesById = {}, values = Object.create(valuesById);
@@ -53,6 +59,28 @@ export const chess = $root.chess = (() => {
         values[valuesById[4] = "PIECE_TYPE_QUEEN"] = 4;
         values[valuesById[5] = "PIECE_TYPE_KING"] = 5;
         values[valuesById[6] = "PIECE_TYPE_PROMOTED_PAWN"] = 6;
+        values[valuesById[7] = "PIECE_TYPE_PROMOTED_KNIGHT"] = 7;
+        values[valuesById[8] = "PIECE_TYPE_PROMOTED_BISHOP"] = 8;
+        values[valuesById[9] = "PIECE_TYPE_PROMOTED_ROOK"] = 9;
+        values[valuesById[10] = "PIECE_TYPE_ARCHBISHOP"] = 10;
+        values[valuesById[11] = "PIECE_TYPE_CHANCELLOR"] = 11;
+        values[valuesById[12] = "PIECE_TYPE_AMAZON"] = 12;
+        return values;
+    })();
+
+    /**
+     * BoardOutcome enum.
+     * @name chess.BoardOutcome
+     * @enum {number}
+     * @property {number} BOARD_OUTCOME_NONE=0 BOARD_OUTCOME_NONE value
+     * @property {number} BOARD_OUTCOME_CHECKMATE=1 BOARD_OUTCOME_CHECKMATE value
+     * @property {number} BOARD_OUTCOME_STALEMATE=2 BOARD_OUTCOME_STALEMATE value
+     */
+    chess.BoardOutcome = (function() {
+        const valuesById = {}, values = Object.create(valuesById);
+        values[valuesById[0] = "BOARD_OUTCOME_NONE"] = 0;
+        values[valuesById[1] = "BOARD_OUTCOME_CHECKMATE"] = 1;
+        values[valuesById[2] = "BOARD_OUTCOME_STALEMATE"] = 2;
         return values;
     })();
 
@@ -233,6 +261,183 @@ export const chess = $root.chess = (() => {
         return ClientPing;
     })();
 
+    chess.ClientGetPlayerStats = (function() {
+
+        /**
+         * Properties of a ClientGetPlayerStats.
+         * @memberof chess
+         * @interface IClientGetPlayerStats
+         */
+
+        /**
+         * Constructs a new ClientGetPlayerStats.
+         * @memberof chess
+         * @classdesc Represents a ClientGetPlayerStats.
+         * @implements IClientGetPlayerStats
+         * @constructor
+         * @param {chess.IClientGetPlayerStats=} [properties] Properties to set
+         */
+        function ClientGetPlayerStats(properties) {
+            if (properties)
+                for (let keys = Object.keys(properties), i = 0; i < keys.length; ++i)
+                    if (properties[keys[i]] != null)
+                        this[keys[i]] = properties[keys[i]];
+        }
+
+        /**
+         * Creates a new ClientGetPlayerStats instance using the specified properties.
+         * @function create
+         * @memberof chess.ClientGetPlayerStats
+         * @static
+         * @param {chess.IClientGetPlayerStats=} [properties] Properties to set
+         * @returns {chess.ClientGetPlayerStats} ClientGetPlayerStats instance
+         */
+        ClientGetPlayerStats.create = function create(properties) {
+            return new ClientGetPlayerStats(properties);
+        };
+
+        /**
+         * Encodes the specified ClientGetPlayerStats message. Does not implicitly {@link chess.ClientGetPlayerStats.verify|verify} messages.
+         * @function encode
+         * @memberof chess.ClientGetPlayerStats
+         * @static
+         * @param {chess.IClientGetPlayerStats} message ClientGetPlayerStats message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        ClientGetPlayerStats.encode = function encode(message, writer) {
+            if (!writer)
+                writer = $Writer.create();
+            return writer;
+        };
+
+        /**
+         * Encodes the specified ClientGetPlayerStats message, length delimited. Does not implicitly {@link chess.ClientGetPlayerStats.verify|verify} messages.
+         * @function encodeDelimited
+         * @memberof chess.ClientGetPlayerStats
+         * @static
+         * @param {chess.IClientGetPlayerStats} message ClientGetPlayerStats message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        ClientGetPlayerStats.encodeDelimited = function encodeDelimited(message, writer) {
+            return this.encode(message, writer).ldelim();
+        };
+
+        /**
+         * Decodes a ClientGetPlayerStats message from the specified reader or buffer.
+         * @function decode
+         * @memberof chess.ClientGetPlayerStats
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @param {number} [length] Message length if known beforehand
+         * @returns {chess.ClientGetPlayerStats} ClientGetPlayerStats
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        ClientGetPlayerStats.decode = function decode(reader, length, error) {
+            if (!(reader instanceof $Reader))
+                reader = $Reader.create(reader);
+            let end = length === undefined ? reader.len : reader.pos + length, message = new $root.chess.ClientGetPlayerStats();
+            while (reader.pos < end) {
+                let tag = reader.uint32();
+                if (tag === error)
+                    break;
+                switch (tag >>> 3) {
+                default:
+                    reader.skipType(tag & 7);
+                    break;
+                }
+            }
+            return message;
+        };
+
+        /**
+         * Decodes a ClientGetPlayerStats message from the specified reader or buffer, length delimited.
+         * @function decodeDelimited
+         * @memberof chess.ClientGetPlayerStats
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @returns {chess.ClientGetPlayerStats} ClientGetPlayerStats
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        ClientGetPlayerStats.decodeDelimited = function decodeDelimited(reader) {
+            if (!(reader instanceof $Reader))
+                reader = new $Reader(reader);
+            return this.decode(reader, reader.uint32());
+        };
+
+        /**
+         * Verifies a ClientGetPlayerStats message.
+         * @function verify
+         * @memberof chess.ClientGetPlayerStats
+         * @static
+         * @param {Object.<string,*>} message Plain object to verify
+         * @returns {string|null} `null` if valid, otherwise the reason why it is not
+         */
+        ClientGetPlayerStats.verify = function verify(message) {
+            if (typeof message !== "object" || message === null)
+                return "object expected";
+            return null;
+        };
+
+        /**
+         * Creates a ClientGetPlayerStats message from a plain object. Also converts values to their respective internal types.
+         * @function fromObject
+         * @memberof chess.ClientGetPlayerStats
+         * @static
+         * @param {Object.<string,*>} object Plain object
+         * @returns {chess.ClientGetPlayerStats} ClientGetPlayerStats
+         */
+        ClientGetPlayerStats.fromObject = function fromObject(object) {
+            if (object instanceof $root.chess.ClientGetPlayerStats)
+                return object;
+            return new $root.chess.ClientGetPlayerStats();
+        };
+
+        /**
+         * Creates a plain object from a ClientGetPlayerStats message. Also converts values to other types if specified.
+         * @function toObject
+         * @memberof chess.ClientGetPlayerStats
+         * @static
+         * @param {chess.ClientGetPlayerStats} message ClientGetPlayerStats
+         * @param {$protobuf.IConversionOptions} [options] Conversion options
+         * @returns {Object.<string,*>} Plain object
+         */
+        ClientGetPlayerStats.toObject = function toObject() {
+            return {};
+        };
+
+        /**
+         * Converts this ClientGetPlayerStats to JSON.
+         * @function toJSON
+         * @memberof chess.ClientGetPlayerStats
+         * @instance
+         * @returns {Object.<string,*>} JSON object
+         */
+        ClientGetPlayerStats.prototype.toJSON = function toJSON() {
+            return this.constructor.toObject(this, $protobuf.util.toJSONOptions);
+        };
+
+        /**
+         * Gets the default type url for ClientGetPlayerStats
+         * @function getTypeUrl
+         * @memberof chess.ClientGetPlayerStats
+         * @static
+         * @param {string} [typeUrlPrefix] your custom typeUrlPrefix(default "type.googleapis.com")
+         * @returns {string} The default type url
+         */
+        ClientGetPlayerStats.getTypeUrl = function getTypeUrl(typeUrlPrefix) {
+            if (typeUrlPrefix === undefined) {
+                typeUrlPrefix = "type.googleapis.com";
+            }
+            return typeUrlPrefix + "/chess.ClientGetPlayerStats";
+        };
+
+        return ClientGetPlayerStats;
+    })();
+
     chess.ClientSubscribe = (function() {
 
         /**
@@ -475,6 +680,7 @@ export const chess = $root.chess = (() => {
          * @property {number|null} [toY] ClientMove toY
          * @property {chess.MoveType|null} [moveType] ClientMove moveType
          * @property {number|null} [moveToken] ClientMove moveToken
+         * @property {chess.PieceType|null} [promotion] ClientMove promotion
          */
 
         /**
@@ -548,6 +754,14 @@ export const chess = $root.chess = (() => {
          */
         ClientMove.prototype.moveToken = 0;
 
+        /**
+         * ClientMove promotion.
+         * @member {chess.PieceType} promotion
+         * @memberof chess.ClientMove
+         * @instance
+         */
+        ClientMove.prototype.promotion = 0;
+
         /**
          * Creates a new ClientMove instance using the specified properties.
          * @function create
@@ -586,6 +800,8 @@ export const chess = $root.chess = (() => {
                 writer.uint32(/* id 6, wireType 0 =*/48).int32(message.moveType);
             if (message.moveToken != null && Object.hasOwnProperty.call(message, "moveToken"))
                 writer.uint32(/* id 7, wireType 0 =*/56).uint32(message.moveToken);
+            if (message.promotion != null && Object.hasOwnProperty.call(message, "promotion"))
+                writer.uint32(/* id 8, wireType 0 =*/64).int32(message.promotion);
             return writer;
         };
 
@@ -650,6 +866,10 @@ export const chess = $root.chess = (() => {
                         message.moveToken = reader.uint32();
                         break;
                     }
+                case 8: {
+                        message.promotion = reader.int32();
+                        break;
+                    }
                 default:
                     reader.skipType(tag & 7);
                     break;
@@ -712,6 +932,25 @@ export const chess = $root.chess = (() => {
             if (message.moveToken != null && message.hasOwnProperty("moveToken"))
                 if (!$util.isInteger(message.moveToken))
                     return "moveToken: integer expected";
+            if (message.promotion != null && message.hasOwnProperty("promotion"))
+                switch (message.promotion) {
+                default:
+                    return "promotion: enum value expected";
+                case 0:
+                case 1:
+                case 2:
+                case 3:
+                case 4:
+                case 5:
+                case 6:
+                case 7:
+                case 8:
+                case 9:
+                case 10:
+                case 11:
+                case 12:
+                    break;
+                }
             return null;
         };
 
@@ -759,6 +998,66 @@ export const chess = $root.chess = (() => {
             }
             if (object.moveToken != null)
                 message.moveToken = object.moveToken >>> 0;
+            switch (object.promotion) {
+            default:
+                if (typeof object.promotion === "number") {
+                    message.promotion = object.promotion;
+                    break;
+                }
+                break;
+            case "PIECE_TYPE_PAWN":
+            case 0:
+                message.promotion = 0;
+                break;
+            case "PIECE_TYPE_KNIGHT":
+            case 1:
+                message.promotion = 1;
+                break;
+            case "PIECE_TYPE_BISHOP":
+            case 2:
+                message.promotion = 2;
+                break;
+            case "PIECE_TYPE_ROOK":
+            case 3:
+                message.promotion = 3;
+                break;
+            case "PIECE_TYPE_QUEEN":
+            case 4:
+                message.promotion = 4;
+                break;
+            case "PIECE_TYPE_KING":
+            case 5:
+                message.promotion = 5;
+                break;
+            case "PIECE_TYPE_PROMOTED_PAWN":
+            case 6:
+                message.promotion = 6;
+                break;
+            case "PIECE_TYPE_PROMOTED_KNIGHT":
+            case 7:
+                message.promotion = 7;
+                break;
+            case "PIECE_TYPE_PROMOTED_BISHOP":
+            case 8:
+                message.promotion = 8;
+                break;
+            case "PIECE_TYPE_PROMOTED_ROOK":
+            case 9:
+                message.promotion = 9;
+                break;
+            case "PIECE_TYPE_ARCHBISHOP":
+            case 10:
+                message.promotion = 10;
+                break;
+            case "PIECE_TYPE_CHANCELLOR":
+            case 11:
+                message.promotion = 11;
+                break;
+            case "PIECE_TYPE_AMAZON":
+            case 12:
+                message.promotion = 12;
+                break;
+            }
             return message;
         };
 
@@ -783,6 +1082,7 @@ export const chess = $root.chess = (() => {
                 object.toY = 0;
                 object.moveType = options.enums === String ? "MOVE_TYPE_NORMAL" : 0;
                 object.moveToken = 0;
+                object.promotion = options.enums === String ? "PIECE_TYPE_PAWN" : 0;
             }
             if (message.pieceId != null && message.hasOwnProperty("pieceId"))
                 object.pieceId = message.pieceId;
@@ -798,6 +1098,8 @@ export const chess = $root.chess = (() => {
                 object.moveType = options.enums === String ? $root.chess.MoveType[message.moveType] === undefined ? message.moveType : $root.chess.MoveType[message.moveType] : message.moveType;
             if (message.moveToken != null && message.hasOwnProperty("moveToken"))
                 object.moveToken = message.moveToken;
+            if (message.promotion != null && message.hasOwnProperty("promotion"))
+                object.promotion = options.enums === String ? $root.chess.PieceType[message.promotion] === undefined ? message.promotion : $root.chess.PieceType[message.promotion] : message.promotion;
             return object;
         };
 
@@ -839,6 +1141,7 @@ export const chess = $root.chess = (() => {
          * @property {chess.IClientPing|null} [ping] ClientMessage ping
          * @property {chess.IClientSubscribe|null} [subscribe] ClientMessage subscribe
          * @property {chess.IClientMove|null} [move] ClientMessage move
+         * @property {chess.IClientGetPlayerStats|null} [getPlayerStats] ClientMessage getPlayerStats
          */
 
         /**
@@ -880,17 +1183,25 @@ export const chess = $root.chess = (() => {
          */
         ClientMessage.prototype.move = null;
 
+        /**
+         * ClientMessage getPlayerStats.
+         * @member {chess.IClientGetPlayerStats|null|undefined} getPlayerStats
+         * @memberof chess.ClientMessage
+         * @instance
+         */
+        ClientMessage.prototype.getPlayerStats = null;
+
         // OneOf field names bound to virtual getters and setters
         let $oneOfFields;
 
         /**
          * ClientMessage payload.
-         * @member {"ping"|"subscribe"|"move"|undefined} payload
+         * @member {"ping"|"subscribe"|"move"|"getPlayerStats"|undefined} payload
          * @memberof chess.ClientMessage
          * @instance
          */
         Object.defineProperty(ClientMessage.prototype, "payload", {
-            get: $util.oneOfGetter($oneOfFields = ["ping", "subscribe", "move"]),
+            get: $util.oneOfGetter($oneOfFields = ["ping", "subscribe", "move", "getPlayerStats"]),
             set: $util.oneOfSetter($oneOfFields)
         });
 
@@ -924,6 +1235,8 @@ export const chess = $root.chess = (() => {
                 $root.chess.ClientSubscribe.encode(message.subscribe, writer.uint32(/* id 2, wireType 2 =*/18).fork()).ldelim();
             if (message.move != null && Object.hasOwnProperty.call(message, "move"))
                 $root.chess.ClientMove.encode(message.move, writer.uint32(/* id 3, wireType 2 =*/26).fork()).ldelim();
+            if (message.getPlayerStats != null && Object.hasOwnProperty.call(message, "getPlayerStats"))
+                $root.chess.ClientGetPlayerStats.encode(message.getPlayerStats, writer.uint32(/* id 4, wireType 2 =*/34).fork()).ldelim();
             return writer;
         };
 
@@ -972,6 +1285,10 @@ export const chess = $root.chess = (() => {
                         message.move = $root.chess.ClientMove.decode(reader, reader.uint32());
                         break;
                     }
+                case 4: {
+                        message.getPlayerStats = $root.chess.ClientGetPlayerStats.decode(reader, reader.uint32());
+                        break;
+                    }
                 default:
                     reader.skipType(tag & 7);
                     break;
@@ -1036,6 +1353,16 @@ export const chess = $root.chess = (() => {
                         return "move." + error;
                 }
             }
+            if (message.getPlayerStats != null && message.hasOwnProperty("getPlayerStats")) {
+                if (properties.payload === 1)
+                    return "payload: multiple values";
+                properties.payload = 1;
+                {
+                    let error = $root.chess.ClientGetPlayerStats.verify(message.getPlayerStats);
+                    if (error)
+                        return "getPlayerStats." + error;
+                }
+            }
             return null;
         };
 
@@ -1066,6 +1393,11 @@ export const chess = $root.chess = (() => {
                     throw TypeError(".chess.ClientMessage.move: object expected");
                 message.move = $root.chess.ClientMove.fromObject(object.move);
             }
+            if (object.getPlayerStats != null) {
+                if (typeof object.getPlayerStats !== "object")
+                    throw TypeError(".chess.ClientMessage.getPlayerStats: object expected");
+                message.getPlayerStats = $root.chess.ClientGetPlayerStats.fromObject(object.getPlayerStats);
+            }
             return message;
         };
 
@@ -1097,6 +1429,11 @@ export const chess = $root.chess = (() => {
                 if (options.oneofs)
                     object.payload = "move";
             }
+            if (message.getPlayerStats != null && message.hasOwnProperty("getPlayerStats")) {
+                object.getPlayerStats = $root.chess.ClientGetPlayerStats.toObject(message.getPlayerStats, options);
+                if (options.oneofs)
+                    object.payload = "getPlayerStats";
+            }
             return object;
         };
 
@@ -2347,6 +2684,12 @@ export const chess = $root.chess = (() => {
                 case 4:
                 case 5:
                 case 6:
+                case 7:
+                case 8:
+                case 9:
+                case 10:
+                case 11:
+                case 12:
                     break;
                 }
             if (message.isWhite != null && message.hasOwnProperty("isWhite"))
@@ -2434,6 +2777,30 @@ export const chess = $root.chess = (() => {
             case 6:
                 message.type = 6;
                 break;
+            case "PIECE_TYPE_PROMOTED_KNIGHT":
+            case 7:
+                message.type = 7;
+                break;
+            case "PIECE_TYPE_PROMOTED_BISHOP":
+            case 8:
+                message.type = 8;
+                break;
+            case "PIECE_TYPE_PROMOTED_ROOK":
+            case 9:
+                message.type = 9;
+                break;
+            case "PIECE_TYPE_ARCHBISHOP":
+            case 10:
+                message.type = 10;
+                break;
+            case "PIECE_TYPE_CHANCELLOR":
+            case 11:
+                message.type = 11;
+                break;
+            case "PIECE_TYPE_AMAZON":
+            case 12:
+                message.type = 12;
+                break;
             }
             if (object.isWhite != null)
                 message.isWhite = Boolean(object.isWhite);
@@ -3917,6 +4284,8 @@ export const chess = $root.chess = (() => {
          * @property {boolean|null} [playingWhite] ServerInitialState playingWhite
          * @property {chess.IPosition|null} [position] ServerInitialState position
          * @property {chess.IServerStateSnapshot|null} [snapshot] ServerInitialState snapshot
+         * @property {string|null} [sessionToken] ServerInitialState sessionToken
+         * @property {string|null} [playerToken] ServerInitialState playerToken
          */
 
         /**
@@ -3958,6 +4327,22 @@ export const chess = $root.chess = (() => {
          */
         ServerInitialState.prototype.snapshot = null;
 
+        /**
+         * ServerInitialState sessionToken.
+         * @member {string} sessionToken
+         * @memberof chess.ServerInitialState
+         * @instance
+         */
+        ServerInitialState.prototype.sessionToken = "";
+
+        /**
+         * ServerInitialState playerToken.
+         * @member {string} playerToken
+         * @memberof chess.ServerInitialState
+         * @instance
+         */
+        ServerInitialState.prototype.playerToken = "";
+
         /**
          * Creates a new ServerInitialState instance using the specified properties.
          * @function create
@@ -3988,6 +4373,10 @@ export const chess = $root.chess = (() => {
                 $root.chess.Position.encode(message.position, writer.uint32(/* id 2, wireType 2 =*/18).fork()).ldelim();
             if (message.snapshot != null && Object.hasOwnProperty.call(message, "snapshot"))
                 $root.chess.ServerStateSnapshot.encode(message.snapshot, writer.uint32(/* id 3, wireType 2 =*/26).fork()).ldelim();
+            if (message.sessionToken != null && Object.hasOwnProperty.call(message, "sessionToken"))
+                writer.uint32(/* id 4, wireType 2 =*/34).string(message.sessionToken);
+            if (message.playerToken != null && Object.hasOwnProperty.call(message, "playerToken"))
+                writer.uint32(/* id 5, wireType 2 =*/42).string(message.playerToken);
             return writer;
         };
 
@@ -4036,6 +4425,14 @@ export const chess = $root.chess = (() => {
                         message.snapshot = $root.chess.ServerStateSnapshot.decode(reader, reader.uint32());
                         break;
                     }
+                case 4: {
+                        message.sessionToken = reader.string();
+                        break;
+                    }
+                case 5: {
+                        message.playerToken = reader.string();
+                        break;
+                    }
                 default:
                     reader.skipType(tag & 7);
                     break;
@@ -4084,6 +4481,12 @@ export const chess = $root.chess = (() => {
                 if (error)
                     return "snapshot." + error;
             }
+            if (message.sessionToken != null && message.hasOwnProperty("sessionToken"))
+                if (!$util.isString(message.sessionToken))
+                    return "sessionToken: string expected";
+            if (message.playerToken != null && message.hasOwnProperty("playerToken"))
+                if (!$util.isString(message.playerToken))
+                    return "playerToken: string expected";
             return null;
         };
 
@@ -4111,6 +4514,10 @@ export const chess = $root.chess = (() => {
                     throw TypeError(".chess.ServerInitialState.snapshot: object expected");
                 message.snapshot = $root.chess.ServerStateSnapshot.fromObject(object.snapshot);
             }
+            if (object.sessionToken != null)
+                message.sessionToken = String(object.sessionToken);
+            if (object.playerToken != null)
+                message.playerToken = String(object.playerToken);
             return message;
         };
 
@@ -4131,6 +4538,8 @@ export const chess = $root.chess = (() => {
                 object.playingWhite = false;
                 object.position = null;
                 object.snapshot = null;
+                object.sessionToken = "";
+                object.playerToken = "";
             }
             if (message.playingWhite != null && message.hasOwnProperty("playingWhite"))
                 object.playingWhite = message.playingWhite;
@@ -4138,6 +4547,10 @@ export const chess = $root.chess = (() => {
                 object.position = $root.chess.Position.toObject(message.position, options);
             if (message.snapshot != null && message.hasOwnProperty("snapshot"))
                 object.snapshot = $root.chess.ServerStateSnapshot.toObject(message.snapshot, options);
+            if (message.sessionToken != null && message.hasOwnProperty("sessionToken"))
+                object.sessionToken = message.sessionToken;
+            if (message.playerToken != null && message.hasOwnProperty("playerToken"))
+                object.playerToken = message.playerToken;
             return object;
         };
 
@@ -4170,25 +4583,30 @@ export const chess = $root.chess = (() => {
         return ServerInitialState;
     })();
 
-    chess.ServerAdoption = (function() {
+    chess.ServerResume = (function() {
 
         /**
-         * Properties of a ServerAdoption.
+         * Properties of a ServerResume.
          * @memberof chess
-         * @interface IServerAdoption
-         * @property {Array.<number>|null} [adoptedIds] ServerAdoption adoptedIds
+         * @interface IServerResume
+         * @property {boolean|null} [playingWhite] ServerResume playingWhite
+         * @property {chess.IPosition|null} [position] ServerResume position
+         * @property {string|null} [sessionToken] ServerResume sessionToken
+         * @property {Array.<chess.IPieceDataForMove>|null} [moves] ServerResume moves
+         * @property {Array.<chess.IPieceCapture>|null} [captures] ServerResume captures
          */
 
         /**
-         * Constructs a new ServerAdoption.
+         * Constructs a new ServerResume.
          * @memberof chess
-         * @classdesc Represents a ServerAdoption.
-         * @implements IServerAdoption
+         * @classdesc Represents a ServerResume.
+         * @implements IServerResume
          * @constructor
-         * @param {chess.IServerAdoption=} [properties] Properties to set
+         * @param {chess.IServerResume=} [properties] Properties to set
          */
-        function ServerAdoption(properties) {
-            this.adoptedIds = [];
+        function ServerResume(properties) {
+            this.moves = [];
+            this.captures = [];
             if (properties)
                 for (let keys = Object.keys(properties), i = 0; i < keys.length; ++i)
                     if (properties[keys[i]] != null)
@@ -4196,88 +4614,139 @@ export const chess = $root.chess = (() => {
         }
 
         /**
-         * ServerAdoption adoptedIds.
-         * @member {Array.<number>} adoptedIds
-         * @memberof chess.ServerAdoption
+         * ServerResume playingWhite.
+         * @member {boolean} playingWhite
+         * @memberof chess.ServerResume
          * @instance
          */
-        ServerAdoption.prototype.adoptedIds = $util.emptyArray;
+        ServerResume.prototype.playingWhite = false;
 
         /**
-         * Creates a new ServerAdoption instance using the specified properties.
-         * @function create
-         * @memberof chess.ServerAdoption
-         * @static
-         * @param {chess.IServerAdoption=} [properties] Properties to set
-         * @returns {chess.ServerAdoption} ServerAdoption instance
+         * ServerResume position.
+         * @member {chess.IPosition|null|undefined} position
+         * @memberof chess.ServerResume
+         * @instance
          */
-        ServerAdoption.create = function create(properties) {
-            return new ServerAdoption(properties);
-        };
+        ServerResume.prototype.position = null;
 
         /**
-         * Encodes the specified ServerAdoption message. Does not implicitly {@link chess.ServerAdoption.verify|verify} messages.
-         * @function encode
-         * @memberof chess.ServerAdoption
-         * @static
-         * @param {chess.IServerAdoption} message ServerAdoption message or plain object to encode
-         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * ServerResume sessionToken.
+         * @member {string} sessionToken
+         * @memberof chess.ServerResume
+         * @instance
+         */
+        ServerResume.prototype.sessionToken = "";
+
+        /**
+         * ServerResume moves.
+         * @member {Array.<chess.IPieceDataForMove>} moves
+         * @memberof chess.ServerResume
+         * @instance
+         */
+        ServerResume.prototype.moves = $util.emptyArray;
+
+        /**
+         * ServerResume captures.
+         * @member {Array.<chess.IPieceCapture>} captures
+         * @memberof chess.ServerResume
+         * @instance
+         */
+        ServerResume.prototype.captures = $util.emptyArray;
+
+        /**
+         * Creates a new ServerResume instance using the specified properties.
+         * @function create
+         * @memberof chess.ServerResume
+         * @static
+         * @param {chess.IServerResume=} [properties] Properties to set
+         * @returns {chess.ServerResume} ServerResume instance
+         */
+        ServerResume.create = function create(properties) {
+            return new ServerResume(properties);
+        };
+
+        /**
+         * Encodes the specified ServerResume message. Does not implicitly {@link chess.ServerResume.verify|verify} messages.
+         * @function encode
+         * @memberof chess.ServerResume
+         * @static
+         * @param {chess.IServerResume} message ServerResume message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
          * @returns {$protobuf.Writer} Writer
          */
-        ServerAdoption.encode = function encode(message, writer) {
+        ServerResume.encode = function encode(message, writer) {
             if (!writer)
                 writer = $Writer.create();
-            if (message.adoptedIds != null && message.adoptedIds.length) {
-                writer.uint32(/* id 1, wireType 2 =*/10).fork();
-                for (let i = 0; i < message.adoptedIds.length; ++i)
-                    writer.uint32(message.adoptedIds[i]);
-                writer.ldelim();
-            }
+            if (message.playingWhite != null && Object.hasOwnProperty.call(message, "playingWhite"))
+                writer.uint32(/* id 1, wireType 0 =*/8).bool(message.playingWhite);
+            if (message.position != null && Object.hasOwnProperty.call(message, "position"))
+                $root.chess.Position.encode(message.position, writer.uint32(/* id 2, wireType 2 =*/18).fork()).ldelim();
+            if (message.sessionToken != null && Object.hasOwnProperty.call(message, "sessionToken"))
+                writer.uint32(/* id 3, wireType 2 =*/26).string(message.sessionToken);
+            if (message.moves != null && message.moves.length)
+                for (let i = 0; i < message.moves.length; ++i)
+                    $root.chess.PieceDataForMove.encode(message.moves[i], writer.uint32(/* id 4, wireType 2 =*/34).fork()).ldelim();
+            if (message.captures != null && message.captures.length)
+                for (let i = 0; i < message.captures.length; ++i)
+                    $root.chess.PieceCapture.encode(message.captures[i], writer.uint32(/* id 5, wireType 2 =*/42).fork()).ldelim();
             return writer;
         };
 
         /**
-         * Encodes the specified ServerAdoption message, length delimited. Does not implicitly {@link chess.ServerAdoption.verify|verify} messages.
+         * Encodes the specified ServerResume message, length delimited. Does not implicitly {@link chess.ServerResume.verify|verify} messages.
          * @function encodeDelimited
-         * @memberof chess.ServerAdoption
+         * @memberof chess.ServerResume
          * @static
-         * @param {chess.IServerAdoption} message ServerAdoption message or plain object to encode
+         * @param {chess.IServerResume} message ServerResume message or plain object to encode
          * @param {$protobuf.Writer} [writer] Writer to encode to
          * @returns {$protobuf.Writer} Writer
          */
-        ServerAdoption.encodeDelimited = function encodeDelimited(message, writer) {
+        ServerResume.encodeDelimited = function encodeDelimited(message, writer) {
             return this.encode(message, writer).ldelim();
         };
 
         /**
-         * Decodes a ServerAdoption message from the specified reader or buffer.
+         * Decodes a ServerResume message from the specified reader or buffer.
          * @function decode
-         * @memberof chess.ServerAdoption
+         * @memberof chess.ServerResume
          * @static
          * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
          * @param {number} [length] Message length if known beforehand
-         * @returns {chess.ServerAdoption} ServerAdoption
+         * @returns {chess.ServerResume} ServerResume
          * @throws {Error} If the payload is not a reader or valid buffer
          * @throws {$protobuf.util.ProtocolError} If required fields are missing
          */
-        ServerAdoption.decode = function decode(reader, length, error) {
+        ServerResume.decode = function decode(reader, length, error) {
             if (!(reader instanceof $Reader))
                 reader = $Reader.create(reader);
-            let end = length === undefined ? reader.len : reader.pos + length, message = new $root.chess.ServerAdoption();
+            let end = length === undefined ? reader.len : reader.pos + length, message = new $root.chess.ServerResume();
             while (reader.pos < end) {
                 let tag = reader.uint32();
                 if (tag === error)
                     break;
                 switch (tag >>> 3) {
                 case 1: {
-                        if (!(message.adoptedIds && message.adoptedIds.length))
-                            message.adoptedIds = [];
-                        if ((tag & 7) === 2) {
-                            let end2 = reader.uint32() + reader.pos;
-                            while (reader.pos < end2)
-                                message.adoptedIds.push(reader.uint32());
-                        } else
-                            message.adoptedIds.push(reader.uint32());
+                        message.playingWhite = reader.bool();
+                        break;
+                    }
+                case 2: {
+                        message.position = $root.chess.Position.decode(reader, reader.uint32());
+                        break;
+                    }
+                case 3: {
+                        message.sessionToken = reader.string();
+                        break;
+                    }
+                case 4: {
+                        if (!(message.moves && message.moves.length))
+                            message.moves = [];
+                        message.moves.push($root.chess.PieceDataForMove.decode(reader, reader.uint32()));
+                        break;
+                    }
+                case 5: {
+                        if (!(message.captures && message.captures.length))
+                            message.captures = [];
+                        message.captures.push($root.chess.PieceCapture.decode(reader, reader.uint32()));
                         break;
                     }
                 default:
@@ -4289,136 +4758,1672 @@ export const chess = $root.chess = (() => {
         };
 
         /**
-         * Decodes a ServerAdoption message from the specified reader or buffer, length delimited.
+         * Decodes a ServerResume message from the specified reader or buffer, length delimited.
          * @function decodeDelimited
-         * @memberof chess.ServerAdoption
+         * @memberof chess.ServerResume
          * @static
          * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
-         * @returns {chess.ServerAdoption} ServerAdoption
+         * @returns {chess.ServerResume} ServerResume
          * @throws {Error} If the payload is not a reader or valid buffer
          * @throws {$protobuf.util.ProtocolError} If required fields are missing
          */
-        ServerAdoption.decodeDelimited = function decodeDelimited(reader) {
+        ServerResume.decodeDelimited = function decodeDelimited(reader) {
             if (!(reader instanceof $Reader))
                 reader = new $Reader(reader);
             return this.decode(reader, reader.uint32());
         };
 
         /**
-         * Verifies a ServerAdoption message.
+         * Verifies a ServerResume message.
          * @function verify
-         * @memberof chess.ServerAdoption
+         * @memberof chess.ServerResume
          * @static
          * @param {Object.<string,*>} message Plain object to verify
          * @returns {string|null} `null` if valid, otherwise the reason why it is not
          */
-        ServerAdoption.verify = function verify(message) {
+        ServerResume.verify = function verify(message) {
             if (typeof message !== "object" || message === null)
                 return "object expected";
-            if (message.adoptedIds != null && message.hasOwnProperty("adoptedIds")) {
-                if (!Array.isArray(message.adoptedIds))
-                    return "adoptedIds: array expected";
-                for (let i = 0; i < message.adoptedIds.length; ++i)
-                    if (!$util.isInteger(message.adoptedIds[i]))
-                        return "adoptedIds: integer[] expected";
+            if (message.playingWhite != null && message.hasOwnProperty("playingWhite"))
+                if (typeof message.playingWhite !== "boolean")
+                    return "playingWhite: boolean expected";
+            if (message.position != null && message.hasOwnProperty("position")) {
+                let error = $root.chess.Position.verify(message.position);
+                if (error)
+                    return "position." + error;
+            }
+            if (message.sessionToken != null && message.hasOwnProperty("sessionToken"))
+                if (!$util.isString(message.sessionToken))
+                    return "sessionToken: string expected";
+            if (message.moves != null && message.hasOwnProperty("moves")) {
+                if (!Array.isArray(message.moves))
+                    return "moves: array expected";
+                for (let i = 0; i < message.moves.length; ++i) {
+                    let error = $root.chess.PieceDataForMove.verify(message.moves[i]);
+                    if (error)
+                        return "moves." + error;
+                }
+            }
+            if (message.captures != null && message.hasOwnProperty("captures")) {
+                if (!Array.isArray(message.captures))
+                    return "captures: array expected";
+                for (let i = 0; i < message.captures.length; ++i) {
+                    let error = $root.chess.PieceCapture.verify(message.captures[i]);
+                    if (error)
+                        return "captures." + error;
+                }
             }
             return null;
         };
 
         /**
-         * Creates a ServerAdoption message from a plain object. Also converts values to their respective internal types.
+         * Creates a ServerResume message from a plain object. Also converts values to their respective internal types.
          * @function fromObject
-         * @memberof chess.ServerAdoption
+         * @memberof chess.ServerResume
          * @static
          * @param {Object.<string,*>} object Plain object
-         * @returns {chess.ServerAdoption} ServerAdoption
+         * @returns {chess.ServerResume} ServerResume
          */
-        ServerAdoption.fromObject = function fromObject(object) {
-            if (object instanceof $root.chess.ServerAdoption)
+        ServerResume.fromObject = function fromObject(object) {
+            if (object instanceof $root.chess.ServerResume)
                 return object;
-            let message = new $root.chess.ServerAdoption();
-            if (object.adoptedIds) {
-                if (!Array.isArray(object.adoptedIds))
-                    throw TypeError(".chess.ServerAdoption.adoptedIds: array expected");
-                message.adoptedIds = [];
-                for (let i = 0; i < object.adoptedIds.length; ++i)
-                    message.adoptedIds[i] = object.adoptedIds[i] >>> 0;
+            let message = new $root.chess.ServerResume();
+            if (object.playingWhite != null)
+                message.playingWhite = Boolean(object.playingWhite);
+            if (object.position != null) {
+                if (typeof object.position !== "object")
+                    throw TypeError(".chess.ServerResume.position: object expected");
+                message.position = $root.chess.Position.fromObject(object.position);
+            }
+            if (object.sessionToken != null)
+                message.sessionToken = String(object.sessionToken);
+            if (object.moves) {
+                if (!Array.isArray(object.moves))
+                    throw TypeError(".chess.ServerResume.moves: array expected");
+                message.moves = [];
+                for (let i = 0; i < object.moves.length; ++i) {
+                    if (typeof object.moves[i] !== "object")
+                        throw TypeError(".chess.ServerResume.moves: object expected");
+                    message.moves[i] = $root.chess.PieceDataForMove.fromObject(object.moves[i]);
+                }
+            }
+            if (object.captures) {
+                if (!Array.isArray(object.captures))
+                    throw TypeError(".chess.ServerResume.captures: array expected");
+                message.captures = [];
+                for (let i = 0; i < object.captures.length; ++i) {
+                    if (typeof object.captures[i] !== "object")
+                        throw TypeError(".chess.ServerResume.captures: object expected");
+                    message.captures[i] = $root.chess.PieceCapture.fromObject(object.captures[i]);
+                }
+            }
+            return message;
+        };
+
+        /**
+         * Creates a plain object from a ServerResume message. Also converts values to other types if specified.
+         * @function toObject
+         * @memberof chess.ServerResume
+         * @static
+         * @param {chess.ServerResume} message ServerResume
+         * @param {$protobuf.IConversionOptions} [options] Conversion options
+         * @returns {Object.<string,*>} Plain object
+         */
+        ServerResume.toObject = function toObject(message, options) {
+            if (!options)
+                options = {};
+            let object = {};
+            if (options.arrays || options.defaults) {
+                object.moves = [];
+                object.captures = [];
+            }
+            if (options.defaults) {
+                object.playingWhite = false;
+                object.position = null;
+                object.sessionToken = "";
+            }
+            if (message.playingWhite != null && message.hasOwnProperty("playingWhite"))
+                object.playingWhite = message.playingWhite;
+            if (message.position != null && message.hasOwnProperty("position"))
+                object.position = $root.chess.Position.toObject(message.position, options);
+            if (message.sessionToken != null && message.hasOwnProperty("sessionToken"))
+                object.sessionToken = message.sessionToken;
+            if (message.moves && message.moves.length) {
+                object.moves = [];
+                for (let j = 0; j < message.moves.length; ++j)
+                    object.moves[j] = $root.chess.PieceDataForMove.toObject(message.moves[j], options);
+            }
+            if (message.captures && message.captures.length) {
+                object.captures = [];
+                for (let j = 0; j < message.captures.length; ++j)
+                    object.captures[j] = $root.chess.PieceCapture.toObject(message.captures[j], options);
+            }
+            return object;
+        };
+
+        /**
+         * Converts this ServerResume to JSON.
+         * @function toJSON
+         * @memberof chess.ServerResume
+         * @instance
+         * @returns {Object.<string,*>} JSON object
+         */
+        ServerResume.prototype.toJSON = function toJSON() {
+            return this.constructor.toObject(this, $protobuf.util.toJSONOptions);
+        };
+
+        /**
+         * Gets the default type url for ServerResume
+         * @function getTypeUrl
+         * @memberof chess.ServerResume
+         * @static
+         * @param {string} [typeUrlPrefix] your custom typeUrlPrefix(default "type.googleapis.com")
+         * @returns {string} The default type url
+         */
+        ServerResume.getTypeUrl = function getTypeUrl(typeUrlPrefix) {
+            if (typeUrlPrefix === undefined) {
+                typeUrlPrefix = "type.googleapis.com";
+            }
+            return typeUrlPrefix + "/chess.ServerResume";
+        };
+
+        return ServerResume;
+    })();
+
+    chess.ServerAdoption = (function() {
+
+        /**
+         * Properties of a ServerAdoption.
+         * @memberof chess
+         * @interface IServerAdoption
+         * @property {Array.<number>|null} [adoptedIds] ServerAdoption adoptedIds
+         */
+
+        /**
+         * Constructs a new ServerAdoption.
+         * @memberof chess
+         * @classdesc Represents a ServerAdoption.
+         * @implements IServerAdoption
+         * @constructor
+         * @param {chess.IServerAdoption=} [properties] Properties to set
+         */
+        function ServerAdoption(properties) {
+            this.adoptedIds = [];
+            if (properties)
+                for (let keys = Object.keys(properties), i = 0; i < keys.length; ++i)
+                    if (properties[keys[i]] != null)
+                        this[keys[i]] = properties[keys[i]];
+        }
+
+        /**
+         * ServerAdoption adoptedIds.
+         * @member {Array.<number>} adoptedIds
+         * @memberof chess.ServerAdoption
+         * @instance
+         */
+        ServerAdoption.prototype.adoptedIds = $util.emptyArray;
+
+        /**
+         * Creates a new ServerAdoption instance using the specified properties.
+         * @function create
+         * @memberof chess.ServerAdoption
+         * @static
+         * @param {chess.IServerAdoption=} [properties] Properties to set
+         * @returns {chess.ServerAdoption} ServerAdoption instance
+         */
+        ServerAdoption.create = function create(properties) {
+            return new ServerAdoption(properties);
+        };
+
+        /**
+         * Encodes the specified ServerAdoption message. Does not implicitly {@link chess.ServerAdoption.verify|verify} messages.
+         * @function encode
+         * @memberof chess.ServerAdoption
+         * @static
+         * @param {chess.IServerAdoption} message ServerAdoption message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        ServerAdoption.encode = function encode(message, writer) {
+            if (!writer)
+                writer = $Writer.create();
+            if (message.adoptedIds != null && message.adoptedIds.length) {
+                writer.uint32(/* id 1, wireType 2 =*/10).fork();
+                for (let i = 0; i < message.adoptedIds.length; ++i)
+                    writer.uint32(message.adoptedIds[i]);
+                writer.ldelim();
+            }
+            return writer;
+        };
+
+        /**
+         * Encodes the specified ServerAdoption message, length delimited. Does not implicitly {@link chess.ServerAdoption.verify|verify} messages.
+         * @function encodeDelimited
+         * @memberof chess.ServerAdoption
+         * @static
+         * @param {chess.IServerAdoption} message ServerAdoption message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        ServerAdoption.encodeDelimited = function encodeDelimited(message, writer) {
+            return this.encode(message, writer).ldelim();
+        };
+
+        /**
+         * Decodes a ServerAdoption message from the specified reader or buffer.
+         * @function decode
+         * @memberof chess.ServerAdoption
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @param {number} [length] Message length if known beforehand
+         * @returns {chess.ServerAdoption} ServerAdoption
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        ServerAdoption.decode = function decode(reader, length, error) {
+            if (!(reader instanceof $Reader))
+                reader = $Reader.create(reader);
+            let end = length === undefined ? reader.len : reader.pos + length, message = new $root.chess.ServerAdoption();
+            while (reader.pos < end) {
+                let tag = reader.uint32();
+                if (tag === error)
+                    break;
+                switch (tag >>> 3) {
+                case 1: {
+                        if (!(message.adoptedIds && message.adoptedIds.length))
+                            message.adoptedIds = [];
+                        if ((tag & 7) === 2) {
+                            let end2 = reader.uint32() + reader.pos;
+                            while (reader.pos < end2)
+                                message.adoptedIds.push(reader.uint32());
+                        } else
+                            message.adoptedIds.push(reader.uint32());
+                        break;
+                    }
+                default:
+                    reader.skipType(tag & 7);
+                    break;
+                }
+            }
+            return message;
+        };
+
+        /**
+         * Decodes a ServerAdoption message from the specified reader or buffer, length delimited.
+         * @function decodeDelimited
+         * @memberof chess.ServerAdoption
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @returns {chess.ServerAdoption} ServerAdoption
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        ServerAdoption.decodeDelimited = function decodeDelimited(reader) {
+            if (!(reader instanceof $Reader))
+                reader = new $Reader(reader);
+            return this.decode(reader, reader.uint32());
+        };
+
+        /**
+         * Verifies a ServerAdoption message.
+         * @function verify
+         * @memberof chess.ServerAdoption
+         * @static
+         * @param {Object.<string,*>} message Plain object to verify
+         * @returns {string|null} `null` if valid, otherwise the reason why it is not
+         */
+        ServerAdoption.verify = function verify(message) {
+            if (typeof message !== "object" || message === null)
+                return "object expected";
+            if (message.adoptedIds != null && message.hasOwnProperty("adoptedIds")) {
+                if (!Array.isArray(message.adoptedIds))
+                    return "adoptedIds: array expected";
+                for (let i = 0; i < message.adoptedIds.length; ++i)
+                    if (!$util.isInteger(message.adoptedIds[i]))
+                        return "adoptedIds: integer[] expected";
+            }
+            return null;
+        };
+
+        /**
+         * Creates a ServerAdoption message from a plain object. Also converts values to their respective internal types.
+         * @function fromObject
+         * @memberof chess.ServerAdoption
+         * @static
+         * @param {Object.<string,*>} object Plain object
+         * @returns {chess.ServerAdoption} ServerAdoption
+         */
+        ServerAdoption.fromObject = function fromObject(object) {
+            if (object instanceof $root.chess.ServerAdoption)
+                return object;
+            let message = new $root.chess.ServerAdoption();
+            if (object.adoptedIds) {
+                if (!Array.isArray(object.adoptedIds))
+                    throw TypeError(".chess.ServerAdoption.adoptedIds: array expected");
+                message.adoptedIds = [];
+                for (let i = 0; i < object.adoptedIds.length; ++i)
+                    message.adoptedIds[i] = object.adoptedIds[i] >>> 0;
+            }
+            return message;
+        };
+
+        /**
+         * Creates a plain object from a ServerAdoption message. Also converts values to other types if specified.
+         * @function toObject
+         * @memberof chess.ServerAdoption
+         * @static
+         * @param {chess.ServerAdoption} message ServerAdoption
+         * @param {$protobuf.IConversionOptions} [options] Conversion options
+         * @returns {Object.<string,*>} Plain object
+         */
+        ServerAdoption.toObject = function toObject(message, options) {
+            if (!options)
+                options = {};
+            let object = {};
+            if (options.arrays || options.defaults)
+                object.adoptedIds = [];
+            if (message.adoptedIds && message.adoptedIds.length) {
+                object.adoptedIds = [];
+                for (let j = 0; j < message.adoptedIds.length; ++j)
+                    object.adoptedIds[j] = message.adoptedIds[j];
+            }
+            return object;
+        };
+
+        /**
+         * Converts this ServerAdoption to JSON.
+         * @function toJSON
+         * @memberof chess.ServerAdoption
+         * @instance
+         * @returns {Object.<string,*>} JSON object
+         */
+        ServerAdoption.prototype.toJSON = function toJSON() {
+            return this.constructor.toObject(this, $protobuf.util.toJSONOptions);
+        };
+
+        /**
+         * Gets the default type url for ServerAdoption
+         * @function getTypeUrl
+         * @memberof chess.ServerAdoption
+         * @static
+         * @param {string} [typeUrlPrefix] your custom typeUrlPrefix(default "type.googleapis.com")
+         * @returns {string} The default type url
+         */
+        ServerAdoption.getTypeUrl = function getTypeUrl(typeUrlPrefix) {
+            if (typeUrlPrefix === undefined) {
+                typeUrlPrefix = "type.googleapis.com";
+            }
+            return typeUrlPrefix + "/chess.ServerAdoption";
+        };
+
+        return ServerAdoption;
+    })();
+
+    chess.ServerBulkCapture = (function() {
+
+        /**
+         * Properties of a ServerBulkCapture.
+         * @memberof chess
+         * @interface IServerBulkCapture
+         * @property {number|Long|null} [seqnum] ServerBulkCapture seqnum
+         * @property {Array.<number>|null} [capturedIds] ServerBulkCapture capturedIds
+         */
+
+        /**
+         * Constructs a new ServerBulkCapture.
+         * @memberof chess
+         * @classdesc Represents a ServerBulkCapture.
+         * @implements IServerBulkCapture
+         * @constructor
+         * @param {chess.IServerBulkCapture=} [properties] Properties to set
+         */
+        function ServerBulkCapture(properties) {
+            this.capturedIds = [];
+            if (properties)
+                for (let keys = Object.keys(properties), i = 0; i < keys.length; ++i)
+                    if (properties[keys[i]] != null)
+                        this[keys[i]] = properties[keys[i]];
+        }
+
+        /**
+         * ServerBulkCapture seqnum.
+         * @member {number|Long} seqnum
+         * @memberof chess.ServerBulkCapture
+         * @instance
+         */
+        ServerBulkCapture.prototype.seqnum = $util.Long ? $util.Long.fromBits(0,0,true) : 0;
+
+        /**
+         * ServerBulkCapture capturedIds.
+         * @member {Array.<number>} capturedIds
+         * @memberof chess.ServerBulkCapture
+         * @instance
+         */
+        ServerBulkCapture.prototype.capturedIds = $util.emptyArray;
+
+        /**
+         * Creates a new ServerBulkCapture instance using the specified properties.
+         * @function create
+         * @memberof chess.ServerBulkCapture
+         * @static
+         * @param {chess.IServerBulkCapture=} [properties] Properties to set
+         * @returns {chess.ServerBulkCapture} ServerBulkCapture instance
+         */
+        ServerBulkCapture.create = function create(properties) {
+            return new ServerBulkCapture(properties);
+        };
+
+        /**
+         * Encodes the specified ServerBulkCapture message. Does not implicitly {@link chess.ServerBulkCapture.verify|verify} messages.
+         * @function encode
+         * @memberof chess.ServerBulkCapture
+         * @static
+         * @param {chess.IServerBulkCapture} message ServerBulkCapture message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        ServerBulkCapture.encode = function encode(message, writer) {
+            if (!writer)
+                writer = $Writer.create();
+            if (message.seqnum != null && Object.hasOwnProperty.call(message, "seqnum"))
+                writer.uint32(/* id 1, wireType 0 =*/8).uint64(message.seqnum);
+            if (message.capturedIds != null && message.capturedIds.length) {
+                writer.uint32(/* id 2, wireType 2 =*/18).fork();
+                for (let i = 0; i < message.capturedIds.length; ++i)
+                    writer.uint32(message.capturedIds[i]);
+                writer.ldelim();
+            }
+            return writer;
+        };
+
+        /**
+         * Encodes the specified ServerBulkCapture message, length delimited. Does not implicitly {@link chess.ServerBulkCapture.verify|verify} messages.
+         * @function encodeDelimited
+         * @memberof chess.ServerBulkCapture
+         * @static
+         * @param {chess.IServerBulkCapture} message ServerBulkCapture message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        ServerBulkCapture.encodeDelimited = function encodeDelimited(message, writer) {
+            return this.encode(message, writer).ldelim();
+        };
+
+        /**
+         * Decodes a ServerBulkCapture message from the specified reader or buffer.
+         * @function decode
+         * @memberof chess.ServerBulkCapture
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @param {number} [length] Message length if known beforehand
+         * @returns {chess.ServerBulkCapture} ServerBulkCapture
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        ServerBulkCapture.decode = function decode(reader, length, error) {
+            if (!(reader instanceof $Reader))
+                reader = $Reader.create(reader);
+            let end = length === undefined ? reader.len : reader.pos + length, message = new $root.chess.ServerBulkCapture();
+            while (reader.pos < end) {
+                let tag = reader.uint32();
+                if (tag === error)
+                    break;
+                switch (tag >>> 3) {
+                case 1: {
+                        message.seqnum = reader.uint64();
+                        break;
+                    }
+                case 2: {
+                        if (!(message.capturedIds && message.capturedIds.length))
+                            message.capturedIds = [];
+                        if ((tag & 7) === 2) {
+                            let end2 = reader.uint32() + reader.pos;
+                            while (reader.pos < end2)
+                                message.capturedIds.push(reader.uint32());
+                        } else
+                            message.capturedIds.push(reader.uint32());
+                        break;
+                    }
+                default:
+                    reader.skipType(tag & 7);
+                    break;
+                }
+            }
+            return message;
+        };
+
+        /**
+         * Decodes a ServerBulkCapture message from the specified reader or buffer, length delimited.
+         * @function decodeDelimited
+         * @memberof chess.ServerBulkCapture
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @returns {chess.ServerBulkCapture} ServerBulkCapture
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        ServerBulkCapture.decodeDelimited = function decodeDelimited(reader) {
+            if (!(reader instanceof $Reader))
+                reader = new $Reader(reader);
+            return this.decode(reader, reader.uint32());
+        };
+
+        /**
+         * Verifies a ServerBulkCapture message.
+         * @function verify
+         * @memberof chess.ServerBulkCapture
+         * @static
+         * @param {Object.<string,*>} message Plain object to verify
+         * @returns {string|null} `null` if valid, otherwise the reason why it is not
+         */
+        ServerBulkCapture.verify = function verify(message) {
+            if (typeof message !== "object" || message === null)
+                return "object expected";
+            if (message.seqnum != null && message.hasOwnProperty("seqnum"))
+                if (!$util.isInteger(message.seqnum) && !(message.seqnum && $util.isInteger(message.seqnum.low) && $util.isInteger(message.seqnum.high)))
+                    return "seqnum: integer|Long expected";
+            if (message.capturedIds != null && message.hasOwnProperty("capturedIds")) {
+                if (!Array.isArray(message.capturedIds))
+                    return "capturedIds: array expected";
+                for (let i = 0; i < message.capturedIds.length; ++i)
+                    if (!$util.isInteger(message.capturedIds[i]))
+                        return "capturedIds: integer[] expected";
+            }
+            return null;
+        };
+
+        /**
+         * Creates a ServerBulkCapture message from a plain object. Also converts values to their respective internal types.
+         * @function fromObject
+         * @memberof chess.ServerBulkCapture
+         * @static
+         * @param {Object.<string,*>} object Plain object
+         * @returns {chess.ServerBulkCapture} ServerBulkCapture
+         */
+        ServerBulkCapture.fromObject = function fromObject(object) {
+            if (object instanceof $root.chess.ServerBulkCapture)
+                return object;
+            let message = new $root.chess.ServerBulkCapture();
+            if (object.seqnum != null)
+                if ($util.Long)
+                    (message.seqnum = $util.Long.fromValue(object.seqnum)).unsigned = true;
+                else if (typeof object.seqnum === "string")
+                    message.seqnum = parseInt(object.seqnum, 10);
+                else if (typeof object.seqnum === "number")
+                    message.seqnum = object.seqnum;
+                else if (typeof object.seqnum === "object")
+                    message.seqnum = new $util.LongBits(object.seqnum.low >>> 0, object.seqnum.high >>> 0).toNumber(true);
+            if (object.capturedIds) {
+                if (!Array.isArray(object.capturedIds))
+                    throw TypeError(".chess.ServerBulkCapture.capturedIds: array expected");
+                message.capturedIds = [];
+                for (let i = 0; i < object.capturedIds.length; ++i)
+                    message.capturedIds[i] = object.capturedIds[i] >>> 0;
+            }
+            return message;
+        };
+
+        /**
+         * Creates a plain object from a ServerBulkCapture message. Also converts values to other types if specified.
+         * @function toObject
+         * @memberof chess.ServerBulkCapture
+         * @static
+         * @param {chess.ServerBulkCapture} message ServerBulkCapture
+         * @param {$protobuf.IConversionOptions} [options] Conversion options
+         * @returns {Object.<string,*>} Plain object
+         */
+        ServerBulkCapture.toObject = function toObject(message, options) {
+            if (!options)
+                options = {};
+            let object = {};
+            if (options.arrays || options.defaults)
+                object.capturedIds = [];
+            if (options.defaults)
+                if ($util.Long) {
+                    let long = new $util.Long(0, 0, true);
+                    object.seqnum = options.longs === String ? long.toString() : options.longs === Number ? long.toNumber() : long;
+                } else
+                    object.seqnum = options.longs === String ? "0" : 0;
+            if (message.seqnum != null && message.hasOwnProperty("seqnum"))
+                if (typeof message.seqnum === "number")
+                    object.seqnum = options.longs === String ? String(message.seqnum) : message.seqnum;
+                else
+                    object.seqnum = options.longs === String ? $util.Long.prototype.toString.call(message.seqnum) : options.longs === Number ? new $util.LongBits(message.seqnum.low >>> 0, message.seqnum.high >>> 0).toNumber(true) : message.seqnum;
+            if (message.capturedIds && message.capturedIds.length) {
+                object.capturedIds = [];
+                for (let j = 0; j < message.capturedIds.length; ++j)
+                    object.capturedIds[j] = message.capturedIds[j];
+            }
+            return object;
+        };
+
+        /**
+         * Converts this ServerBulkCapture to JSON.
+         * @function toJSON
+         * @memberof chess.ServerBulkCapture
+         * @instance
+         * @returns {Object.<string,*>} JSON object
+         */
+        ServerBulkCapture.prototype.toJSON = function toJSON() {
+            return this.constructor.toObject(this, $protobuf.util.toJSONOptions);
+        };
+
+        /**
+         * Gets the default type url for ServerBulkCapture
+         * @function getTypeUrl
+         * @memberof chess.ServerBulkCapture
+         * @static
+         * @param {string} [typeUrlPrefix] your custom typeUrlPrefix(default "type.googleapis.com")
+         * @returns {string} The default type url
+         */
+        ServerBulkCapture.getTypeUrl = function getTypeUrl(typeUrlPrefix) {
+            if (typeUrlPrefix === undefined) {
+                typeUrlPrefix = "type.googleapis.com";
+            }
+            return typeUrlPrefix + "/chess.ServerBulkCapture";
+        };
+
+        return ServerBulkCapture;
+    })();
+
+    chess.ServerSetPieces = (function() {
+
+        /**
+         * Properties of a ServerSetPieces.
+         * @memberof chess
+         * @interface IServerSetPieces
+         * @property {number|Long|null} [seqnum] ServerSetPieces seqnum
+         * @property {Array.<chess.IPieceDataForMove>|null} [placed] ServerSetPieces placed
+         * @property {Array.<number>|null} [removedIds] ServerSetPieces removedIds
+         */
+
+        /**
+         * Constructs a new ServerSetPieces.
+         * @memberof chess
+         * @classdesc Represents a ServerSetPieces.
+         * @implements IServerSetPieces
+         * @constructor
+         * @param {chess.IServerSetPieces=} [properties] Properties to set
+         */
+        function ServerSetPieces(properties) {
+            this.placed = [];
+            this.removedIds = [];
+            if (properties)
+                for (let keys = Object.keys(properties), i = 0; i < keys.length; ++i)
+                    if (properties[keys[i]] != null)
+                        this[keys[i]] = properties[keys[i]];
+        }
+
+        /**
+         * ServerSetPieces seqnum.
+         * @member {number|Long} seqnum
+         * @memberof chess.ServerSetPieces
+         * @instance
+         */
+        ServerSetPieces.prototype.seqnum = $util.Long ? $util.Long.fromBits(0,0,true) : 0;
+
+        /**
+         * ServerSetPieces placed.
+         * @member {Array.<chess.IPieceDataForMove>} placed
+         * @memberof chess.ServerSetPieces
+         * @instance
+         */
+        ServerSetPieces.prototype.placed = $util.emptyArray;
+
+        /**
+         * ServerSetPieces removedIds.
+         * @member {Array.<number>} removedIds
+         * @memberof chess.ServerSetPieces
+         * @instance
+         */
+        ServerSetPieces.prototype.removedIds = $util.emptyArray;
+
+        /**
+         * Creates a new ServerSetPieces instance using the specified properties.
+         * @function create
+         * @memberof chess.ServerSetPieces
+         * @static
+         * @param {chess.IServerSetPieces=} [properties] Properties to set
+         * @returns {chess.ServerSetPieces} ServerSetPieces instance
+         */
+        ServerSetPieces.create = function create(properties) {
+            return new ServerSetPieces(properties);
+        };
+
+        /**
+         * Encodes the specified ServerSetPieces message. Does not implicitly {@link chess.ServerSetPieces.verify|verify} messages.
+         * @function encode
+         * @memberof chess.ServerSetPieces
+         * @static
+         * @param {chess.IServerSetPieces} message ServerSetPieces message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        ServerSetPieces.encode = function encode(message, writer) {
+            if (!writer)
+                writer = $Writer.create();
+            if (message.seqnum != null && Object.hasOwnProperty.call(message, "seqnum"))
+                writer.uint32(/* id 1, wireType 0 =*/8).uint64(message.seqnum);
+            if (message.placed != null && message.placed.length)
+                for (let i = 0; i < message.placed.length; ++i)
+                    $root.chess.PieceDataForMove.encode(message.placed[i], writer.uint32(/* id 2, wireType 2 =*/18).fork()).ldelim();
+            if (message.removedIds != null && message.removedIds.length) {
+                writer.uint32(/* id 3, wireType 2 =*/26).fork();
+                for (let i = 0; i < message.removedIds.length; ++i)
+                    writer.uint32(message.removedIds[i]);
+                writer.ldelim();
+            }
+            return writer;
+        };
+
+        /**
+         * Encodes the specified ServerSetPieces message, length delimited. Does not implicitly {@link chess.ServerSetPieces.verify|verify} messages.
+         * @function encodeDelimited
+         * @memberof chess.ServerSetPieces
+         * @static
+         * @param {chess.IServerSetPieces} message ServerSetPieces message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        ServerSetPieces.encodeDelimited = function encodeDelimited(message, writer) {
+            return this.encode(message, writer).ldelim();
+        };
+
+        /**
+         * Decodes a ServerSetPieces message from the specified reader or buffer.
+         * @function decode
+         * @memberof chess.ServerSetPieces
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @param {number} [length] Message length if known beforehand
+         * @returns {chess.ServerSetPieces} ServerSetPieces
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        ServerSetPieces.decode = function decode(reader, length, error) {
+            if (!(reader instanceof $Reader))
+                reader = $Reader.create(reader);
+            let end = length === undefined ? reader.len : reader.pos + length, message = new $root.chess.ServerSetPieces();
+            while (reader.pos < end) {
+                let tag = reader.uint32();
+                if (tag === error)
+                    break;
+                switch (tag >>> 3) {
+                case 1: {
+                        message.seqnum = reader.uint64();
+                        break;
+                    }
+                case 2: {
+                        if (!(message.placed && message.placed.length))
+                            message.placed = [];
+                        message.placed.push($root.chess.PieceDataForMove.decode(reader, reader.uint32()));
+                        break;
+                    }
+                case 3: {
+                        if (!(message.removedIds && message.removedIds.length))
+                            message.removedIds = [];
+                        if ((tag & 7) === 2) {
+                            let end2 = reader.uint32() + reader.pos;
+                            while (reader.pos < end2)
+                                message.removedIds.push(reader.uint32());
+                        } else
+                            message.removedIds.push(reader.uint32());
+                        break;
+                    }
+                default:
+                    reader.skipType(tag & 7);
+                    break;
+                }
+            }
+            return message;
+        };
+
+        /**
+         * Decodes a ServerSetPieces message from the specified reader or buffer, length delimited.
+         * @function decodeDelimited
+         * @memberof chess.ServerSetPieces
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @returns {chess.ServerSetPieces} ServerSetPieces
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        ServerSetPieces.decodeDelimited = function decodeDelimited(reader) {
+            if (!(reader instanceof $Reader))
+                reader = new $Reader(reader);
+            return this.decode(reader, reader.uint32());
+        };
+
+        /**
+         * Verifies a ServerSetPieces message.
+         * @function verify
+         * @memberof chess.ServerSetPieces
+         * @static
+         * @param {Object.<string,*>} message Plain object to verify
+         * @returns {string|null} `null` if valid, otherwise the reason why it is not
+         */
+        ServerSetPieces.verify = function verify(message) {
+            if (typeof message !== "object" || message === null)
+                return "object expected";
+            if (message.seqnum != null && message.hasOwnProperty("seqnum"))
+                if (!$util.isInteger(message.seqnum) && !(message.seqnum && $util.isInteger(message.seqnum.low) && $util.isInteger(message.seqnum.high)))
+                    return "seqnum: integer|Long expected";
+            if (message.placed != null && message.hasOwnProperty("placed")) {
+                if (!Array.isArray(message.placed))
+                    return "placed: array expected";
+                for (let i = 0; i < message.placed.length; ++i) {
+                    let error = $root.chess.PieceDataForMove.verify(message.placed[i]);
+                    if (error)
+                        return "placed." + error;
+                }
+            }
+            if (message.removedIds != null && message.hasOwnProperty("removedIds")) {
+                if (!Array.isArray(message.removedIds))
+                    return "removedIds: array expected";
+                for (let i = 0; i < message.removedIds.length; ++i)
+                    if (!$util.isInteger(message.removedIds[i]))
+                        return "removedIds: integer[] expected";
+            }
+            return null;
+        };
+
+        /**
+         * Creates a ServerSetPieces message from a plain object. Also converts values to their respective internal types.
+         * @function fromObject
+         * @memberof chess.ServerSetPieces
+         * @static
+         * @param {Object.<string,*>} object Plain object
+         * @returns {chess.ServerSetPieces} ServerSetPieces
+         */
+        ServerSetPieces.fromObject = function fromObject(object) {
+            if (object instanceof $root.chess.ServerSetPieces)
+                return object;
+            let message = new $root.chess.ServerSetPieces();
+            if (object.seqnum != null)
+                if ($util.Long)
+                    (message.seqnum = $util.Long.fromValue(object.seqnum)).unsigned = true;
+                else if (typeof object.seqnum === "string")
+                    message.seqnum = parseInt(object.seqnum, 10);
+                else if (typeof object.seqnum === "number")
+                    message.seqnum = object.seqnum;
+                else if (typeof object.seqnum === "object")
+                    message.seqnum = new $util.LongBits(object.seqnum.low >>> 0, object.seqnum.high >>> 0).toNumber(true);
+            if (object.placed) {
+                if (!Array.isArray(object.placed))
+                    throw TypeError(".chess.ServerSetPieces.placed: array expected");
+                message.placed = [];
+                for (let i = 0; i < object.placed.length; ++i) {
+                    if (typeof object.placed[i] !== "object")
+                        throw TypeError(".chess.ServerSetPieces.placed: object expected");
+                    message.placed[i] = $root.chess.PieceDataForMove.fromObject(object.placed[i]);
+                }
+            }
+            if (object.removedIds) {
+                if (!Array.isArray(object.removedIds))
+                    throw TypeError(".chess.ServerSetPieces.removedIds: array expected");
+                message.removedIds = [];
+                for (let i = 0; i < object.removedIds.length; ++i)
+                    message.removedIds[i] = object.removedIds[i] >>> 0;
+            }
+            return message;
+        };
+
+        /**
+         * Creates a plain object from a ServerSetPieces message. Also converts values to other types if specified.
+         * @function toObject
+         * @memberof chess.ServerSetPieces
+         * @static
+         * @param {chess.ServerSetPieces} message ServerSetPieces
+         * @param {$protobuf.IConversionOptions} [options] Conversion options
+         * @returns {Object.<string,*>} Plain object
+         */
+        ServerSetPieces.toObject = function toObject(message, options) {
+            if (!options)
+                options = {};
+            let object = {};
+            if (options.arrays || options.defaults) {
+                object.placed = [];
+                object.removedIds = [];
+            }
+            if (options.defaults)
+                if ($util.Long) {
+                    let long = new $util.Long(0, 0, true);
+                    object.seqnum = options.longs === String ? long.toString() : options.longs === Number ? long.toNumber() : long;
+                } else
+                    object.seqnum = options.longs === String ? "0" : 0;
+            if (message.seqnum != null && message.hasOwnProperty("seqnum"))
+                if (typeof message.seqnum === "number")
+                    object.seqnum = options.longs === String ? String(message.seqnum) : message.seqnum;
+                else
+                    object.seqnum = options.longs === String ? $util.Long.prototype.toString.call(message.seqnum) : options.longs === Number ? new $util.LongBits(message.seqnum.low >>> 0, message.seqnum.high >>> 0).toNumber(true) : message.seqnum;
+            if (message.placed && message.placed.length) {
+                object.placed = [];
+                for (let j = 0; j < message.placed.length; ++j)
+                    object.placed[j] = $root.chess.PieceDataForMove.toObject(message.placed[j], options);
+            }
+            if (message.removedIds && message.removedIds.length) {
+                object.removedIds = [];
+                for (let j = 0; j < message.removedIds.length; ++j)
+                    object.removedIds[j] = message.removedIds[j];
+            }
+            return object;
+        };
+
+        /**
+         * Converts this ServerSetPieces to JSON.
+         * @function toJSON
+         * @memberof chess.ServerSetPieces
+         * @instance
+         * @returns {Object.<string,*>} JSON object
+         */
+        ServerSetPieces.prototype.toJSON = function toJSON() {
+            return this.constructor.toObject(this, $protobuf.util.toJSONOptions);
+        };
+
+        /**
+         * Gets the default type url for ServerSetPieces
+         * @function getTypeUrl
+         * @memberof chess.ServerSetPieces
+         * @static
+         * @param {string} [typeUrlPrefix] your custom typeUrlPrefix(default "type.googleapis.com")
+         * @returns {string} The default type url
+         */
+        ServerSetPieces.getTypeUrl = function getTypeUrl(typeUrlPrefix) {
+            if (typeUrlPrefix === undefined) {
+                typeUrlPrefix = "type.googleapis.com";
+            }
+            return typeUrlPrefix + "/chess.ServerSetPieces";
+        };
+
+        return ServerSetPieces;
+    })();
+
+    chess.ServerBoardOutcome = (function() {
+
+        /**
+         * Properties of a ServerBoardOutcome.
+         * @memberof chess
+         * @interface IServerBoardOutcome
+         * @property {number|Long|null} [seqnum] ServerBoardOutcome seqnum
+         * @property {number|null} [boardX] ServerBoardOutcome boardX
+         * @property {number|null} [boardY] ServerBoardOutcome boardY
+         * @property {chess.BoardOutcome|null} [white] ServerBoardOutcome white
+         * @property {chess.BoardOutcome|null} [black] ServerBoardOutcome black
+         */
+
+        /**
+         * Constructs a new ServerBoardOutcome.
+         * @memberof chess
+         * @classdesc Represents a ServerBoardOutcome.
+         * @implements IServerBoardOutcome
+         * @constructor
+         * @param {chess.IServerBoardOutcome=} [properties] Properties to set
+         */
+        function ServerBoardOutcome(properties) {
+            if (properties)
+                for (let keys = Object.keys(properties), i = 0; i < keys.length; ++i)
+                    if (properties[keys[i]] != null)
+                        this[keys[i]] = properties[keys[i]];
+        }
+
+        /**
+         * ServerBoardOutcome seqnum.
+         * @member {number|Long} seqnum
+         * @memberof chess.ServerBoardOutcome
+         * @instance
+         */
+        ServerBoardOutcome.prototype.seqnum = $util.Long ? $util.Long.fromBits(0,0,true) : 0;
+
+        /**
+         * ServerBoardOutcome boardX.
+         * @member {number} boardX
+         * @memberof chess.ServerBoardOutcome
+         * @instance
+         */
+        ServerBoardOutcome.prototype.boardX = 0;
+
+        /**
+         * ServerBoardOutcome boardY.
+         * @member {number} boardY
+         * @memberof chess.ServerBoardOutcome
+         * @instance
+         */
+        ServerBoardOutcome.prototype.boardY = 0;
+
+        /**
+         * ServerBoardOutcome white.
+         * @member {chess.BoardOutcome} white
+         * @memberof chess.ServerBoardOutcome
+         * @instance
+         */
+        ServerBoardOutcome.prototype.white = 0;
+
+        /**
+         * ServerBoardOutcome black.
+         * @member {chess.BoardOutcome} black
+         * @memberof chess.ServerBoardOutcome
+         * @instance
+         */
+        ServerBoardOutcome.prototype.black = 0;
+
+        /**
+         * Creates a new ServerBoardOutcome instance using the specified properties.
+         * @function create
+         * @memberof chess.ServerBoardOutcome
+         * @static
+         * @param {chess.IServerBoardOutcome=} [properties] Properties to set
+         * @returns {chess.ServerBoardOutcome} ServerBoardOutcome instance
+         */
+        ServerBoardOutcome.create = function create(properties) {
+            return new ServerBoardOutcome(properties);
+        };
+
+        /**
+         * Encodes the specified ServerBoardOutcome message. Does not implicitly {@link chess.ServerBoardOutcome.verify|verify} messages.
+         * @function encode
+         * @memberof chess.ServerBoardOutcome
+         * @static
+         * @param {chess.IServerBoardOutcome} message ServerBoardOutcome message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        ServerBoardOutcome.encode = function encode(message, writer) {
+            if (!writer)
+                writer = $Writer.create();
+            if (message.seqnum != null && Object.hasOwnProperty.call(message, "seqnum"))
+                writer.uint32(/* id 1, wireType 0 =*/8).uint64(message.seqnum);
+            if (message.boardX != null && Object.hasOwnProperty.call(message, "boardX"))
+                writer.uint32(/* id 2, wireType 0 =*/16).uint32(message.boardX);
+            if (message.boardY != null && Object.hasOwnProperty.call(message, "boardY"))
+                writer.uint32(/* id 3, wireType 0 =*/24).uint32(message.boardY);
+            if (message.white != null && Object.hasOwnProperty.call(message, "white"))
+                writer.uint32(/* id 4, wireType 0 =*/32).int32(message.white);
+            if (message.black != null && Object.hasOwnProperty.call(message, "black"))
+                writer.uint32(/* id 5, wireType 0 =*/40).int32(message.black);
+            return writer;
+        };
+
+        /**
+         * Encodes the specified ServerBoardOutcome message, length delimited. Does not implicitly {@link chess.ServerBoardOutcome.verify|verify} messages.
+         * @function encodeDelimited
+         * @memberof chess.ServerBoardOutcome
+         * @static
+         * @param {chess.IServerBoardOutcome} message ServerBoardOutcome message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        ServerBoardOutcome.encodeDelimited = function encodeDelimited(message, writer) {
+            return this.encode(message, writer).ldelim();
+        };
+
+        /**
+         * Decodes a ServerBoardOutcome message from the specified reader or buffer.
+         * @function decode
+         * @memberof chess.ServerBoardOutcome
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @param {number} [length] Message length if known beforehand
+         * @returns {chess.ServerBoardOutcome} ServerBoardOutcome
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        ServerBoardOutcome.decode = function decode(reader, length, error) {
+            if (!(reader instanceof $Reader))
+                reader = $Reader.create(reader);
+            let end = length === undefined ? reader.len : reader.pos + length, message = new $root.chess.ServerBoardOutcome();
+            while (reader.pos < end) {
+                let tag = reader.uint32();
+                if (tag === error)
+                    break;
+                switch (tag >>> 3) {
+                case 1: {
+                        message.seqnum = reader.uint64();
+                        break;
+                    }
+                case 2: {
+                        message.boardX = reader.uint32();
+                        break;
+                    }
+                case 3: {
+                        message.boardY = reader.uint32();
+                        break;
+                    }
+                case 4: {
+                        message.white = reader.int32();
+                        break;
+                    }
+                case 5: {
+                        message.black = reader.int32();
+                        break;
+                    }
+                default:
+                    reader.skipType(tag & 7);
+                    break;
+                }
+            }
+            return message;
+        };
+
+        /**
+         * Decodes a ServerBoardOutcome message from the specified reader or buffer, length delimited.
+         * @function decodeDelimited
+         * @memberof chess.ServerBoardOutcome
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @returns {chess.ServerBoardOutcome} ServerBoardOutcome
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        ServerBoardOutcome.decodeDelimited = function decodeDelimited(reader) {
+            if (!(reader instanceof $Reader))
+                reader = new $Reader(reader);
+            return this.decode(reader, reader.uint32());
+        };
+
+        /**
+         * Verifies a ServerBoardOutcome message.
+         * @function verify
+         * @memberof chess.ServerBoardOutcome
+         * @static
+         * @param {Object.<string,*>} message Plain object to verify
+         * @returns {string|null} `null` if valid, otherwise the reason why it is not
+         */
+        ServerBoardOutcome.verify = function verify(message) {
+            if (typeof message !== "object" || message === null)
+                return "object expected";
+            if (message.seqnum != null && message.hasOwnProperty("seqnum"))
+                if (!$util.isInteger(message.seqnum) && !(message.seqnum && $util.isInteger(message.seqnum.low) && $util.isInteger(message.seqnum.high)))
+                    return "seqnum: integer|Long expected";
+            if (message.boardX != null && message.hasOwnProperty("boardX"))
+                if (!$util.isInteger(message.boardX))
+                    return "boardX: integer expected";
+            if (message.boardY != null && message.hasOwnProperty("boardY"))
+                if (!$util.isInteger(message.boardY))
+                    return "boardY: integer expected";
+            if (message.white != null && message.hasOwnProperty("white"))
+                switch (message.white) {
+                default:
+                    return "white: enum value expected";
+                case 0:
+                case 1:
+                case 2:
+                    break;
+                }
+            if (message.black != null && message.hasOwnProperty("black"))
+                switch (message.black) {
+                default:
+                    return "black: enum value expected";
+                case 0:
+                case 1:
+                case 2:
+                    break;
+                }
+            return null;
+        };
+
+        /**
+         * Creates a ServerBoardOutcome message from a plain object. Also converts values to their respective internal types.
+         * @function fromObject
+         * @memberof chess.ServerBoardOutcome
+         * @static
+         * @param {Object.<string,*>} object Plain object
+         * @returns {chess.ServerBoardOutcome} ServerBoardOutcome
+         */
+        ServerBoardOutcome.fromObject = function fromObject(object) {
+            if (object instanceof $root.chess.ServerBoardOutcome)
+                return object;
+            let message = new $root.chess.ServerBoardOutcome();
+            if (object.seqnum != null)
+                if ($util.Long)
+                    (message.seqnum = $util.Long.fromValue(object.seqnum)).unsigned = true;
+                else if (typeof object.seqnum === "string")
+                    message.seqnum = parseInt(object.seqnum, 10);
+                else if (typeof object.seqnum === "number")
+                    message.seqnum = object.seqnum;
+                else if (typeof object.seqnum === "object")
+                    message.seqnum = new $util.LongBits(object.seqnum.low >>> 0, object.seqnum.high >>> 0).toNumber(true);
+            if (object.boardX != null)
+                message.boardX = object.boardX >>> 0;
+            if (object.boardY != null)
+                message.boardY = object.boardY >>> 0;
+            switch (object.white) {
+            default:
+                if (typeof object.white === "number") {
+                    message.white = object.white;
+                    break;
+                }
+                break;
+            case "BOARD_OUTCOME_NONE":
+            case 0:
+                message.white = 0;
+                break;
+            case "BOARD_OUTCOME_CHECKMATE":
+            case 1:
+                message.white = 1;
+                break;
+            case "BOARD_OUTCOME_STALEMATE":
+            case 2:
+                message.white = 2;
+                break;
+            }
+            switch (object.black) {
+            default:
+                if (typeof object.black === "number") {
+                    message.black = object.black;
+                    break;
+                }
+                break;
+            case "BOARD_OUTCOME_NONE":
+            case 0:
+                message.black = 0;
+                break;
+            case "BOARD_OUTCOME_CHECKMATE":
+            case 1:
+                message.black = 1;
+                break;
+            case "BOARD_OUTCOME_STALEMATE":
+            case 2:
+                message.black = 2;
+                break;
+            }
+            return message;
+        };
+
+        /**
+         * Creates a plain object from a ServerBoardOutcome message. Also converts values to other types if specified.
+         * @function toObject
+         * @memberof chess.ServerBoardOutcome
+         * @static
+         * @param {chess.ServerBoardOutcome} message ServerBoardOutcome
+         * @param {$protobuf.IConversionOptions} [options] Conversion options
+         * @returns {Object.<string,*>} Plain object
+         */
+        ServerBoardOutcome.toObject = function toObject(message, options) {
+            if (!options)
+                options = {};
+            let object = {};
+            if (options.defaults) {
+                if ($util.Long) {
+                    let long = new $util.Long(0, 0, true);
+                    object.seqnum = options.longs === String ? long.toString() : options.longs === Number ? long.toNumber() : long;
+                } else
+                    object.seqnum = options.longs === String ? "0" : 0;
+                object.boardX = 0;
+                object.boardY = 0;
+                object.white = options.enums === String ? "BOARD_OUTCOME_NONE" : 0;
+                object.black = options.enums === String ? "BOARD_OUTCOME_NONE" : 0;
+            }
+            if (message.seqnum != null && message.hasOwnProperty("seqnum"))
+                if (typeof message.seqnum === "number")
+                    object.seqnum = options.longs === String ? String(message.seqnum) : message.seqnum;
+                else
+                    object.seqnum = options.longs === String ? $util.Long.prototype.toString.call(message.seqnum) : options.longs === Number ? new $util.LongBits(message.seqnum.low >>> 0, message.seqnum.high >>> 0).toNumber(true) : message.seqnum;
+            if (message.boardX != null && message.hasOwnProperty("boardX"))
+                object.boardX = message.boardX;
+            if (message.boardY != null && message.hasOwnProperty("boardY"))
+                object.boardY = message.boardY;
+            if (message.white != null && message.hasOwnProperty("white"))
+                object.white = options.enums === String ? $root.chess.BoardOutcome[message.white] === undefined ? message.white : $root.chess.BoardOutcome[message.white] : message.white;
+            if (message.black != null && message.hasOwnProperty("black"))
+                object.black = options.enums === String ? $root.chess.BoardOutcome[message.black] === undefined ? message.black : $root.chess.BoardOutcome[message.black] : message.black;
+            return object;
+        };
+
+        /**
+         * Converts this ServerBoardOutcome to JSON.
+         * @function toJSON
+         * @memberof chess.ServerBoardOutcome
+         * @instance
+         * @returns {Object.<string,*>} JSON object
+         */
+        ServerBoardOutcome.prototype.toJSON = function toJSON() {
+            return this.constructor.toObject(this, $protobuf.util.toJSONOptions);
+        };
+
+        /**
+         * Gets the default type url for ServerBoardOutcome
+         * @function getTypeUrl
+         * @memberof chess.ServerBoardOutcome
+         * @static
+         * @param {string} [typeUrlPrefix] your custom typeUrlPrefix(default "type.googleapis.com")
+         * @returns {string} The default type url
+         */
+        ServerBoardOutcome.getTypeUrl = function getTypeUrl(typeUrlPrefix) {
+            if (typeUrlPrefix === undefined) {
+                typeUrlPrefix = "type.googleapis.com";
+            }
+            return typeUrlPrefix + "/chess.ServerBoardOutcome";
+        };
+
+        return ServerBoardOutcome;
+    })();
+
+    chess.PieceTypeCount = (function() {
+
+        /**
+         * Properties of a PieceTypeCount.
+         * @memberof chess
+         * @interface IPieceTypeCount
+         * @property {chess.PieceType|null} [type] PieceTypeCount type
+         * @property {number|null} [count] PieceTypeCount count
+         */
+
+        /**
+         * Constructs a new PieceTypeCount.
+         * @memberof chess
+         * @classdesc Represents a PieceTypeCount.
+         * @implements IPieceTypeCount
+         * @constructor
+         * @param {chess.IPieceTypeCount=} [properties] Properties to set
+         */
+        function PieceTypeCount(properties) {
+            if (properties)
+                for (let keys = Object.keys(properties), i = 0; i < keys.length; ++i)
+                    if (properties[keys[i]] != null)
+                        this[keys[i]] = properties[keys[i]];
+        }
+
+        /**
+         * PieceTypeCount type.
+         * @member {chess.PieceType} type
+         * @memberof chess.PieceTypeCount
+         * @instance
+         */
+        PieceTypeCount.prototype.type = 0;
+
+        /**
+         * PieceTypeCount count.
+         * @member {number} count
+         * @memberof chess.PieceTypeCount
+         * @instance
+         */
+        PieceTypeCount.prototype.count = 0;
+
+        /**
+         * Creates a new PieceTypeCount instance using the specified properties.
+         * @function create
+         * @memberof chess.PieceTypeCount
+         * @static
+         * @param {chess.IPieceTypeCount=} [properties] Properties to set
+         * @returns {chess.PieceTypeCount} PieceTypeCount instance
+         */
+        PieceTypeCount.create = function create(properties) {
+            return new PieceTypeCount(properties);
+        };
+
+        /**
+         * Encodes the specified PieceTypeCount message. Does not implicitly {@link chess.PieceTypeCount.verify|verify} messages.
+         * @function encode
+         * @memberof chess.PieceTypeCount
+         * @static
+         * @param {chess.IPieceTypeCount} message PieceTypeCount message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        PieceTypeCount.encode = function encode(message, writer) {
+            if (!writer)
+                writer = $Writer.create();
+            if (message.type != null && Object.hasOwnProperty.call(message, "type"))
+                writer.uint32(/* id 1, wireType 0 =*/8).int32(message.type);
+            if (message.count != null && Object.hasOwnProperty.call(message, "count"))
+                writer.uint32(/* id 2, wireType 0 =*/16).uint32(message.count);
+            return writer;
+        };
+
+        /**
+         * Encodes the specified PieceTypeCount message, length delimited. Does not implicitly {@link chess.PieceTypeCount.verify|verify} messages.
+         * @function encodeDelimited
+         * @memberof chess.PieceTypeCount
+         * @static
+         * @param {chess.IPieceTypeCount} message PieceTypeCount message or plain object to encode
+         * @param {$protobuf.Writer} [writer] Writer to encode to
+         * @returns {$protobuf.Writer} Writer
+         */
+        PieceTypeCount.encodeDelimited = function encodeDelimited(message, writer) {
+            return this.encode(message, writer).ldelim();
+        };
+
+        /**
+         * Decodes a PieceTypeCount message from the specified reader or buffer.
+         * @function decode
+         * @memberof chess.PieceTypeCount
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @param {number} [length] Message length if known beforehand
+         * @returns {chess.PieceTypeCount} PieceTypeCount
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        PieceTypeCount.decode = function decode(reader, length, error) {
+            if (!(reader instanceof $Reader))
+                reader = $Reader.create(reader);
+            let end = length === undefined ? reader.len : reader.pos + length, message = new $root.chess.PieceTypeCount();
+            while (reader.pos < end) {
+                let tag = reader.uint32();
+                if (tag === error)
+                    break;
+                switch (tag >>> 3) {
+                case 1: {
+                        message.type = reader.int32();
+                        break;
+                    }
+                case 2: {
+                        message.count = reader.uint32();
+                        break;
+                    }
+                default:
+                    reader.skipType(tag & 7);
+                    break;
+                }
+            }
+            return message;
+        };
+
+        /**
+         * Decodes a PieceTypeCount message from the specified reader or buffer, length delimited.
+         * @function decodeDelimited
+         * @memberof chess.PieceTypeCount
+         * @static
+         * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
+         * @returns {chess.PieceTypeCount} PieceTypeCount
+         * @throws {Error} If the payload is not a reader or valid buffer
+         * @throws {$protobuf.util.ProtocolError} If required fields are missing
+         */
+        PieceTypeCount.decodeDelimited = function decodeDelimited(reader) {
+            if (!(reader instanceof $Reader))
+                reader = new $Reader(reader);
+            return this.decode(reader, reader.uint32());
+        };
+
+        /**
+         * Verifies a PieceTypeCount message.
+         * @function verify
+         * @memberof chess.PieceTypeCount
+         * @static
+         * @param {Object.<string,*>} message Plain object to verify
+         * @returns {string|null} `null` if valid, otherwise the reason why it is not
+         */
+        PieceTypeCount.verify = function verify(message) {
+            if (typeof message !== "object" || message === null)
+                return "object expected";
+            if (message.type != null && message.hasOwnProperty("type"))
+                switch (message.type) {
+                default:
+                    return "type: enum value expected";
+                case 0:
+                case 1:
+                case 2:
+                case 3:
+                case 4:
+                case 5:
+                case 6:
+                case 7:
+                case 8:
+                case 9:
+                case 10:
+                case 11:
+                case 12:
+                    break;
+                }
+            if (message.count != null && message.hasOwnProperty("count"))
+                if (!$util.isInteger(message.count))
+                    return "count: integer expected";
+            return null;
+        };
+
+        /**
+         * Creates a PieceTypeCount message from a plain object. Also converts values to their respective internal types.
+         * @function fromObject
+         * @memberof chess.PieceTypeCount
+         * @static
+         * @param {Object.<string,*>} object Plain object
+         * @returns {chess.PieceTypeCount} PieceTypeCount
+         */
+        PieceTypeCount.fromObject = function fromObject(object) {
+            if (object instanceof $root.chess.PieceTypeCount)
+                return object;
+            let message = new $root.chess.PieceTypeCount();
+            switch (object.type) {
+            default:
+                if (typeof object.type === "number") {
+                    message.type = object.type;
+                    break;
+                }
+                break;
+            case "PIECE_TYPE_PAWN":
+            case 0:
+                message.type = 0;
+                break;
+            case "PIECE_TYPE_KNIGHT":
+            case 1:
+                message.type = 1;
+                break;
+            case "PIECE_TYPE_BISHOP":
+            case 2:
+                message.type = 2;
+                break;
+            case "PIECE_TYPE_ROOK":
+            case 3:
+                message.type = 3;
+                break;
+            case "PIECE_TYPE_QUEEN":
+            case 4:
+                message.type = 4;
+                break;
+            case "PIECE_TYPE_KING":
+            case 5:
+                message.type = 5;
+                break;
+            case "PIECE_TYPE_PROMOTED_PAWN":
+            case 6:
+                message.type = 6;
+                break;
+            case "PIECE_TYPE_PROMOTED_KNIGHT":
+            case 7:
+                message.type = 7;
+                break;
+            case "PIECE_TYPE_PROMOTED_BISHOP":
+            case 8:
+                message.type = 8;
+                break;
+            case "PIECE_TYPE_PROMOTED_ROOK":
+            case 9:
+                message.type = 9;
+                break;
+            case "PIECE_TYPE_ARCHBISHOP":
+            case 10:
+                message.type = 10;
+                break;
+            case "PIECE_TYPE_CHANCELLOR":
+            case 11:
+                message.type = 11;
+                break;
+            case "PIECE_TYPE_AMAZON":
+            case 12:
+                message.type = 12;
+                break;
             }
+            if (object.count != null)
+                message.count = object.count >>> 0;
             return message;
         };
 
         /**
-         * Creates a plain object from a ServerAdoption message. Also converts values to other types if specified.
+         * Creates a plain object from a PieceTypeCount message. Also converts values to other types if specified.
          * @function toObject
-         * @memberof chess.ServerAdoption
+         * @memberof chess.PieceTypeCount
          * @static
-         * @param {chess.ServerAdoption} message ServerAdoption
+         * @param {chess.PieceTypeCount} message PieceTypeCount
          * @param {$protobuf.IConversionOptions} [options] Conversion options
          * @returns {Object.<string,*>} Plain object
          */
-        ServerAdoption.toObject = function toObject(message, options) {
+        PieceTypeCount.toObject = function toObject(message, options) {
             if (!options)
                 options = {};
             let object = {};
-            if (options.arrays || options.defaults)
-                object.adoptedIds = [];
-            if (message.adoptedIds && message.adoptedIds.length) {
-                object.adoptedIds = [];
-                for (let j = 0; j < message.adoptedIds.length; ++j)
-                    object.adoptedIds[j] = message.adoptedIds[j];
+            if (options.defaults) {
+                object.type = options.enums === String ? "PIECE_TYPE_PAWN" : 0;
+                object.count = 0;
             }
+            if (message.type != null && message.hasOwnProperty("type"))
+                object.type = options.enums === String ? $root.chess.PieceType[message.type] === undefined ? message.type : $root.chess.PieceType[message.type] : message.type;
+            if (message.count != null && message.hasOwnProperty("count"))
+                object.count = message.count;
             return object;
         };
 
         /**
-         * Converts this ServerAdoption to JSON.
+         * Converts this PieceTypeCount to JSON.
          * @function toJSON
-         * @memberof chess.ServerAdoption
+         * @memberof chess.PieceTypeCount
          * @instance
          * @returns {Object.<string,*>} JSON object
          */
-        ServerAdoption.prototype.toJSON = function toJSON() {
+        PieceTypeCount.prototype.toJSON = function toJSON() {
             return this.constructor.toObject(this, $protobuf.util.toJSONOptions);
         };
 
         /**
-         * Gets the default type url for ServerAdoption
+         * Gets the default type url for PieceTypeCount
          * @function getTypeUrl
-         * @memberof chess.ServerAdoption
+         * @memberof chess.PieceTypeCount
          * @static
          * @param {string} [typeUrlPrefix] your custom typeUrlPrefix(default "type.googleapis.com")
          * @returns {string} The default type url
          */
-        ServerAdoption.getTypeUrl = function getTypeUrl(typeUrlPrefix) {
+        PieceTypeCount.getTypeUrl = function getTypeUrl(typeUrlPrefix) {
             if (typeUrlPrefix === undefined) {
                 typeUrlPrefix = "type.googleapis.com";
             }
-            return typeUrlPrefix + "/chess.ServerAdoption";
+            return typeUrlPrefix + "/chess.PieceTypeCount";
         };
 
-        return ServerAdoption;
+        return PieceTypeCount;
     })();
 
-    chess.ServerBulkCapture = (function() {
+    chess.ServerPlayerStats = (function() {
 
         /**
-         * Properties of a ServerBulkCapture.
+         * Properties of a ServerPlayerStats.
          * @memberof chess
-         * @interface IServerBulkCapture
-         * @property {number|Long|null} [seqnum] ServerBulkCapture seqnum
-         * @property {Array.<number>|null} [capturedIds] ServerBulkCapture capturedIds
+         * @interface IServerPlayerStats
+         * @property {number|null} [moves] ServerPlayerStats moves
+         * @property {number|null} [captures] ServerPlayerStats captures
+         * @property {Array.<chess.IPieceTypeCount>|null} [capturesByType] ServerPlayerStats capturesByType
+         * @property {number|null} [kingsCaptured] ServerPlayerStats kingsCaptured
+         * @property {number|null} [promotions] ServerPlayerStats promotions
          */
 
         /**
-         * Constructs a new ServerBulkCapture.
+         * Constructs a new ServerPlayerStats.
          * @memberof chess
-         * @classdesc Represents a ServerBulkCapture.
-         * @implements IServerBulkCapture
+         * @classdesc Represents a ServerPlayerStats.
+         * @implements IServerPlayerStats
          * @constructor
-         * @param {chess.IServerBulkCapture=} [properties] Properties to set
+         * @param {chess.IServerPlayerStats=} [properties] Properties to set
          */
-        function ServerBulkCapture(properties) {
-            this.capturedIds = [];
+        function ServerPlayerStats(properties) {
+            this.capturesByType = [];
             if (properties)
                 for (let keys = Object.keys(properties), i = 0; i < keys.length; ++i)
                     if (properties[keys[i]] != null)
@@ -4426,102 +6431,136 @@ export const chess = $root.chess = (() => {
         }
 
         /**
-         * ServerBulkCapture seqnum.
-         * @member {number|Long} seqnum
-         * @memberof chess.ServerBulkCapture
+         * ServerPlayerStats moves.
+         * @member {number} moves
+         * @memberof chess.ServerPlayerStats
          * @instance
          */
-        ServerBulkCapture.prototype.seqnum = $util.Long ? $util.Long.fromBits(0,0,true) : 0;
+        ServerPlayerStats.prototype.moves = 0;
 
         /**
-         * ServerBulkCapture capturedIds.
-         * @member {Array.<number>} capturedIds
-         * @memberof chess.ServerBulkCapture
+         * ServerPlayerStats captures.
+         * @member {number} captures
+         * @memberof chess.ServerPlayerStats
          * @instance
          */
-        ServerBulkCapture.prototype.capturedIds = $util.emptyArray;
+        ServerPlayerStats.prototype.captures = 0;
 
         /**
-         * Creates a new ServerBulkCapture instance using the specified properties.
+         * ServerPlayerStats capturesByType.
+         * @member {Array.<chess.IPieceTypeCount>} capturesByType
+         * @memberof chess.ServerPlayerStats
+         * @instance
+         */
+        ServerPlayerStats.prototype.capturesByType = $util.emptyArray;
+
+        /**
+         * ServerPlayerStats kingsCaptured.
+         * @member {number} kingsCaptured
+         * @memberof chess.ServerPlayerStats
+         * @instance
+         */
+        ServerPlayerStats.prototype.kingsCaptured = 0;
+
+        /**
+         * ServerPlayerStats promotions.
+         * @member {number} promotions
+         * @memberof chess.ServerPlayerStats
+         * @instance
+         */
+        ServerPlayerStats.prototype.promotions = 0;
+
+        /**
+         * Creates a new ServerPlayerStats instance using the specified properties.
          * @function create
-         * @memberof chess.ServerBulkCapture
+         * @memberof chess.ServerPlayerStats
          * @static
-         * @param {chess.IServerBulkCapture=} [properties] Properties to set
-         * @returns {chess.ServerBulkCapture} ServerBulkCapture instance
+         * @param {chess.IServerPlayerStats=} [properties] Properties to set
+         * @returns {chess.ServerPlayerStats} ServerPlayerStats instance
          */
-        ServerBulkCapture.create = function create(properties) {
-            return new ServerBulkCapture(properties);
+        ServerPlayerStats.create = function create(properties) {
+            return new ServerPlayerStats(properties);
         };
 
         /**
-         * Encodes the specified ServerBulkCapture message. Does not implicitly {@link chess.ServerBulkCapture.verify|verify} messages.
+         * Encodes the specified ServerPlayerStats message. Does not implicitly {@link chess.ServerPlayerStats.verify|verify} messages.
          * @function encode
-         * @memberof chess.ServerBulkCapture
+         * @memberof chess.ServerPlayerStats
          * @static
-         * @param {chess.IServerBulkCapture} message ServerBulkCapture message or plain object to encode
+         * @param {chess.IServerPlayerStats} message ServerPlayerStats message or plain object to encode
          * @param {$protobuf.Writer} [writer] Writer to encode to
          * @returns {$protobuf.Writer} Writer
          */
-        ServerBulkCapture.encode = function encode(message, writer) {
+        ServerPlayerStats.encode = function encode(message, writer) {
             if (!writer)
                 writer = $Writer.create();
-            if (message.seqnum != null && Object.hasOwnProperty.call(message, "seqnum"))
-                writer.uint32(/* id 1, wireType 0 =*/8).uint64(message.seqnum);
-            if (message.capturedIds != null && message.capturedIds.length) {
-                writer.uint32(/* id 2, wireType 2 =*/18).fork();
-                for (let i = 0; i < message.capturedIds.length; ++i)
-                    writer.uint32(message.capturedIds[i]);
-                writer.ldelim();
-            }
+            if (message.moves != null && Object.hasOwnProperty.call(message, "moves"))
+                writer.uint32(/* id 1, wireType 0 =*/8).uint32(message.moves);
+            if (message.captures != null && Object.hasOwnProperty.call(message, "captures"))
+                writer.uint32(/* id 2, wireType 0 =*/16).uint32(message.captures);
+            if (message.capturesByType != null && message.capturesByType.length)
+                for (let i = 0; i < message.capturesByType.length; ++i)
+                    $root.chess.PieceTypeCount.encode(message.capturesByType[i], writer.uint32(/* id 3, wireType 2 =*/26).fork()).ldelim();
+            if (message.kingsCaptured != null && Object.hasOwnProperty.call(message, "kingsCaptured"))
+                writer.uint32(/* id 4, wireType 0 =*/32).uint32(message.kingsCaptured);
+            if (message.promotions != null && Object.hasOwnProperty.call(message, "promotions"))
+                writer.uint32(/* id 5, wireType 0 =*/40).uint32(message.promotions);
             return writer;
         };
 
         /**
-         * Encodes the specified ServerBulkCapture message, length delimited. Does not implicitly {@link chess.ServerBulkCapture.verify|verify} messages.
+         * Encodes the specified ServerPlayerStats message, length delimited. Does not implicitly {@link chess.ServerPlayerStats.verify|verify} messages.
          * @function encodeDelimited
-         * @memberof chess.ServerBulkCapture
+         * @memberof chess.ServerPlayerStats
          * @static
-         * @param {chess.IServerBulkCapture} message ServerBulkCapture message or plain object to encode
+         * @param {chess.IServerPlayerStats} message ServerPlayerStats message or plain object to encode
          * @param {$protobuf.Writer} [writer] Writer to encode to
          * @returns {$protobuf.Writer} Writer
          */
-        ServerBulkCapture.encodeDelimited = function encodeDelimited(message, writer) {
+        ServerPlayerStats.encodeDelimited = function encodeDelimited(message, writer) {
             return this.encode(message, writer).ldelim();
         };
 
         /**
-         * Decodes a ServerBulkCapture message from the specified reader or buffer.
+         * Decodes a ServerPlayerStats message from the specified reader or buffer.
          * @function decode
-         * @memberof chess.ServerBulkCapture
+         * @memberof chess.ServerPlayerStats
          * @static
          * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
          * @param {number} [length] Message length if known beforehand
-         * @returns {chess.ServerBulkCapture} ServerBulkCapture
+         * @returns {chess.ServerPlayerStats} ServerPlayerStats
          * @throws {Error} If the payload is not a reader or valid buffer
          * @throws {$protobuf.util.ProtocolError} If required fields are missing
          */
-        ServerBulkCapture.decode = function decode(reader, length, error) {
+        ServerPlayerStats.decode = function decode(reader, length, error) {
             if (!(reader instanceof $Reader))
                 reader = $Reader.create(reader);
-            let end = length === undefined ? reader.len : reader.pos + length, message = new $root.chess.ServerBulkCapture();
+            let end = length === undefined ? reader.len : reader.pos + length, message = new $root.chess.ServerPlayerStats();
             while (reader.pos < end) {
                 let tag = reader.uint32();
                 if (tag === error)
                     break;
                 switch (tag >>> 3) {
                 case 1: {
-                        message.seqnum = reader.uint64();
+                        message.moves = reader.uint32();
                         break;
                     }
                 case 2: {
-                        if (!(message.capturedIds && message.capturedIds.length))
-                            message.capturedIds = [];
-                        if ((tag & 7) === 2) {
-                            let end2 = reader.uint32() + reader.pos;
-                            while (reader.pos < end2)
-                                message.capturedIds.push(reader.uint32());
-                        } else
-                            message.capturedIds.push(reader.uint32());
+                        message.captures = reader.uint32();
+                        break;
+                    }
+                case 3: {
+                        if (!(message.capturesByType && message.capturesByType.length))
+                            message.capturesByType = [];
+                        message.capturesByType.push($root.chess.PieceTypeCount.decode(reader, reader.uint32()));
+                        break;
+                    }
+                case 4: {
+                        message.kingsCaptured = reader.uint32();
+                        break;
+                    }
+                case 5: {
+                        message.promotions = reader.uint32();
                         break;
                     }
                 default:
@@ -4533,137 +6572,153 @@ export const chess = $root.chess = (() => {
         };
 
         /**
-         * Decodes a ServerBulkCapture message from the specified reader or buffer, length delimited.
+         * Decodes a ServerPlayerStats message from the specified reader or buffer, length delimited.
          * @function decodeDelimited
-         * @memberof chess.ServerBulkCapture
+         * @memberof chess.ServerPlayerStats
          * @static
          * @param {$protobuf.Reader|Uint8Array} reader Reader or buffer to decode from
-         * @returns {chess.ServerBulkCapture} ServerBulkCapture
+         * @returns {chess.ServerPlayerStats} ServerPlayerStats
          * @throws {Error} If the payload is not a reader or valid buffer
          * @throws {$protobuf.util.ProtocolError} If required fields are missing
          */
-        ServerBulkCapture.decodeDelimited = function decodeDelimited(reader) {
+        ServerPlayerStats.decodeDelimited = function decodeDelimited(reader) {
             if (!(reader instanceof $Reader))
                 reader = new $Reader(reader);
             return this.decode(reader, reader.uint32());
         };
 
         /**
-         * Verifies a ServerBulkCapture message.
+         * Verifies a ServerPlayerStats message.
          * @function verify
-         * @memberof chess.ServerBulkCapture
+         * @memberof chess.ServerPlayerStats
          * @static
          * @param {Object.<string,*>} message Plain object to verify
          * @returns {string|null} `null` if valid, otherwise the reason why it is not
          */
-        ServerBulkCapture.verify = function verify(message) {
+        ServerPlayerStats.verify = function verify(message) {
             if (typeof message !== "object" || message === null)
                 return "object expected";
-            if (message.seqnum != null && message.hasOwnProperty("seqnum"))
-                if (!$util.isInteger(message.seqnum) && !(message.seqnum && $util.isInteger(message.seqnum.low) && $util.isInteger(message.seqnum.high)))
-                    return "seqnum: integer|Long expected";
-            if (message.capturedIds != null && message.hasOwnProperty("capturedIds")) {
-                if (!Array.isArray(message.capturedIds))
-                    return "capturedIds: array expected";
-                for (let i = 0; i < message.capturedIds.length; ++i)
-                    if (!$util.isInteger(message.capturedIds[i]))
-                        return "capturedIds: integer[] expected";
+            if (message.moves != null && message.hasOwnProperty("moves"))
+                if (!$util.isInteger(message.moves))
+                    return "moves: integer expected";
+            if (message.captures != null && message.hasOwnProperty("captures"))
+                if (!$util.isInteger(message.captures))
+                    return "captures: integer expected";
+            if (message.capturesByType != null && message.hasOwnProperty("capturesByType")) {
+                if (!Array.isArray(message.capturesByType))
+                    return "capturesByType: array expected";
+                for (let i = 0; i < message.capturesByType.length; ++i) {
+                    let error = $root.chess.PieceTypeCount.verify(message.capturesByType[i]);
+                    if (error)
+                        return "capturesByType." + error;
+                }
             }
+            if (message.kingsCaptured != null && message.hasOwnProperty("kingsCaptured"))
+                if (!$util.isInteger(message.kingsCaptured))
+                    return "kingsCaptured: integer expected";
+            if (message.promotions != null && message.hasOwnProperty("promotions"))
+                if (!$util.isInteger(message.promotions))
+                    return "promotions: integer expected";
             return null;
         };
 
         /**
-         * Creates a ServerBulkCapture message from a plain object. Also converts values to their respective internal types.
+         * Creates a ServerPlayerStats message from a plain object. Also converts values to their respective internal types.
          * @function fromObject
-         * @memberof chess.ServerBulkCapture
+         * @memberof chess.ServerPlayerStats
          * @static
          * @param {Object.<string,*>} object Plain object
-         * @returns {chess.ServerBulkCapture} ServerBulkCapture
+         * @returns {chess.ServerPlayerStats} ServerPlayerStats
          */
-        ServerBulkCapture.fromObject = function fromObject(object) {
-            if (object instanceof $root.chess.ServerBulkCapture)
+        ServerPlayerStats.fromObject = function fromObject(object) {
+            if (object instanceof $root.chess.ServerPlayerStats)
                 return object;
-            let message = new $root.chess.ServerBulkCapture();
-            if (object.seqnum != null)
-                if ($util.Long)
-                    (message.seqnum = $util.Long.fromValue(object.seqnum)).unsigned = true;
-                else if (typeof object.seqnum === "string")
-                    message.seqnum = parseInt(object.seqnum, 10);
-                else if (typeof object.seqnum === "number")
-                    message.seqnum = object.seqnum;
-                else if (typeof object.seqnum === "object")
-                    message.seqnum = new $util.LongBits(object.seqnum.low >>> 0, object.seqnum.high >>> 0).toNumber(true);
-            if (object.capturedIds) {
-                if (!Array.isArray(object.capturedIds))
-                    throw TypeError(".chess.ServerBulkCapture.capturedIds: array expected");
-                message.capturedIds = [];
-                for (let i = 0; i < object.capturedIds.length; ++i)
-                    message.capturedIds[i] = object.capturedIds[i] >>> 0;
+            let message = new $root.chess.ServerPlayerStats();
+            if (object.moves != null)
+                message.moves = object.moves >>> 0;
+            if (object.captures != null)
+                message.captures = object.captures >>> 0;
+            if (object.capturesByType) {
+                if (!Array.isArray(object.capturesByType))
+                    throw TypeError(".chess.ServerPlayerStats.capturesByType: array expected");
+                message.capturesByType = [];
+                for (let i = 0; i < object.capturesByType.length; ++i) {
+                    if (typeof object.capturesByType[i] !== "object")
+                        throw TypeError(".chess.ServerPlayerStats.capturesByType: object expected");
+                    message.capturesByType[i] = $root.chess.PieceTypeCount.fromObject(object.capturesByType[i]);
+                }
             }
+            if (object.kingsCaptured != null)
+                message.kingsCaptured = object.kingsCaptured >>> 0;
+            if (object.promotions != null)
+                message.promotions = object.promotions >>> 0;
             return message;
         };
 
         /**
-         * Creates a plain object from a ServerBulkCapture message. Also converts values to other types if specified.
+         * Creates a plain object from a ServerPlayerStats message. Also converts values to other types if specified.
          * @function toObject
-         * @memberof chess.ServerBulkCapture
+         * @memberof chess.ServerPlayerStats
          * @static
-         * @param {chess.ServerBulkCapture} message ServerBulkCapture
+         * @param {chess.ServerPlayerStats} message ServerPlayerStats
          * @param {$protobuf.IConversionOptions} [options] Conversion options
          * @returns {Object.<string,*>} Plain object
          */
-        ServerBulkCapture.toObject = function toObject(message, options) {
+        ServerPlayerStats.toObject = function toObject(message, options) {
             if (!options)
                 options = {};
             let object = {};
             if (options.arrays || options.defaults)
-                object.capturedIds = [];
-            if (options.defaults)
-                if ($util.Long) {
-                    let long = new $util.Long(0, 0, true);
-                    object.seqnum = options.longs === String ? long.toString() : options.longs === Number ? long.toNumber() : long;
-                } else
-                    object.seqnum = options.longs === String ? "0" : 0;
-            if (message.seqnum != null && message.hasOwnProperty("seqnum"))
-                if (typeof message.seqnum === "number")
-                    object.seqnum = options.longs === String ? String(message.seqnum) : message.seqnum;
-                else
-                    object.seqnum = options.longs === String ? $util.Long.prototype.toString.call(message.seqnum) : options.longs === Number ? new $util.LongBits(message.seqnum.low >>> 0, message.seqnum.high >>> 0).toNumber(true) : message.seqnum;
-            if (message.capturedIds && message.capturedIds.length) {
-                object.capturedIds = [];
-                for (let j = 0; j < message.capturedIds.length; ++j)
-                    object.capturedIds[j] = message.capturedIds[j];
+                object.capturesByType = [];
+            if (options.defaults) {
+                object.moves = 0;
+                object.captures = 0;
+                object.kingsCaptured = 0;
+                object.promotions = 0;
+            }
+            if (message.moves != null && message.hasOwnProperty("moves"))
+                object.moves = message.moves;
+            if (message.captures != null && message.hasOwnProperty("captures"))
+                object.captures = message.captures;
+            if (message.capturesByType && message.capturesByType.length) {
+                object.capturesByType = [];
+                for (let j = 0; j < message.capturesByType.length; ++j)
+                    object.capturesByType[j] = $root.chess.PieceTypeCount.toObject(message.capturesByType[j], options);
             }
+            if (message.kingsCaptured != null && message.hasOwnProperty("kingsCaptured"))
+                object.kingsCaptured = message.kingsCaptured;
+            if (message.promotions != null && message.hasOwnProperty("promotions"))
+                object.promotions = message.promotions;
             return object;
         };
 
         /**
-         * Converts this ServerBulkCapture to JSON.
+         * Converts this ServerPlayerStats to JSON.
          * @function toJSON
-         * @memberof chess.ServerBulkCapture
+         * @memberof chess.ServerPlayerStats
          * @instance
          * @returns {Object.<string,*>} JSON object
          */
-        ServerBulkCapture.prototype.toJSON = function toJSON() {
+        ServerPlayerStats.prototype.toJSON = function toJSON() {
             return this.constructor.toObject(this, $protobuf.util.toJSONOptions);
         };
 
         /**
-         * Gets the default type url for ServerBulkCapture
+         * Gets the default type url for ServerPlayerStats
          * @function getTypeUrl
-         * @memberof chess.ServerBulkCapture
+         * @memberof chess.ServerPlayerStats
          * @static
          * @param {string} [typeUrlPrefix] your custom typeUrlPrefix(default "type.googleapis.com")
          * @returns {string} The default type url
          */
-        ServerBulkCapture.getTypeUrl = function getTypeUrl(typeUrlPrefix) {
+        ServerPlayerStats.getTypeUrl = function getTypeUrl(typeUrlPrefix) {
             if (typeUrlPrefix === undefined) {
                 typeUrlPrefix = "type.googleapis.com";
             }
-            return typeUrlPrefix + "/chess.ServerBulkCapture";
+            return typeUrlPrefix + "/chess.ServerPlayerStats";
         };
 
-        return ServerBulkCapture;
+        return ServerPlayerStats;
     })();
 
     chess.ServerMessage = (function() {
@@ -4680,6 +6735,10 @@ export const chess = $root.chess = (() => {
          * @property {chess.IServerPong|null} [pong] ServerMessage pong
          * @property {chess.IServerAdoption|null} [adoption] ServerMessage adoption
          * @property {chess.IServerBulkCapture|null} [bulkCapture] ServerMessage bulkCapture
+         * @property {chess.IServerResume|null} [resume] ServerMessage resume
+         * @property {chess.IServerPlayerStats|null} [playerStats] ServerMessage playerStats
+         * @property {chess.IServerSetPieces|null} [setPieces] ServerMessage setPieces
+         * @property {chess.IServerBoardOutcome|null} [boardOutcome] ServerMessage boardOutcome
          */
 
         /**
@@ -4761,17 +6820,49 @@ export const chess = $root.chess = (() => {
          */
         ServerMessage.prototype.bulkCapture = null;
 
+        /**
+         * ServerMessage resume.
+         * @member {chess.IServerResume|null|undefined} resume
+         * @memberof chess.ServerMessage
+         * @instance
+         */
+        ServerMessage.prototype.resume = null;
+
+        /**
+         * ServerMessage playerStats.
+         * @member {chess.IServerPlayerStats|null|undefined} playerStats
+         * @memberof chess.ServerMessage
+         * @instance
+         */
+        ServerMessage.prototype.playerStats = null;
+
+        /**
+         * ServerMessage setPieces.
+         * @member {chess.IServerSetPieces|null|undefined} setPieces
+         * @memberof chess.ServerMessage
+         * @instance
+         */
+        ServerMessage.prototype.setPieces = null;
+
+        /**
+         * ServerMessage boardOutcome.
+         * @member {chess.IServerBoardOutcome|null|undefined} boardOutcome
+         * @memberof chess.ServerMessage
+         * @instance
+         */
+        ServerMessage.prototype.boardOutcome = null;
+
         // OneOf field names bound to virtual getters and setters
         let $oneOfFields;
 
         /**
          * ServerMessage payload.
-         * @member {"initialState"|"snapshot"|"movesAndCaptures"|"validMove"|"invalidMove"|"pong"|"adoption"|"bulkCapture"|undefined} payload
+         * @member {"initialState"|"snapshot"|"movesAndCaptures"|"validMove"|"invalidMove"|"pong"|"adoption"|"bulkCapture"|"resume"|"playerStats"|"setPieces"|"boardOutcome"|undefined} payload
          * @memberof chess.ServerMessage
          * @instance
          */
         Object.defineProperty(ServerMessage.prototype, "payload", {
-            get: $util.oneOfGetter($oneOfFields = ["initialState", "snapshot", "movesAndCaptures", "validMove", "invalidMove", "pong", "adoption", "bulkCapture"]),
+            get: $util.oneOfGetter($oneOfFields = ["initialState", "snapshot", "movesAndCaptures", "validMove", "invalidMove", "pong", "adoption", "bulkCapture", "resume", "playerStats", "setPieces", "boardOutcome"]),
             set: $util.oneOfSetter($oneOfFields)
         });
 
@@ -4815,6 +6906,14 @@ export const chess = $root.chess = (() => {
                 $root.chess.ServerAdoption.encode(message.adoption, writer.uint32(/* id 7, wireType 2 =*/58).fork()).ldelim();
             if (message.bulkCapture != null && Object.hasOwnProperty.call(message, "bulkCapture"))
                 $root.chess.ServerBulkCapture.encode(message.bulkCapture, writer.uint32(/* id 8, wireType 2 =*/66).fork()).ldelim();
+            if (message.resume != null && Object.hasOwnProperty.call(message, "resume"))
+                $root.chess.ServerResume.encode(message.resume, writer.uint32(/* id 9, wireType 2 =*/74).fork()).ldelim();
+            if (message.playerStats != null && Object.hasOwnProperty.call(message, "playerStats"))
+                $root.chess.ServerPlayerStats.encode(message.playerStats, writer.uint32(/* id 10, wireType 2 =*/82).fork()).ldelim();
+            if (message.setPieces != null && Object.hasOwnProperty.call(message, "setPieces"))
+                $root.chess.ServerSetPieces.encode(message.setPieces, writer.uint32(/* id 11, wireType 2 =*/90).fork()).ldelim();
+            if (message.boardOutcome != null && Object.hasOwnProperty.call(message, "boardOutcome"))
+                $root.chess.ServerBoardOutcome.encode(message.boardOutcome, writer.uint32(/* id 12, wireType 2 =*/98).fork()).ldelim();
             return writer;
         };
 
@@ -4883,6 +6982,22 @@ export const chess = $root.chess = (() => {
                         message.bulkCapture = $root.chess.ServerBulkCapture.decode(reader, reader.uint32());
                         break;
                     }
+                case 9: {
+                        message.resume = $root.chess.ServerResume.decode(reader, reader.uint32());
+                        break;
+                    }
+                case 10: {
+                        message.playerStats = $root.chess.ServerPlayerStats.decode(reader, reader.uint32());
+                        break;
+                    }
+                case 11: {
+                        message.setPieces = $root.chess.ServerSetPieces.decode(reader, reader.uint32());
+                        break;
+                    }
+                case 12: {
+                        message.boardOutcome = $root.chess.ServerBoardOutcome.decode(reader, reader.uint32());
+                        break;
+                    }
                 default:
                     reader.skipType(tag & 7);
                     break;
@@ -4997,6 +7112,46 @@ export const chess = $root.chess = (() => {
                         return "bulkCapture." + error;
                 }
             }
+            if (message.resume != null && message.hasOwnProperty("resume")) {
+                if (properties.payload === 1)
+                    return "payload: multiple values";
+                properties.payload = 1;
+                {
+                    let error = $root.chess.ServerResume.verify(message.resume);
+                    if (error)
+                        return "resume." + error;
+                }
+            }
+            if (message.playerStats != null && message.hasOwnProperty("playerStats")) {
+                if (properties.payload === 1)
+                    return "payload: multiple values";
+                properties.payload = 1;
+                {
+                    let error = $root.chess.ServerPlayerStats.verify(message.playerStats);
+                    if (error)
+                        return "playerStats." + error;
+                }
+            }
+            if (message.setPieces != null && message.hasOwnProperty("setPieces")) {
+                if (properties.payload === 1)
+                    return "payload: multiple values";
+                properties.payload = 1;
+                {
+                    let error = $root.chess.ServerSetPieces.verify(message.setPieces);
+                    if (error)
+                        return "setPieces." + error;
+                }
+            }
+            if (message.boardOutcome != null && message.hasOwnProperty("boardOutcome")) {
+                if (properties.payload === 1)
+                    return "payload: multiple values";
+                properties.payload = 1;
+                {
+                    let error = $root.chess.ServerBoardOutcome.verify(message.boardOutcome);
+                    if (error)
+                        return "boardOutcome." + error;
+                }
+            }
             return null;
         };
 
@@ -5052,6 +7207,26 @@ export const chess = $root.chess = (() => {
                     throw TypeError(".chess.ServerMessage.bulkCapture: object expected");
                 message.bulkCapture = $root.chess.ServerBulkCapture.fromObject(object.bulkCapture);
             }
+            if (object.resume != null) {
+                if (typeof object.resume !== "object")
+                    throw TypeError(".chess.ServerMessage.resume: object expected");
+                message.resume = $root.chess.ServerResume.fromObject(object.resume);
+            }
+            if (object.playerStats != null) {
+                if (typeof object.playerStats !== "object")
+                    throw TypeError(".chess.ServerMessage.playerStats: object expected");
+                message.playerStats = $root.chess.ServerPlayerStats.fromObject(object.playerStats);
+            }
+            if (object.setPieces != null) {
+                if (typeof object.setPieces !== "object")
+                    throw TypeError(".chess.ServerMessage.setPieces: object expected");
+                message.setPieces = $root.chess.ServerSetPieces.fromObject(object.setPieces);
+            }
+            if (object.boardOutcome != null) {
+                if (typeof object.boardOutcome !== "object")
+                    throw TypeError(".chess.ServerMessage.boardOutcome: object expected");
+                message.boardOutcome = $root.chess.ServerBoardOutcome.fromObject(object.boardOutcome);
+            }
             return message;
         };
 
@@ -5108,6 +7283,26 @@ export const chess = $root.chess = (() => {
                 if (options.oneofs)
                     object.payload = "bulkCapture";
             }
+            if (message.resume != null && message.hasOwnProperty("resume")) {
+                object.resume = $root.chess.ServerResume.toObject(message.resume, options);
+                if (options.oneofs)
+                    object.payload = "resume";
+            }
+            if (message.playerStats != null && message.hasOwnProperty("playerStats")) {
+                object.playerStats = $root.chess.ServerPlayerStats.toObject(message.playerStats, options);
+                if (options.oneofs)
+                    object.payload = "playerStats";
+            }
+            if (message.setPieces != null && message.hasOwnProperty("setPieces")) {
+                object.setPieces = $root.chess.ServerSetPieces.toObject(message.setPieces, options);
+                if (options.oneofs)
+                    object.payload = "setPieces";
+            }
+            if (message.boardOutcome != null && message.hasOwnProperty("boardOutcome")) {
+                object.boardOutcome = $root.chess.ServerBoardOutcome.toObject(message.boardOutcome, options);
+                if (options.oneofs)
+                    object.payload = "boardOutcome";
+            }
             return object;
         };
 
@@ -94,33 +94,48 @@ export const TYPE_TO_NAME = {
   [chess.PieceType.PIECE_TYPE_QUEEN]: "queen",
   [chess.PieceType.PIECE_TYPE_KING]: "king",
   [chess.PieceType.PIECE_TYPE_PROMOTED_PAWN]: "promotedPawn",
+  [chess.PieceType.PIECE_TYPE_PROMOTED_KNIGHT]: "promotedKnight",
+  [chess.PieceType.PIECE_TYPE_PROMOTED_BISHOP]: "promotedBishop",
+  [chess.PieceType.PIECE_TYPE_PROMOTED_ROOK]: "promotedRook",
 };
 
+// promotedPawn is a pawn that promoted to a queen; the others are pawns that
+// underpromoted. They all move and look like the piece they promoted to.
+const PROMOTED_TO_NAME = {
+  promotedPawn: "queen",
+  promotedKnight: "knight",
+  promotedBishop: "bishop",
+  promotedRook: "rook",
+};
+
+export function isPromotedPieceType(pieceType) {
+  return TYPE_TO_NAME[pieceType] in PROMOTED_TO_NAME;
+}
+
+function baseNameForPieceType(pieceType) {
+  const name = TYPE_TO_NAME[pieceType];
+  return PROMOTED_TO_NAME[name] ?? name;
+}
+
 export const NAME_TO_TYPE = Object.fromEntries(
   Object.entries(TYPE_TO_NAME).map(([key, value]) => [value, key])
 );
 
 export function humanNameForPieceType({ pieceType }) {
   let name = TYPE_TO_NAME[pieceType];
-  if (name === "promotedPawn") {
+  if (isPromotedPieceType(pieceType)) {
     return "Pawn";
   }
   return name.charAt(0).toUpperCase() + name.slice(1);
 }
 
 export function frameImageForPieceType({ pieceType }) {
-  let name = TYPE_TO_NAME[pieceType];
-  if (name === "promotedPawn") {
-    name = "queen";
-  }
+  const name = baseNameForPieceType(pieceType);
   return `/pieces/frames-final/${name}.png`;
 }
 
 export function imageForPieceType({ pieceType, isWhite }) {
-  let name = TYPE_TO_NAME[pieceType];
-  if (name === "promotedPawn") {
-    name = "queen";
-  }
+  const name = baseNameForPieceType(pieceType);
   return `/pieces/${isWhite ? "white" : "black"}-processed/${name}.png`;
 }
 
@@ -134,6 +149,9 @@ const WHITE_PIECE_COLORS = {
   // queen: "#c4b5fd",
   queen: whiteQueenColor,
   promotedPawn: whiteQueenColor,
+  promotedKnight: defaultWhiteColor,
+  promotedBishop: defaultWhiteColor,
+  promotedRook: defaultWhiteColor,
   // king: "#FDE047",
   king: "#7dd3fc",
   knight: defaultWhiteColor,
@@ -148,6 +166,9 @@ const BLACK_PIECE_COLORS = {
   // queen: "#5B21B6",
   queen: blackQueenColor,
   promotedPawn: blackQueenColor,
+  promotedKnight: defaultBlackColor,
+  promotedBishop: defaultBlackColor,
+  promotedRook: defaultBlackColor,
   // king: "#CA8A04",
   king: "#2dd4bf",
   knight: defaultBlackColor,
@@ -564,7 +585,7 @@ const DEBUG_LIE_ABOUT_MOVEABLE_SQUARES = false;
 export function getMoveableSquares(piece, pieces) {
   const squares = [];
   const pieceType = piece.type;
-  const name = TYPE_TO_NAME[pieceType];
+  const name = baseNameForPieceType(pieceType);
 
   if (DEBUG_LIE_ABOUT_MOVEABLE_SQUARES) {
     LieAboutMoveableSquaresAndJustGive2By2Region(piece, squares, pieces);
@@ -583,7 +604,6 @@ export function getMoveableSquares(piece, pieces) {
         addMoveableSquaresForRook({ piece, pieces, squares });
         break;
       case "queen":
-      case "promotedPawn":
         addMoveableSquaresForBishop({ piece, pieces, squares });
         addMoveableSquaresForRook({ piece, pieces, squares });
         break;
@@ -637,22 +657,35 @@ export function storeColorPref({ playingWhite }) {
   localStorage.setItem("colorPref", colorPref);
 }
 
+// The server signs this and hands it out with our initial state; passing it
+// back keeps us the same player (and the same color) across reloads
+export function getPlayerToken() {
+  return localStorage.getItem("playerToken");
+}
+
+export function storePlayerToken({ playerToken }) {
+  if (playerToken) {
+    localStorage.setItem("playerToken", playerToken);
+  }
+}
+
 export function computeInitialArguments() {
   const url = new URL(window.location.href);
   const hash = url.hash.slice(1);
   const colorPref = getColorPref();
+  const playerToken = getPlayerToken();
   if (!hash || hash === "") {
-    return { x: null, y: null, colorPref };
+    return { x: null, y: null, colorPref, playerToken };
   }
   try {
     let [x, y] = hash.split(",").map(Number);
     if (isNaN(x) || isNaN(y)) {
-      return { x: null, y: null, colorPref };
+      return { x: null, y: null, colorPref, playerToken };
     }
     x = clamp(x, 0, 7999);
     y = clamp(y, 0, 7999);
-    return { x, y, colorPref };
+    return { x, y, colorPref, playerToken };
   } catch (e) {
-    return { x: null, y: null, colorPref };
+    return { x: null, y: null, colorPref, playerToken };
   }
 }
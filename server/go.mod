@@ -7,7 +7,9 @@ require (
 	github.com/gorilla/websocket v1.5.3
 	github.com/json-iterator/go v1.1.12
 	github.com/klauspost/compress v1.18.0
+	github.com/puzpuzpuz/xsync/v4 v4.0.0
 	github.com/rs/zerolog v1.34.0
+	golang.org/x/time v0.11.0
 	google.golang.org/protobuf v1.36.5
 )
 
@@ -17,7 +19,5 @@ require (
 	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/puzpuzpuz/xsync v1.5.2 // indirect
-	github.com/puzpuzpuz/xsync/v4 v4.0.0 // indirect
 	golang.org/x/sys v0.12.0 // indirect
-	golang.org/x/time v0.11.0 // indirect
 )
@@ -80,27 +80,50 @@ const (
 	PieceType_PIECE_TYPE_QUEEN         PieceType = 4
 	PieceType_PIECE_TYPE_KING          PieceType = 5
 	PieceType_PIECE_TYPE_PROMOTED_PAWN PieceType = 6
+	// Underpromotions. They move like the piece they're named for but
+	// remember that they used to be pawns.
+	PieceType_PIECE_TYPE_PROMOTED_KNIGHT PieceType = 7
+	PieceType_PIECE_TYPE_PROMOTED_BISHOP PieceType = 8
+	PieceType_PIECE_TYPE_PROMOTED_ROOK   PieceType = 9
+	// Fairy pieces, for special events. An archbishop moves like a bishop
+	// or a knight, a chancellor like a rook or a knight, and an amazon like
+	// a queen or a knight.
+	PieceType_PIECE_TYPE_ARCHBISHOP PieceType = 10
+	PieceType_PIECE_TYPE_CHANCELLOR PieceType = 11
+	PieceType_PIECE_TYPE_AMAZON     PieceType = 12
 )
 
 // Enum value maps for PieceType.
 var (
 	PieceType_name = map[int32]string{
-		0: "PIECE_TYPE_PAWN",
-		1: "PIECE_TYPE_KNIGHT",
-		2: "PIECE_TYPE_BISHOP",
-		3: "PIECE_TYPE_ROOK",
-		4: "PIECE_TYPE_QUEEN",
-		5: "PIECE_TYPE_KING",
-		6: "PIECE_TYPE_PROMOTED_PAWN",
+		0:  "PIECE_TYPE_PAWN",
+		1:  "PIECE_TYPE_KNIGHT",
+		2:  "PIECE_TYPE_BISHOP",
+		3:  "PIECE_TYPE_ROOK",
+		4:  "PIECE_TYPE_QUEEN",
+		5:  "PIECE_TYPE_KING",
+		6:  "PIECE_TYPE_PROMOTED_PAWN",
+		7:  "PIECE_TYPE_PROMOTED_KNIGHT",
+		8:  "PIECE_TYPE_PROMOTED_BISHOP",
+		9:  "PIECE_TYPE_PROMOTED_ROOK",
+		10: "PIECE_TYPE_ARCHBISHOP",
+		11: "PIECE_TYPE_CHANCELLOR",
+		12: "PIECE_TYPE_AMAZON",
 	}
 	PieceType_value = map[string]int32{
-		"PIECE_TYPE_PAWN":          0,
-		"PIECE_TYPE_KNIGHT":        1,
-		"PIECE_TYPE_BISHOP":        2,
-		"PIECE_TYPE_ROOK":          3,
-		"PIECE_TYPE_QUEEN":         4,
-		"PIECE_TYPE_KING":          5,
-		"PIECE_TYPE_PROMOTED_PAWN": 6,
+		"PIECE_TYPE_PAWN":            0,
+		"PIECE_TYPE_KNIGHT":          1,
+		"PIECE_TYPE_BISHOP":          2,
+		"PIECE_TYPE_ROOK":            3,
+		"PIECE_TYPE_QUEEN":           4,
+		"PIECE_TYPE_KING":            5,
+		"PIECE_TYPE_PROMOTED_PAWN":   6,
+		"PIECE_TYPE_PROMOTED_KNIGHT": 7,
+		"PIECE_TYPE_PROMOTED_BISHOP": 8,
+		"PIECE_TYPE_PROMOTED_ROOK":   9,
+		"PIECE_TYPE_ARCHBISHOP":      10,
+		"PIECE_TYPE_CHANCELLOR":      11,
+		"PIECE_TYPE_AMAZON":          12,
 	}
 )
 
@@ -131,6 +154,55 @@ func (PieceType) EnumDescriptor() ([]byte, []int) {
 	return file_chess_proto_rawDescGZIP(), []int{1}
 }
 
+type BoardOutcome int32
+
+const (
+	BoardOutcome_BOARD_OUTCOME_NONE      BoardOutcome = 0
+	BoardOutcome_BOARD_OUTCOME_CHECKMATE BoardOutcome = 1
+	BoardOutcome_BOARD_OUTCOME_STALEMATE BoardOutcome = 2
+)
+
+// Enum value maps for BoardOutcome.
+var (
+	BoardOutcome_name = map[int32]string{
+		0: "BOARD_OUTCOME_NONE",
+		1: "BOARD_OUTCOME_CHECKMATE",
+		2: "BOARD_OUTCOME_STALEMATE",
+	}
+	BoardOutcome_value = map[string]int32{
+		"BOARD_OUTCOME_NONE":      0,
+		"BOARD_OUTCOME_CHECKMATE": 1,
+		"BOARD_OUTCOME_STALEMATE": 2,
+	}
+)
+
+func (x BoardOutcome) Enum() *BoardOutcome {
+	p := new(BoardOutcome)
+	*p = x
+	return p
+}
+
+func (x BoardOutcome) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (BoardOutcome) Descriptor() protoreflect.EnumDescriptor {
+	return file_chess_proto_enumTypes[2].Descriptor()
+}
+
+func (BoardOutcome) Type() protoreflect.EnumType {
+	return &file_chess_proto_enumTypes[2]
+}
+
+func (x BoardOutcome) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use BoardOutcome.Descriptor instead.
+func (BoardOutcome) EnumDescriptor() ([]byte, []int) {
+	return file_chess_proto_rawDescGZIP(), []int{2}
+}
+
 type ClientPing struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -167,6 +239,42 @@ func (*ClientPing) Descriptor() ([]byte, []int) {
 	return file_chess_proto_rawDescGZIP(), []int{0}
 }
 
+type ClientGetPlayerStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientGetPlayerStats) Reset() {
+	*x = ClientGetPlayerStats{}
+	mi := &file_chess_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientGetPlayerStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientGetPlayerStats) ProtoMessage() {}
+
+func (x *ClientGetPlayerStats) ProtoReflect() protoreflect.Message {
+	mi := &file_chess_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientGetPlayerStats.ProtoReflect.Descriptor instead.
+func (*ClientGetPlayerStats) Descriptor() ([]byte, []int) {
+	return file_chess_proto_rawDescGZIP(), []int{1}
+}
+
 type ClientSubscribe struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	CenterX       uint32                 `protobuf:"varint,1,opt,name=centerX,proto3" json:"centerX,omitempty"`
@@ -177,7 +285,7 @@ type ClientSubscribe struct {
 
 func (x *ClientSubscribe) Reset() {
 	*x = ClientSubscribe{}
-	mi := &file_chess_proto_msgTypes[1]
+	mi := &file_chess_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -189,7 +297,7 @@ func (x *ClientSubscribe) String() string {
 func (*ClientSubscribe) ProtoMessage() {}
 
 func (x *ClientSubscribe) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[1]
+	mi := &file_chess_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -202,7 +310,7 @@ func (x *ClientSubscribe) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ClientSubscribe.ProtoReflect.Descriptor instead.
 func (*ClientSubscribe) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{1}
+	return file_chess_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *ClientSubscribe) GetCenterX() uint32 {
@@ -220,21 +328,25 @@ func (x *ClientSubscribe) GetCenterY() uint32 {
 }
 
 type ClientMove struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	PieceId       uint32                 `protobuf:"varint,1,opt,name=pieceId,proto3" json:"pieceId,omitempty"`
-	FromX         uint32                 `protobuf:"varint,2,opt,name=fromX,proto3" json:"fromX,omitempty"`
-	FromY         uint32                 `protobuf:"varint,3,opt,name=fromY,proto3" json:"fromY,omitempty"`
-	ToX           uint32                 `protobuf:"varint,4,opt,name=toX,proto3" json:"toX,omitempty"`
-	ToY           uint32                 `protobuf:"varint,5,opt,name=toY,proto3" json:"toY,omitempty"`
-	MoveType      MoveType               `protobuf:"varint,6,opt,name=moveType,proto3,enum=chess.MoveType" json:"moveType,omitempty"`
-	MoveToken     uint32                 `protobuf:"varint,7,opt,name=moveToken,proto3" json:"moveToken,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	PieceId   uint32                 `protobuf:"varint,1,opt,name=pieceId,proto3" json:"pieceId,omitempty"`
+	FromX     uint32                 `protobuf:"varint,2,opt,name=fromX,proto3" json:"fromX,omitempty"`
+	FromY     uint32                 `protobuf:"varint,3,opt,name=fromY,proto3" json:"fromY,omitempty"`
+	ToX       uint32                 `protobuf:"varint,4,opt,name=toX,proto3" json:"toX,omitempty"`
+	ToY       uint32                 `protobuf:"varint,5,opt,name=toY,proto3" json:"toY,omitempty"`
+	MoveType  MoveType               `protobuf:"varint,6,opt,name=moveType,proto3,enum=chess.MoveType" json:"moveType,omitempty"`
+	MoveToken uint32                 `protobuf:"varint,7,opt,name=moveToken,proto3" json:"moveToken,omitempty"`
+	// What a pawn reaching the far edge becomes: KNIGHT, BISHOP, ROOK or
+	// QUEEN. Leave it unset (PAWN) for a queen. Setting it on any other
+	// move makes the move invalid.
+	Promotion     PieceType `protobuf:"varint,8,opt,name=promotion,proto3,enum=chess.PieceType" json:"promotion,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ClientMove) Reset() {
 	*x = ClientMove{}
-	mi := &file_chess_proto_msgTypes[2]
+	mi := &file_chess_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -246,7 +358,7 @@ func (x *ClientMove) String() string {
 func (*ClientMove) ProtoMessage() {}
 
 func (x *ClientMove) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[2]
+	mi := &file_chess_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -259,7 +371,7 @@ func (x *ClientMove) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ClientMove.ProtoReflect.Descriptor instead.
 func (*ClientMove) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{2}
+	return file_chess_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *ClientMove) GetPieceId() uint32 {
@@ -311,6 +423,13 @@ func (x *ClientMove) GetMoveToken() uint32 {
 	return 0
 }
 
+func (x *ClientMove) GetPromotion() PieceType {
+	if x != nil {
+		return x.Promotion
+	}
+	return PieceType_PIECE_TYPE_PAWN
+}
+
 type ClientMessage struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Payload:
@@ -318,6 +437,7 @@ type ClientMessage struct {
 	//	*ClientMessage_Ping
 	//	*ClientMessage_Subscribe
 	//	*ClientMessage_Move
+	//	*ClientMessage_GetPlayerStats
 	Payload       isClientMessage_Payload `protobuf_oneof:"payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -325,7 +445,7 @@ type ClientMessage struct {
 
 func (x *ClientMessage) Reset() {
 	*x = ClientMessage{}
-	mi := &file_chess_proto_msgTypes[3]
+	mi := &file_chess_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -337,7 +457,7 @@ func (x *ClientMessage) String() string {
 func (*ClientMessage) ProtoMessage() {}
 
 func (x *ClientMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[3]
+	mi := &file_chess_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -350,7 +470,7 @@ func (x *ClientMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ClientMessage.ProtoReflect.Descriptor instead.
 func (*ClientMessage) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{3}
+	return file_chess_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *ClientMessage) GetPayload() isClientMessage_Payload {
@@ -387,6 +507,15 @@ func (x *ClientMessage) GetMove() *ClientMove {
 	return nil
 }
 
+func (x *ClientMessage) GetGetPlayerStats() *ClientGetPlayerStats {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientMessage_GetPlayerStats); ok {
+			return x.GetPlayerStats
+		}
+	}
+	return nil
+}
+
 type isClientMessage_Payload interface {
 	isClientMessage_Payload()
 }
@@ -403,12 +532,18 @@ type ClientMessage_Move struct {
 	Move *ClientMove `protobuf:"bytes,3,opt,name=move,proto3,oneof"`
 }
 
+type ClientMessage_GetPlayerStats struct {
+	GetPlayerStats *ClientGetPlayerStats `protobuf:"bytes,4,opt,name=getPlayerStats,proto3,oneof"`
+}
+
 func (*ClientMessage_Ping) isClientMessage_Payload() {}
 
 func (*ClientMessage_Subscribe) isClientMessage_Payload() {}
 
 func (*ClientMessage_Move) isClientMessage_Payload() {}
 
+func (*ClientMessage_GetPlayerStats) isClientMessage_Payload() {}
+
 type ServerValidMove struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
 	AsOfSeqnum      uint64                 `protobuf:"varint,1,opt,name=asOfSeqnum,proto3" json:"asOfSeqnum,omitempty"`
@@ -420,7 +555,7 @@ type ServerValidMove struct {
 
 func (x *ServerValidMove) Reset() {
 	*x = ServerValidMove{}
-	mi := &file_chess_proto_msgTypes[4]
+	mi := &file_chess_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -432,7 +567,7 @@ func (x *ServerValidMove) String() string {
 func (*ServerValidMove) ProtoMessage() {}
 
 func (x *ServerValidMove) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[4]
+	mi := &file_chess_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -445,7 +580,7 @@ func (x *ServerValidMove) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerValidMove.ProtoReflect.Descriptor instead.
 func (*ServerValidMove) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{4}
+	return file_chess_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *ServerValidMove) GetAsOfSeqnum() uint64 {
@@ -478,7 +613,7 @@ type ServerInvalidMove struct {
 
 func (x *ServerInvalidMove) Reset() {
 	*x = ServerInvalidMove{}
-	mi := &file_chess_proto_msgTypes[5]
+	mi := &file_chess_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -490,7 +625,7 @@ func (x *ServerInvalidMove) String() string {
 func (*ServerInvalidMove) ProtoMessage() {}
 
 func (x *ServerInvalidMove) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[5]
+	mi := &file_chess_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -503,7 +638,7 @@ func (x *ServerInvalidMove) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerInvalidMove.ProtoReflect.Descriptor instead.
 func (*ServerInvalidMove) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{5}
+	return file_chess_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *ServerInvalidMove) GetMoveToken() uint32 {
@@ -521,7 +656,7 @@ type ServerPong struct {
 
 func (x *ServerPong) Reset() {
 	*x = ServerPong{}
-	mi := &file_chess_proto_msgTypes[6]
+	mi := &file_chess_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -533,7 +668,7 @@ func (x *ServerPong) String() string {
 func (*ServerPong) ProtoMessage() {}
 
 func (x *ServerPong) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[6]
+	mi := &file_chess_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -546,7 +681,7 @@ func (x *ServerPong) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerPong.ProtoReflect.Descriptor instead.
 func (*ServerPong) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{6}
+	return file_chess_proto_rawDescGZIP(), []int{7}
 }
 
 type PieceCapture struct {
@@ -559,7 +694,7 @@ type PieceCapture struct {
 
 func (x *PieceCapture) Reset() {
 	*x = PieceCapture{}
-	mi := &file_chess_proto_msgTypes[7]
+	mi := &file_chess_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -571,7 +706,7 @@ func (x *PieceCapture) String() string {
 func (*PieceCapture) ProtoMessage() {}
 
 func (x *PieceCapture) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[7]
+	mi := &file_chess_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -584,7 +719,7 @@ func (x *PieceCapture) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PieceCapture.ProtoReflect.Descriptor instead.
 func (*PieceCapture) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{7}
+	return file_chess_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *PieceCapture) GetCapturedPieceId() uint32 {
@@ -622,7 +757,7 @@ type PieceDataShared struct {
 
 func (x *PieceDataShared) Reset() {
 	*x = PieceDataShared{}
-	mi := &file_chess_proto_msgTypes[8]
+	mi := &file_chess_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -634,7 +769,7 @@ func (x *PieceDataShared) String() string {
 func (*PieceDataShared) ProtoMessage() {}
 
 func (x *PieceDataShared) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[8]
+	mi := &file_chess_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -647,7 +782,7 @@ func (x *PieceDataShared) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PieceDataShared.ProtoReflect.Descriptor instead.
 func (*PieceDataShared) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{8}
+	return file_chess_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *PieceDataShared) GetId() uint32 {
@@ -765,7 +900,7 @@ type PieceDataForMove struct {
 
 func (x *PieceDataForMove) Reset() {
 	*x = PieceDataForMove{}
-	mi := &file_chess_proto_msgTypes[9]
+	mi := &file_chess_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -777,7 +912,7 @@ func (x *PieceDataForMove) String() string {
 func (*PieceDataForMove) ProtoMessage() {}
 
 func (x *PieceDataForMove) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[9]
+	mi := &file_chess_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -790,7 +925,7 @@ func (x *PieceDataForMove) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PieceDataForMove.ProtoReflect.Descriptor instead.
 func (*PieceDataForMove) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{9}
+	return file_chess_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *PieceDataForMove) GetX() uint32 {
@@ -835,7 +970,7 @@ type PieceDataForSnapshot struct {
 
 func (x *PieceDataForSnapshot) Reset() {
 	*x = PieceDataForSnapshot{}
-	mi := &file_chess_proto_msgTypes[10]
+	mi := &file_chess_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -847,7 +982,7 @@ func (x *PieceDataForSnapshot) String() string {
 func (*PieceDataForSnapshot) ProtoMessage() {}
 
 func (x *PieceDataForSnapshot) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[10]
+	mi := &file_chess_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -860,7 +995,7 @@ func (x *PieceDataForSnapshot) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PieceDataForSnapshot.ProtoReflect.Descriptor instead.
 func (*PieceDataForSnapshot) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{10}
+	return file_chess_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *PieceDataForSnapshot) GetDx() int32 {
@@ -894,7 +1029,7 @@ type ServerMovesAndCaptures struct {
 
 func (x *ServerMovesAndCaptures) Reset() {
 	*x = ServerMovesAndCaptures{}
-	mi := &file_chess_proto_msgTypes[11]
+	mi := &file_chess_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -906,7 +1041,7 @@ func (x *ServerMovesAndCaptures) String() string {
 func (*ServerMovesAndCaptures) ProtoMessage() {}
 
 func (x *ServerMovesAndCaptures) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[11]
+	mi := &file_chess_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -919,7 +1054,7 @@ func (x *ServerMovesAndCaptures) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerMovesAndCaptures.ProtoReflect.Descriptor instead.
 func (*ServerMovesAndCaptures) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{11}
+	return file_chess_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *ServerMovesAndCaptures) GetMoves() []*PieceDataForMove {
@@ -948,7 +1083,7 @@ type ServerStateSnapshot struct {
 
 func (x *ServerStateSnapshot) Reset() {
 	*x = ServerStateSnapshot{}
-	mi := &file_chess_proto_msgTypes[12]
+	mi := &file_chess_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -960,7 +1095,7 @@ func (x *ServerStateSnapshot) String() string {
 func (*ServerStateSnapshot) ProtoMessage() {}
 
 func (x *ServerStateSnapshot) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[12]
+	mi := &file_chess_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -973,7 +1108,7 @@ func (x *ServerStateSnapshot) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerStateSnapshot.ProtoReflect.Descriptor instead.
 func (*ServerStateSnapshot) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{12}
+	return file_chess_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *ServerStateSnapshot) GetXCoord() uint32 {
@@ -1014,7 +1149,7 @@ type Position struct {
 
 func (x *Position) Reset() {
 	*x = Position{}
-	mi := &file_chess_proto_msgTypes[13]
+	mi := &file_chess_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1026,7 +1161,7 @@ func (x *Position) String() string {
 func (*Position) ProtoMessage() {}
 
 func (x *Position) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[13]
+	mi := &file_chess_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1039,7 +1174,7 @@ func (x *Position) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Position.ProtoReflect.Descriptor instead.
 func (*Position) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{13}
+	return file_chess_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *Position) GetX() uint32 {
@@ -1057,17 +1192,23 @@ func (x *Position) GetY() uint32 {
 }
 
 type ServerInitialState struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	PlayingWhite  bool                   `protobuf:"varint,1,opt,name=playingWhite,proto3" json:"playingWhite,omitempty"`
-	Position      *Position              `protobuf:"bytes,2,opt,name=position,proto3" json:"position,omitempty"`
-	Snapshot      *ServerStateSnapshot   `protobuf:"bytes,3,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	PlayingWhite bool                   `protobuf:"varint,1,opt,name=playingWhite,proto3" json:"playingWhite,omitempty"`
+	Position     *Position              `protobuf:"bytes,2,opt,name=position,proto3" json:"position,omitempty"`
+	Snapshot     *ServerStateSnapshot   `protobuf:"bytes,3,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
+	// Pass this back (along with the last seqnum you saw) when you reconnect
+	// to keep your color and maybe skip the snapshot
+	SessionToken string `protobuf:"bytes,4,opt,name=sessionToken,proto3" json:"sessionToken,omitempty"`
+	// Signed; pass this back whenever you connect to stay the same player
+	// (and keep your color)
+	PlayerToken   string `protobuf:"bytes,5,opt,name=playerToken,proto3" json:"playerToken,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ServerInitialState) Reset() {
 	*x = ServerInitialState{}
-	mi := &file_chess_proto_msgTypes[14]
+	mi := &file_chess_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1079,7 +1220,7 @@ func (x *ServerInitialState) String() string {
 func (*ServerInitialState) ProtoMessage() {}
 
 func (x *ServerInitialState) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[14]
+	mi := &file_chess_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1092,7 +1233,7 @@ func (x *ServerInitialState) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerInitialState.ProtoReflect.Descriptor instead.
 func (*ServerInitialState) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{14}
+	return file_chess_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *ServerInitialState) GetPlayingWhite() bool {
@@ -1116,6 +1257,99 @@ func (x *ServerInitialState) GetSnapshot() *ServerStateSnapshot {
 	return nil
 }
 
+func (x *ServerInitialState) GetSessionToken() string {
+	if x != nil {
+		return x.SessionToken
+	}
+	return ""
+}
+
+func (x *ServerInitialState) GetPlayerToken() string {
+	if x != nil {
+		return x.PlayerToken
+	}
+	return ""
+}
+
+// Sent instead of ServerInitialState when a reconnecting client's gap is
+// recent enough for us to replay. moves and captures are everything around
+// position after the seqnum that the client gave us.
+type ServerResume struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PlayingWhite  bool                   `protobuf:"varint,1,opt,name=playingWhite,proto3" json:"playingWhite,omitempty"`
+	Position      *Position              `protobuf:"bytes,2,opt,name=position,proto3" json:"position,omitempty"`
+	SessionToken  string                 `protobuf:"bytes,3,opt,name=sessionToken,proto3" json:"sessionToken,omitempty"`
+	Moves         []*PieceDataForMove    `protobuf:"bytes,4,rep,name=moves,proto3" json:"moves,omitempty"`
+	Captures      []*PieceCapture        `protobuf:"bytes,5,rep,name=captures,proto3" json:"captures,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerResume) Reset() {
+	*x = ServerResume{}
+	mi := &file_chess_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerResume) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerResume) ProtoMessage() {}
+
+func (x *ServerResume) ProtoReflect() protoreflect.Message {
+	mi := &file_chess_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerResume.ProtoReflect.Descriptor instead.
+func (*ServerResume) Descriptor() ([]byte, []int) {
+	return file_chess_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ServerResume) GetPlayingWhite() bool {
+	if x != nil {
+		return x.PlayingWhite
+	}
+	return false
+}
+
+func (x *ServerResume) GetPosition() *Position {
+	if x != nil {
+		return x.Position
+	}
+	return nil
+}
+
+func (x *ServerResume) GetSessionToken() string {
+	if x != nil {
+		return x.SessionToken
+	}
+	return ""
+}
+
+func (x *ServerResume) GetMoves() []*PieceDataForMove {
+	if x != nil {
+		return x.Moves
+	}
+	return nil
+}
+
+func (x *ServerResume) GetCaptures() []*PieceCapture {
+	if x != nil {
+		return x.Captures
+	}
+	return nil
+}
+
 type ServerAdoption struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	AdoptedIds    []uint32               `protobuf:"varint,1,rep,packed,name=adoptedIds,proto3" json:"adoptedIds,omitempty"`
@@ -1125,7 +1359,7 @@ type ServerAdoption struct {
 
 func (x *ServerAdoption) Reset() {
 	*x = ServerAdoption{}
-	mi := &file_chess_proto_msgTypes[15]
+	mi := &file_chess_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1137,7 +1371,7 @@ func (x *ServerAdoption) String() string {
 func (*ServerAdoption) ProtoMessage() {}
 
 func (x *ServerAdoption) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[15]
+	mi := &file_chess_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1150,7 +1384,7 @@ func (x *ServerAdoption) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerAdoption.ProtoReflect.Descriptor instead.
 func (*ServerAdoption) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{15}
+	return file_chess_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *ServerAdoption) GetAdoptedIds() []uint32 {
@@ -1170,7 +1404,7 @@ type ServerBulkCapture struct {
 
 func (x *ServerBulkCapture) Reset() {
 	*x = ServerBulkCapture{}
-	mi := &file_chess_proto_msgTypes[16]
+	mi := &file_chess_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1182,7 +1416,7 @@ func (x *ServerBulkCapture) String() string {
 func (*ServerBulkCapture) ProtoMessage() {}
 
 func (x *ServerBulkCapture) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[16]
+	mi := &file_chess_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1195,7 +1429,7 @@ func (x *ServerBulkCapture) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerBulkCapture.ProtoReflect.Descriptor instead.
 func (*ServerBulkCapture) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{16}
+	return file_chess_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *ServerBulkCapture) GetSeqnum() uint64 {
@@ -1212,6 +1446,276 @@ func (x *ServerBulkCapture) GetCapturedIds() []uint32 {
 	return nil
 }
 
+// Squares that an admin set or cleared. Pieces in placed replace whatever
+// was on their squares; removedIds were taken off the board.
+type ServerSetPieces struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seqnum        uint64                 `protobuf:"varint,1,opt,name=seqnum,proto3" json:"seqnum,omitempty"`
+	Placed        []*PieceDataForMove    `protobuf:"bytes,2,rep,name=placed,proto3" json:"placed,omitempty"`
+	RemovedIds    []uint32               `protobuf:"varint,3,rep,packed,name=removedIds,proto3" json:"removedIds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerSetPieces) Reset() {
+	*x = ServerSetPieces{}
+	mi := &file_chess_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerSetPieces) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerSetPieces) ProtoMessage() {}
+
+func (x *ServerSetPieces) ProtoReflect() protoreflect.Message {
+	mi := &file_chess_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerSetPieces.ProtoReflect.Descriptor instead.
+func (*ServerSetPieces) Descriptor() ([]byte, []int) {
+	return file_chess_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ServerSetPieces) GetSeqnum() uint64 {
+	if x != nil {
+		return x.Seqnum
+	}
+	return 0
+}
+
+func (x *ServerSetPieces) GetPlaced() []*PieceDataForMove {
+	if x != nil {
+		return x.Placed
+	}
+	return nil
+}
+
+func (x *ServerSetPieces) GetRemovedIds() []uint32 {
+	if x != nil {
+		return x.RemovedIds
+	}
+	return nil
+}
+
+// Only with classical rules. Sent when a change to the board or the rules
+// changes whether either side is checkmated or stalemated on the 8x8 board
+// at (boardX, boardY), counted in boards rather than squares.
+type ServerBoardOutcome struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seqnum        uint64                 `protobuf:"varint,1,opt,name=seqnum,proto3" json:"seqnum,omitempty"`
+	BoardX        uint32                 `protobuf:"varint,2,opt,name=boardX,proto3" json:"boardX,omitempty"`
+	BoardY        uint32                 `protobuf:"varint,3,opt,name=boardY,proto3" json:"boardY,omitempty"`
+	White         BoardOutcome           `protobuf:"varint,4,opt,name=white,proto3,enum=chess.BoardOutcome" json:"white,omitempty"`
+	Black         BoardOutcome           `protobuf:"varint,5,opt,name=black,proto3,enum=chess.BoardOutcome" json:"black,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerBoardOutcome) Reset() {
+	*x = ServerBoardOutcome{}
+	mi := &file_chess_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerBoardOutcome) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerBoardOutcome) ProtoMessage() {}
+
+func (x *ServerBoardOutcome) ProtoReflect() protoreflect.Message {
+	mi := &file_chess_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerBoardOutcome.ProtoReflect.Descriptor instead.
+func (*ServerBoardOutcome) Descriptor() ([]byte, []int) {
+	return file_chess_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ServerBoardOutcome) GetSeqnum() uint64 {
+	if x != nil {
+		return x.Seqnum
+	}
+	return 0
+}
+
+func (x *ServerBoardOutcome) GetBoardX() uint32 {
+	if x != nil {
+		return x.BoardX
+	}
+	return 0
+}
+
+func (x *ServerBoardOutcome) GetBoardY() uint32 {
+	if x != nil {
+		return x.BoardY
+	}
+	return 0
+}
+
+func (x *ServerBoardOutcome) GetWhite() BoardOutcome {
+	if x != nil {
+		return x.White
+	}
+	return BoardOutcome_BOARD_OUTCOME_NONE
+}
+
+func (x *ServerBoardOutcome) GetBlack() BoardOutcome {
+	if x != nil {
+		return x.Black
+	}
+	return BoardOutcome_BOARD_OUTCOME_NONE
+}
+
+type PieceTypeCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          PieceType              `protobuf:"varint,1,opt,name=type,proto3,enum=chess.PieceType" json:"type,omitempty"`
+	Count         uint32                 `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PieceTypeCount) Reset() {
+	*x = PieceTypeCount{}
+	mi := &file_chess_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PieceTypeCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PieceTypeCount) ProtoMessage() {}
+
+func (x *PieceTypeCount) ProtoReflect() protoreflect.Message {
+	mi := &file_chess_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PieceTypeCount.ProtoReflect.Descriptor instead.
+func (*PieceTypeCount) Descriptor() ([]byte, []int) {
+	return file_chess_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *PieceTypeCount) GetType() PieceType {
+	if x != nil {
+		return x.Type
+	}
+	return PieceType_PIECE_TYPE_PAWN
+}
+
+func (x *PieceTypeCount) GetCount() uint32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// Your own stats. Sent after your initial state and whenever you ask.
+type ServerPlayerStats struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Moves          uint32                 `protobuf:"varint,1,opt,name=moves,proto3" json:"moves,omitempty"`
+	Captures       uint32                 `protobuf:"varint,2,opt,name=captures,proto3" json:"captures,omitempty"`
+	CapturesByType []*PieceTypeCount      `protobuf:"bytes,3,rep,name=capturesByType,proto3" json:"capturesByType,omitempty"`
+	KingsCaptured  uint32                 `protobuf:"varint,4,opt,name=kingsCaptured,proto3" json:"kingsCaptured,omitempty"`
+	Promotions     uint32                 `protobuf:"varint,5,opt,name=promotions,proto3" json:"promotions,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ServerPlayerStats) Reset() {
+	*x = ServerPlayerStats{}
+	mi := &file_chess_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPlayerStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPlayerStats) ProtoMessage() {}
+
+func (x *ServerPlayerStats) ProtoReflect() protoreflect.Message {
+	mi := &file_chess_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPlayerStats.ProtoReflect.Descriptor instead.
+func (*ServerPlayerStats) Descriptor() ([]byte, []int) {
+	return file_chess_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ServerPlayerStats) GetMoves() uint32 {
+	if x != nil {
+		return x.Moves
+	}
+	return 0
+}
+
+func (x *ServerPlayerStats) GetCaptures() uint32 {
+	if x != nil {
+		return x.Captures
+	}
+	return 0
+}
+
+func (x *ServerPlayerStats) GetCapturesByType() []*PieceTypeCount {
+	if x != nil {
+		return x.CapturesByType
+	}
+	return nil
+}
+
+func (x *ServerPlayerStats) GetKingsCaptured() uint32 {
+	if x != nil {
+		return x.KingsCaptured
+	}
+	return 0
+}
+
+func (x *ServerPlayerStats) GetPromotions() uint32 {
+	if x != nil {
+		return x.Promotions
+	}
+	return 0
+}
+
 type ServerMessage struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Payload:
@@ -1224,6 +1728,10 @@ type ServerMessage struct {
 	//	*ServerMessage_Pong
 	//	*ServerMessage_Adoption
 	//	*ServerMessage_BulkCapture
+	//	*ServerMessage_Resume
+	//	*ServerMessage_PlayerStats
+	//	*ServerMessage_SetPieces
+	//	*ServerMessage_BoardOutcome
 	Payload       isServerMessage_Payload `protobuf_oneof:"payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -1231,7 +1739,7 @@ type ServerMessage struct {
 
 func (x *ServerMessage) Reset() {
 	*x = ServerMessage{}
-	mi := &file_chess_proto_msgTypes[17]
+	mi := &file_chess_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1243,7 +1751,7 @@ func (x *ServerMessage) String() string {
 func (*ServerMessage) ProtoMessage() {}
 
 func (x *ServerMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_chess_proto_msgTypes[17]
+	mi := &file_chess_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1256,7 +1764,7 @@ func (x *ServerMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerMessage.ProtoReflect.Descriptor instead.
 func (*ServerMessage) Descriptor() ([]byte, []int) {
-	return file_chess_proto_rawDescGZIP(), []int{17}
+	return file_chess_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *ServerMessage) GetPayload() isServerMessage_Payload {
@@ -1338,6 +1846,42 @@ func (x *ServerMessage) GetBulkCapture() *ServerBulkCapture {
 	return nil
 }
 
+func (x *ServerMessage) GetResume() *ServerResume {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerMessage_Resume); ok {
+			return x.Resume
+		}
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetPlayerStats() *ServerPlayerStats {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerMessage_PlayerStats); ok {
+			return x.PlayerStats
+		}
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetSetPieces() *ServerSetPieces {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerMessage_SetPieces); ok {
+			return x.SetPieces
+		}
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetBoardOutcome() *ServerBoardOutcome {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerMessage_BoardOutcome); ok {
+			return x.BoardOutcome
+		}
+	}
+	return nil
+}
+
 type isServerMessage_Payload interface {
 	isServerMessage_Payload()
 }
@@ -1374,6 +1918,22 @@ type ServerMessage_BulkCapture struct {
 	BulkCapture *ServerBulkCapture `protobuf:"bytes,8,opt,name=bulkCapture,proto3,oneof"`
 }
 
+type ServerMessage_Resume struct {
+	Resume *ServerResume `protobuf:"bytes,9,opt,name=resume,proto3,oneof"`
+}
+
+type ServerMessage_PlayerStats struct {
+	PlayerStats *ServerPlayerStats `protobuf:"bytes,10,opt,name=playerStats,proto3,oneof"`
+}
+
+type ServerMessage_SetPieces struct {
+	SetPieces *ServerSetPieces `protobuf:"bytes,11,opt,name=setPieces,proto3,oneof"`
+}
+
+type ServerMessage_BoardOutcome struct {
+	BoardOutcome *ServerBoardOutcome `protobuf:"bytes,12,opt,name=boardOutcome,proto3,oneof"`
+}
+
 func (*ServerMessage_InitialState) isServerMessage_Payload() {}
 
 func (*ServerMessage_Snapshot) isServerMessage_Payload() {}
@@ -1390,16 +1950,25 @@ func (*ServerMessage_Adoption) isServerMessage_Payload() {}
 
 func (*ServerMessage_BulkCapture) isServerMessage_Payload() {}
 
+func (*ServerMessage_Resume) isServerMessage_Payload() {}
+
+func (*ServerMessage_PlayerStats) isServerMessage_Payload() {}
+
+func (*ServerMessage_SetPieces) isServerMessage_Payload() {}
+
+func (*ServerMessage_BoardOutcome) isServerMessage_Payload() {}
+
 var File_chess_proto protoreflect.FileDescriptor
 
 const file_chess_proto_rawDesc = "" +
 	"\n" +
 	"\vchess.proto\x12\x05chess\"\f\n" +
 	"\n" +
-	"ClientPing\"E\n" +
+	"ClientPing\"\x16\n" +
+	"\x14ClientGetPlayerStats\"E\n" +
 	"\x0fClientSubscribe\x12\x18\n" +
 	"\acenterX\x18\x01 \x01(\rR\acenterX\x12\x18\n" +
-	"\acenterY\x18\x02 \x01(\rR\acenterY\"\xc1\x01\n" +
+	"\acenterY\x18\x02 \x01(\rR\acenterY\"\xf1\x01\n" +
 	"\n" +
 	"ClientMove\x12\x18\n" +
 	"\apieceId\x18\x01 \x01(\rR\apieceId\x12\x14\n" +
@@ -1408,11 +1977,13 @@ const file_chess_proto_rawDesc = "" +
 	"\x03toX\x18\x04 \x01(\rR\x03toX\x12\x10\n" +
 	"\x03toY\x18\x05 \x01(\rR\x03toY\x12+\n" +
 	"\bmoveType\x18\x06 \x01(\x0e2\x0f.chess.MoveTypeR\bmoveType\x12\x1c\n" +
-	"\tmoveToken\x18\a \x01(\rR\tmoveToken\"\xa4\x01\n" +
+	"\tmoveToken\x18\a \x01(\rR\tmoveToken\x12.\n" +
+	"\tpromotion\x18\b \x01(\x0e2\x10.chess.PieceTypeR\tpromotion\"\xeb\x01\n" +
 	"\rClientMessage\x12'\n" +
 	"\x04ping\x18\x01 \x01(\v2\x11.chess.ClientPingH\x00R\x04ping\x126\n" +
 	"\tsubscribe\x18\x02 \x01(\v2\x16.chess.ClientSubscribeH\x00R\tsubscribe\x12'\n" +
-	"\x04move\x18\x03 \x01(\v2\x11.chess.ClientMoveH\x00R\x04moveB\t\n" +
+	"\x04move\x18\x03 \x01(\v2\x11.chess.ClientMoveH\x00R\x04move\x12E\n" +
+	"\x0egetPlayerStats\x18\x04 \x01(\v2\x1b.chess.ClientGetPlayerStatsH\x00R\x0egetPlayerStatsB\t\n" +
 	"\apayload\"y\n" +
 	"\x0fServerValidMove\x12\x1e\n" +
 	"\n" +
@@ -1465,18 +2036,49 @@ const file_chess_proto_rawDesc = "" +
 	"\x06pieces\x18\x04 \x03(\v2\x1b.chess.PieceDataForSnapshotR\x06pieces\"&\n" +
 	"\bPosition\x12\f\n" +
 	"\x01x\x18\x01 \x01(\rR\x01x\x12\f\n" +
-	"\x01y\x18\x02 \x01(\rR\x01y\"\x9d\x01\n" +
+	"\x01y\x18\x02 \x01(\rR\x01y\"\xe3\x01\n" +
 	"\x12ServerInitialState\x12\"\n" +
 	"\fplayingWhite\x18\x01 \x01(\bR\fplayingWhite\x12+\n" +
 	"\bposition\x18\x02 \x01(\v2\x0f.chess.PositionR\bposition\x126\n" +
-	"\bsnapshot\x18\x03 \x01(\v2\x1a.chess.ServerStateSnapshotR\bsnapshot\"0\n" +
+	"\bsnapshot\x18\x03 \x01(\v2\x1a.chess.ServerStateSnapshotR\bsnapshot\x12\"\n" +
+	"\fsessionToken\x18\x04 \x01(\tR\fsessionToken\x12 \n" +
+	"\vplayerToken\x18\x05 \x01(\tR\vplayerToken\"\xe3\x01\n" +
+	"\fServerResume\x12\"\n" +
+	"\fplayingWhite\x18\x01 \x01(\bR\fplayingWhite\x12+\n" +
+	"\bposition\x18\x02 \x01(\v2\x0f.chess.PositionR\bposition\x12\"\n" +
+	"\fsessionToken\x18\x03 \x01(\tR\fsessionToken\x12-\n" +
+	"\x05moves\x18\x04 \x03(\v2\x17.chess.PieceDataForMoveR\x05moves\x12/\n" +
+	"\bcaptures\x18\x05 \x03(\v2\x13.chess.PieceCaptureR\bcaptures\"0\n" +
 	"\x0eServerAdoption\x12\x1e\n" +
 	"\n" +
 	"adoptedIds\x18\x01 \x03(\rR\n" +
 	"adoptedIds\"M\n" +
 	"\x11ServerBulkCapture\x12\x16\n" +
 	"\x06seqnum\x18\x01 \x01(\x04R\x06seqnum\x12 \n" +
-	"\vcapturedIds\x18\x02 \x03(\rR\vcapturedIds\"\xf4\x03\n" +
+	"\vcapturedIds\x18\x02 \x03(\rR\vcapturedIds\"z\n" +
+	"\x0fServerSetPieces\x12\x16\n" +
+	"\x06seqnum\x18\x01 \x01(\x04R\x06seqnum\x12/\n" +
+	"\x06placed\x18\x02 \x03(\v2\x17.chess.PieceDataForMoveR\x06placed\x12\x1e\n" +
+	"\n" +
+	"removedIds\x18\x03 \x03(\rR\n" +
+	"removedIds\"\xb2\x01\n" +
+	"\x12ServerBoardOutcome\x12\x16\n" +
+	"\x06seqnum\x18\x01 \x01(\x04R\x06seqnum\x12\x16\n" +
+	"\x06boardX\x18\x02 \x01(\rR\x06boardX\x12\x16\n" +
+	"\x06boardY\x18\x03 \x01(\rR\x06boardY\x12)\n" +
+	"\x05white\x18\x04 \x01(\x0e2\x13.chess.BoardOutcomeR\x05white\x12)\n" +
+	"\x05black\x18\x05 \x01(\x0e2\x13.chess.BoardOutcomeR\x05black\"L\n" +
+	"\x0ePieceTypeCount\x12$\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x10.chess.PieceTypeR\x04type\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\rR\x05count\"\xca\x01\n" +
+	"\x11ServerPlayerStats\x12\x14\n" +
+	"\x05moves\x18\x01 \x01(\rR\x05moves\x12\x1a\n" +
+	"\bcaptures\x18\x02 \x01(\rR\bcaptures\x12=\n" +
+	"\x0ecapturesByType\x18\x03 \x03(\v2\x15.chess.PieceTypeCountR\x0ecapturesByType\x12$\n" +
+	"\rkingsCaptured\x18\x04 \x01(\rR\rkingsCaptured\x12\x1e\n" +
+	"\n" +
+	"promotions\x18\x05 \x01(\rR\n" +
+	"promotions\"\xda\x05\n" +
 	"\rServerMessage\x12?\n" +
 	"\finitialState\x18\x01 \x01(\v2\x19.chess.ServerInitialStateH\x00R\finitialState\x128\n" +
 	"\bsnapshot\x18\x02 \x01(\v2\x1a.chess.ServerStateSnapshotH\x00R\bsnapshot\x12K\n" +
@@ -1485,12 +2087,17 @@ const file_chess_proto_rawDesc = "" +
 	"\vinvalidMove\x18\x05 \x01(\v2\x18.chess.ServerInvalidMoveH\x00R\vinvalidMove\x12'\n" +
 	"\x04pong\x18\x06 \x01(\v2\x11.chess.ServerPongH\x00R\x04pong\x123\n" +
 	"\badoption\x18\a \x01(\v2\x15.chess.ServerAdoptionH\x00R\badoption\x12<\n" +
-	"\vbulkCapture\x18\b \x01(\v2\x18.chess.ServerBulkCaptureH\x00R\vbulkCaptureB\t\n" +
+	"\vbulkCapture\x18\b \x01(\v2\x18.chess.ServerBulkCaptureH\x00R\vbulkCapture\x12-\n" +
+	"\x06resume\x18\t \x01(\v2\x13.chess.ServerResumeH\x00R\x06resume\x12<\n" +
+	"\vplayerStats\x18\n" +
+	" \x01(\v2\x18.chess.ServerPlayerStatsH\x00R\vplayerStats\x126\n" +
+	"\tsetPieces\x18\v \x01(\v2\x16.chess.ServerSetPiecesH\x00R\tsetPieces\x12?\n" +
+	"\fboardOutcome\x18\f \x01(\v2\x19.chess.ServerBoardOutcomeH\x00R\fboardOutcomeB\t\n" +
 	"\apayload*P\n" +
 	"\bMoveType\x12\x14\n" +
 	"\x10MOVE_TYPE_NORMAL\x10\x00\x12\x14\n" +
 	"\x10MOVE_TYPE_CASTLE\x10\x01\x12\x18\n" +
-	"\x14MOVE_TYPE_EN_PASSANT\x10\x02*\xac\x01\n" +
+	"\x14MOVE_TYPE_EN_PASSANT\x10\x02*\xd7\x02\n" +
 	"\tPieceType\x12\x13\n" +
 	"\x0fPIECE_TYPE_PAWN\x10\x00\x12\x15\n" +
 	"\x11PIECE_TYPE_KNIGHT\x10\x01\x12\x15\n" +
@@ -1498,7 +2105,18 @@ const file_chess_proto_rawDesc = "" +
 	"\x0fPIECE_TYPE_ROOK\x10\x03\x12\x14\n" +
 	"\x10PIECE_TYPE_QUEEN\x10\x04\x12\x13\n" +
 	"\x0fPIECE_TYPE_KING\x10\x05\x12\x1c\n" +
-	"\x18PIECE_TYPE_PROMOTED_PAWN\x10\x06B2Z0one-million-chessboards/server/protocol;protocolb\x06proto3"
+	"\x18PIECE_TYPE_PROMOTED_PAWN\x10\x06\x12\x1e\n" +
+	"\x1aPIECE_TYPE_PROMOTED_KNIGHT\x10\a\x12\x1e\n" +
+	"\x1aPIECE_TYPE_PROMOTED_BISHOP\x10\b\x12\x1c\n" +
+	"\x18PIECE_TYPE_PROMOTED_ROOK\x10\t\x12\x19\n" +
+	"\x15PIECE_TYPE_ARCHBISHOP\x10\n" +
+	"\x12\x19\n" +
+	"\x15PIECE_TYPE_CHANCELLOR\x10\v\x12\x15\n" +
+	"\x11PIECE_TYPE_AMAZON\x10\f*`\n" +
+	"\fBoardOutcome\x12\x16\n" +
+	"\x12BOARD_OUTCOME_NONE\x10\x00\x12\x1b\n" +
+	"\x17BOARD_OUTCOME_CHECKMATE\x10\x01\x12\x1b\n" +
+	"\x17BOARD_OUTCOME_STALEMATE\x10\x02B2Z0one-million-chessboards/server/protocol;protocolb\x06proto3"
 
 var (
 	file_chess_proto_rawDescOnce sync.Once
@@ -1512,56 +2130,77 @@ func file_chess_proto_rawDescGZIP() []byte {
 	return file_chess_proto_rawDescData
 }
 
-var file_chess_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_chess_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_chess_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_chess_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
 var file_chess_proto_goTypes = []any{
 	(MoveType)(0),                  // 0: chess.MoveType
 	(PieceType)(0),                 // 1: chess.PieceType
-	(*ClientPing)(nil),             // 2: chess.ClientPing
-	(*ClientSubscribe)(nil),        // 3: chess.ClientSubscribe
-	(*ClientMove)(nil),             // 4: chess.ClientMove
-	(*ClientMessage)(nil),          // 5: chess.ClientMessage
-	(*ServerValidMove)(nil),        // 6: chess.ServerValidMove
-	(*ServerInvalidMove)(nil),      // 7: chess.ServerInvalidMove
-	(*ServerPong)(nil),             // 8: chess.ServerPong
-	(*PieceCapture)(nil),           // 9: chess.PieceCapture
-	(*PieceDataShared)(nil),        // 10: chess.PieceDataShared
-	(*PieceDataForMove)(nil),       // 11: chess.PieceDataForMove
-	(*PieceDataForSnapshot)(nil),   // 12: chess.PieceDataForSnapshot
-	(*ServerMovesAndCaptures)(nil), // 13: chess.ServerMovesAndCaptures
-	(*ServerStateSnapshot)(nil),    // 14: chess.ServerStateSnapshot
-	(*Position)(nil),               // 15: chess.Position
-	(*ServerInitialState)(nil),     // 16: chess.ServerInitialState
-	(*ServerAdoption)(nil),         // 17: chess.ServerAdoption
-	(*ServerBulkCapture)(nil),      // 18: chess.ServerBulkCapture
-	(*ServerMessage)(nil),          // 19: chess.ServerMessage
+	(BoardOutcome)(0),              // 2: chess.BoardOutcome
+	(*ClientPing)(nil),             // 3: chess.ClientPing
+	(*ClientGetPlayerStats)(nil),   // 4: chess.ClientGetPlayerStats
+	(*ClientSubscribe)(nil),        // 5: chess.ClientSubscribe
+	(*ClientMove)(nil),             // 6: chess.ClientMove
+	(*ClientMessage)(nil),          // 7: chess.ClientMessage
+	(*ServerValidMove)(nil),        // 8: chess.ServerValidMove
+	(*ServerInvalidMove)(nil),      // 9: chess.ServerInvalidMove
+	(*ServerPong)(nil),             // 10: chess.ServerPong
+	(*PieceCapture)(nil),           // 11: chess.PieceCapture
+	(*PieceDataShared)(nil),        // 12: chess.PieceDataShared
+	(*PieceDataForMove)(nil),       // 13: chess.PieceDataForMove
+	(*PieceDataForSnapshot)(nil),   // 14: chess.PieceDataForSnapshot
+	(*ServerMovesAndCaptures)(nil), // 15: chess.ServerMovesAndCaptures
+	(*ServerStateSnapshot)(nil),    // 16: chess.ServerStateSnapshot
+	(*Position)(nil),               // 17: chess.Position
+	(*ServerInitialState)(nil),     // 18: chess.ServerInitialState
+	(*ServerResume)(nil),           // 19: chess.ServerResume
+	(*ServerAdoption)(nil),         // 20: chess.ServerAdoption
+	(*ServerBulkCapture)(nil),      // 21: chess.ServerBulkCapture
+	(*ServerSetPieces)(nil),        // 22: chess.ServerSetPieces
+	(*ServerBoardOutcome)(nil),     // 23: chess.ServerBoardOutcome
+	(*PieceTypeCount)(nil),         // 24: chess.PieceTypeCount
+	(*ServerPlayerStats)(nil),      // 25: chess.ServerPlayerStats
+	(*ServerMessage)(nil),          // 26: chess.ServerMessage
 }
 var file_chess_proto_depIdxs = []int32{
 	0,  // 0: chess.ClientMove.moveType:type_name -> chess.MoveType
-	2,  // 1: chess.ClientMessage.ping:type_name -> chess.ClientPing
-	3,  // 2: chess.ClientMessage.subscribe:type_name -> chess.ClientSubscribe
-	4,  // 3: chess.ClientMessage.move:type_name -> chess.ClientMove
-	1,  // 4: chess.PieceDataShared.type:type_name -> chess.PieceType
-	10, // 5: chess.PieceDataForMove.piece:type_name -> chess.PieceDataShared
-	10, // 6: chess.PieceDataForSnapshot.piece:type_name -> chess.PieceDataShared
-	11, // 7: chess.ServerMovesAndCaptures.moves:type_name -> chess.PieceDataForMove
-	9,  // 8: chess.ServerMovesAndCaptures.captures:type_name -> chess.PieceCapture
-	12, // 9: chess.ServerStateSnapshot.pieces:type_name -> chess.PieceDataForSnapshot
-	15, // 10: chess.ServerInitialState.position:type_name -> chess.Position
-	14, // 11: chess.ServerInitialState.snapshot:type_name -> chess.ServerStateSnapshot
-	16, // 12: chess.ServerMessage.initialState:type_name -> chess.ServerInitialState
-	14, // 13: chess.ServerMessage.snapshot:type_name -> chess.ServerStateSnapshot
-	13, // 14: chess.ServerMessage.movesAndCaptures:type_name -> chess.ServerMovesAndCaptures
-	6,  // 15: chess.ServerMessage.validMove:type_name -> chess.ServerValidMove
-	7,  // 16: chess.ServerMessage.invalidMove:type_name -> chess.ServerInvalidMove
-	8,  // 17: chess.ServerMessage.pong:type_name -> chess.ServerPong
-	17, // 18: chess.ServerMessage.adoption:type_name -> chess.ServerAdoption
-	18, // 19: chess.ServerMessage.bulkCapture:type_name -> chess.ServerBulkCapture
-	20, // [20:20] is the sub-list for method output_type
-	20, // [20:20] is the sub-list for method input_type
-	20, // [20:20] is the sub-list for extension type_name
-	20, // [20:20] is the sub-list for extension extendee
-	0,  // [0:20] is the sub-list for field type_name
+	1,  // 1: chess.ClientMove.promotion:type_name -> chess.PieceType
+	3,  // 2: chess.ClientMessage.ping:type_name -> chess.ClientPing
+	5,  // 3: chess.ClientMessage.subscribe:type_name -> chess.ClientSubscribe
+	6,  // 4: chess.ClientMessage.move:type_name -> chess.ClientMove
+	4,  // 5: chess.ClientMessage.getPlayerStats:type_name -> chess.ClientGetPlayerStats
+	1,  // 6: chess.PieceDataShared.type:type_name -> chess.PieceType
+	12, // 7: chess.PieceDataForMove.piece:type_name -> chess.PieceDataShared
+	12, // 8: chess.PieceDataForSnapshot.piece:type_name -> chess.PieceDataShared
+	13, // 9: chess.ServerMovesAndCaptures.moves:type_name -> chess.PieceDataForMove
+	11, // 10: chess.ServerMovesAndCaptures.captures:type_name -> chess.PieceCapture
+	14, // 11: chess.ServerStateSnapshot.pieces:type_name -> chess.PieceDataForSnapshot
+	17, // 12: chess.ServerInitialState.position:type_name -> chess.Position
+	16, // 13: chess.ServerInitialState.snapshot:type_name -> chess.ServerStateSnapshot
+	17, // 14: chess.ServerResume.position:type_name -> chess.Position
+	13, // 15: chess.ServerResume.moves:type_name -> chess.PieceDataForMove
+	11, // 16: chess.ServerResume.captures:type_name -> chess.PieceCapture
+	13, // 17: chess.ServerSetPieces.placed:type_name -> chess.PieceDataForMove
+	2,  // 18: chess.ServerBoardOutcome.white:type_name -> chess.BoardOutcome
+	2,  // 19: chess.ServerBoardOutcome.black:type_name -> chess.BoardOutcome
+	1,  // 20: chess.PieceTypeCount.type:type_name -> chess.PieceType
+	24, // 21: chess.ServerPlayerStats.capturesByType:type_name -> chess.PieceTypeCount
+	18, // 22: chess.ServerMessage.initialState:type_name -> chess.ServerInitialState
+	16, // 23: chess.ServerMessage.snapshot:type_name -> chess.ServerStateSnapshot
+	15, // 24: chess.ServerMessage.movesAndCaptures:type_name -> chess.ServerMovesAndCaptures
+	8,  // 25: chess.ServerMessage.validMove:type_name -> chess.ServerValidMove
+	9,  // 26: chess.ServerMessage.invalidMove:type_name -> chess.ServerInvalidMove
+	10, // 27: chess.ServerMessage.pong:type_name -> chess.ServerPong
+	20, // 28: chess.ServerMessage.adoption:type_name -> chess.ServerAdoption
+	21, // 29: chess.ServerMessage.bulkCapture:type_name -> chess.ServerBulkCapture
+	19, // 30: chess.ServerMessage.resume:type_name -> chess.ServerResume
+	25, // 31: chess.ServerMessage.playerStats:type_name -> chess.ServerPlayerStats
+	22, // 32: chess.ServerMessage.setPieces:type_name -> chess.ServerSetPieces
+	23, // 33: chess.ServerMessage.boardOutcome:type_name -> chess.ServerBoardOutcome
+	34, // [34:34] is the sub-list for method output_type
+	34, // [34:34] is the sub-list for method input_type
+	34, // [34:34] is the sub-list for extension type_name
+	34, // [34:34] is the sub-list for extension extendee
+	0,  // [0:34] is the sub-list for field type_name
 }
 
 func init() { file_chess_proto_init() }
@@ -1569,12 +2208,13 @@ func file_chess_proto_init() {
 	if File_chess_proto != nil {
 		return
 	}
-	file_chess_proto_msgTypes[3].OneofWrappers = []any{
+	file_chess_proto_msgTypes[4].OneofWrappers = []any{
 		(*ClientMessage_Ping)(nil),
 		(*ClientMessage_Subscribe)(nil),
 		(*ClientMessage_Move)(nil),
+		(*ClientMessage_GetPlayerStats)(nil),
 	}
-	file_chess_proto_msgTypes[17].OneofWrappers = []any{
+	file_chess_proto_msgTypes[23].OneofWrappers = []any{
 		(*ServerMessage_InitialState)(nil),
 		(*ServerMessage_Snapshot)(nil),
 		(*ServerMessage_MovesAndCaptures)(nil),
@@ -1583,14 +2223,18 @@ func file_chess_proto_init() {
 		(*ServerMessage_Pong)(nil),
 		(*ServerMessage_Adoption)(nil),
 		(*ServerMessage_BulkCapture)(nil),
+		(*ServerMessage_Resume)(nil),
+		(*ServerMessage_PlayerStats)(nil),
+		(*ServerMessage_SetPieces)(nil),
+		(*ServerMessage_BoardOutcome)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_chess_proto_rawDesc), len(file_chess_proto_rawDesc)),
-			NumEnums:      2,
-			NumMessages:   18,
+			NumEnums:      3,
+			NumMessages:   24,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"flag"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Where the action is. Every valid move scores a point on the 8x8 board
+// that it lands on, and a capture scores ACTIVITY_CAPTURE_WEIGHT more.
+// Scores decay exponentially, halving every ACTIVITY_HALF_LIFE, so unlike
+// RecentCaptures this remembers a busy area for a while after it quiets
+// down (and doesn't forget it the moment 20 more captures happen elsewhere).
+//
+// Like the minimap we serialize it on a timer and serve the cached blob:
+// per-minimap-cell scores for the whole board, plus the busiest few boards.
+
+var spawnNearActivity = flag.Bool("spawn-near-activity", true, "Drop new players without a requested position near recent activity")
+
+const (
+	ACTIVITY_HALF_LIFE      = time.Minute * 2
+	ACTIVITY_CAPTURE_WEIGHT = 3
+	ACTIVITY_HOTSPOTS       = 50
+	// Boards that have decayed below this don't count as hotspots
+	ACTIVITY_MIN_HOTSPOT_SCORE = 1
+)
+
+type ActivityHotspot struct {
+	// The board's top left square
+	X     uint16  `json:"x"`
+	Y     uint16  `json:"y"`
+	Score float32 `json:"score"`
+}
+
+// Cells are minimap cells (CELL_SIZE boards a side), row-major
+type ActivityResponse struct {
+	Type            string            `json:"type"`
+	HalfLifeSeconds int               `json:"halfLifeSeconds"`
+	CellSize        int               `json:"cellSize"`
+	Width           int               `json:"width"`
+	Cells           []float32         `json:"cells"`
+	Hotspots        []ActivityHotspot `json:"hotspots"`
+}
+
+type ActivityHeatmap struct {
+	sync.Mutex
+	scores     [BOARDS_PER_SIDE][BOARDS_PER_SIDE]float32
+	lastDecay  time.Time
+	hotspots   atomic.Pointer[[]ActivityHotspot]
+	cellScores atomic.Pointer[[NUMBER_OF_CELLS][NUMBER_OF_CELLS]float32]
+	serialized atomic.Pointer[[]byte]
+}
+
+func NewActivityHeatmap() *ActivityHeatmap {
+	h := &ActivityHeatmap{lastDecay: time.Now()}
+	h.hotspots.Store(&[]ActivityHotspot{})
+	h.cellScores.Store(&[NUMBER_OF_CELLS][NUMBER_OF_CELLS]float32{})
+	h.serialized.Store(&[]byte{})
+	return h
+}
+
+func (h *ActivityHeatmap) RecordMove(res *MoveResult) {
+	if len(res.MovedPieces) == 0 {
+		return
+	}
+	moved := res.MovedPieces[0]
+	h.Lock()
+	defer h.Unlock()
+	h.scores[moved.ToY/SINGLE_BOARD_SIZE][moved.ToX/SINGLE_BOARD_SIZE]++
+	if !res.CapturedPiece.Piece.IsEmpty() {
+		captured := res.CapturedPiece
+		h.scores[captured.Y/SINGLE_BOARD_SIZE][captured.X/SINGLE_BOARD_SIZE] += ACTIVITY_CAPTURE_WEIGHT
+	}
+}
+
+// Decays everything to now and recomputes what we serve
+func (h *ActivityHeatmap) refresh() {
+	hotspots := newTopN[uint32, ActivityHotspot](ACTIVITY_HOTSPOTS)
+	var cells [NUMBER_OF_CELLS][NUMBER_OF_CELLS]float32
+
+	h.Lock()
+	now := time.Now()
+	decay := float32(math.Exp2(-now.Sub(h.lastDecay).Seconds() / ACTIVITY_HALF_LIFE.Seconds()))
+	h.lastDecay = now
+	for by := range h.scores {
+		for bx := range h.scores[by] {
+			score := h.scores[by][bx] * decay
+			if score < 0.01 {
+				score = 0
+			}
+			h.scores[by][bx] = score
+			if score == 0 {
+				continue
+			}
+			cells[by/CELL_SIZE][bx/CELL_SIZE] += score
+			if score >= ACTIVITY_MIN_HOTSPOT_SCORE {
+				hotspots.update(uint32(by*BOARDS_PER_SIDE+bx), uint64(score*1000), ActivityHotspot{
+					X:     uint16(bx * SINGLE_BOARD_SIZE),
+					Y:     uint16(by * SINGLE_BOARD_SIZE),
+					Score: score,
+				})
+			}
+		}
+	}
+	h.Unlock()
+
+	top := hotspots.top(ACTIVITY_HOTSPOTS)
+	h.hotspots.Store(&top)
+	h.cellScores.Store(&cells)
+
+	response := ActivityResponse{
+		Type:            "activity",
+		HalfLifeSeconds: int(ACTIVITY_HALF_LIFE.Seconds()),
+		CellSize:        CELL_SIZE,
+		Width:           NUMBER_OF_CELLS,
+		Cells:           make([]float32, 0, NUMBER_OF_CELLS*NUMBER_OF_CELLS),
+		Hotspots:        top,
+	}
+	for y := range cells {
+		for x := range cells[y] {
+			// Nobody needs more precision than this, and it compresses far better
+			response.Cells = append(response.Cells, float32(math.Round(float64(cells[y][x])*10)/10))
+		}
+	}
+	serialized := compressAggregation(response)
+	if serialized == nil {
+		return
+	}
+	h.serialized.Store(&serialized)
+}
+
+// As of the last refresh
+func (h *ActivityHeatmap) CellScores() *[NUMBER_OF_CELLS][NUMBER_OF_CELLS]float32 {
+	return h.cellScores.Load()
+}
+
+// A random hotspot, busier ones more likely, as of the last refresh
+func (h *ActivityHeatmap) RandomHotspot() (Position, bool) {
+	hotspots := *h.hotspots.Load()
+	if len(hotspots) == 0 {
+		return Position{}, false
+	}
+	total := float32(0)
+	for _, hotspot := range hotspots {
+		total += hotspot.Score
+	}
+	target := rand.Float32() * total
+	chosen := hotspots[len(hotspots)-1]
+	for _, hotspot := range hotspots {
+		target -= hotspot.Score
+		if target <= 0 {
+			chosen = hotspot
+			break
+		}
+	}
+	return Position{
+		X: chosen.X + SINGLE_BOARD_SIZE/2,
+		Y: chosen.Y + SINGLE_BOARD_SIZE/2,
+	}, true
+}
+
+func (s *Server) refreshActivityPeriodically() {
+	s.activityHeatmap.refresh()
+	go func() {
+		ticker := time.NewTicker(ACTIVITY_REFRESH_INTERVAL)
+		s.backgroundJobWg.Add(1)
+		defer func() {
+			ticker.Stop()
+			s.backgroundJobWg.Done()
+		}()
+
+		for {
+			select {
+			case <-s.backgroundJobCtx.Done():
+				return
+			case <-ticker.C:
+				s.activityHeatmap.refresh()
+			}
+		}
+	}()
+}
+
+func (s *Server) ServeActivity(w http.ResponseWriter, r *http.Request) {
+	s.httpLogger.Info().
+		Str("rpc", "ServeActivity").
+		Send()
+	serialized := *s.activityHeatmap.serialized.Load()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Encoding", "zstd")
+	w.Header().Set("Cache-Control", "public, max-age=5, s-maxage=10")
+	w.Write(serialized)
+}
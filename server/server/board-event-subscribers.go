@@ -0,0 +1,269 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"one-million-chessboards/protocol"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Fanning out a move is cheap, so this only fills up if something's
+	// badly wrong, and we'd rather slow down than have clients miss moves.
+	CLIENT_FANOUT_EVENT_BUFFER   = 4096
+	RECENT_CAPTURES_EVENT_BUFFER = 1024
+)
+
+// Inline subscribers run in this order, so replicas hear about a request
+// before we persist it, and everything derived from the board is up to date
+// before the next request is applied.
+func (s *Server) subscribeToBoardEvents() {
+	if hub := s.boardToDiskHandler.replication; hub != nil {
+		s.events.Subscribe("replication", DeliverInline, 0, func(ev *BoardEvent) {
+			hub.publish(ev.Request)
+		})
+	}
+	s.events.Subscribe("persistence", DeliverInline, 0, func(ev *BoardEvent) {
+		s.boardToDiskHandler.Add(ev.Request)
+	})
+	s.events.Subscribe("minimap", DeliverInline, 0, func(ev *BoardEvent) {
+		s.minimapAggregator.ApplyChangeSet(ev.Changes)
+	})
+	s.events.Subscribe("board-outcomes", DeliverInline, 0, func(ev *BoardEvent) {
+		if ev.Kind() == BoardEventRuleChange {
+			s.boardOutcomes.ApplyRuleChange(s.board)
+			return
+		}
+		ev.BoardOutcomes = s.boardOutcomes.ApplyChangeSet(s.board, ev.Changes)
+	})
+	s.events.Subscribe("activity", DeliverInline, 0, func(ev *BoardEvent) {
+		if ev.MoveResult != nil {
+			s.activityHeatmap.RecordMove(ev.MoveResult)
+		}
+	})
+	s.events.Subscribe("leaderboards", DeliverInline, 0, func(ev *BoardEvent) {
+		s.leaderboards.ApplyChangeSet(ev.Changes)
+	})
+	s.events.Subscribe("player-stats", DeliverInline, 0, s.recordPlayerStats)
+	s.events.Subscribe("recent-captures", DeliverOrDrop, RECENT_CAPTURES_EVENT_BUFFER, func(ev *BoardEvent) {
+		if capture, ok := ev.Capture(); ok {
+			s.recentCaptures.AddCapture(&capture)
+		}
+	})
+	s.events.Subscribe("clients", DeliverOrBlock, CLIENT_FANOUT_EVENT_BUFFER, s.fanOutToClients)
+}
+
+// Call from processMoves right after changing the board
+func (s *Server) publishBoardEvent(req boardToDiskRequest, changes *ChangeSet, moveResult *MoveResult) {
+	req.Seqnum = changes.Seqnum
+	if req.TimestampNs == 0 {
+		req.TimestampNs = time.Now().UnixNano()
+	}
+	s.events.Publish(&BoardEvent{Request: req, Changes: changes, MoveResult: moveResult})
+}
+
+func (s *Server) recordPlayerStats(ev *BoardEvent) {
+	if ev.MoveResult == nil {
+		return
+	}
+	player := ev.Request.Move.PlayerID
+	if stats, ok := s.playerStats.RecordMove(player, ev.MoveResult); ok {
+		s.leaderboards.UpdatePlayer(player, stats)
+	}
+}
+
+func (s *Server) fanOutToClients(ev *BoardEvent) {
+	switch ev.Kind() {
+	case BoardEventMove:
+		s.fanOutMove(ev)
+	case BoardEventAdoption:
+		s.fanOutAdoption(ev)
+	case BoardEventBulkCapture:
+		s.fanOutBulkCapture(ev)
+	case BoardEventRollback:
+		s.fanOutRollback(ev)
+	case BoardEventSetPieces:
+		s.fanOutSetPieces(ev)
+	}
+	for _, outcome := range ev.BoardOutcomes {
+		s.fanOutBoardOutcome(outcome, ev.Seqnum())
+	}
+}
+
+// CR-someday nroyalty: is there a way we can avoid the overhead of re-serializing a move
+// for each client here? It's annoying that we might end up doing the same serialization
+// for 100 different clients if they're looking at the same zones.
+//
+// CR-someday nroyalty: I THINK this can't actually matter, but there's a bug here where
+// you castle queenside and that results in us moving a rook that's on the edge
+// of your vision, but the king isn't in your vision and so we don't tell you about it
+// I think this is fine...but I need to think about it some more.
+//
+// nroyalty: lol I found a funny client rendering bug (or set of bugs) as a result
+// of testing this, but I failed to actually test it. Let's not worry about it.
+//
+// Ok I think this doesn't matter in practice regardless but since our zones
+// are slightly bigger than our snapshots it super doesn't matter
+func (s *Server) fanOutMove(ev *BoardEvent) {
+	move := *ev.Request.Move
+	moveResult := ev.MoveResult
+	numMoved := len(moveResult.MovedPieces)
+	if numMoved < 1 {
+		log.Printf("IMPOSSIBLE? moveResult length < 1")
+		return
+	}
+	capturedPiece := moveResult.CapturedPiece
+	movedPiecesProto := make([]*protocol.PieceDataForMove, 0, numMoved)
+	for _, movedPiece := range moveResult.MovedPieces {
+		piece := movedPiece.Piece
+
+		movedPiecesProto = append(movedPiecesProto, &protocol.PieceDataForMove{
+			X:      uint32(movedPiece.ToX),
+			Y:      uint32(movedPiece.ToY),
+			Seqnum: moveResult.Seqnum,
+			Piece:  piece.ToProtocolAlloc(),
+		})
+	}
+
+	var pieceCapture *protocol.PieceCapture = nil
+	if !capturedPiece.Piece.IsEmpty() {
+		pieceCapture = &protocol.PieceCapture{
+			CapturedPieceId: capturedPiece.Piece.ID,
+			Seqnum:          moveResult.Seqnum,
+		}
+	}
+	affectedZones := s.clientManager.GetAffectedZones(move)
+	s.zoneHistory.RecordMove(affectedZones, move, moveResult.Seqnum, movedPiecesProto, pieceCapture)
+	interestedClients := s.clientManager.GetClientsForZones(affectedZones)
+	// Each zone is 50x50 and a client is typically in 9 of them, so we
+	// offer them each move in a 150x150 zone that is not necessarily centered
+	// exactly on where they are. Depending on their position within their
+	// central zone, plenty of moves aren't going to be relevant to them
+	// (outside of their current snapshot window)
+	//
+	// I suppose it depends on access patterns in some way, but I think
+	// in practice checking before sending a move to a client is just gonna
+	// be faster than slamming out every move, given that I think we should
+	// drop a reasonable amount of moves with this check.
+	//
+	// potential bug around castle notification again here?
+	for client := range interestedClients {
+		if client.IsInterestedInMove(move) {
+			client.AddMovesToBuffer(movedPiecesProto, pieceCapture)
+		}
+	}
+	s.clientManager.ReturnClientMap(interestedClients)
+}
+
+func (s *Server) fanOutBoardOutcome(outcome BoardOutcomeResult, seqnum uint64) {
+	m := &protocol.ServerMessage{
+		Payload: &protocol.ServerMessage_BoardOutcome{
+			BoardOutcome: &protocol.ServerBoardOutcome{
+				Seqnum: seqnum,
+				BoardX: uint32(outcome.BoardX),
+				BoardY: uint32(outcome.BoardY),
+				White:  outcome.White,
+				Black:  outcome.Black,
+			},
+		},
+	}
+	message, err := proto.Marshal(m)
+	if err != nil {
+		log.Printf("Error marshalling board outcome: %v", err)
+		return
+	}
+	// A board can straddle up to 4 zones
+	minX := outcome.BoardX * SINGLE_BOARD_SIZE
+	minY := outcome.BoardY * SINGLE_BOARD_SIZE
+	maxX := minX + SINGLE_BOARD_SIZE - 1
+	maxY := minY + SINGLE_BOARD_SIZE - 1
+	affectedZones := map[ZoneCoord]struct{}{
+		GetZoneCoord(minX, minY): {},
+		GetZoneCoord(maxX, minY): {},
+		GetZoneCoord(minX, maxY): {},
+		GetZoneCoord(maxX, maxY): {},
+	}
+	interestedClients := s.clientManager.GetClientsForZones(affectedZones)
+	for client := range interestedClients {
+		client.SendBoardOutcome(message)
+	}
+	s.clientManager.ReturnClientMap(interestedClients)
+}
+
+func (s *Server) fanOutAdoption(ev *BoardEvent) {
+	affectedZones := s.clientManager.AffectedZonesForAdoption(ev.Request.AdoptionRequest)
+	s.zoneHistory.Invalidate(affectedZones, ev.Seqnum())
+	interestedClients := s.clientManager.GetClientsForZones(affectedZones)
+	defer s.clientManager.ReturnClientMap(interestedClients)
+	m := &protocol.ServerMessage{
+		Payload: &protocol.ServerMessage_Adoption{
+			Adoption: &protocol.ServerAdoption{
+				AdoptedIds: ev.Changes.PlacedIDs(),
+			},
+		},
+	}
+	message, err := proto.Marshal(m)
+	if err != nil {
+		log.Printf("Error marshalling adoption: %v", err)
+		return
+	}
+
+	for client := range interestedClients {
+		client.SendAdoption(message)
+	}
+}
+
+func (s *Server) fanOutBulkCapture(ev *BoardEvent) {
+	m := &protocol.ServerMessage{
+		Payload: &protocol.ServerMessage_BulkCapture{
+			BulkCapture: &protocol.ServerBulkCapture{
+				CapturedIds: ev.Changes.RemovedIDs(),
+				Seqnum:      ev.Seqnum(),
+			},
+		},
+	}
+	message, err := proto.Marshal(m)
+	if err != nil {
+		log.Printf("Error marshalling bulk capture: %v", err)
+		return
+	}
+	affectedZones := s.clientManager.AffectedZonesForBulkCapture(ev.Request.BulkCaptureRequest)
+	s.zoneHistory.Invalidate(affectedZones, ev.Seqnum())
+	interestedClients := s.clientManager.GetClientsForZones(affectedZones)
+	for client := range interestedClients {
+		client.SendBulkCapture(message)
+	}
+	s.clientManager.ReturnClientMap(interestedClients)
+}
+
+func (s *Server) fanOutRollback(ev *BoardEvent) {
+	message, err := proto.Marshal(rollbackMessage(ev.Changes))
+	if err != nil {
+		log.Printf("Error marshalling rollback: %v", err)
+		return
+	}
+	affectedZones := affectedZonesForSquares(ev.Request.RollbackRequest.Squares)
+	s.zoneHistory.Invalidate(affectedZones, ev.Seqnum())
+	interestedClients := s.clientManager.GetClientsForZones(affectedZones)
+	for client := range interestedClients {
+		client.SendRollback(message)
+	}
+	s.clientManager.ReturnClientMap(interestedClients)
+}
+
+func (s *Server) fanOutSetPieces(ev *BoardEvent) {
+	message, err := proto.Marshal(setPiecesMessage(ev.Changes))
+	if err != nil {
+		log.Printf("Error marshalling set pieces: %v", err)
+		return
+	}
+	affectedZones := affectedZonesForSquares(ev.Request.SetPiecesRequest.Squares)
+	s.zoneHistory.Invalidate(affectedZones, ev.Seqnum())
+	interestedClients := s.clientManager.GetClientsForZones(affectedZones)
+	for client := range interestedClients {
+		client.SendSetPieces(message)
+	}
+	s.clientManager.ReturnClientMap(interestedClients)
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"log"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// Every change to the live board goes out as a BoardEvent, in the order that
+// processMoves made them. Persistence, replication, the minimap, stats, the
+// captures ring and our clients all subscribe here instead of being wired
+// into each branch of processMoves, so a new kind of change only has to
+// publish an event and a new kind of view only has to subscribe.
+//
+// Subscribers pick how they deal with falling behind:
+//   - Inline subscribers run on processMoves itself. They're for anything
+//     cheap that has to stay exactly in step with the board.
+//   - Blocking subscribers get a queue, and processMoves waits for room when
+//     it's full. Nothing is lost, but a slow subscriber slows everyone down.
+//   - Dropping subscribers get a queue too, but when it's full we drop the
+//     event and count it. For things that can cope with gaps.
+//
+// Within a subscriber events always arrive in seqnum order.
+
+type BoardEventKind uint8
+
+const (
+	BoardEventMove BoardEventKind = iota + 1
+	BoardEventAdoption
+	BoardEventBulkCapture
+	BoardEventRollback
+	BoardEventSetPieces
+	BoardEventRuleChange
+)
+
+func (k BoardEventKind) String() string {
+	switch k {
+	case BoardEventMove:
+		return "move"
+	case BoardEventAdoption:
+		return "adoption"
+	case BoardEventBulkCapture:
+		return "bulk_capture"
+	case BoardEventRollback:
+		return "rollback"
+	case BoardEventSetPieces:
+		return "set_pieces"
+	case BoardEventRuleChange:
+		return "rule_change"
+	default:
+		return "unknown"
+	}
+}
+
+// Request is exactly what we persist and replicate, with its Seqnum and
+// TimestampNs filled in. A capture is part of the move that made it.
+//
+// BoardOutcomes is filled in by the board-outcomes subscriber, which runs
+// inline ahead of every queued subscriber, so they can all read it.
+type BoardEvent struct {
+	Request       boardToDiskRequest
+	Changes       *ChangeSet
+	MoveResult    *MoveResult // moves only
+	BoardOutcomes []BoardOutcomeResult
+}
+
+func (ev *BoardEvent) Kind() BoardEventKind {
+	switch {
+	case ev.Request.Move != nil:
+		return BoardEventMove
+	case ev.Request.AdoptionRequest != nil:
+		return BoardEventAdoption
+	case ev.Request.BulkCaptureRequest != nil:
+		return BoardEventBulkCapture
+	case ev.Request.RollbackRequest != nil:
+		return BoardEventRollback
+	case ev.Request.SetPiecesRequest != nil:
+		return BoardEventSetPieces
+	case ev.Request.RuleChangeRequest != nil:
+		return BoardEventRuleChange
+	default:
+		return 0
+	}
+}
+
+func (ev *BoardEvent) Seqnum() uint64 {
+	return ev.Request.Seqnum
+}
+
+func (ev *BoardEvent) Capture() (CaptureResult, bool) {
+	if ev.MoveResult == nil || ev.MoveResult.CapturedPiece.Piece.IsEmpty() {
+		return CaptureResult{}, false
+	}
+	return ev.MoveResult.CapturedPiece, true
+}
+
+type BackpressurePolicy uint8
+
+const (
+	DeliverInline BackpressurePolicy = iota
+	DeliverOrBlock
+	DeliverOrDrop
+)
+
+// Log the first drop and then every this many
+const BOARD_EVENT_DROP_LOG_INTERVAL = 1000
+
+type boardEventSubscriber struct {
+	name    string
+	policy  BackpressurePolicy
+	handle  func(ev *BoardEvent)
+	events  chan *BoardEvent
+	dropped uint64
+}
+
+type BoardEventBus struct {
+	subscribers []*boardEventSubscriber
+	lastSeqnum  uint64
+	wg          sync.WaitGroup
+	closeOnce   sync.Once
+	logger      zerolog.Logger
+}
+
+func NewBoardEventBus(seqnum uint64) *BoardEventBus {
+	return &BoardEventBus{
+		lastSeqnum: seqnum,
+		logger:     NewCoreLogger().With().Str("kind", "board_events").Logger(),
+	}
+}
+
+// Subscribe before anything is published. Inline subscribers run in the
+// order that they subscribed; queued ones run on their own goroutine.
+func (b *BoardEventBus) Subscribe(name string, policy BackpressurePolicy, bufferSize int, handle func(ev *BoardEvent)) {
+	sub := &boardEventSubscriber{name: name, policy: policy, handle: handle}
+	b.subscribers = append(b.subscribers, sub)
+	if policy == DeliverInline {
+		return
+	}
+	sub.events = make(chan *BoardEvent, bufferSize)
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for ev := range sub.events {
+			sub.handle(ev)
+		}
+	}()
+}
+
+// Only call this from processMoves, right after changing the board
+func (b *BoardEventBus) Publish(ev *BoardEvent) {
+	seqnum := ev.Seqnum()
+	if seqnum <= b.lastSeqnum {
+		log.Printf("BUG: publishing %s at seqnum %d after %d", ev.Kind(), seqnum, b.lastSeqnum)
+		b.logger.Error().Str("error_kind", "board_event_out_of_order").
+			Str("event_kind", ev.Kind().String()).
+			Uint64("seqnum", seqnum).Uint64("last_seqnum", b.lastSeqnum).
+			Send()
+	}
+	b.lastSeqnum = seqnum
+
+	for _, sub := range b.subscribers {
+		switch sub.policy {
+		case DeliverInline:
+			sub.handle(ev)
+		case DeliverOrBlock:
+			sub.events <- ev
+		case DeliverOrDrop:
+			select {
+			case sub.events <- ev:
+			default:
+				sub.dropped++
+				if sub.dropped%BOARD_EVENT_DROP_LOG_INTERVAL == 1 {
+					log.Printf("Board event subscriber %s is behind; dropped %d events", sub.name, sub.dropped)
+					b.logger.Warn().Str("subscriber", sub.name).Uint64("dropped", sub.dropped).Send()
+				}
+			}
+		}
+	}
+}
+
+// Waits for queued subscribers to get through what they already have. Call
+// once processMoves has stopped.
+func (b *BoardEventBus) Close() {
+	b.closeOnce.Do(func() {
+		for _, sub := range b.subscribers {
+			if sub.events != nil {
+				close(sub.events)
+			}
+		}
+		b.wg.Wait()
+	})
+}
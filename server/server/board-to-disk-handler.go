@@ -3,19 +3,18 @@ package server
 import (
 	"bufio"
 	"context"
-	"encoding/binary"
 	"encoding/gob"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"one-million-chessboards/protocol"
-	"os"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -25,10 +24,19 @@ const (
 	BOARD_SERIALIZATION_INTERVAL = time.Second * 127
 	MOVE_SERIALIZATION_INTERVAL  = time.Second * 10
 	MAX_MOVES_TO_SERIALIZE       = 5000
+	// Finished segments waiting for sealMoveLogs. If our store is slow or
+	// down for long enough to fill this up, the rest wait in pendingSeals.
+	MOVE_LOG_SEAL_QUEUE = 1024
 )
 
 var doNotSaveState = flag.Bool("do-not-save-state", false, "Don't save state to disk")
 
+// A broken chain means that a segment is missing or that two overlap, which
+// we can't replay past. Losing the end of the chain isn't one: on S3 we
+// upload the open segment in the background, so a crash loses the last few
+// seconds of moves (see s3AppendableObject) and replay just stops early.
+var allowBrokenMoveLog = flag.Bool("allow-broken-move-log", false, "Start up even if the move logs after our latest snapshot have gaps or overlaps")
+
 /*
 1. Duplicate board. It's not worth the trouble of re-implementing the logic; doing things
    this way will make sure we handle everything
@@ -56,28 +64,26 @@ var doNotSaveState = flag.Bool("do-not-save-state", false, "Don't save state to
 // We need to run these through a single channel because otherwise we won't
 // know what order they were applied in on the main channel. Hopefully this
 // doesn't matter much, but you never know.
+//
+// Seqnum is the seqnum that the live board assigned when it applied the
+// request, and TimestampNs is (roughly) when that happened. Requests that we
+// wrote before we started recording these have both set to 0.
 type boardToDiskRequest struct {
 	Move               *Move
 	AdoptionRequest    *adoptionRequest
 	BulkCaptureRequest *bulkCaptureRequest
+	RollbackRequest    *rollbackRequest
+	SetPiecesRequest   *setPiecesRequest
+	RuleChangeRequest  *ruleChangeRequest
+	Seqnum             uint64
+	TimestampNs        int64
 }
 
-func (btd *BoardToDiskHandler) AddMove(move *Move) {
-	btd.requests <- boardToDiskRequest{
-		Move: move,
-	}
-}
-
-func (btd *BoardToDiskHandler) AddAdoption(adoptionRequest *adoptionRequest) {
-	btd.requests <- boardToDiskRequest{
-		AdoptionRequest: adoptionRequest,
-	}
-}
-
-func (btd *BoardToDiskHandler) AddBulkCapture(bulkCaptureRequest *bulkCaptureRequest) {
-	btd.requests <- boardToDiskRequest{
-		BulkCaptureRequest: bulkCaptureRequest,
-	}
+// Called from processMoves (via the board event bus), so we see requests
+// in the order that they were applied. This blocks when we're far behind,
+// which in turn holds up processMoves.
+func (btd *BoardToDiskHandler) Add(req boardToDiskRequest) {
+	btd.requests <- req
 }
 
 type PieceAndCoords struct {
@@ -96,15 +102,34 @@ func decodeCoords(coords uint32) (x uint16, y uint16) {
 }
 
 type BoardToDiskHandler struct {
-	board               *Board
-	requests            chan boardToDiskRequest
-	stateDir            string
-	done                chan struct{}
-	requestsToSerialize []boardToDiskRequest
-	logger              zerolog.Logger
-	ctx                 context.Context
-	cancel              context.CancelFunc
-	wg                  *sync.WaitGroup
+	board    *Board
+	requests chan boardToDiskRequest
+	store    StateStore
+	done     chan struct{}
+	moveLog  *moveLogWriter
+	history  *pieceHistoryBuilder
+	readOnly bool
+
+	seals    chan moveLogSeal
+	sealerWg sync.WaitGroup
+	// Only touched from our main loop (or once it has stopped); see
+	// handOffSeals
+	pendingSeals []moveLogSeal
+
+	playerStats *PlayerStatsTracker
+
+	replication      *replicationHub
+	snapshotRequests chan chan *Snapshot
+
+	dirty            *dirtyBoards
+	lastFullSeqNum   uint64
+	deltasSinceFull  int
+	needFullSnapshot atomic.Bool
+	retentionRunning atomic.Bool
+	logger           zerolog.Logger
+	ctx              context.Context
+	cancel           context.CancelFunc
+	wg               *sync.WaitGroup
 }
 
 type SnapshotHeader struct {
@@ -116,11 +141,17 @@ type SnapshotHeader struct {
 	WhiteKingsCaptured  uint32
 	BlackKingsCaptured  uint32
 	PieceCount          uint32
+	// Added later; older snapshots read as PromotionRuleWorldEdge and
+	// RuleSetStandard
+	PromotionRule PromotionRuleID
+	RuleSet       RuleSetID
 }
 
 type Snapshot struct {
 	Header          SnapshotHeader
 	PiecesAndCoords []PieceAndCoords
+	PlayerStats     []playerStatsRecord
+	TakenAtNs       int64
 }
 
 // CR-someday nroyalty: we could pool this but we'd need to be careful to zero out
@@ -129,16 +160,7 @@ type Snapshot struct {
 // be gc'd anyway..
 func (btd *BoardToDiskHandler) getSnapshot() (snapshot *Snapshot) {
 	start := time.Now()
-	header := SnapshotHeader{
-		NextID:              btd.board.nextID,
-		SeqNum:              btd.board.seqNum,
-		TotalMoves:          btd.board.totalMoves.Load(),
-		WhitePiecesCaptured: btd.board.whitePiecesCaptured.Load(),
-		BlackPiecesCaptured: btd.board.blackPiecesCaptured.Load(),
-		WhiteKingsCaptured:  btd.board.whiteKingsCaptured.Load(),
-		BlackKingsCaptured:  btd.board.blackKingsCaptured.Load(),
-		PieceCount:          0,
-	}
+	header := btd.snapshotHeader()
 	probableSize := BOARD_SIZE * BOARD_SIZE / 2
 	probableSize -= int(header.WhitePiecesCaptured)
 	probableSize -= int(header.BlackPiecesCaptured)
@@ -151,6 +173,8 @@ func (btd *BoardToDiskHandler) getSnapshot() (snapshot *Snapshot) {
 	snapshot = &Snapshot{
 		Header:          header,
 		PiecesAndCoords: make([]PieceAndCoords, 0, probableSize),
+		PlayerStats:     btd.playerStats.records(nil),
+		TakenAtNs:       start.UnixNano(),
 	}
 
 	actualSize := 0
@@ -178,6 +202,15 @@ func (btd *BoardToDiskHandler) getSnapshot() (snapshot *Snapshot) {
 	return
 }
 
+func (s *Snapshot) writeBuffered(writer io.Writer) error {
+	buf := bufio.NewWriterSize(writer, 128*1024*1024)
+	ie := s.writeTo(buf)
+	if ie != nil {
+		return ie
+	}
+	return buf.Flush()
+}
+
 func (btd *BoardToDiskHandler) saveToFile(s *Snapshot) error {
 	if *doNotSaveState {
 		return nil
@@ -185,22 +218,10 @@ func (btd *BoardToDiskHandler) saveToFile(s *Snapshot) error {
 
 	now := time.Now()
 	name := fmt.Sprintf("board-ts:%d-seq:%d.bin", now.UnixNano(), s.Header.SeqNum)
-	filename := filepath.Join(btd.stateDir, name)
-	err := WriteFileAtomic(filename, func(writer io.Writer) error {
-		buf := bufio.NewWriterSize(writer, 128*1024*1024)
-		ie := binary.Write(buf, binary.LittleEndian, &s.Header)
-		if ie != nil {
-			return ie
-		}
-		ie = binary.Write(buf, binary.LittleEndian, s.PiecesAndCoords)
-		if ie != nil {
-			return ie
-		}
-		return buf.Flush()
-	})
+	err := btd.store.Put(name, s.writeBuffered)
 	if err != nil {
 		btd.logger.Error().Str("error_kind", "writing_to_file").AnErr("err", err).Send()
-		log.Printf("ERROR writing board snapshot to file %s: %v", filename, err)
+		log.Printf("ERROR writing board snapshot %s: %v", name, err)
 		return err
 	}
 	elapsed := time.Since(now)
@@ -208,26 +229,17 @@ func (btd *BoardToDiskHandler) saveToFile(s *Snapshot) error {
 	return nil
 }
 
-func (s *Snapshot) initializeFromFile(filename string) error {
-	file, err := os.Open(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	reader := bufio.NewReaderSize(file, 128*1024*1024)
-	err = binary.Read(reader, binary.LittleEndian, &s.Header)
+func (btd *BoardToDiskHandler) initializeFromStore(name string) error {
+	snapshot := &Snapshot{}
+	err := snapshot.initializeFromStore(btd.store, name)
 	if err != nil {
 		return err
 	}
-	s.PiecesAndCoords = make([]PieceAndCoords, s.Header.PieceCount)
-	err = binary.Read(reader, binary.LittleEndian, &s.PiecesAndCoords)
-	return err
+	return btd.initializeFromSnapshot(snapshot)
 }
 
-func (btd *BoardToDiskHandler) initializeFromFile(filename string) error {
-	snapshot := &Snapshot{}
-	err := snapshot.initializeFromFile(filename)
-	if err != nil {
+func (btd *BoardToDiskHandler) initializeFromSnapshot(snapshot *Snapshot) error {
+	if err := btd.board.setRulesFromHeader(&snapshot.Header); err != nil {
 		return err
 	}
 	btd.board.nextID = snapshot.Header.NextID
@@ -237,6 +249,8 @@ func (btd *BoardToDiskHandler) initializeFromFile(filename string) error {
 	btd.board.blackPiecesCaptured.Store(snapshot.Header.BlackPiecesCaptured)
 	btd.board.whiteKingsCaptured.Store(snapshot.Header.WhiteKingsCaptured)
 	btd.board.blackKingsCaptured.Store(snapshot.Header.BlackKingsCaptured)
+	btd.playerStats = NewPlayerStatsTracker()
+	btd.playerStats.load(snapshot.PlayerStats)
 	log.Printf("number of pieces at load: %d", len(snapshot.PiecesAndCoords))
 	for _, pc := range snapshot.PiecesAndCoords {
 		x, y := decodeCoords(pc.Coords)
@@ -245,7 +259,7 @@ func (btd *BoardToDiskHandler) initializeFromFile(filename string) error {
 	return nil
 }
 
-func extractTSFromSnapshotFilename(path string) (timestamp int64, err error) {
+func parseSnapshotFilename(path string) (timestamp int64, seq uint64, err error) {
 	name := filepath.Base(path)
 	withoutExt := strings.TrimSuffix(name, ".bin")
 	parts := strings.Split(withoutExt, "-")
@@ -255,19 +269,23 @@ func extractTSFromSnapshotFilename(path string) (timestamp int64, err error) {
 	}
 	prefix := parts[0]
 	ts := parts[1]
-	seq := parts[2]
-	if prefix != "board" || !strings.HasPrefix(ts, "ts:") || !strings.HasPrefix(seq, "seq:") {
+	seqPart := parts[2]
+	if prefix != "board" || !strings.HasPrefix(ts, "ts:") || !strings.HasPrefix(seqPart, "seq:") {
 		err = fmt.Errorf("Unexpected format: %s", path)
 		return
 	}
 	timestampString := strings.TrimPrefix(ts, "ts:")
 	timestamp, err = strconv.ParseInt(timestampString, 10, 64)
+	if err != nil {
+		return
+	}
+	seq, err = strconv.ParseUint(strings.TrimPrefix(seqPart, "seq:"), 10, 64)
 	return
 }
 
-func (btd *BoardToDiskHandler) SortedSnapshotFilenames() (file *string, err error) {
-	glob := filepath.Join(btd.stateDir, "board-*.bin")
-	files, err := filepath.Glob(glob)
+// Sorted from oldest to newest
+func (btd *BoardToDiskHandler) SortedSnapshotFilenames() (sorted []string, err error) {
+	files, err := btd.store.List("board-*.bin")
 	if err != nil || len(files) == 0 {
 		return
 	}
@@ -277,7 +295,7 @@ func (btd *BoardToDiskHandler) SortedSnapshotFilenames() (file *string, err erro
 	}
 	fileWithTimestamps := make([]FileWithTimestamp, 0, len(files))
 	for _, f := range files {
-		ts, err := extractTSFromSnapshotFilename(f)
+		ts, _, err := parseSnapshotFilename(f)
 		if err != nil {
 			log.Printf("ERROR? Could not extract ts from snapshot file: %v", err)
 			continue
@@ -293,46 +311,356 @@ func (btd *BoardToDiskHandler) SortedSnapshotFilenames() (file *string, err erro
 	slices.SortFunc(fileWithTimestamps, func(i, j FileWithTimestamp) int {
 		return int(i.Timestamp - j.Timestamp)
 	})
-	last := fileWithTimestamps[len(fileWithTimestamps)-1]
-	file = &last.File
+	sorted = make([]string, 0, len(fileWithTimestamps))
+	for _, f := range fileWithTimestamps {
+		sorted = append(sorted, f.File)
+	}
 	return
 }
 
-func NewBoardToDiskHandler(stateDir string) (*BoardToDiskHandler, error) {
+// Try our restore points from newest to oldest, skipping any that are
+// corrupt. If we fall back to an older one we'll replay more of the move logs.
+func (btd *BoardToDiskHandler) initializeFromNewestValidSnapshot(points []restorePoint) error {
+	for i := len(points) - 1; i >= 0; i-- {
+		log.Printf("Initializing from %s", points[i])
+		err := btd.initializeFromRestorePoint(points[i])
+		if err == nil {
+			return nil
+		}
+		btd.logger.Error().Str("error_kind", "loading_snapshot").Str("file", points[i].String()).AnErr("err", err).Send()
+		log.Printf("ERROR loading snapshot %s: %v", points[i], err)
+	}
+	return fmt.Errorf("none of our %d snapshots could be loaded", len(points))
+}
+
+type moveLogFile struct {
+	Name      string
+	Timestamp int64
+	FirstSeq  uint64
+	LastSeq   uint64
+	Open      bool
+}
+
+func moveLogFilename(timestampNs int64, firstSeq, lastSeq uint64) string {
+	return fmt.Sprintf("moves-ts:%d-firstseq:%d-lastseq:%d.bin", timestampNs, firstSeq, lastSeq)
+}
+
+// Move logs are named moves-ts:<ts>-firstseq:<first>-lastseq:<last>.bin, where
+// first and last are the (inclusive) seqnums of the requests in the file.
+//
+// Older logs are named moves-ts:<ts>-startseq:<start>-endseq:<end>.bin, using
+// the board's seqnum at the time that we serialized them. That was *after*
+// every request in the file had been applied, so "startseq" is really the
+// seqnum of the last request in the file and endseq - startseq is the number
+// of requests that the file contains.
+//
+// Either kind of name gets a .zst suffix once the log has been compressed.
+func parseMoveLogFilename(path string) (ml moveLogFile, err error) {
+	name := strings.TrimSuffix(filepath.Base(path), compressedMoveLogSuffix)
+	withoutExt := strings.TrimSuffix(name, ".bin")
+	parts := strings.Split(withoutExt, "-")
+	if len(parts) != 4 {
+		err = fmt.Errorf("wrong number of parts: %s", path)
+		return
+	}
+	prefix, ts, start, end := parts[0], parts[1], parts[2], parts[3]
+	legacy := strings.HasPrefix(start, "startseq:") && strings.HasPrefix(end, "endseq:")
+	exact := strings.HasPrefix(start, "firstseq:") && strings.HasPrefix(end, "lastseq:")
+	if prefix != "moves" || !strings.HasPrefix(ts, "ts:") || (!legacy && !exact) {
+		err = fmt.Errorf("Unexpected format: %s", path)
+		return
+	}
+	timestamp, err := strconv.ParseInt(strings.TrimPrefix(ts, "ts:"), 10, 64)
+	if err != nil {
+		return
+	}
+	startSeq, err := strconv.ParseUint(start[strings.Index(start, ":")+1:], 10, 64)
+	if err != nil {
+		return
+	}
+	endSeq, err := strconv.ParseUint(end[strings.Index(end, ":")+1:], 10, 64)
+	if err != nil {
+		return
+	}
+	if exact {
+		if endSeq < startSeq {
+			err = fmt.Errorf("Bad seqnum range: %s", path)
+			return
+		}
+		ml = moveLogFile{
+			Name:      path,
+			Timestamp: timestamp,
+			FirstSeq:  startSeq,
+			LastSeq:   endSeq,
+		}
+		return
+	}
+	count := endSeq - startSeq
+	if endSeq <= startSeq || count > startSeq {
+		err = fmt.Errorf("Bad seqnum range: %s", path)
+		return
+	}
+	ml = moveLogFile{
+		Name:      path,
+		Timestamp: timestamp,
+		FirstSeq:  startSeq - count + 1,
+		LastSeq:   startSeq,
+	}
+	return
+}
+
+// If we crashed while compressing a log we might have both the .bin and the
+// .bin.zst; we only return the .bin.
+func (btd *BoardToDiskHandler) SortedMoveLogs() ([]moveLogFile, error) {
+	files, err := btd.store.List("moves-*.bin")
+	if err != nil {
+		return nil, err
+	}
+	compressedFiles, err := btd.store.List("moves-*.bin" + compressedMoveLogSuffix)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]moveLogFile, 0, len(files)+len(compressedFiles))
+	haveUncompressed := make(map[string]bool, len(files))
+	for _, f := range files {
+		ml, err := parseMoveLogFilename(f)
+		if err != nil {
+			log.Printf("ERROR? Could not parse move log filename: %v", err)
+			continue
+		}
+		haveUncompressed[f] = true
+		logs = append(logs, ml)
+	}
+	for _, f := range compressedFiles {
+		if haveUncompressed[strings.TrimSuffix(f, compressedMoveLogSuffix)] {
+			continue
+		}
+		ml, err := parseMoveLogFilename(f)
+		if err != nil {
+			log.Printf("ERROR? Could not parse move log filename: %v", err)
+			continue
+		}
+		logs = append(logs, ml)
+	}
+	openFiles, err := btd.store.List("moves-*.bin" + openMoveLogSuffix)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range openFiles {
+		ml, _, err := scanOpenMoveLog(btd.store, f)
+		if err != nil {
+			log.Printf("ERROR? Could not scan open move log: %v", err)
+			continue
+		}
+		// If we died while sealing it, the sealed copy is already on our list
+		if ml.LastSeq < ml.FirstSeq || haveUncompressed[moveLogFilename(ml.Timestamp, ml.FirstSeq, ml.LastSeq)] {
+			continue
+		}
+		logs = append(logs, ml)
+	}
+	slices.SortFunc(logs, func(i, j moveLogFile) int {
+		if i.FirstSeq != j.FirstSeq {
+			if i.FirstSeq < j.FirstSeq {
+				return -1
+			}
+			return 1
+		}
+		return int(i.Timestamp - j.Timestamp)
+	})
+	return logs, nil
+}
+
+// Anything that we couldn't replay gets renamed so that it doesn't collide
+// with the seqnums that we're about to hand out again.
+func quarantineMoveLogs(store StateStore, logs []moveLogFile) {
+	for _, ml := range logs {
+		orphaned := "orphaned-" + ml.Name
+		log.Printf("Quarantining unreplayable move log %s -> %s", ml.Name, orphaned)
+		if err := store.Rename(ml.Name, orphaned); err != nil {
+			log.Printf("ERROR quarantining move log %s: %v", ml.Name, err)
+		}
+	}
+}
+
+// Our snapshot contains every request up to and including its seqnum. Replay
+// every request after that from our move logs so that a crash only loses
+// whatever we hadn't serialized yet.
+//
+// The first log that we apply is allowed to straddle the snapshot (we took
+// the snapshot between two move serializations), but after that every log
+// must start exactly where the previous one ended.
+func (btd *BoardToDiskHandler) replayMoveLogs() error {
+	return btd.replayMoveLogsUntil(nil)
+}
+
+// Like replayMoveLogs, but stops at the first request for which pastTarget
+// returns true (without applying it). A nil pastTarget replays everything.
+func (btd *BoardToDiskHandler) replayMoveLogsUntil(pastTarget func(boardToDiskRequest) bool) error {
+	logs, err := btd.SortedMoveLogs()
+	if err != nil {
+		return err
+	}
+	snapshotSeq := btd.board.seqNum
+	expected := snapshotSeq + 1
+	replayedFiles := 0
+	replayedRequests := 0
+	start := time.Now()
+	for i, ml := range logs {
+		if ml.LastSeq < expected {
+			continue
+		}
+		var chainErr error
+		if ml.FirstSeq > expected {
+			chainErr = fmt.Errorf("gap in move logs: expected seqnum %d but %s starts at %d",
+				expected, ml.Name, ml.FirstSeq)
+		} else if ml.FirstSeq < expected && replayedFiles > 0 {
+			chainErr = fmt.Errorf("overlapping move logs: expected seqnum %d but %s starts at %d",
+				expected, ml.Name, ml.FirstSeq)
+		}
+		if chainErr != nil {
+			btd.logger.Error().Str("error_kind", "broken_move_log_chain").AnErr("err", chainErr).Send()
+			if !*allowBrokenMoveLog {
+				return fmt.Errorf("%w (pass -allow-broken-move-log to start anyway)", chainErr)
+			}
+			log.Printf("WARNING: %v; stopping replay at seqnum %d", chainErr, btd.board.seqNum)
+			if !btd.readOnly {
+				quarantineMoveLogs(btd.store, logs[i:])
+			}
+			break
+		}
+
+		replayed, reachedTarget, err := btd.replayMoveLog(ml, expected, pastTarget)
+		replayedRequests += replayed
+		if err != nil {
+			return err
+		}
+		expected = ml.LastSeq + 1
+		replayedFiles++
+		if reachedTarget {
+			break
+		}
+	}
+	log.Printf("Replayed %d requests from %d move logs (seqnum %d -> %d) in %s",
+		replayedRequests, replayedFiles, snapshotSeq, btd.board.seqNum, time.Since(start))
+	btd.logger.Info().
+		Int("replayed_requests", replayedRequests).
+		Int("replayed_files", replayedFiles).
+		Uint64("snapshot_seqnum", snapshotSeq).
+		Uint64("replayed_to_seqnum", btd.board.seqNum).
+		Send()
+	return nil
+}
+
+// Apply every request in ml from expected onwards. Open segments (left
+// behind by a crash) may end with a torn record, so we only read as far as
+// the intact records that SortedMoveLogs found.
+func (btd *BoardToDiskHandler) replayMoveLog(
+	ml moveLogFile,
+	expected uint64,
+	pastTarget func(boardToDiskRequest) bool,
+) (replayed int, reachedTarget bool, err error) {
+	r, err := openMoveLog(btd.store, ml.Name)
+	if err != nil {
+		err = fmt.Errorf("reading move log %s: %w", ml.Name, err)
+		return
+	}
+	defer r.Close()
+	for seq := ml.FirstSeq; seq <= ml.LastSeq; seq++ {
+		var req boardToDiskRequest
+		req, err = r.Next()
+		if err == io.EOF {
+			err = fmt.Errorf("move log %s ends before seqnum %d but its name claims %d",
+				ml.Name, seq, ml.LastSeq)
+			return
+		} else if err != nil {
+			err = fmt.Errorf("reading move log %s: %w", ml.Name, err)
+			return
+		}
+		if req.Seqnum != 0 && req.Seqnum != seq {
+			err = fmt.Errorf("move log %s is not contiguous: got seqnum %d, expected %d",
+				ml.Name, req.Seqnum, seq)
+			return
+		}
+		if seq < expected {
+			continue
+		}
+		if pastTarget != nil && pastTarget(req) {
+			reachedTarget = true
+			return
+		}
+		btd.apply(req)
+		if btd.board.seqNum != seq {
+			err = fmt.Errorf("replaying %s: expected seqnum %d after applying %s but board is at %d",
+				ml.Name, seq, req.ToString(), btd.board.seqNum)
+			return
+		}
+		replayed++
+	}
+	if !ml.Open {
+		if _, nextErr := r.Next(); nextErr != io.EOF {
+			err = fmt.Errorf("move log %s has more requests than its name claims", ml.Name)
+		}
+	}
+	return
+}
+
+func newBoardToDiskHandler(store StateStore) *BoardToDiskHandler {
 	gob.Register(Move{})
 	gob.Register(adoptionRequest{})
 	gob.Register(bulkCaptureRequest{})
+	gob.Register(rollbackRequest{})
+	gob.Register(setPiecesRequest{})
+	gob.Register(ruleChangeRequest{})
 	gob.Register(boardToDiskRequest{})
 	ctx, cancel := context.WithCancel(context.Background())
 	wg := &sync.WaitGroup{}
 
 	btd := &BoardToDiskHandler{
-		stateDir:            stateDir,
-		requests:            make(chan boardToDiskRequest, 16384),
-		done:                make(chan struct{}, 1),
-		board:               NewBoard(false),
-		requestsToSerialize: make([]boardToDiskRequest, 0, MAX_MOVES_TO_SERIALIZE),
-		logger:              NewCoreLogger().With().Str("kind", "btd-handler").Logger(),
-		ctx:                 ctx,
-		cancel:              cancel,
-		wg:                  wg,
-	}
-	lastFile, err := btd.SortedSnapshotFilenames()
+		store:    store,
+		requests: make(chan boardToDiskRequest, 16384),
+		done:     make(chan struct{}, 1),
+		seals:    make(chan moveLogSeal, MOVE_LOG_SEAL_QUEUE),
+
+		snapshotRequests: make(chan chan *Snapshot),
+		board:            NewBoard(false),
+		playerStats:      NewPlayerStatsTracker(),
+		logger:           NewCoreLogger().With().Str("kind", "btd-handler").Logger(),
+		ctx:              ctx,
+		cancel:           cancel,
+		wg:               wg,
+		dirty:            newDirtyBoards(),
+	}
+	btd.needFullSnapshot.Store(true)
+	return btd
+}
+
+func NewBoardToDiskHandler(stateDir string) (*BoardToDiskHandler, error) {
+	store, err := NewStateStore(stateDir)
 	if err != nil {
 		return nil, err
 	}
-	if lastFile == nil {
+	log.Printf("Keeping state in %s", store.Describe())
+	btd := newBoardToDiskHandler(store)
+	points, err := btd.sortedRestorePoints()
+	if err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
 		log.Printf("No snapshot filenames found - initializing new board")
 		btd.board.InitializeRandom()
 		snap := btd.getSnapshot()
 		btd.saveToFile(snap)
 	} else {
-		log.Printf("Initializing from file %s", *lastFile)
-		err = btd.initializeFromFile(*lastFile)
+		err = btd.initializeFromNewestValidSnapshot(points)
+		if err != nil {
+			return nil, err
+		}
+		err = btd.replayMoveLogs()
 		if err != nil {
 			return nil, err
 		}
 	}
+	log.Printf("Playing by %s rules; pawns promote at the %s", btd.board.rules.Name(), btd.board.promotion.Name())
 	return btd, nil
 }
 
@@ -346,12 +674,19 @@ func (btd *BoardToDiskHandler) GetLiveBoard() *Board {
 	board.blackPiecesCaptured.Store(btd.board.blackPiecesCaptured.Load())
 	board.whiteKingsCaptured.Store(btd.board.whiteKingsCaptured.Load())
 	board.blackKingsCaptured.Store(btd.board.blackKingsCaptured.Load())
+	board.rules = btd.board.rules
+	board.promotion = btd.board.promotion
 	board.pieces = btd.board.pieces
 	duration := time.Since(now)
 	log.Printf("Time to get live board: %s", duration)
 	return board
 }
 
+// Like GetLiveBoard: call this before RunForever
+func (btd *BoardToDiskHandler) GetLivePlayerStats() *PlayerStatsTracker {
+	return btd.playerStats.clone()
+}
+
 func (req boardToDiskRequest) ToString() string {
 	switch {
 	case req.Move != nil:
@@ -360,6 +695,12 @@ func (req boardToDiskRequest) ToString() string {
 		return req.AdoptionRequest.ToString()
 	case req.BulkCaptureRequest != nil:
 		return req.BulkCaptureRequest.ToString()
+	case req.RollbackRequest != nil:
+		return req.RollbackRequest.ToString()
+	case req.SetPiecesRequest != nil:
+		return req.SetPiecesRequest.ToString()
+	case req.RuleChangeRequest != nil:
+		return req.RuleChangeRequest.ToString()
 	default:
 		return fmt.Sprintf("UNKNOWN REQ: %v", req)
 	}
@@ -387,72 +728,90 @@ func (btd *BoardToDiskHandler) apply(req boardToDiskRequest) {
 			context := fmt.Sprintf("Applied invalid move %s", req.Move.ToString())
 			btd.panicWithContext(context, req)
 		}
+		if btd.history != nil {
+			btd.history.recordMove(req, &res)
+		}
+		btd.dirty.markMoveResult(&res)
+		if _, ok := btd.playerStats.RecordMove(req.Move.PlayerID, &res); ok {
+			btd.dirty.markPlayer(req.Move.PlayerID)
+		}
 	case req.AdoptionRequest != nil:
 		_, err := btd.board.Adopt(req.AdoptionRequest)
 		if err != nil {
 			context := fmt.Sprintf("Received invalid adoption req %s", req.AdoptionRequest.ToString())
 			btd.panicWithContext(context, req)
 		}
+		btd.dirty.markBoard(req.AdoptionRequest.BoardX, req.AdoptionRequest.BoardY)
 	case req.BulkCaptureRequest != nil:
+		if btd.history != nil {
+			btd.history.recordBulkCapture(btd.board, req)
+		}
 		_, err := btd.board.DoBulkCapture(req.BulkCaptureRequest)
 		if err != nil {
 			context := fmt.Sprintf("Received invalid bulk capture req %s", req.BulkCaptureRequest.ToString())
 			btd.panicWithContext(context, req)
 		}
+		btd.dirty.markBoard(req.BulkCaptureRequest.BoardX, req.BulkCaptureRequest.BoardY)
+	case req.RollbackRequest != nil:
+		if btd.history != nil {
+			btd.history.recordRollback(btd.board, req)
+		}
+		_, err := btd.board.ApplyRollback(req.RollbackRequest)
+		if err != nil {
+			context := fmt.Sprintf("Received invalid rollback req %s", req.RollbackRequest.ToString())
+			btd.panicWithContext(context, req)
+		}
+		for _, sq := range req.RollbackRequest.Squares {
+			btd.dirty.markSquare(sq.X, sq.Y)
+		}
+	case req.SetPiecesRequest != nil:
+		if btd.history != nil {
+			btd.history.recordSetPieces(btd.board, req)
+		}
+		_, err := btd.board.SetPieces(req.SetPiecesRequest)
+		if err != nil {
+			context := fmt.Sprintf("Received invalid set pieces req %s", req.SetPiecesRequest.ToString())
+			btd.panicWithContext(context, req)
+		}
+		for _, sq := range req.SetPiecesRequest.Squares {
+			btd.dirty.markSquare(sq.X, sq.Y)
+		}
+	case req.RuleChangeRequest != nil:
+		_, err := btd.board.ChangeRules(req.RuleChangeRequest)
+		if err != nil {
+			context := fmt.Sprintf("Received invalid rule change req %s", req.RuleChangeRequest.ToString())
+			btd.panicWithContext(context, req)
+		}
 	default:
 		btd.logger.Error().Str("error_kind", "unrecognized_req").Str("req", req.ToString()).Send()
+		return
 	}
-}
-
-func writeRequestsToDisk(
-	path string,
-	toWrite []boardToDiskRequest,
-	firstSeqnum uint64,
-	lastSeqnum uint64,
-) error {
-	if *doNotSaveState {
-		return nil
+	if req.Seqnum != 0 && req.Seqnum != btd.board.seqNum {
+		context := fmt.Sprintf("Seqnum mismatch: live board applied %s at %d but we're at %d",
+			req.ToString(), req.Seqnum, btd.board.seqNum)
+		btd.panicWithContext(context, req)
 	}
-	return WriteFileAtomic(path, func(writer io.Writer) error {
-		buf := bufio.NewWriterSize(writer, 2*1024*1024)
-		enc := gob.NewEncoder(buf)
-		err := enc.Encode(toWrite)
-		if err != nil {
-			return err
-		}
-		enc.Encode(toWrite)
-		return buf.Flush()
-	})
 }
 
 func ReadAndPrintRequestsFromFile(filename string) error {
-	file, err := os.Open(filename)
+	r, err := OpenMoveLog(filename)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	reader := bufio.NewReaderSize(file, 4*1024*1024)
-	dec := gob.NewDecoder(reader)
-	requests := make([]boardToDiskRequest, 0, 128)
-	err = dec.Decode(&requests)
-	if err != nil {
-		return err
-	}
-	for _, req := range requests {
-		s := ""
-		switch {
-		case req.Move != nil:
-			s = req.Move.ToString()
-		case req.AdoptionRequest != nil:
-			s = req.AdoptionRequest.ToString()
-		case req.BulkCaptureRequest != nil:
-			s = req.BulkCaptureRequest.ToString()
-		default:
-			s = fmt.Sprintf("UNKNOWN REQ: %v", req)
+	defer r.Close()
+	for {
+		req, err := r.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if req.Seqnum != 0 {
+			ts := time.Unix(0, req.TimestampNs).UTC().Format(time.RFC3339Nano)
+			fmt.Printf("%d %s ", req.Seqnum, ts)
 		}
-		fmt.Println(s)
+		fmt.Println(req.ToString())
 	}
-	return nil
 }
 
 type PieceWithCount struct {
@@ -534,29 +893,117 @@ func PrintLivePieceStats(stateDir string) error {
 	return nil
 }
 
-func (btd *BoardToDiskHandler) maybeSerializeCurrentRequests(blocking bool) error {
-	if len(btd.requestsToSerialize) == 0 {
-		return nil
+func (btd *BoardToDiskHandler) logMoveLogError(errorKind string, err error) {
+	btd.logger.Error().Str("error_kind", errorKind).AnErr("err", err).Send()
+	log.Printf("ERROR WRITING MOVES (%s) %v", errorKind, err)
+}
+
+// If a write fails we give up on the segment and start a fresh one with the
+// next request. That leaves a gap in the chain, which replay will notice.
+func (btd *BoardToDiskHandler) appendToMoveLog(req boardToDiskRequest) {
+	if *doNotSaveState {
+		return
 	}
-	toWrite := make([]boardToDiskRequest, len(btd.requestsToSerialize))
-	copy(toWrite, btd.requestsToSerialize)
-	btd.requestsToSerialize = btd.requestsToSerialize[:0]
-	firstSeqnum := btd.board.seqNum
-	lastSeqnum := firstSeqnum + uint64(len(toWrite))
-	now := time.Now()
-	name := fmt.Sprintf("moves-ts:%d-startseq:%d-endseq:%d.bin", now.UnixNano(), firstSeqnum, lastSeqnum)
-	path := filepath.Join(btd.stateDir, name)
-	if blocking {
-		return writeRequestsToDisk(path, toWrite, firstSeqnum, lastSeqnum)
-	} else {
-		go func() {
-			err := writeRequestsToDisk(path, toWrite, firstSeqnum, lastSeqnum)
-			if err != nil {
-				btd.logger.Error().Str("error_kind", "writing_moves_to_disk").AnErr("err", err).Send()
-				log.Printf("ERROR WRITING MOVES %v", err)
+	if btd.moveLog == nil {
+		w, err := newMoveLogWriter(btd.store, req.Seqnum)
+		if err != nil {
+			btd.logMoveLogError("opening_move_log", err)
+			return
+		}
+		btd.moveLog = w
+	}
+	if err := btd.moveLog.append(req); err != nil {
+		btd.logMoveLogError("appending_to_move_log", err)
+		btd.rotateMoveLog()
+		return
+	}
+	if btd.moveLog.records >= MAX_MOVES_TO_SERIALIZE {
+		btd.logger.Info().Str("action", "serialize_early").Send()
+		btd.rotateMoveLog()
+	}
+}
+
+func (btd *BoardToDiskHandler) flushMoveLog() {
+	btd.handOffSeals()
+	if btd.moveLog == nil {
+		return
+	}
+	if err := btd.moveLog.flush(); err != nil {
+		btd.logMoveLogError("flushing_move_log", err)
+	}
+}
+
+// A segment that we're done with, and its piece history index (nil if we
+// aren't writing one)
+type moveLogSeal struct {
+	w       *moveLogWriter
+	history []PieceHistoryEntry
+}
+
+func (btd *BoardToDiskHandler) rotateMoveLog() {
+	if btd.moveLog == nil {
+		return
+	}
+	seal := moveLogSeal{w: btd.moveLog}
+	btd.moveLog = nil
+	if btd.history != nil && seal.w.records > 0 {
+		seal.history = btd.history.takeIndex(seal.w.firstSeq, seal.w.lastSeq)
+	}
+	btd.pendingSeals = append(btd.pendingSeals, seal)
+	btd.handOffSeals()
+}
+
+// Passes finished segments to sealMoveLogs, in order, without waiting for
+// room. Waiting would back up our requests channel and with it
+// processMoves, so if sealMoveLogs is behind we hold on to the rest and try
+// again when we next rotate or flush.
+func (btd *BoardToDiskHandler) handOffSeals() {
+	for len(btd.pendingSeals) > 0 {
+		select {
+		case btd.seals <- btd.pendingSeals[0]:
+			btd.pendingSeals[0] = moveLogSeal{}
+			btd.pendingSeals = btd.pendingSeals[1:]
+		default:
+			return
+		}
+	}
+}
+
+// Sealing a segment and writing its index means waiting on our store, which
+// might be slow (or down, if it's S3), so we do it here rather than on our
+// main loop. Segments are sealed in the order that we finished them.
+func (btd *BoardToDiskHandler) sealMoveLogs() {
+	defer btd.sealerWg.Done()
+	for seal := range btd.seals {
+		w := seal.w
+		if err := w.seal(); err != nil {
+			btd.logMoveLogError("sealing_move_log", err)
+			continue
+		}
+		if seal.history != nil {
+			if err := writePieceHistoryIndex(btd.store, w.firstSeq, w.lastSeq, seal.history); err != nil {
+				btd.logMoveLogError("writing_piece_history", err)
 			}
-		}()
-		return nil
+		}
+	}
+}
+
+// Anything still open was being written when we last went down. Replay has
+// already applied it; give it a proper name so that it's swept up with the
+// rest of our logs.
+func (btd *BoardToDiskHandler) sealOpenMoveLogs() {
+	if *doNotSaveState {
+		return
+	}
+	files, err := btd.store.List("moves-*.bin" + openMoveLogSuffix)
+	if err != nil {
+		btd.logMoveLogError("finding_open_move_logs", err)
+		return
+	}
+	for _, f := range files {
+		if err := sealOpenMoveLog(btd.store, f); err != nil {
+			btd.logMoveLogError("sealing_open_move_log", err)
+		}
 	}
 }
 
@@ -565,7 +1012,7 @@ func (btd *BoardToDiskHandler) drainRequests() {
 		select {
 		case req := <-btd.requests:
 			btd.apply(req)
-			btd.requestsToSerialize = append(btd.requestsToSerialize, req)
+			btd.appendToMoveLog(req)
 		default:
 			return
 		}
@@ -579,8 +1026,17 @@ func (btd *BoardToDiskHandler) GracefulShutdown() {
 	btd.wg.Wait()
 	log.Printf("BTD: Jobs finished, flushing queue")
 	btd.drainRequests()
-	log.Printf("Queue flushed, serializing %d requests", len(btd.requestsToSerialize))
-	btd.maybeSerializeCurrentRequests(true)
+	if btd.replication != nil {
+		btd.replication.close()
+	}
+	log.Printf("BTD: Queue flushed, sealing move logs")
+	btd.rotateMoveLog()
+	for _, seal := range btd.pendingSeals {
+		btd.seals <- seal
+	}
+	btd.pendingSeals = nil
+	close(btd.seals)
+	btd.sealerWg.Wait()
 	log.Printf("BTD: Getting snapshot")
 	snap := btd.getSnapshot()
 	log.Printf("BTD: Saving snapshot")
@@ -591,8 +1047,16 @@ func (btd *BoardToDiskHandler) GracefulShutdown() {
 func (btd *BoardToDiskHandler) RunForever() {
 	boardSerializationTicker := time.NewTicker(BOARD_SERIALIZATION_INTERVAL)
 	requestSerializationTicker := time.NewTicker(MOVE_SERIALIZATION_INTERVAL)
+	moveLogFlushTicker := time.NewTicker(MOVE_LOG_FLUSH_INTERVAL)
+	retentionTicker := time.NewTicker(RETENTION_INTERVAL)
 	btd.wg.Add(1)
 	defer btd.wg.Done()
+	btd.sealOpenMoveLogs()
+	btd.sealerWg.Add(1)
+	go btd.sealMoveLogs()
+	if !*doNotSaveState {
+		btd.history = newPieceHistoryBuilder()
+	}
 
 	for {
 		select {
@@ -600,21 +1064,39 @@ func (btd *BoardToDiskHandler) RunForever() {
 			return
 		case req := <-btd.requests:
 			btd.apply(req)
-			btd.requestsToSerialize = append(btd.requestsToSerialize, req)
-			if len(btd.requestsToSerialize) > MAX_MOVES_TO_SERIALIZE {
-				btd.logger.Info().Str("action", "serialize_early").Send()
-				btd.maybeSerializeCurrentRequests(false)
-			}
+			btd.appendToMoveLog(req)
 		case <-boardSerializationTicker.C:
-			snap := btd.getSnapshot()
-			go func() {
-				btd.saveToFile(snap)
-			}()
+			btd.takePeriodicSnapshot()
+		case <-moveLogFlushTicker.C:
+			btd.flushMoveLog()
+		case <-retentionTicker.C:
+			btd.maybeRunRetention()
 		case <-requestSerializationTicker.C:
-			btd.maybeSerializeCurrentRequests(false)
+			btd.rotateMoveLog()
+		case reply := <-btd.snapshotRequests:
+			reply <- btd.getSnapshot()
 		case <-btd.done:
 			log.Printf("Ending BTD loop")
 			return
 		}
 	}
 }
+
+// Get a snapshot from our main loop (which owns our board) without waiting
+// for the next snapshot tick
+func (btd *BoardToDiskHandler) requestSnapshot(ctx context.Context) (*Snapshot, error) {
+	reply := make(chan *Snapshot, 1)
+	select {
+	case btd.snapshotRequests <- reply:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-btd.ctx.Done():
+		return nil, btd.ctx.Err()
+	}
+	select {
+	case snapshot := <-reply:
+		return snapshot, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
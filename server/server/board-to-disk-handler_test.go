@@ -0,0 +1,182 @@
+package server
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Rule changes are always valid and don't need any pieces on the board, so
+// they make for easy move logs
+func testRuleChange(seq uint64) boardToDiskRequest {
+	return boardToDiskRequest{
+		Seqnum:            seq,
+		TimestampNs:       int64(seq) * 1000,
+		RuleChangeRequest: &ruleChangeRequest{PromotionRule: PromotionRuleID(seq % 2)},
+	}
+}
+
+// A move log with a rule change for each seqnum in [first, last]
+func testMoveLogBytes(t *testing.T, first, last uint64) []byte {
+	t.Helper()
+	b := appendMoveLogHeader(nil)
+	for seq := first; seq <= last; seq++ {
+		payload, err := appendRequestPayload(nil, testRuleChange(seq))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b = appendMoveLogRecord(b, payload)
+	}
+	return b
+}
+
+func putBytes(t *testing.T, store StateStore, name string, data []byte) {
+	t.Helper()
+	err := store.Put(name, func(writer io.Writer) error {
+		_, err := writer.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("put %s: %v", name, err)
+	}
+}
+
+type testMoveLog struct {
+	first, last uint64
+	// Named like a segment that we crashed while writing
+	open bool
+	// The record for last is cut short
+	torn bool
+}
+
+func (l testMoveLog) name(i int) string {
+	if l.open {
+		return openMoveLogFilename(int64(i+1), l.first)
+	}
+	return moveLogFilename(int64(i+1), l.first, l.last)
+}
+
+func TestReplayMoveLogs(t *testing.T) {
+	tests := []struct {
+		name        string
+		snapshotSeq uint64
+		logs        []testMoveLog
+		allowBroken bool
+		readOnly    bool
+		stopAt      uint64
+		wantSeq     uint64
+		wantErr     string
+		// Indexes into logs
+		wantQuarantined []int
+	}{
+		{
+			name:    "contiguous",
+			logs:    []testMoveLog{{first: 1, last: 10}, {first: 11, last: 20}, {first: 21, last: 25, open: true}},
+			wantSeq: 25,
+		},
+		{
+			name:        "first log straddles the snapshot",
+			snapshotSeq: 15,
+			logs:        []testMoveLog{{first: 1, last: 10}, {first: 11, last: 20}, {first: 21, last: 30}},
+			wantSeq:     30,
+		},
+		{
+			name:    "stops at the target",
+			logs:    []testMoveLog{{first: 1, last: 10}, {first: 11, last: 20}},
+			stopAt:  15,
+			wantSeq: 14,
+		},
+		{
+			name:    "gap",
+			logs:    []testMoveLog{{first: 1, last: 10}, {first: 12, last: 20}},
+			wantSeq: 10,
+			wantErr: "gap in move logs",
+		},
+		{
+			name:    "gap after the snapshot",
+			logs:    []testMoveLog{{first: 2, last: 10}},
+			wantErr: "gap in move logs",
+		},
+		{
+			name:    "overlap",
+			logs:    []testMoveLog{{first: 1, last: 10}, {first: 9, last: 20}},
+			wantSeq: 10,
+			wantErr: "overlapping move logs",
+		},
+		{
+			name:    "open log with a torn trailing record",
+			logs:    []testMoveLog{{first: 1, last: 10}, {first: 11, last: 15, open: true, torn: true}},
+			wantSeq: 14,
+		},
+		{
+			name:    "sealed log with a torn trailing record",
+			logs:    []testMoveLog{{first: 1, last: 10, torn: true}},
+			wantSeq: 9,
+			wantErr: "torn record",
+		},
+		{
+			name:            "quarantined after a gap",
+			logs:            []testMoveLog{{first: 1, last: 10}, {first: 12, last: 20}, {first: 21, last: 25, open: true}},
+			allowBroken:     true,
+			wantSeq:         10,
+			wantQuarantined: []int{1, 2},
+		},
+		{
+			name:            "quarantined after an overlap",
+			logs:            []testMoveLog{{first: 1, last: 10}, {first: 9, last: 20}},
+			allowBroken:     true,
+			wantSeq:         10,
+			wantQuarantined: []int{1},
+		},
+		{
+			name:        "read only doesn't quarantine",
+			logs:        []testMoveLog{{first: 1, last: 10}, {first: 12, last: 20}},
+			allowBroken: true,
+			readOnly:    true,
+			wantSeq:     10,
+		},
+	}
+	defer func(allow bool) { *allowBrokenMoveLog = allow }(*allowBrokenMoveLog)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*allowBrokenMoveLog = tt.allowBroken
+			store := NewMemoryStateStore()
+			for i, l := range tt.logs {
+				data := testMoveLogBytes(t, l.first, l.last)
+				if l.torn {
+					data = data[:len(data)-3]
+				}
+				putBytes(t, store, l.name(i), data)
+			}
+			btd := newBoardToDiskHandler(store)
+			btd.board.seqNum = tt.snapshotSeq
+			btd.readOnly = tt.readOnly
+			var pastTarget func(boardToDiskRequest) bool
+			if tt.stopAt != 0 {
+				pastTarget = func(req boardToDiskRequest) bool { return req.Seqnum >= tt.stopAt }
+			}
+
+			err := btd.replayMoveLogsUntil(pastTarget)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+			if btd.board.seqNum != tt.wantSeq {
+				t.Errorf("replayed to seqnum %d, want %d", btd.board.seqNum, tt.wantSeq)
+			}
+
+			for i, l := range tt.logs {
+				quarantined := slices.Contains(tt.wantQuarantined, i)
+				_, _, origErr := store.Get(l.name(i))
+				_, _, orphanErr := store.Get("orphaned-" + l.name(i))
+				if quarantined && (origErr == nil || orphanErr != nil) {
+					t.Errorf("%s should have been quarantined", l.name(i))
+				} else if !quarantined && (origErr != nil || orphanErr == nil) {
+					t.Errorf("%s shouldn't have been quarantined", l.name(i))
+				}
+			}
+		})
+	}
+}
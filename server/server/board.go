@@ -25,6 +25,8 @@ type Board struct {
 	blackPiecesCaptured                       atomic.Uint32
 	whiteKingsCaptured                        atomic.Uint32
 	blackKingsCaptured                        atomic.Uint32
+	rules                                     RuleSet
+	promotion                                 PromotionRule
 	mutexTimeLogger_USEHELPERS_YOUFUCK        zerolog.Logger
 	snapshotDurationLogger_USEHELPERS_YOUFUCK zerolog.Logger
 	generalLogger                             zerolog.Logger
@@ -37,6 +39,11 @@ type GameStats struct {
 	WhiteKingsRemaining  uint32
 	BlackKingsRemaining  uint32
 	Seqnum               uint64
+	// Only filled in by BoardOutcomeTracker.AddCounts
+	WhiteCheckmatedBoards uint32
+	BlackCheckmatedBoards uint32
+	WhiteStalematedBoards uint32
+	BlackStalematedBoards uint32
 }
 
 func NewBoard(doLogging bool) *Board {
@@ -48,6 +55,8 @@ func NewBoard(doLogging bool) *Board {
 		blackPiecesCaptured: atomic.Uint32{},
 		whiteKingsCaptured:  atomic.Uint32{},
 		blackKingsCaptured:  atomic.Uint32{},
+		rules:               standardRules{},
+		promotion:           worldEdgePromotion{},
 		doLogging:           doLogging,
 		rawRowsPool: sync.Pool{
 			New: func() any {
@@ -110,6 +119,7 @@ type MoveResult struct {
 	CapturedPiece CaptureResult
 	Seqnum        uint64
 	WinningMove   bool
+	Promoted      bool
 }
 
 func (b *Board) crossedSquaresAreEmpty(fromX, fromY, toX, toY uint16) bool {
@@ -262,14 +272,26 @@ func (b *Board) satisfiesMoveRules(movedPiece Piece, capturedPiece Piece, move M
 		return b.satisfiesQueenMoveRules(move)
 	case PromotedPawn:
 		return b.satisfiesQueenMoveRules(move)
+	case PromotedKnight:
+		return b.satisfiesKnightMoveRules(move)
+	case PromotedBishop:
+		return b.satisfiesBishopMoveRules(move)
+	case PromotedRook:
+		return b.satisfiesRookMoveRules(move)
+	case Archbishop:
+		return b.satisfiesKnightMoveRules(move) || b.satisfiesBishopMoveRules(move)
+	case Chancellor:
+		return b.satisfiesKnightMoveRules(move) || b.satisfiesRookMoveRules(move)
+	case Amazon:
+		return b.satisfiesKnightMoveRules(move) || b.satisfiesQueenMoveRules(move)
 	case King:
 		return b.satisfiesKingMoveRules(move)
 	}
 	return true
 }
 
-func (b *Board) DoBulkCapture(bulkCaptureRequest *bulkCaptureRequest) (*protocol.ServerBulkCapture, error) {
-	capturedPieces := make([]uint32, 0, 16)
+func (b *Board) DoBulkCapture(bulkCaptureRequest *bulkCaptureRequest) (*ChangeSet, error) {
+	changes := &ChangeSet{Removed: make([]PieceAtSquare, 0, 16)}
 	onlyColor := bulkCaptureRequest.OnlyColor()
 	startingX := bulkCaptureRequest.StartingX()
 	startingY := bulkCaptureRequest.StartingY()
@@ -314,7 +336,7 @@ func (b *Board) DoBulkCapture(bulkCaptureRequest *bulkCaptureRequest) (*protocol
 				}
 			}
 
-			capturedPieces = append(capturedPieces, p.ID)
+			changes.Removed = append(changes.Removed, PieceAtSquare{Piece: p, X: x, Y: y})
 			b.pieces[y][x] = uint64(EmptyEncodedPiece)
 		}
 	}
@@ -322,21 +344,15 @@ func (b *Board) DoBulkCapture(bulkCaptureRequest *bulkCaptureRequest) (*protocol
 	b.maybeLogSpecialMutexAction(took, "is_clear_board")
 
 	b.seqNum++
-	seqNum := b.seqNum
-
-	return &protocol.ServerBulkCapture{
-		CapturedIds: capturedPieces,
-		Seqnum:      seqNum,
-	}, nil
-}
-
-type AdoptionResult struct {
-	AdoptedPieces []uint32
-	Seqnum        uint64
+	changes.Seqnum = b.seqNum
+	return changes, nil
 }
 
-func (b *Board) Adopt(adoptionRequest *adoptionRequest) (*AdoptionResult, error) {
-	adoptedPieces := make([]uint32, 0, 16)
+func (b *Board) Adopt(adoptionRequest *adoptionRequest) (*ChangeSet, error) {
+	changes := &ChangeSet{
+		Removed: make([]PieceAtSquare, 0, 16),
+		Placed:  make([]PieceAtSquare, 0, 16),
+	}
 	onlyColor := adoptionRequest.OnlyColor()
 	startingX := adoptionRequest.StartingX()
 	startingY := adoptionRequest.StartingY()
@@ -368,21 +384,103 @@ func (b *Board) Adopt(adoptionRequest *adoptionRequest) (*AdoptionResult, error)
 			} else if onlyColor == OnlyColorBlack && p.IsWhite {
 				continue
 			}
+			changes.Removed = append(changes.Removed, PieceAtSquare{Piece: p, X: x, Y: y})
 			p.Adopted = true
 			b.pieces[y][x] = uint64(p.Encode())
-			adoptedPieces = append(adoptedPieces, p.ID)
+			changes.Placed = append(changes.Placed, PieceAtSquare{Piece: p, X: x, Y: y})
 		}
 	}
 	took := time.Since(now).Nanoseconds()
 	b.maybeLogSpecialMutexAction(took, "is_adoption")
 
 	b.seqNum++
-	seqNum := b.seqNum
+	changes.Seqnum = b.seqNum
+	return changes, nil
+}
+
+// Put Piece (which may be empty) on a square, whatever was there before
+type squareAssignment struct {
+	X     uint16
+	Y     uint16
+	Piece EncodedPiece
+}
+
+type PieceAtSquare struct {
+	Piece Piece
+	X     uint16
+	Y     uint16
+}
+
+func (b *Board) ApplyRollback(req *rollbackRequest) (*ChangeSet, error) {
+	return b.assignSquares(req.Squares, "is_rollback")
+}
+
+func (b *Board) SetPieces(req *setPiecesRequest) (*ChangeSet, error) {
+	return b.assignSquares(req.Squares, "is_set_pieces")
+}
+
+// Removing a piece counts as capturing it and placing one as uncapturing
+// it, so a piece that just moves doesn't change our stats.
+func (b *Board) assignSquares(squares []squareAssignment, kind string) (*ChangeSet, error) {
+	for _, sq := range squares {
+		if sq.X >= BOARD_SIZE || sq.Y >= BOARD_SIZE {
+			log.Printf("BUG: assignSquares: out of bounds: %d %d", sq.X, sq.Y)
+			b.generalLogger.Error().Str("error_kind", "assign_squares_out_of_bounds").
+				Uint16("x", sq.X).Uint16("y", sq.Y).
+				Send()
+			return nil, fmt.Errorf("out of bounds: %d %d", sq.X, sq.Y)
+		}
+	}
+
+	result := &ChangeSet{
+		Removed: make([]PieceAtSquare, 0, len(squares)),
+		Placed:  make([]PieceAtSquare, 0, len(squares)),
+	}
+	now := time.Now()
+	b.Lock()
+	defer b.Unlock()
+
+	for _, sq := range squares {
+		old := EncodedPiece(b.pieces[sq.Y][sq.X])
+		if !EncodedIsEmpty(old) {
+			removed := PieceOfEncodedPiece(old)
+			b.adjustCapturedCounts(removed, true)
+			result.Removed = append(result.Removed, PieceAtSquare{Piece: removed, X: sq.X, Y: sq.Y})
+		}
+		if !EncodedIsEmpty(sq.Piece) {
+			placed := PieceOfEncodedPiece(sq.Piece)
+			b.adjustCapturedCounts(placed, false)
+			result.Placed = append(result.Placed, PieceAtSquare{Piece: placed, X: sq.X, Y: sq.Y})
+			// Replaying a request that handed out IDs has to hand them out here too
+			b.nextID = max(b.nextID, placed.ID+1)
+		}
+		b.pieces[sq.Y][sq.X] = uint64(sq.Piece)
+	}
+	took := time.Since(now).Nanoseconds()
+	b.maybeLogSpecialMutexAction(took, kind)
+
+	b.seqNum++
+	result.Seqnum = b.seqNum
+	return result, nil
+}
 
-	return &AdoptionResult{
-		AdoptedPieces: adoptedPieces,
-		Seqnum:        seqNum,
-	}, nil
+// Call with the write lock held
+func (b *Board) adjustCapturedCounts(p Piece, captured bool) {
+	delta := uint32(1)
+	if !captured {
+		delta = ^uint32(0)
+	}
+	if p.IsWhite {
+		b.whitePiecesCaptured.Add(delta)
+		if p.Type == King {
+			b.whiteKingsCaptured.Add(delta)
+		}
+	} else {
+		b.blackPiecesCaptured.Add(delta)
+		if p.Type == King {
+			b.blackKingsCaptured.Add(delta)
+		}
+	}
 }
 
 // this can't handle multiple writers because it releases its read lock before
@@ -399,7 +497,7 @@ func (b *Board) ValidateAndApplyMove__NOTTHREADSAFE(move Move) MoveResult {
 		return MoveResult{Valid: false}
 	}
 
-	if move.ExceedsMaxMoveDistance() {
+	if !b.rules.MoveInRange(move) || !b.rules.AllowsMoveType(move.MoveType) {
 		return MoveResult{Valid: false}
 	}
 
@@ -426,11 +524,9 @@ func (b *Board) ValidateAndApplyMove__NOTTHREADSAFE(move Move) MoveResult {
 
 	movedPiece := PieceOfEncodedPiece(EncodedPiece(raw))
 
-	// Can't move an opponent's piece
-	if move.ClientIsPlayingWhite != movedPiece.IsWhite {
-		if RESPECT_COLOR_REQUIREMENT {
-			return MoveResult{Valid: false}
-		}
+	// Usually, can't move an opponent's piece
+	if !b.rules.MayMove(move, movedPiece) {
+		return MoveResult{Valid: false}
 	}
 
 	// piece ID must match
@@ -439,6 +535,11 @@ func (b *Board) ValidateAndApplyMove__NOTTHREADSAFE(move Move) MoveResult {
 		return MoveResult{Valid: false}
 	}
 
+	// Only a pawn reaching the far edge gets to pick what it becomes
+	if move.Promotion != Pawn && move.MoveType != protocol.MoveType_MOVE_TYPE_NORMAL {
+		return MoveResult{Valid: false}
+	}
+
 	switch move.MoveType {
 	case protocol.MoveType_MOVE_TYPE_CASTLE:
 		// Must be a king
@@ -500,6 +601,10 @@ func (b *Board) ValidateAndApplyMove__NOTTHREADSAFE(move Move) MoveResult {
 			return MoveResult{Valid: false}
 		}
 
+		if b.rules.CastlesThroughCheck(b, move, movedPiece.IsWhite) {
+			return MoveResult{Valid: false}
+		}
+
 		rookToY := uint16(move.ToY)
 		rookToX := uint16(move.ToX)
 
@@ -509,9 +614,18 @@ func (b *Board) ValidateAndApplyMove__NOTTHREADSAFE(move Move) MoveResult {
 			rookToX += 1
 		}
 
-		// That's it! Apply the move
 		movedPiece.MoveCount = 1
 		rookPiece.MoveCount = 1
+		if b.rules.LeavesKingInCheck(b, move, movedPiece.IsWhite,
+			squareWrite{move.FromX, move.FromY, EmptyEncodedPiece},
+			squareWrite{uint16(rookFromX), rookFromY, EmptyEncodedPiece},
+			squareWrite{move.ToX, move.ToY, movedPiece.Encode()},
+			squareWrite{rookToX, rookToY, rookPiece.Encode()},
+		) {
+			return MoveResult{Valid: false}
+		}
+
+		// That's it! Apply the move
 		haveReadLock = false
 		b.RUnlock()
 		now := time.Now()
@@ -595,10 +709,18 @@ func (b *Board) ValidateAndApplyMove__NOTTHREADSAFE(move Move) MoveResult {
 			return MoveResult{Valid: false}
 		}
 
-		// That's it! Apply the move
 		movedPiece.IncrementMoveCount()
 		movedPiece.IncrementCaptureCount()
 		movedPiece.JustDoubleMoved = false
+		if b.rules.LeavesKingInCheck(b, move, movedPiece.IsWhite,
+			squareWrite{move.FromX, move.FromY, EmptyEncodedPiece},
+			squareWrite{capturedX, capturedY, EmptyEncodedPiece},
+			squareWrite{move.ToX, move.ToY, movedPiece.Encode()},
+		) {
+			return MoveResult{Valid: false}
+		}
+
+		// That's it! Apply the move
 		haveReadLock = false
 		b.RUnlock()
 		now := time.Now()
@@ -646,40 +768,28 @@ func (b *Board) ValidateAndApplyMove__NOTTHREADSAFE(move Move) MoveResult {
 			if capturedPiece.IsWhite == movedPiece.IsWhite {
 				return MoveResult{Valid: false}
 			}
-
-			startBoardX := move.FromX / 8
-			startBoardY := move.FromY / 8
-			endBoardX := move.ToX / 8
-			endBoardY := move.ToY / 8
-
-			// captures must be on the same board unless the target
-			// has already moved
-			if startBoardX != endBoardX || startBoardY != endBoardY {
-				if capturedPiece.MoveCount == 0 {
-					return MoveResult{Valid: false}
-				}
-			}
 		}
 
 		// Must satisfy move rules
-		if !b.satisfiesMoveRules(movedPiece, capturedPiece, move) {
+		if !b.rules.ValidateNormalMove(b, movedPiece, capturedPiece, move) {
 			return MoveResult{Valid: false}
 		}
 
-		// Pawns must handle double move, promotion
-		if movedPiece.Type == Pawn {
-			dy := int32(move.ToY) - int32(move.FromY)
-			if dy == 2 || dy == -2 {
-				movedPiece.JustDoubleMoved = true
-			} else {
-				movedPiece.JustDoubleMoved = false
-			}
+		// Our own piece only blocks for our king, so it doesn't matter
+		// that we haven't promoted it yet
+		if b.rules.LeavesKingInCheck(b, move, movedPiece.IsWhite,
+			squareWrite{move.FromX, move.FromY, EmptyEncodedPiece},
+			squareWrite{move.ToX, move.ToY, movedPiece.Encode()},
+		) {
+			return MoveResult{Valid: false}
+		}
 
-			if move.ToY == 0 && movedPiece.IsWhite {
-				movedPiece.Type = PromotedPawn
-			} else if move.ToY == BOARD_SIZE-1 && !movedPiece.IsWhite {
-				movedPiece.Type = PromotedPawn
-			}
+		promoted, ok := b.rules.ApplyNormalMove(b, &movedPiece, move)
+		if !ok {
+			return MoveResult{Valid: false}
+		}
+		if !promoted && move.Promotion != Pawn {
+			return MoveResult{Valid: false}
 		}
 		movedPiece.IncrementMoveCount()
 		winningMove := false
@@ -698,17 +808,13 @@ func (b *Board) ValidateAndApplyMove__NOTTHREADSAFE(move Move) MoveResult {
 				}
 			}
 			if capturedPiece.Type == King {
+				var count uint32
 				if capturedPiece.IsWhite {
-					count := b.whiteKingsCaptured.Add(1)
-					if count == TOTAL_KINGS_PER_SIDE {
-						winningMove = true
-					}
+					count = b.whiteKingsCaptured.Add(1)
 				} else {
-					count := b.blackKingsCaptured.Add(1)
-					if count == TOTAL_KINGS_PER_SIDE {
-						winningMove = true
-					}
+					count = b.blackKingsCaptured.Add(1)
 				}
+				winningMove = b.rules.EndsGame(capturedPiece, count)
 				movedPiece.KingKiller = true
 				if movedPiece.Type == Pawn {
 					movedPiece.KingPawner = true
@@ -754,6 +860,7 @@ func (b *Board) ValidateAndApplyMove__NOTTHREADSAFE(move Move) MoveResult {
 				},
 				Seqnum:      seqNum,
 				WinningMove: winningMove,
+				Promoted:    promoted,
 			}
 		} else {
 			return MoveResult{
@@ -761,6 +868,7 @@ func (b *Board) ValidateAndApplyMove__NOTTHREADSAFE(move Move) MoveResult {
 				MovedPieces: movedPieces,
 				Seqnum:      seqNum,
 				WinningMove: winningMove,
+				Promoted:    promoted,
 			}
 		}
 	default:
@@ -857,12 +965,19 @@ func (b *Board) GetBoardSnapshot_RETURN_TO_POOL_AFTER_YOU_FUCK(pos Position) *pr
 	return snapshot
 }
 
-func (b *Board) setupPiecesForColor(boardX, boardY uint16, isWhite bool) {
+func (b *Board) setupPiecesForColor(boardX, boardY uint16, isWhite bool, layout StartingLayout) {
+	for _, sq := range b.startingPiecesForColor(boardX, boardY, isWhite, layout) {
+		b.pieces[sq.Y][sq.X] = uint64(sq.Piece)
+	}
+}
+
+// Uses up 16 IDs
+func (b *Board) startingPiecesForColor(boardX, boardY uint16, isWhite bool, layout StartingLayout) []squareAssignment {
 	baseX := boardX * SINGLE_BOARD_SIZE
 	baseY := boardY * SINGLE_BOARD_SIZE
 	if baseX > BOARD_SIZE-SINGLE_BOARD_SIZE || baseY > BOARD_SIZE-SINGLE_BOARD_SIZE {
 		log.Printf("Board section is out of bounds: %d, %d", baseX, baseY)
-		return
+		return nil
 	}
 
 	var pawnRow, pieceRow uint16
@@ -875,16 +990,18 @@ func (b *Board) setupPiecesForColor(boardX, boardY uint16, isWhite bool) {
 		pawnRow = baseY + 1 // Second row for black pawns
 	}
 
+	squares := make([]squareAssignment, 0, 16)
 	for x := uint16(0); x < 8; x++ {
 		piece := b.createPiece(Pawn, isWhite)
-		b.pieces[pawnRow][baseX+x] = uint64(piece.Encode())
+		squares = append(squares, squareAssignment{X: baseX + x, Y: pawnRow, Piece: piece.Encode()})
 	}
 
-	pieceTypes := []protocol.PieceType{Rook, Knight, Bishop, Queen, King, Bishop, Knight, Rook}
+	pieceTypes := startingLayouts[layout].BackRank
 	for x := uint16(0); x < 8; x++ {
 		piece := b.createPiece(pieceTypes[x], isWhite)
-		b.pieces[pieceRow][baseX+x] = uint64(piece.Encode())
+		squares = append(squares, squareAssignment{X: baseX + x, Y: pieceRow, Piece: piece.Encode()})
 	}
+	return squares
 }
 
 func (b *Board) createPiece(pieceType protocol.PieceType, isWhite bool) Piece {
@@ -917,13 +1034,13 @@ func (b *Board) InitializeRandom() {
 				includeBlack = good
 			}
 			if includeWhite {
-				b.setupPiecesForColor(startX+uint16(dx), startY+uint16(dy), true)
+				b.setupPiecesForColor(startX+uint16(dx), startY+uint16(dy), true, LayoutClassic)
 			} else {
 				b.whitePiecesCaptured.Add(16)
 				b.whiteKingsCaptured.Add(1)
 			}
 			if includeBlack {
-				b.setupPiecesForColor(startX+uint16(dx), startY+uint16(dy), false)
+				b.setupPiecesForColor(startX+uint16(dx), startY+uint16(dy), false, LayoutClassic)
 			} else {
 				b.blackPiecesCaptured.Add(16)
 				b.blackKingsCaptured.Add(1)
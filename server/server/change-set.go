@@ -0,0 +1,58 @@
+package server
+
+// Every way that the board changes comes down to some pieces coming off of
+// squares and some pieces going onto them, so that's what each mutation
+// reports (and what goes out with each BoardEvent). Anything that we derive
+// from the board (the minimap, the leaderboards) updates from these and only
+// these, in the order that processMoves applied them, so it can't drift from
+// the board no matter how the board got changed.
+//
+// A piece that moves or changes (a promotion, an adoption) shows up in both
+// Removed and Placed. Placed pieces are as they are now. Removed pieces are
+// as they were, except that a move only knows about its moved pieces after
+// the fact; their IDs, colors and types are all that you should rely on.
+type ChangeSet struct {
+	Removed []PieceAtSquare
+	Placed  []PieceAtSquare
+	Seqnum  uint64
+}
+
+func (cs *ChangeSet) RemovedIDs() []uint32 {
+	ids := make([]uint32, 0, len(cs.Removed))
+	for _, p := range cs.Removed {
+		ids = append(ids, p.Piece.ID)
+	}
+	return ids
+}
+
+func (cs *ChangeSet) PlacedIDs() []uint32 {
+	ids := make([]uint32, 0, len(cs.Placed))
+	for _, p := range cs.Placed {
+		ids = append(ids, p.Piece.ID)
+	}
+	return ids
+}
+
+func (res *MoveResult) ChangeSet() *ChangeSet {
+	cs := &ChangeSet{
+		Removed: make([]PieceAtSquare, 0, len(res.MovedPieces)+1),
+		Placed:  make([]PieceAtSquare, 0, len(res.MovedPieces)),
+		Seqnum:  res.Seqnum,
+	}
+	if !res.CapturedPiece.Piece.IsEmpty() {
+		cs.Removed = append(cs.Removed, PieceAtSquare{
+			Piece: res.CapturedPiece.Piece,
+			X:     res.CapturedPiece.X,
+			Y:     res.CapturedPiece.Y,
+		})
+	}
+	for _, moved := range res.MovedPieces {
+		before := moved.Piece
+		if res.Promoted {
+			before.Type = Pawn
+		}
+		cs.Removed = append(cs.Removed, PieceAtSquare{Piece: before, X: moved.FromX, Y: moved.FromY})
+		cs.Placed = append(cs.Placed, PieceAtSquare{Piece: moved.Piece, X: moved.ToX, Y: moved.ToY})
+	}
+	return cs
+}
@@ -0,0 +1,498 @@
+package server
+
+import (
+	"log"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"one-million-chessboards/protocol"
+)
+
+// Classical boards: with -rules classical, each 8x8 board plays a little
+// more like a normal game of chess. For moves that start and end on the
+// same board we reject anything that leaves one of your kings on that board
+// attacked by the other side's pieces on that board (which includes
+// castling out of or through check), and after every change to the board
+// we work out whether either side is checkmated or stalemated on the boards
+// that it touched.
+//
+// Everything here only looks at one board at a time. Pieces on neighbouring
+// boards don't give check, and moves off the board don't get you out of it.
+// We don't bother with castling when looking for a way out (it can never get
+// you out of check, and would only matter for the silliest of stalemates),
+// but we do look at en passant, which can capture a checking pawn.
+//
+// It's a RuleSet like any other, so the mode is in every snapshot header
+// and switching it is a rule change in the move log: replays and replicas
+// check each move under the rules that it was made under. Outcomes are
+// derived from the board and only the live server tracks them, starting and
+// stopping whenever a rule change (ours or our primary's) switches modes.
+
+type BoardOutcomeResult struct {
+	BoardX uint16
+	BoardY uint16
+	White  protocol.BoardOutcome
+	Black  protocol.BoardOutcome
+}
+
+func boardIndex(boardX, boardY uint16) uint32 {
+	return uint32(boardY)*BOARDS_PER_SIDE + uint32(boardX)
+}
+
+func onSameBoard(fromX, fromY, toX, toY uint16) bool {
+	return fromX/SINGLE_BOARD_SIZE == toX/SINGLE_BOARD_SIZE &&
+		fromY/SINGLE_BOARD_SIZE == toY/SINGLE_BOARD_SIZE
+}
+
+// Just what we need to know about a square to play chess on it. Decoding
+// whole pieces is slow enough to matter when we check every board at startup.
+type localSquare struct {
+	occupied        bool
+	isWhite         bool
+	unmoved         bool
+	justDoubleMoved bool
+	kind            protocol.PieceType
+}
+
+func localSquareOf(raw EncodedPiece) localSquare {
+	if EncodedIsEmpty(raw) {
+		return localSquare{}
+	}
+	return localSquare{
+		occupied:        true,
+		isWhite:         raw&isWhiteMask != 0,
+		unmoved:         raw&moveCountMask == 0,
+		justDoubleMoved: raw&justDoubleMovedMask != 0,
+		kind:            protocol.PieceType((raw & typeMask) >> PieceTypeShift),
+	}
+}
+
+// A copy of one 8x8 board, in local coordinates
+type localBoard [SINGLE_BOARD_SIZE][SINGLE_BOARD_SIZE]localSquare
+
+// Call with a lock held
+func (b *Board) loadLocalBoard(boardX, boardY uint16) localBoard {
+	var lb localBoard
+	startX := int(boardX) * SINGLE_BOARD_SIZE
+	startY := int(boardY) * SINGLE_BOARD_SIZE
+	for y := 0; y < SINGLE_BOARD_SIZE; y++ {
+		for x := 0; x < SINGLE_BOARD_SIZE; x++ {
+			lb[y][x] = localSquareOf(EncodedPiece(b.pieces[startY+y][startX+x]))
+		}
+	}
+	return lb
+}
+
+func inLocalBoard(x, y int) bool {
+	return x >= 0 && x < SINGLE_BOARD_SIZE && y >= 0 && y < SINGLE_BOARD_SIZE
+}
+
+var (
+	knightOffsets    = [8][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingOffsets      = [8][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	rookDirections   = [4][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	bishopDirections = [4][2]int{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+)
+
+func slidesOrthogonally(t protocol.PieceType) bool {
+	return t == Rook || t == Queen || t == PromotedPawn || t == PromotedRook ||
+		t == Chancellor || t == Amazon
+}
+
+func slidesDiagonally(t protocol.PieceType) bool {
+	return t == Bishop || t == Queen || t == PromotedPawn || t == PromotedBishop ||
+		t == Archbishop || t == Amazon
+}
+
+func leapsLikeKnight(t protocol.PieceType) bool {
+	return t == Knight || t == PromotedKnight || t == Archbishop || t == Chancellor || t == Amazon
+}
+
+func (lb *localBoard) attackedBy(x, y int, byWhite bool) bool {
+	attackerIs := func(ax, ay int, matches func(protocol.PieceType) bool) bool {
+		if !inLocalBoard(ax, ay) {
+			return false
+		}
+		sq := lb[ay][ax]
+		return sq.occupied && sq.isWhite == byWhite && matches(sq.kind)
+	}
+
+	// white pawns move up, so they attack from below
+	pawnY := y - 1
+	if byWhite {
+		pawnY = y + 1
+	}
+	isPawn := func(t protocol.PieceType) bool { return t == Pawn }
+	if attackerIs(x-1, pawnY, isPawn) || attackerIs(x+1, pawnY, isPawn) {
+		return true
+	}
+
+	for _, o := range knightOffsets {
+		if attackerIs(x+o[0], y+o[1], leapsLikeKnight) {
+			return true
+		}
+	}
+
+	isKing := func(t protocol.PieceType) bool { return t == King }
+	for _, o := range kingOffsets {
+		if attackerIs(x+o[0], y+o[1], isKing) {
+			return true
+		}
+	}
+
+	return lb.slidingAttack(x, y, byWhite, rookDirections[:], slidesOrthogonally) ||
+		lb.slidingAttack(x, y, byWhite, bishopDirections[:], slidesDiagonally)
+}
+
+func (lb *localBoard) slidingAttack(x, y int, byWhite bool, directions [][2]int, slides func(protocol.PieceType) bool) bool {
+	for _, d := range directions {
+		ax, ay := x+d[0], y+d[1]
+		for inLocalBoard(ax, ay) {
+			if sq := lb[ay][ax]; sq.occupied {
+				if sq.isWhite == byWhite && slides(sq.kind) {
+					return true
+				}
+				break
+			}
+			ax += d[0]
+			ay += d[1]
+		}
+	}
+	return false
+}
+
+// Whether isWhite has any kings on this board, and whether any of them are attacked
+func (lb *localBoard) kingsInCheck(isWhite bool) (hasKing bool, inCheck bool) {
+	for y := 0; y < SINGLE_BOARD_SIZE; y++ {
+		for x := 0; x < SINGLE_BOARD_SIZE; x++ {
+			sq := lb[y][x]
+			if !sq.occupied || sq.kind != King || sq.isWhite != isWhite {
+				continue
+			}
+			hasKing = true
+			if lb.attackedBy(x, y, !isWhite) {
+				return true, true
+			}
+		}
+	}
+	return hasKing, false
+}
+
+// Where the piece at (x, y) could move to without leaving the board,
+// ignoring check
+func (lb *localBoard) destinations(x, y int, visit func(toX, toY int) bool) bool {
+	p := lb[y][x]
+	canLand := func(tx, ty int) bool {
+		if !inLocalBoard(tx, ty) {
+			return false
+		}
+		other := lb[ty][tx]
+		return !other.occupied || other.isWhite != p.isWhite
+	}
+
+	switch p.kind {
+	case Pawn:
+		dy := 1
+		if p.isWhite {
+			dy = -1
+		}
+		if inLocalBoard(x, y+dy) && !lb[y+dy][x].occupied {
+			if visit(x, y+dy) {
+				return true
+			}
+			if p.unmoved && inLocalBoard(x, y+2*dy) && !lb[y+2*dy][x].occupied {
+				if visit(x, y+2*dy) {
+					return true
+				}
+			}
+		}
+		for _, dx := range [2]int{-1, 1} {
+			if !inLocalBoard(x+dx, y+dy) {
+				continue
+			}
+			other := lb[y+dy][x+dx]
+			// en passant lands on the empty square behind a pawn that just
+			// double moved
+			beside := lb[y][x+dx]
+			enPassant := !other.occupied && beside.occupied && beside.isWhite != p.isWhite &&
+				beside.kind == Pawn && beside.justDoubleMoved
+			if (other.occupied && other.isWhite != p.isWhite) || enPassant {
+				if visit(x+dx, y+dy) {
+					return true
+				}
+			}
+		}
+	case King:
+		for _, o := range kingOffsets {
+			if canLand(x+o[0], y+o[1]) && visit(x+o[0], y+o[1]) {
+				return true
+			}
+		}
+	default:
+		// Knights only leap, the fairy pieces leap and slide
+		if leapsLikeKnight(p.kind) {
+			for _, o := range knightOffsets {
+				if canLand(x+o[0], y+o[1]) && visit(x+o[0], y+o[1]) {
+					return true
+				}
+			}
+		}
+		var directions [][2]int
+		if slidesOrthogonally(p.kind) {
+			directions = append(directions, rookDirections[:]...)
+		}
+		if slidesDiagonally(p.kind) {
+			directions = append(directions, bishopDirections[:]...)
+		}
+		for _, d := range directions {
+			tx, ty := x+d[0], y+d[1]
+			for canLand(tx, ty) {
+				if visit(tx, ty) {
+					return true
+				}
+				if lb[ty][tx].occupied {
+					break
+				}
+				tx += d[0]
+				ty += d[1]
+			}
+		}
+	}
+	return false
+}
+
+func (lb *localBoard) hasLegalMove(isWhite bool) bool {
+	for y := 0; y < SINGLE_BOARD_SIZE; y++ {
+		for x := 0; x < SINGLE_BOARD_SIZE; x++ {
+			sq := lb[y][x]
+			if !sq.occupied || sq.isWhite != isWhite {
+				continue
+			}
+			found := lb.destinations(x, y, func(toX, toY int) bool {
+				after := *lb
+				if sq.kind == Pawn && toX != x && !after[toY][toX].occupied {
+					// en passant takes the pawn beside us
+					after[y][toX] = localSquare{}
+				}
+				after[toY][toX] = sq
+				after[y][x] = localSquare{}
+				_, inCheck := after.kingsInCheck(isWhite)
+				return !inCheck
+			})
+			if found {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (lb *localBoard) outcomeFor(isWhite bool) protocol.BoardOutcome {
+	hasKing, inCheck := lb.kingsInCheck(isWhite)
+	if !hasKing || lb.hasLegalMove(isWhite) {
+		return protocol.BoardOutcome_BOARD_OUTCOME_NONE
+	}
+	if inCheck {
+		return protocol.BoardOutcome_BOARD_OUTCOME_CHECKMATE
+	}
+	return protocol.BoardOutcome_BOARD_OUTCOME_STALEMATE
+}
+
+type squareWrite struct {
+	X     uint16
+	Y     uint16
+	Piece EncodedPiece
+}
+
+// Call with a lock held. Whether writing these squares would leave one of
+// isWhite's kings in check on the board that the move is on. Always false
+// if the move leaves the board.
+func (b *Board) exposesKing(move Move, isWhite bool, writes ...squareWrite) bool {
+	if !onSameBoard(move.FromX, move.FromY, move.ToX, move.ToY) {
+		return false
+	}
+	boardX := move.FromX / SINGLE_BOARD_SIZE
+	boardY := move.FromY / SINGLE_BOARD_SIZE
+	lb := b.loadLocalBoard(boardX, boardY)
+	for _, w := range writes {
+		if w.X/SINGLE_BOARD_SIZE != boardX || w.Y/SINGLE_BOARD_SIZE != boardY {
+			continue
+		}
+		lb[w.Y%SINGLE_BOARD_SIZE][w.X%SINGLE_BOARD_SIZE] = localSquareOf(w.Piece)
+	}
+	_, inCheck := lb.kingsInCheck(isWhite)
+	return inCheck
+}
+
+// Call with a lock held. You can't castle out of check or through an
+// attacked square (landing on one is caught by exposesKing).
+func (b *Board) castlesThroughCheck(move Move, isWhite bool) bool {
+	if !onSameBoard(move.FromX, move.FromY, move.ToX, move.ToY) {
+		return false
+	}
+	lb := b.loadLocalBoard(move.FromX/SINGLE_BOARD_SIZE, move.FromY/SINGLE_BOARD_SIZE)
+	x := int(move.FromX % SINGLE_BOARD_SIZE)
+	y := int(move.FromY % SINGLE_BOARD_SIZE)
+	crossedX := (x + int(move.ToX%SINGLE_BOARD_SIZE)) / 2
+	return lb.attackedBy(x, y, !isWhite) || lb.attackedBy(crossedX, y, !isWhite)
+}
+
+type boardOutcomePair struct {
+	White protocol.BoardOutcome
+	Black protocol.BoardOutcome
+}
+
+// Which boards are checkmated or stalemated right now. Like the minimap,
+// it's derived from the board and kept up to date from board events, so
+// anything that changes a board (a move, an adoption, a bulk capture, a
+// rollback, set-pieces) re-checks it.
+type BoardOutcomeTracker struct {
+	enabled               bool
+	outcomes              map[uint32]boardOutcomePair
+	whiteCheckmatedBoards atomic.Uint32
+	blackCheckmatedBoards atomic.Uint32
+	whiteStalematedBoards atomic.Uint32
+	blackStalematedBoards atomic.Uint32
+}
+
+func NewBoardOutcomeTracker() *BoardOutcomeTracker {
+	return &BoardOutcomeTracker{outcomes: make(map[uint32]boardOutcomePair)}
+}
+
+func (t *BoardOutcomeTracker) AddCounts(stats *GameStats) {
+	stats.WhiteCheckmatedBoards = t.whiteCheckmatedBoards.Load()
+	stats.BlackCheckmatedBoards = t.blackCheckmatedBoards.Load()
+	stats.WhiteStalematedBoards = t.whiteStalematedBoards.Load()
+	stats.BlackStalematedBoards = t.blackStalematedBoards.Load()
+}
+
+func (t *BoardOutcomeTracker) store(idx uint32, white, black protocol.BoardOutcome) bool {
+	prev := t.outcomes[idx]
+	if prev.White == white && prev.Black == black {
+		return false
+	}
+	t.adjustCount(prev.White, true, -1)
+	t.adjustCount(prev.Black, false, -1)
+	t.adjustCount(white, true, 1)
+	t.adjustCount(black, false, 1)
+	if white == protocol.BoardOutcome_BOARD_OUTCOME_NONE && black == protocol.BoardOutcome_BOARD_OUTCOME_NONE {
+		delete(t.outcomes, idx)
+	} else {
+		t.outcomes[idx] = boardOutcomePair{White: white, Black: black}
+	}
+	return true
+}
+
+func (t *BoardOutcomeTracker) adjustCount(outcome protocol.BoardOutcome, isWhite bool, delta int32) {
+	switch {
+	case outcome == protocol.BoardOutcome_BOARD_OUTCOME_CHECKMATE && isWhite:
+		t.whiteCheckmatedBoards.Add(uint32(delta))
+	case outcome == protocol.BoardOutcome_BOARD_OUTCOME_CHECKMATE:
+		t.blackCheckmatedBoards.Add(uint32(delta))
+	case outcome == protocol.BoardOutcome_BOARD_OUTCOME_STALEMATE && isWhite:
+		t.whiteStalematedBoards.Add(uint32(delta))
+	case outcome == protocol.BoardOutcome_BOARD_OUTCOME_STALEMATE:
+		t.blackStalematedBoards.Add(uint32(delta))
+	}
+}
+
+// Re-checks every board that a change touched, and returns the ones whose
+// outcome changed. Only call this from a board event subscriber: processMoves
+// is the only thing that changes the board, so nothing can sneak in between
+// the change and this.
+func (t *BoardOutcomeTracker) ApplyChangeSet(b *Board, cs *ChangeSet) []BoardOutcomeResult {
+	if !t.enabled {
+		return nil
+	}
+	type boardCoord struct{ x, y uint16 }
+	touched := make([]boardCoord, 0, 2)
+	touch := func(x, y uint16) {
+		c := boardCoord{x / SINGLE_BOARD_SIZE, y / SINGLE_BOARD_SIZE}
+		for _, seen := range touched {
+			if seen == c {
+				return
+			}
+		}
+		touched = append(touched, c)
+	}
+	for _, removed := range cs.Removed {
+		touch(removed.X, removed.Y)
+	}
+	for _, placed := range cs.Placed {
+		touch(placed.X, placed.Y)
+	}
+
+	var changed []BoardOutcomeResult
+	for _, c := range touched {
+		b.RLock()
+		lb := b.loadLocalBoard(c.x, c.y)
+		b.RUnlock()
+		white := lb.outcomeFor(true)
+		black := lb.outcomeFor(false)
+		if t.store(boardIndex(c.x, c.y), white, black) {
+			changed = append(changed, BoardOutcomeResult{BoardX: c.x, BoardY: c.y, White: white, Black: black})
+		}
+	}
+	return changed
+}
+
+// Starts or stops tracking outcomes if a rule change switched us in or out
+// of classical rules. Like ApplyChangeSet, only call this from a board
+// event subscriber.
+func (t *BoardOutcomeTracker) ApplyRuleChange(b *Board) {
+	b.RLock()
+	enabled := b.rules.TracksBoardOutcomes()
+	b.RUnlock()
+	if enabled != t.enabled {
+		t.Initialize(b, enabled)
+	}
+}
+
+// Works out every board's outcome from scratch, or forgets them all if we
+// aren't tracking outcomes. We don't persist outcomes, so call this at
+// startup before processing any moves.
+func (t *BoardOutcomeTracker) Initialize(b *Board, enabled bool) {
+	t.enabled = enabled
+	for idx := range t.outcomes {
+		t.store(idx, protocol.BoardOutcome_BOARD_OUTCOME_NONE, protocol.BoardOutcome_BOARD_OUTCOME_NONE)
+	}
+	if !enabled {
+		return
+	}
+
+	now := time.Now()
+	b.RLock()
+	defer b.RUnlock()
+
+	// A row of boards per job, spread across every core
+	rows := make(chan uint16, BOARDS_PER_SIDE)
+	for boardY := uint16(0); boardY < BOARDS_PER_SIDE; boardY++ {
+		rows <- boardY
+	}
+	close(rows)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for boardY := range rows {
+				for boardX := uint16(0); boardX < BOARDS_PER_SIDE; boardX++ {
+					lb := b.loadLocalBoard(boardX, boardY)
+					white := lb.outcomeFor(true)
+					black := lb.outcomeFor(false)
+					if white == protocol.BoardOutcome_BOARD_OUTCOME_NONE && black == protocol.BoardOutcome_BOARD_OUTCOME_NONE {
+						continue
+					}
+					mu.Lock()
+					t.store(boardIndex(boardX, boardY), white, black)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	log.Printf("Initialized board outcomes in %s (%d boards decided)", time.Since(now), len(t.outcomes))
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"testing"
+
+	"one-million-chessboards/protocol"
+)
+
+// Squares are given as rows from y = 0, with white pieces in upper case and
+// black in lower case. E is a white pawn that just double moved.
+func testLocalBoard(t *testing.T, rows [SINGLE_BOARD_SIZE]string) localBoard {
+	t.Helper()
+	b := NewBoard(false)
+	kinds := map[rune]protocol.PieceType{'p': Pawn, 'n': Knight, 'b': Bishop, 'r': Rook, 'q': Queen, 'k': King}
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '.' {
+				continue
+			}
+			justDoubleMoved := c == 'E'
+			if justDoubleMoved {
+				c = 'P'
+			}
+			isWhite := c >= 'A' && c <= 'Z'
+			kind, ok := kinds[c|0x20]
+			if !ok {
+				t.Fatalf("unknown piece %q", c)
+			}
+			piece := placeTestPiece(b, uint16(x), uint16(y), kind, isWhite)
+			if justDoubleMoved {
+				piece.JustDoubleMoved = true
+				piece.MoveCount = 1
+				b.pieces[y][x] = uint64(piece.Encode())
+			}
+		}
+	}
+	return b.loadLocalBoard(0, 0)
+}
+
+func TestLocalBoardOutcome(t *testing.T) {
+	none := protocol.BoardOutcome_BOARD_OUTCOME_NONE
+	tests := []struct {
+		name      string
+		rows      [SINGLE_BOARD_SIZE]string
+		wantBlack protocol.BoardOutcome
+		wantWhite protocol.BoardOutcome
+	}{
+		{
+			name: "back rank mate",
+			rows: [SINGLE_BOARD_SIZE]string{
+				"k...R...",
+				"pp......",
+				"........",
+				"........",
+				"........",
+				"........",
+				"........",
+				".......K",
+			},
+			wantBlack: protocol.BoardOutcome_BOARD_OUTCOME_CHECKMATE,
+			wantWhite: none,
+		},
+		{
+			name: "stalemate",
+			rows: [SINGLE_BOARD_SIZE]string{
+				"k.......",
+				"........",
+				".Q......",
+				"........",
+				"........",
+				"........",
+				"........",
+				".......K",
+			},
+			wantBlack: protocol.BoardOutcome_BOARD_OUTCOME_STALEMATE,
+			wantWhite: none,
+		},
+		{
+			// The only way out of check is taking the pawn en passant
+			name: "en passant escape",
+			rows: [SINGLE_BOARD_SIZE]string{
+				"........",
+				"........",
+				"pp......",
+				"kp......",
+				"pE......",
+				"..P.....",
+				"........",
+				".......K",
+			},
+			wantBlack: none,
+			wantWhite: none,
+		},
+		{
+			name: "no en passant escape after the pawn's first turn",
+			rows: [SINGLE_BOARD_SIZE]string{
+				"........",
+				"........",
+				"pp......",
+				"kp......",
+				"pP......",
+				"..P.....",
+				"........",
+				".......K",
+			},
+			wantBlack: protocol.BoardOutcome_BOARD_OUTCOME_CHECKMATE,
+			wantWhite: none,
+		},
+		{
+			name: "no king",
+			rows: [SINGLE_BOARD_SIZE]string{
+				"........",
+				"........",
+				"........",
+				"........",
+				"........",
+				"........",
+				"........",
+				".......K",
+			},
+			wantBlack: none,
+			wantWhite: none,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := testLocalBoard(t, tt.rows)
+			if got := lb.outcomeFor(false); got != tt.wantBlack {
+				t.Errorf("black: got %v, want %v", got, tt.wantBlack)
+			}
+			if got := lb.outcomeFor(true); got != tt.wantWhite {
+				t.Errorf("white: got %v, want %v", got, tt.wantWhite)
+			}
+		})
+	}
+}
+
+type testPlacement struct {
+	x, y      uint16
+	pieceType protocol.PieceType
+	isWhite   bool
+}
+
+// Pins and castling through check are only illegal under classical rules, so
+// every one of these moves is fine under standard rules
+func TestClassicalMoveValidation(t *testing.T) {
+	normal := protocol.MoveType_MOVE_TYPE_NORMAL
+	castle := protocol.MoveType_MOVE_TYPE_CASTLE
+	castlingKing := []testPlacement{{4, 7, King, true}, {7, 7, Rook, true}, {0, 7, Rook, true}}
+	// On the board at (1, 1), with the bishop pinned along the diagonal
+	pinned := []testPlacement{{8, 12, King, true}, {9, 11, Bishop, true}, {11, 9, Bishop, false}}
+	tests := []struct {
+		name      string
+		pieces    []testPlacement
+		move      int
+		moveType  protocol.MoveType
+		toX, toY  uint16
+		wantValid bool
+	}{
+		{
+			name:     "castling out of check",
+			pieces:   append(castlingKing, testPlacement{4, 0, Rook, false}),
+			moveType: castle,
+			toX:      6,
+			toY:      7,
+		},
+		{
+			name:     "castling through check",
+			pieces:   append(castlingKing, testPlacement{5, 0, Rook, false}),
+			moveType: castle,
+			toX:      6,
+			toY:      7,
+		},
+		{
+			name:     "castling into check",
+			pieces:   append(castlingKing, testPlacement{6, 0, Rook, false}),
+			moveType: castle,
+			toX:      6,
+			toY:      7,
+		},
+		{
+			// The king never crosses b1
+			name:      "castling queenside past an attacked b1",
+			pieces:    append(castlingKing, testPlacement{1, 0, Rook, false}),
+			moveType:  castle,
+			toX:       2,
+			toY:       7,
+			wantValid: true,
+		},
+		{
+			name:     "moving a pinned piece",
+			pieces:   pinned,
+			move:     1,
+			moveType: normal,
+			toX:      8,
+			toY:      10,
+		},
+		{
+			name:      "taking the pinning piece",
+			pieces:    pinned,
+			move:      1,
+			moveType:  normal,
+			toX:       11,
+			toY:       9,
+			wantValid: true,
+		},
+		{
+			// Whatever happens to a king on a board that we leave isn't
+			// our problem
+			name:      "moving a pinned piece off its board",
+			pieces:    pinned,
+			move:      1,
+			moveType:  normal,
+			toX:       14,
+			toY:       16,
+			wantValid: true,
+		},
+	}
+	for _, tt := range tests {
+		for _, rules := range []RuleSet{classicalRules{}, standardRules{}} {
+			t.Run(tt.name+"/"+rules.Name(), func(t *testing.T) {
+				b := NewBoard(false)
+				b.rules = rules
+				var placed []Piece
+				for _, p := range tt.pieces {
+					placed = append(placed, placeTestPiece(b, p.x, p.y, p.pieceType, p.isWhite))
+				}
+				p := tt.pieces[tt.move]
+				req := testMove(placed[tt.move], p.x, p.y, tt.toX, tt.toY, tt.moveType)
+				want := tt.wantValid || rules.ID() != RuleSetClassical
+				if res := b.ValidateAndApplyMove__NOTTHREADSAFE(*req.Move); res.Valid != want {
+					t.Errorf("%s: got valid %v, want %v", req.Move.ToString(), res.Valid, want)
+				}
+			})
+		}
+	}
+}
@@ -184,6 +184,9 @@ type Client struct {
 	totalMoves                                     atomic.Uint64
 	allPieceTypesMoved                             map[protocol.PieceType]bool
 	allPieceTypesCaptured                          map[protocol.PieceType]bool
+	sessionToken                                   string
+	session                                        *session
+	player                                         PlayerIdentity
 }
 
 func NewClient(
@@ -249,7 +252,17 @@ func NewClient(
 	return c
 }
 
-func (c *Client) Run(playingWhite bool, pos Position) {
+// Must be called before Run
+func (c *Client) AttachSession(token string, sess *session) {
+	c.sessionToken = token
+	c.session = sess
+	c.player = sess.player
+	c.rpcLogger = c.rpcLogger.With().Uint64("player", uint64(sess.player.ID)).Logger()
+	sess.connect()
+}
+
+// resumeAfterSeqnum is the last seqnum that a reconnecting client saw, or 0
+func (c *Client) Run(playingWhite bool, pos Position, resumeAfterSeqnum uint64) {
 	c.playingWhite.Store(playingWhite)
 	c.position.Store(pos)
 	c.lastSnapshotPosition.Store(pos)
@@ -257,7 +270,10 @@ func (c *Client) Run(playingWhite bool, pos Position) {
 	go c.WritePump()
 	go c.SendPeriodicUpdates()
 	go c.ProcessMoveUpdates()
-	c.sendInitialState()
+	if resumeAfterSeqnum == 0 || !c.sendResume(resumeAfterSeqnum) {
+		c.sendInitialState()
+	}
+	c.SendPlayerStats()
 }
 
 const minCompressBytes = 64
@@ -298,6 +314,8 @@ func (c *Client) sendInitialState() {
 				Position:     &protocol.Position{X: uint32(currentPosition.X), Y: uint32(currentPosition.Y)},
 				PlayingWhite: c.playingWhite.Load(),
 				Snapshot:     snapshot,
+				SessionToken: c.sessionToken,
+				PlayerToken:  c.session.playerToken,
 			},
 		},
 	}
@@ -309,6 +327,47 @@ func (c *Client) sendInitialState() {
 	c.compressAndSend(message, "sendInitialState", false)
 }
 
+// We don't know where the client's last snapshot was centered, but it asked
+// for one whenever it moved far enough, so it's close to where it is now.
+func (c *Client) sendResume(afterSeqnum uint64) bool {
+	if afterSeqnum > c.server.board.GetStats().Seqnum {
+		return false
+	}
+	currentPosition := c.position.Load().(Position)
+	moves, captures, ok := c.server.zoneHistory.Since(currentPosition, afterSeqnum)
+	if !ok {
+		c.rpcLogger.Info().
+			Str("rpc", "ResumeTooOld").
+			Uint64("after_seqnum", afterSeqnum).
+			Send()
+		return false
+	}
+
+	m := &protocol.ServerMessage{
+		Payload: &protocol.ServerMessage_Resume{
+			Resume: &protocol.ServerResume{
+				Position:     &protocol.Position{X: uint32(currentPosition.X), Y: uint32(currentPosition.Y)},
+				PlayingWhite: c.playingWhite.Load(),
+				SessionToken: c.sessionToken,
+				Moves:        moves,
+				Captures:     captures,
+			},
+		},
+	}
+	message, err := proto.Marshal(m)
+	if err != nil {
+		log.Printf("Error marshalling resume: %v", err)
+		return false
+	}
+	c.rpcLogger.Info().
+		Str("rpc", "Resume").
+		Uint64("after_seqnum", afterSeqnum).
+		Int("moves", len(moves)).
+		Send()
+	c.compressAndSend(message, "sendResume", false)
+	return true
+}
+
 func (c *Client) IsActive() bool {
 	lastActionTime := c.lastActionTime.Load()
 	if lastActionTime == 0 {
@@ -428,6 +487,7 @@ func (c *Client) handleProtoMessage(msg *protocol.ClientMessage) {
 		toY := p.Move.ToY
 		moveType := p.Move.MoveType
 		moveToken := p.Move.MoveToken
+		promotion := p.Move.Promotion
 
 		if c.server.gameOver.Load() {
 			if !c.moveRejectionOnRateLimitLimiter.Allow() {
@@ -508,6 +568,8 @@ func (c *Client) handleProtoMessage(msg *protocol.ClientMessage) {
 			MoveType:             moveType,
 			MoveToken:            moveToken,
 			ClientIsPlayingWhite: c.playingWhite.Load(),
+			PlayerID:             c.player.ID,
+			Promotion:            promotion,
 		}
 
 		req := MoveRequest{
@@ -530,6 +592,8 @@ func (c *Client) handleProtoMessage(msg *protocol.ClientMessage) {
 		}
 		c.BumpActive()
 		c.UpdatePositionAndMaybeSnapshot(Position{X: uint16(centerX), Y: uint16(centerY)})
+	case *protocol.ClientMessage_GetPlayerStats:
+		c.SendPlayerStats()
 	case *protocol.ClientMessage_Ping:
 		m := &protocol.ServerMessage{
 			Payload: &protocol.ServerMessage_Pong{
@@ -630,8 +694,10 @@ func dint16(a, b uint16) int {
 const interestThreshold = VIEW_RADIUS + 2
 
 func (c *Client) IsInterestedInMove(move Move) bool {
-	currentPos := c.position.Load().(Position)
+	return isMoveNearPosition(move, c.position.Load().(Position))
+}
 
+func isMoveNearPosition(move Move, currentPos Position) bool {
 	dxf, dyf := dint16(move.FromX, currentPos.X), dint16(move.FromY, currentPos.Y)
 	dxt, dyt := dint16(move.ToX, currentPos.X), dint16(move.ToY, currentPos.Y)
 	if (dxf <= interestThreshold && dyf <= interestThreshold) ||
@@ -764,6 +830,21 @@ func (c *Client) SendValidMove(moveToken uint32,
 	c.compressAndSend(message, "SendValidMove", false)
 }
 
+func (c *Client) SendPlayerStats() {
+	stats := c.server.playerStats.Get(c.player.ID)
+	m := &protocol.ServerMessage{
+		Payload: &protocol.ServerMessage_PlayerStats{
+			PlayerStats: stats.ToProtocol(),
+		},
+	}
+	message, err := proto.Marshal(m)
+	if err != nil {
+		log.Printf("Error marshalling player stats: %v", err)
+		return
+	}
+	c.compressAndSend(message, "SendPlayerStats", false)
+}
+
 func (c *Client) SendAdoption(msg []byte) {
 	c.compressAndSend(msg, "SendAdoption", false)
 }
@@ -772,6 +853,18 @@ func (c *Client) SendBulkCapture(msg []byte) {
 	c.compressAndSend(msg, "SendBulkCapture", false)
 }
 
+func (c *Client) SendRollback(msg []byte) {
+	c.compressAndSend(msg, "SendRollback", false)
+}
+
+func (c *Client) SendSetPieces(msg []byte) {
+	c.compressAndSend(msg, "SendSetPieces", false)
+}
+
+func (c *Client) SendBoardOutcome(msg []byte) {
+	c.compressAndSend(msg, "SendBoardOutcome", false)
+}
+
 func (c *Client) Close(why string) {
 	if !c.isClosed.CompareAndSwap(false, true) {
 		return
@@ -780,5 +873,8 @@ func (c *Client) Close(why string) {
 	c.clientCancel()
 	c.server.DecrementCountForIp(c.ipString)
 	c.server.clientManager.UnregisterClient(c)
+	if c.session != nil {
+		c.session.disconnect()
+	}
 	c.conn.Close()
 }
@@ -0,0 +1,401 @@
+package server
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A full snapshot writes every piece on the board, which is a lot of disk for
+// a board where most 8x8 boards haven't changed. In between full snapshots we
+// write delta snapshots instead: every 8x8 board that has changed since the
+// last full snapshot, plus the usual header. Deltas are cumulative, so
+// restoring is "load the full snapshot, then overwrite the boards from the
+// newest delta on top of it".
+//
+// Deltas are named delta-ts:<ts>-seq:<seq>-baseseq:<seq of the full>.bin and
+// use the snapshot container with a delta info section and a boards section
+// instead of a pieces section. Player stats work the same way: a delta has
+// the stats of every player who has moved since the full snapshot.
+
+const BOARDS_PER_SIDE = BOARD_SIZE / SINGLE_BOARD_SIZE
+
+var deltasBetweenFullSnapshots = flag.Int("deltas-between-full-snapshots", 9, "Write this many delta snapshots between each full board snapshot")
+
+const (
+	snapshotSectionDeltaInfo snapshotSectionKind = 3
+	snapshotSectionBoards    snapshotSectionKind = 4
+)
+
+type deltaInfo struct {
+	BaseSeqNum uint64
+	BoardCount uint32
+}
+
+type deltaBoard struct {
+	BoardX uint16
+	BoardY uint16
+	Pieces [SINGLE_BOARD_SIZE * SINGLE_BOARD_SIZE]EncodedPiece
+}
+
+// Header.PieceCount is always 0 for a delta
+type DeltaSnapshot struct {
+	Header      SnapshotHeader
+	Info        deltaInfo
+	Boards      []deltaBoard
+	PlayerStats []playerStatsRecord
+	TakenAtNs   int64
+}
+
+// The 8x8 boards (and players) that have changed since our last full snapshot
+type dirtyBoards struct {
+	bits    []uint64
+	list    []uint32
+	players map[PlayerID]struct{}
+}
+
+func newDirtyBoards() *dirtyBoards {
+	return &dirtyBoards{
+		bits:    make([]uint64, (BOARDS_PER_SIDE*BOARDS_PER_SIDE+63)/64),
+		list:    make([]uint32, 0, 1024),
+		players: make(map[PlayerID]struct{}),
+	}
+}
+
+func (d *dirtyBoards) markPlayer(player PlayerID) {
+	d.players[player] = struct{}{}
+}
+
+func (d *dirtyBoards) markBoard(boardX, boardY uint16) {
+	idx := uint32(boardY)*BOARDS_PER_SIDE + uint32(boardX)
+	word, bit := idx/64, uint64(1)<<(idx%64)
+	if d.bits[word]&bit != 0 {
+		return
+	}
+	d.bits[word] |= bit
+	d.list = append(d.list, idx)
+}
+
+func (d *dirtyBoards) markSquare(x, y uint16) {
+	d.markBoard(x/SINGLE_BOARD_SIZE, y/SINGLE_BOARD_SIZE)
+}
+
+func (d *dirtyBoards) markMoveResult(res *MoveResult) {
+	for _, moved := range res.MovedPieces {
+		d.markSquare(moved.FromX, moved.FromY)
+		d.markSquare(moved.ToX, moved.ToY)
+	}
+	if !res.CapturedPiece.Piece.IsEmpty() {
+		d.markSquare(res.CapturedPiece.X, res.CapturedPiece.Y)
+	}
+}
+
+func (d *dirtyBoards) reset() {
+	for _, idx := range d.list {
+		d.bits[idx/64] = 0
+	}
+	d.list = d.list[:0]
+	clear(d.players)
+}
+
+func (btd *BoardToDiskHandler) snapshotHeader() SnapshotHeader {
+	return SnapshotHeader{
+		NextID:              btd.board.nextID,
+		SeqNum:              btd.board.seqNum,
+		TotalMoves:          btd.board.totalMoves.Load(),
+		WhitePiecesCaptured: btd.board.whitePiecesCaptured.Load(),
+		BlackPiecesCaptured: btd.board.blackPiecesCaptured.Load(),
+		WhiteKingsCaptured:  btd.board.whiteKingsCaptured.Load(),
+		BlackKingsCaptured:  btd.board.blackKingsCaptured.Load(),
+		PieceCount:          0,
+		PromotionRule:       btd.board.promotion.ID(),
+		RuleSet:             btd.board.rules.ID(),
+	}
+}
+
+func (btd *BoardToDiskHandler) getDeltaSnapshot(baseSeqNum uint64) *DeltaSnapshot {
+	start := time.Now()
+	delta := &DeltaSnapshot{
+		Header:      btd.snapshotHeader(),
+		Boards:      make([]deltaBoard, 0, len(btd.dirty.list)),
+		PlayerStats: btd.playerStats.records(btd.dirty.players),
+		TakenAtNs:   start.UnixNano(),
+	}
+	for _, idx := range btd.dirty.list {
+		b := deltaBoard{
+			BoardX: uint16(idx % BOARDS_PER_SIDE),
+			BoardY: uint16(idx / BOARDS_PER_SIDE),
+		}
+		baseX := b.BoardX * SINGLE_BOARD_SIZE
+		baseY := b.BoardY * SINGLE_BOARD_SIZE
+		for dy := uint16(0); dy < SINGLE_BOARD_SIZE; dy++ {
+			for dx := uint16(0); dx < SINGLE_BOARD_SIZE; dx++ {
+				b.Pieces[dy*SINGLE_BOARD_SIZE+dx] = EncodedPiece(btd.board.pieces[baseY+dy][baseX+dx])
+			}
+		}
+		delta.Boards = append(delta.Boards, b)
+	}
+	delta.Info = deltaInfo{BaseSeqNum: baseSeqNum, BoardCount: uint32(len(delta.Boards))}
+	btd.logger.Info().
+		Int64("get_delta_snapshot_ms", time.Since(start).Milliseconds()).
+		Int("delta_boards", len(delta.Boards)).
+		Int("delta_players", len(delta.PlayerStats)).
+		Send()
+	return delta
+}
+
+func (d *DeltaSnapshot) writeTo(writer io.Writer) error {
+	return writeSnapshotContainer(writer, d.TakenAtNs, []snapshotSection{
+		{Kind: snapshotSectionHeader, Data: &d.Header},
+		{Kind: snapshotSectionDeltaInfo, Data: &d.Info},
+		{Kind: snapshotSectionBoards, Data: d.Boards},
+		{Kind: snapshotSectionPlayerStats, Data: d.PlayerStats},
+	})
+}
+
+func (d *DeltaSnapshot) readFrom(reader *bufio.Reader) error {
+	r, err := newSnapshotContainerReader(reader)
+	if err != nil {
+		return err
+	}
+	d.TakenAtNs = r.preamble.TakenAtNs
+	haveHeader := false
+	haveInfo := false
+	haveBoards := false
+	for {
+		sh, err := r.nextSection()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		switch sh.Kind {
+		case snapshotSectionHeader:
+			err = r.readGrowableSectionData(sh, &d.Header)
+			haveHeader = err == nil
+		case snapshotSectionDeltaInfo:
+			err = r.readSectionData(sh, &d.Info)
+			haveInfo = err == nil
+		case snapshotSectionBoards:
+			if !haveInfo {
+				return fmt.Errorf("%w: boards before delta info", ErrCorruptSnapshot)
+			}
+			d.Boards = make([]deltaBoard, d.Info.BoardCount)
+			err = r.readSectionData(sh, d.Boards)
+			haveBoards = err == nil
+		case snapshotSectionPlayerStats:
+			d.PlayerStats, err = readPlayerStatsSection(r, sh)
+		default:
+			err = r.skipSectionData(sh)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !haveHeader || !haveInfo || !haveBoards {
+		return fmt.Errorf("%w: missing header, delta info or boards", ErrCorruptSnapshot)
+	}
+	return nil
+}
+
+func (d *DeltaSnapshot) initializeFromStore(store StateStore, name string) error {
+	file, _, err := store.Get(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return d.readFrom(bufio.NewReaderSize(file, 16*1024*1024))
+}
+
+func deltaSnapshotFilename(timestampNs int64, seq, baseSeq uint64) string {
+	return fmt.Sprintf("delta-ts:%d-seq:%d-baseseq:%d.bin", timestampNs, seq, baseSeq)
+}
+
+func parseDeltaSnapshotFilename(path string) (timestamp int64, seq, baseSeq uint64, err error) {
+	name := strings.TrimSuffix(filepath.Base(path), ".bin")
+	parts := strings.Split(name, "-")
+	if len(parts) != 4 || parts[0] != "delta" || !strings.HasPrefix(parts[1], "ts:") ||
+		!strings.HasPrefix(parts[2], "seq:") || !strings.HasPrefix(parts[3], "baseseq:") {
+		err = fmt.Errorf("Unexpected format: %s", path)
+		return
+	}
+	timestamp, err = strconv.ParseInt(strings.TrimPrefix(parts[1], "ts:"), 10, 64)
+	if err != nil {
+		return
+	}
+	seq, err = strconv.ParseUint(strings.TrimPrefix(parts[2], "seq:"), 10, 64)
+	if err != nil {
+		return
+	}
+	baseSeq, err = strconv.ParseUint(strings.TrimPrefix(parts[3], "baseseq:"), 10, 64)
+	return
+}
+
+func (btd *BoardToDiskHandler) saveDeltaToFile(d *DeltaSnapshot) error {
+	if *doNotSaveState {
+		return nil
+	}
+	now := time.Now()
+	name := deltaSnapshotFilename(now.UnixNano(), d.Header.SeqNum, d.Info.BaseSeqNum)
+	err := btd.store.Put(name, func(writer io.Writer) error {
+		buf := bufio.NewWriterSize(writer, 16*1024*1024)
+		if err := d.writeTo(buf); err != nil {
+			return err
+		}
+		return buf.Flush()
+	})
+	if err != nil {
+		btd.logger.Error().Str("error_kind", "writing_delta_to_file").AnErr("err", err).Send()
+		log.Printf("ERROR writing delta snapshot %s: %v", name, err)
+		return err
+	}
+	btd.logger.Info().Int64("save_delta_snapshot_ms", time.Since(now).Milliseconds()).Send()
+	return nil
+}
+
+// Called from our main loop on every snapshot tick. We write a full snapshot
+// every so often and a delta otherwise. We also need a full snapshot if the
+// last one failed (our deltas would have nothing to apply to) or if we just
+// started up (we don't know which boards changed since the last one).
+func (btd *BoardToDiskHandler) takePeriodicSnapshot() {
+	needFull := btd.needFullSnapshot.Swap(false) ||
+		btd.deltasSinceFull >= *deltasBetweenFullSnapshots
+	if needFull {
+		snap := btd.getSnapshot()
+		btd.dirty.reset()
+		btd.lastFullSeqNum = snap.Header.SeqNum
+		btd.deltasSinceFull = 0
+		go func() {
+			if err := btd.saveToFile(snap); err != nil {
+				btd.needFullSnapshot.Store(true)
+			}
+		}()
+		return
+	}
+	delta := btd.getDeltaSnapshot(btd.lastFullSeqNum)
+	btd.deltasSinceFull++
+	go func() {
+		btd.saveDeltaToFile(delta)
+	}()
+}
+
+func (btd *BoardToDiskHandler) applyDelta(d *DeltaSnapshot) error {
+	if err := btd.board.setRulesFromHeader(&d.Header); err != nil {
+		return err
+	}
+	btd.board.nextID = d.Header.NextID
+	btd.board.seqNum = d.Header.SeqNum
+	btd.board.totalMoves.Store(d.Header.TotalMoves)
+	btd.board.whitePiecesCaptured.Store(d.Header.WhitePiecesCaptured)
+	btd.board.blackPiecesCaptured.Store(d.Header.BlackPiecesCaptured)
+	btd.board.whiteKingsCaptured.Store(d.Header.WhiteKingsCaptured)
+	btd.board.blackKingsCaptured.Store(d.Header.BlackKingsCaptured)
+	btd.playerStats.load(d.PlayerStats)
+	for _, b := range d.Boards {
+		baseX := b.BoardX * SINGLE_BOARD_SIZE
+		baseY := b.BoardY * SINGLE_BOARD_SIZE
+		for dy := uint16(0); dy < SINGLE_BOARD_SIZE; dy++ {
+			for dx := uint16(0); dx < SINGLE_BOARD_SIZE; dx++ {
+				btd.board.pieces[baseY+dy][baseX+dx] = uint64(b.Pieces[dy*SINGLE_BOARD_SIZE+dx])
+			}
+		}
+	}
+	return nil
+}
+
+// Somewhere we can restore the board from: a full snapshot, optionally with
+// a delta on top of it.
+type restorePoint struct {
+	Full      string
+	Delta     string
+	SeqNum    uint64
+	Timestamp int64
+}
+
+func (p restorePoint) String() string {
+	if p.Delta == "" {
+		return p.Full
+	}
+	return fmt.Sprintf("%s + %s", p.Full, p.Delta)
+}
+
+// Sorted from oldest to newest. Deltas whose full snapshot is missing are
+// ignored.
+func (btd *BoardToDiskHandler) sortedRestorePoints() ([]restorePoint, error) {
+	fulls, err := btd.SortedSnapshotFilenames()
+	if err != nil {
+		return nil, err
+	}
+	points := make([]restorePoint, 0, len(fulls))
+	fullsBySeq := make(map[uint64]string, len(fulls))
+	for _, f := range fulls {
+		ts, seq, err := parseSnapshotFilename(f)
+		if err != nil {
+			continue
+		}
+		fullsBySeq[seq] = f
+		points = append(points, restorePoint{Full: f, SeqNum: seq, Timestamp: ts})
+	}
+	deltas, err := btd.store.List("delta-*.bin")
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deltas {
+		ts, seq, baseSeq, err := parseDeltaSnapshotFilename(d)
+		if err != nil {
+			log.Printf("ERROR? Could not parse delta snapshot filename: %v", err)
+			continue
+		}
+		full, ok := fullsBySeq[baseSeq]
+		if !ok {
+			continue
+		}
+		points = append(points, restorePoint{Full: full, Delta: d, SeqNum: seq, Timestamp: ts})
+	}
+	slices.SortFunc(points, func(a, b restorePoint) int {
+		if a.Timestamp < b.Timestamp {
+			return -1
+		} else if a.Timestamp > b.Timestamp {
+			return 1
+		}
+		return 0
+	})
+	return points, nil
+}
+
+func (btd *BoardToDiskHandler) initializeFromRestorePoint(p restorePoint) error {
+	// Read the delta first; it's much smaller and there's no point loading
+	// the full snapshot if the delta is bad.
+	var delta *DeltaSnapshot
+	if p.Delta != "" {
+		delta = &DeltaSnapshot{}
+		if err := delta.initializeFromStore(btd.store, p.Delta); err != nil {
+			return err
+		}
+	}
+	snapshot := &Snapshot{}
+	if err := snapshot.initializeFromStore(btd.store, p.Full); err != nil {
+		return err
+	}
+	if delta != nil && snapshot.Header.SeqNum != delta.Info.BaseSeqNum {
+		return fmt.Errorf("delta %s is based on seqnum %d but %s is at %d",
+			p.Delta, delta.Info.BaseSeqNum, p.Full, snapshot.Header.SeqNum)
+	}
+	if err := btd.initializeFromSnapshot(snapshot); err != nil {
+		return err
+	}
+	if delta != nil {
+		if err := btd.applyDelta(delta); err != nil {
+			return err
+		}
+		log.Printf("Applied %d boards from delta %s", len(delta.Boards), p.Delta)
+	}
+	return nil
+}
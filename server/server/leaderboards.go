@@ -0,0 +1,323 @@
+package server
+
+import (
+	"cmp"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"one-million-chessboards/protocol"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Live leaderboards, the same rankings that PrintLivePieceStats computes
+// offline, kept up to date from processMoves instead of by scanning the
+// board. We scan the board once at startup to seed them.
+//
+// Pieces drop off their boards when they're captured, and we don't know
+// who was next in line without another scan. So we keep a few times as
+// many candidates as we serve, which is plenty unless somebody goes on a
+// rampage through a leaderboard.
+
+const (
+	LEADERBOARD_SIZE       = 10
+	LEADERBOARD_CANDIDATES = LEADERBOARD_SIZE * 4
+	// Matches PrintLivePieceStats
+	SELF_HATING_MIN_CAPTURES = 10
+)
+
+type rankedEntry[K comparable, V any] struct {
+	key   K
+	score uint64
+	value V
+}
+
+// The highest-scoring few keys, best first. Ties go to whoever got there
+// first.
+type topN[K comparable, V any] struct {
+	capacity int
+	entries  []rankedEntry[K, V]
+}
+
+func newTopN[K comparable, V any](capacity int) *topN[K, V] {
+	return &topN[K, V]{capacity: capacity, entries: make([]rankedEntry[K, V], 0, capacity+1)}
+}
+
+func (t *topN[K, V]) update(key K, score uint64, value V) {
+	idx := slices.IndexFunc(t.entries, func(e rankedEntry[K, V]) bool { return e.key == key })
+	if idx >= 0 {
+		t.entries[idx].score = score
+		t.entries[idx].value = value
+	} else if len(t.entries) < t.capacity || score > t.entries[len(t.entries)-1].score {
+		t.entries = append(t.entries, rankedEntry[K, V]{key: key, score: score, value: value})
+	} else {
+		return
+	}
+	slices.SortStableFunc(t.entries, func(a, b rankedEntry[K, V]) int {
+		return cmp.Compare(b.score, a.score)
+	})
+	if len(t.entries) > t.capacity {
+		t.entries = t.entries[:t.capacity]
+	}
+}
+
+func (t *topN[K, V]) remove(key K) {
+	t.entries = slices.DeleteFunc(t.entries, func(e rankedEntry[K, V]) bool { return e.key == key })
+}
+
+func (t *topN[K, V]) top(n int) []V {
+	ret := make([]V, 0, min(n, len(t.entries)))
+	for _, e := range t.entries[:min(n, len(t.entries))] {
+		ret = append(ret, e.value)
+	}
+	return ret
+}
+
+type PieceLeaderboardEntry struct {
+	ID       uint32 `json:"id"`
+	Type     string `json:"type"`
+	IsWhite  bool   `json:"isWhite"`
+	X        uint16 `json:"x"`
+	Y        uint16 `json:"y"`
+	Moves    uint16 `json:"moves"`
+	Captures uint16 `json:"captures"`
+}
+
+type PlayerLeaderboardEntry struct {
+	// Player IDs are just numbers; knowing one doesn't let you make a token
+	Player        string `json:"player"`
+	Moves         uint32 `json:"moves"`
+	Captures      uint32 `json:"captures"`
+	KingsCaptured uint32 `json:"kingsCaptured"`
+	Promotions    uint32 `json:"promotions"`
+}
+
+type Leaderboards struct {
+	sync.Mutex
+	mostCaptures    map[protocol.PieceType]*topN[uint32, PieceLeaderboardEntry]
+	mostMoves       map[protocol.PieceType]*topN[uint32, PieceLeaderboardEntry]
+	selfHating      *topN[uint32, PieceLeaderboardEntry]
+	kingslayerPawns *topN[uint32, PieceLeaderboardEntry]
+
+	playersByMoves    *topN[PlayerID, PlayerLeaderboardEntry]
+	playersByCaptures *topN[PlayerID, PlayerLeaderboardEntry]
+	playersByKings    *topN[PlayerID, PlayerLeaderboardEntry]
+}
+
+func NewLeaderboards() *Leaderboards {
+	return &Leaderboards{
+		mostCaptures:      make(map[protocol.PieceType]*topN[uint32, PieceLeaderboardEntry]),
+		mostMoves:         make(map[protocol.PieceType]*topN[uint32, PieceLeaderboardEntry]),
+		selfHating:        newTopN[uint32, PieceLeaderboardEntry](LEADERBOARD_CANDIDATES),
+		kingslayerPawns:   newTopN[uint32, PieceLeaderboardEntry](LEADERBOARD_CANDIDATES),
+		playersByMoves:    newTopN[PlayerID, PlayerLeaderboardEntry](LEADERBOARD_CANDIDATES),
+		playersByCaptures: newTopN[PlayerID, PlayerLeaderboardEntry](LEADERBOARD_CANDIDATES),
+		playersByKings:    newTopN[PlayerID, PlayerLeaderboardEntry](LEADERBOARD_CANDIDATES),
+	}
+}
+
+func boardForType(boards map[protocol.PieceType]*topN[uint32, PieceLeaderboardEntry], pieceType protocol.PieceType) *topN[uint32, PieceLeaderboardEntry] {
+	board, ok := boards[pieceType]
+	if !ok {
+		board = newTopN[uint32, PieceLeaderboardEntry](LEADERBOARD_CANDIDATES)
+		boards[pieceType] = board
+	}
+	return board
+}
+
+// Call with the lock held
+func (l *Leaderboards) updatePiece(piece Piece, x, y uint16) {
+	entry := PieceLeaderboardEntry{
+		ID:       piece.ID,
+		Type:     piece.Type.String(),
+		IsWhite:  piece.IsWhite,
+		X:        x,
+		Y:        y,
+		Moves:    piece.MoveCount,
+		Captures: piece.CaptureCount,
+	}
+	if piece.MoveCount > 0 {
+		boardForType(l.mostMoves, piece.Type).update(piece.ID, uint64(piece.MoveCount), entry)
+	}
+	if piece.CaptureCount > 0 {
+		boardForType(l.mostCaptures, piece.Type).update(piece.ID, uint64(piece.CaptureCount), entry)
+	}
+	if piece.CaptureCount >= SELF_HATING_MIN_CAPTURES && !piece.HasCapturedPieceTypeOtherThanOwn {
+		l.selfHating.update(piece.ID, uint64(piece.CaptureCount), entry)
+	} else {
+		l.selfHating.remove(piece.ID)
+	}
+	if piece.KingPawner {
+		l.kingslayerPawns.update(piece.ID, uint64(piece.CaptureCount), entry)
+	}
+}
+
+// Call with the lock held
+func (l *Leaderboards) removePiece(id uint32) {
+	for _, board := range l.mostMoves {
+		board.remove(id)
+	}
+	for _, board := range l.mostCaptures {
+		board.remove(id)
+	}
+	l.selfHating.remove(id)
+	l.kingslayerPawns.remove(id)
+}
+
+// Called from processMoves, so we see changes in order. That matters
+// because a piece that's been captured must never come back.
+func (l *Leaderboards) ApplyChangeSet(cs *ChangeSet) {
+	l.Lock()
+	defer l.Unlock()
+	// A piece that's still around keeps its place (and any ties) unless
+	// it's been promoted off of the pawn boards
+	stillHere := make(map[uint32]protocol.PieceType, len(cs.Placed))
+	for _, placed := range cs.Placed {
+		stillHere[placed.Piece.ID] = placed.Piece.Type
+	}
+	for _, removed := range cs.Removed {
+		if pieceType, ok := stillHere[removed.Piece.ID]; ok && pieceType == removed.Piece.Type {
+			continue
+		}
+		l.removePiece(removed.Piece.ID)
+	}
+	for _, placed := range cs.Placed {
+		l.updatePiece(placed.Piece, placed.X, placed.Y)
+	}
+}
+
+func (l *Leaderboards) UpdatePlayer(player PlayerID, stats PlayerStats) {
+	entry := PlayerLeaderboardEntry{
+		Player:        strconv.FormatUint(uint64(player), 10),
+		Moves:         stats.Moves,
+		Captures:      stats.TotalCaptures(),
+		KingsCaptured: stats.Captures[King],
+		Promotions:    stats.Promotions,
+	}
+	l.Lock()
+	defer l.Unlock()
+	l.playersByMoves.update(player, uint64(entry.Moves), entry)
+	if entry.Captures > 0 {
+		l.playersByCaptures.update(player, uint64(entry.Captures), entry)
+	}
+	if entry.KingsCaptured > 0 {
+		l.playersByKings.update(player, uint64(entry.KingsCaptured), entry)
+	}
+}
+
+// Seed everything from the board and our player stats. Like
+// MinimapAggregator.Initialize, this is only safe before processMoves starts.
+func (l *Leaderboards) Initialize(board *Board, playerStats *PlayerStatsTracker) {
+	start := time.Now()
+	l.Lock()
+	board.RLock()
+	for y := 0; y < BOARD_SIZE; y++ {
+		for x := 0; x < BOARD_SIZE; x++ {
+			rawPiece := EncodedPiece(board.pieces[y][x])
+			if EncodedIsEmpty(rawPiece) {
+				continue
+			}
+			piece := PieceOfEncodedPiece(rawPiece)
+			if piece.MoveCount == 0 {
+				continue
+			}
+			l.updatePiece(piece, uint16(x), uint16(y))
+		}
+	}
+	board.RUnlock()
+	l.Unlock()
+	for _, record := range playerStats.records(nil) {
+		l.UpdatePlayer(record.Player, record.Stats)
+	}
+	log.Printf("Initialized leaderboards in %s", time.Since(start))
+}
+
+type PieceLeaderboards struct {
+	MostCaptures    map[string][]PieceLeaderboardEntry `json:"mostCaptures"`
+	MostMoves       map[string][]PieceLeaderboardEntry `json:"mostMoves"`
+	SelfHating      []PieceLeaderboardEntry            `json:"selfHating"`
+	KingslayerPawns []PieceLeaderboardEntry            `json:"kingslayerPawns"`
+}
+
+type PlayerLeaderboards struct {
+	MostMoves         []PlayerLeaderboardEntry `json:"mostMoves"`
+	MostCaptures      []PlayerLeaderboardEntry `json:"mostCaptures"`
+	MostKingsCaptured []PlayerLeaderboardEntry `json:"mostKingsCaptured"`
+}
+
+type LeaderboardsResponse struct {
+	Pieces  PieceLeaderboards  `json:"pieces"`
+	Players PlayerLeaderboards `json:"players"`
+	Seqnum  uint64             `json:"seqnum"`
+}
+
+func (l *Leaderboards) Snapshot() LeaderboardsResponse {
+	l.Lock()
+	defer l.Unlock()
+	resp := LeaderboardsResponse{
+		Pieces: PieceLeaderboards{
+			MostCaptures:    make(map[string][]PieceLeaderboardEntry, len(l.mostCaptures)),
+			MostMoves:       make(map[string][]PieceLeaderboardEntry, len(l.mostMoves)),
+			SelfHating:      l.selfHating.top(LEADERBOARD_SIZE),
+			KingslayerPawns: l.kingslayerPawns.top(LEADERBOARD_SIZE),
+		},
+		Players: PlayerLeaderboards{
+			MostMoves:         l.playersByMoves.top(LEADERBOARD_SIZE),
+			MostCaptures:      l.playersByCaptures.top(LEADERBOARD_SIZE),
+			MostKingsCaptured: l.playersByKings.top(LEADERBOARD_SIZE),
+		},
+	}
+	for pieceType, board := range l.mostCaptures {
+		resp.Pieces.MostCaptures[pieceType.String()] = board.top(LEADERBOARD_SIZE)
+	}
+	for pieceType, board := range l.mostMoves {
+		resp.Pieces.MostMoves[pieceType.String()] = board.top(LEADERBOARD_SIZE)
+	}
+	return resp
+}
+
+func (s *Server) refreshLeaderboardsOnce() {
+	resp := s.leaderboards.Snapshot()
+	resp.Seqnum = s.board.GetStats().Seqnum
+	serialized, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling leaderboards: %v", err)
+		return
+	}
+	s.currentLeaderboardsMutex.Lock()
+	s.currentLeaderboards = jsoniter.RawMessage(serialized)
+	s.currentLeaderboardsMutex.Unlock()
+}
+
+func (s *Server) refreshLeaderboardsPeriodically() {
+	s.refreshLeaderboardsOnce()
+	go func() {
+		ticker := time.NewTicker(LEADERBOARD_REFRESH_INTERVAL)
+		s.backgroundJobWg.Add(1)
+		defer func() {
+			ticker.Stop()
+			s.backgroundJobWg.Done()
+		}()
+
+		for {
+			select {
+			case <-s.backgroundJobCtx.Done():
+				return
+			case <-ticker.C:
+				s.refreshLeaderboardsOnce()
+			}
+		}
+	}()
+}
+
+func (s *Server) ServeLeaderboards(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=5, s-maxage=10")
+	s.currentLeaderboardsMutex.RLock()
+	defer s.currentLeaderboardsMutex.RUnlock()
+	w.Write(s.currentLeaderboards)
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"log"
+	"math"
+	"slices"
 	"sync"
 	"sync/atomic"
 
@@ -20,9 +22,25 @@ import (
 const CELL_SIZE = 5
 const NUMBER_OF_CELLS = 1000 / CELL_SIZE
 
+// Besides who's ahead where, we serve a few more layers, each at a few zoom
+// levels (boards per cell side, always a multiple of CELL_SIZE). Clients pick
+// one with ?layer=...&cellSize=... and get back a precomputed blob, so these
+// cache just as well as the original. No parameters gets the original.
+const (
+	MinimapLayerBalance  = "balance"
+	MinimapLayerCounts   = "counts"
+	MinimapLayerKings    = "kings"
+	MinimapLayerActivity = "activity"
+)
+
+var minimapLayers = []string{MinimapLayerBalance, MinimapLayerCounts, MinimapLayerKings, MinimapLayerActivity}
+var minimapCellSizes = []int{CELL_SIZE, 10, 25, 50}
+
 type MinimapCell struct {
 	WhiteCount uint16
 	BlackCount uint16
+	WhiteKings uint16
+	BlackKings uint16
 }
 
 type packedAggregation uint8
@@ -45,20 +63,57 @@ func makePackedAggregation(whiteAhead bool, amount uint8) packedAggregation {
 	return packedAggregation(amountMasked | uint8(whiteAheadMasked))
 }
 
+// The thresholds were picked for CELL_SIZE cells; bigger cells have
+// proportionally more pieces, so scale is (cellSize / CELL_SIZE)^2.
+func balanceForCounts(whiteCount, blackCount uint32, scale uint32) packedAggregation {
+	diff := max(whiteCount, blackCount) - min(whiteCount, blackCount)
+	percentage := float64(diff) / float64(whiteCount+blackCount)
+	amount := 0
+	if percentage > 0.3 && diff > 50*scale {
+		amount = 3
+	} else if percentage > 0.15 && diff > 25*scale {
+		amount = 2
+	} else if percentage > 0.03 && diff > 2*scale {
+		amount = 1
+	}
+	return makePackedAggregation(whiteCount > blackCount, uint8(amount))
+}
+
 type AggregationResponse struct {
 	Type         string                                               `json:"type"`
 	Aggregations [NUMBER_OF_CELLS * NUMBER_OF_CELLS]packedAggregation `json:"aggregations"`
 }
 
+// Everything but the original. Cells are in row-major order, Width per row.
+// Only the fields for the layer are set. Aggregations are packed just like
+// the original (a []packedAggregation would come out as base64).
+type MinimapLayerResponse struct {
+	Type         string    `json:"type"`
+	Layer        string    `json:"layer"`
+	CellSize     int       `json:"cellSize"`
+	Width        int       `json:"width"`
+	Aggregations []uint16  `json:"aggregations,omitempty"`
+	White        []uint32  `json:"white,omitempty"`
+	Black        []uint32  `json:"black,omitempty"`
+	Activity     []float32 `json:"activity,omitempty"`
+}
+
+type minimapKey struct {
+	layer    string
+	cellSize int
+}
+
+// The activity layer comes from the ActivityHeatmap
 type MinimapAggregator struct {
 	sync.Mutex
-	cells           [NUMBER_OF_CELLS][NUMBER_OF_CELLS]MinimapCell
-	lastAggregation atomic.Value
+	cells        [NUMBER_OF_CELLS][NUMBER_OF_CELLS]MinimapCell
+	activity     *ActivityHeatmap
+	aggregations atomic.Pointer[map[minimapKey][]byte]
 }
 
-func NewMinimapAggregator() *MinimapAggregator {
-	ret := &MinimapAggregator{}
-	ret.lastAggregation.Store([]byte{})
+func NewMinimapAggregator(activity *ActivityHeatmap) *MinimapAggregator {
+	ret := &MinimapAggregator{activity: activity}
+	ret.aggregations.Store(&map[minimapKey][]byte{})
 	return ret
 }
 
@@ -96,11 +151,7 @@ func (m *MinimapAggregator) Initialize(board *Board) {
 			}
 			piece := PieceOfEncodedPiece(rawPiece)
 			coords := getAggregatorCoords(uint16(x), uint16(y))
-			if piece.IsWhite {
-				m.cells[coords.Y][coords.X].WhiteCount++
-			} else {
-				m.cells[coords.Y][coords.X].BlackCount++
-			}
+			m.unsafeUpdateForAggregatorCoords(coords, piece, false)
 		}
 	}
 	board.RUnlock()
@@ -108,33 +159,106 @@ func (m *MinimapAggregator) Initialize(board *Board) {
 	m.createAndStoreAggregation()
 }
 
-func (m *MinimapAggregator) createAndStoreAggregation() []byte {
+func (m *MinimapAggregator) createAndStoreAggregation() {
 	m.Lock()
 	snapshot := m.cells
 	m.Unlock()
+	activity := m.activity.CellScores()
 
-	response := AggregationResponse{
-		Type: "minimapUpdate",
+	aggregations := make(map[minimapKey][]byte, len(minimapLayers)*len(minimapCellSizes))
+	for _, cellSize := range minimapCellSizes {
+		cells, width := mergeMinimapCells(&snapshot, activity, cellSize/CELL_SIZE)
+		for _, layer := range minimapLayers {
+			var response any
+			if layer == MinimapLayerBalance && cellSize == CELL_SIZE {
+				response = originalAggregationResponse(cells)
+			} else {
+				response = layerResponse(cells, width, layer, cellSize)
+			}
+			compressed := compressAggregation(response)
+			if compressed == nil {
+				continue
+			}
+			aggregations[minimapKey{layer: layer, cellSize: cellSize}] = compressed
+		}
 	}
-	for i := 0; i < NUMBER_OF_CELLS*NUMBER_OF_CELLS; i++ {
-		x := i % NUMBER_OF_CELLS
-		y := i / NUMBER_OF_CELLS
-		whiteCount := snapshot[y][x].WhiteCount
-		blackCount := snapshot[y][x].BlackCount
-		diff := max(whiteCount, blackCount) - min(whiteCount, blackCount)
-		percentage := float64(diff) / float64(whiteCount+blackCount)
-		amount := 0
-		if percentage > 0.3 && diff > 50 {
-			amount = 3
-		} else if percentage > 0.15 && diff > 25 {
-			amount = 2
-		} else if percentage > 0.03 && diff > 2 {
-			amount = 1
+	m.aggregations.Store(&aggregations)
+}
+
+// Sums each factor x factor block of cells, row-major
+func mergeMinimapCells(cells *[NUMBER_OF_CELLS][NUMBER_OF_CELLS]MinimapCell, activity *[NUMBER_OF_CELLS][NUMBER_OF_CELLS]float32, factor int) ([]minimapTotals, int) {
+	width := NUMBER_OF_CELLS / factor
+	merged := make([]minimapTotals, width*width)
+	for y := 0; y < NUMBER_OF_CELLS; y++ {
+		for x := 0; x < NUMBER_OF_CELLS; x++ {
+			cell := &cells[y][x]
+			totals := &merged[(y/factor)*width+x/factor]
+			totals.white += uint32(cell.WhiteCount)
+			totals.black += uint32(cell.BlackCount)
+			totals.whiteKings += uint32(cell.WhiteKings)
+			totals.blackKings += uint32(cell.BlackKings)
+			totals.activity += activity[y][x]
 		}
+	}
+	return merged, width
+}
+
+type minimapTotals struct {
+	white      uint32
+	black      uint32
+	whiteKings uint32
+	blackKings uint32
+	activity   float32
+}
 
-		response.Aggregations[i] = makePackedAggregation(whiteCount > blackCount, uint8(amount))
+func originalAggregationResponse(cells []minimapTotals) *AggregationResponse {
+	response := &AggregationResponse{
+		Type: "minimapUpdate",
+	}
+	for i, cell := range cells {
+		response.Aggregations[i] = balanceForCounts(cell.white, cell.black, 1)
+	}
+	return response
+}
 
+func layerResponse(cells []minimapTotals, width int, layer string, cellSize int) *MinimapLayerResponse {
+	response := &MinimapLayerResponse{
+		Type:     "minimapLayer",
+		Layer:    layer,
+		CellSize: cellSize,
+		Width:    width,
 	}
+	switch layer {
+	case MinimapLayerBalance:
+		factor := uint32(cellSize / CELL_SIZE)
+		response.Aggregations = make([]uint16, len(cells))
+		for i, cell := range cells {
+			response.Aggregations[i] = uint16(balanceForCounts(cell.white, cell.black, factor*factor))
+		}
+	case MinimapLayerCounts:
+		response.White = make([]uint32, len(cells))
+		response.Black = make([]uint32, len(cells))
+		for i, cell := range cells {
+			response.White[i] = cell.white
+			response.Black[i] = cell.black
+		}
+	case MinimapLayerKings:
+		response.White = make([]uint32, len(cells))
+		response.Black = make([]uint32, len(cells))
+		for i, cell := range cells {
+			response.White[i] = cell.whiteKings
+			response.Black[i] = cell.blackKings
+		}
+	case MinimapLayerActivity:
+		response.Activity = make([]float32, len(cells))
+		for i, cell := range cells {
+			response.Activity[i] = float32(math.Round(float64(cell.activity)*10) / 10)
+		}
+	}
+	return response
+}
+
+func compressAggregation(response any) []byte {
 	jsonResponse, err := json.Marshal(response)
 	if err != nil {
 		log.Printf("Error marshalling aggregation response: %v", err)
@@ -144,64 +268,53 @@ func (m *MinimapAggregator) createAndStoreAggregation() []byte {
 	enc.Reset(nil)
 	ret := enc.EncodeAll(jsonResponse, make([]byte, 0, len(jsonResponse)))
 	GLOBAL_zstdPool.Put(enc)
-	m.lastAggregation.Store(ret)
 	return ret
 }
 
 // assumes that the lock is already held!
-func (m *MinimapAggregator) unsafeUpdateForAggregatorCoords(coords AggregatorCoords, isWhite bool, decr bool) {
-	if isWhite {
-		if decr && m.cells[coords.Y][coords.X].WhiteCount > 0 {
-			m.cells[coords.Y][coords.X].WhiteCount--
-		} else if !decr {
-			m.cells[coords.Y][coords.X].WhiteCount++
-		}
-	} else {
-		if decr && m.cells[coords.Y][coords.X].BlackCount > 0 {
-			m.cells[coords.Y][coords.X].BlackCount--
+func (m *MinimapAggregator) unsafeUpdateForAggregatorCoords(coords AggregatorCoords, piece Piece, decr bool) {
+	cell := &m.cells[coords.Y][coords.X]
+	count, kings := &cell.BlackCount, &cell.BlackKings
+	if piece.IsWhite {
+		count, kings = &cell.WhiteCount, &cell.WhiteKings
+	}
+	if decr && *count > 0 {
+		*count--
+	} else if !decr {
+		*count++
+	}
+	if piece.Type == King {
+		if decr && *kings > 0 {
+			*kings--
 		} else if !decr {
-			m.cells[coords.Y][coords.X].BlackCount++
+			*kings++
 		}
 	}
 }
 
-func (m *MinimapAggregator) UpdateForMoveResult(moveResult MoveResult) {
-	needsUpdate := false
-	if !moveResult.CapturedPiece.Piece.IsEmpty() {
-		needsUpdate = true
-	} else {
-		for _, movedPiece := range moveResult.MovedPieces {
-			fromCoords := getAggregatorCoords(movedPiece.FromX, movedPiece.FromY)
-			toCoords := getAggregatorCoords(movedPiece.ToX, movedPiece.ToY)
-			if fromCoords != toCoords {
-				needsUpdate = true
-				break
-			}
-		}
-	}
-
-	if !needsUpdate {
-		return
-	}
-
+func (m *MinimapAggregator) ApplyChangeSet(cs *ChangeSet) {
 	m.Lock()
 	defer m.Unlock()
 
-	for _, movedPiece := range moveResult.MovedPieces {
-		fromCoords := getAggregatorCoords(movedPiece.FromX, movedPiece.FromY)
-		toCoords := getAggregatorCoords(movedPiece.ToX, movedPiece.ToY)
-		if fromCoords != toCoords {
-			m.unsafeUpdateForAggregatorCoords(fromCoords, movedPiece.Piece.IsWhite, true)
-			m.unsafeUpdateForAggregatorCoords(toCoords, movedPiece.Piece.IsWhite, false)
-		}
+	// Most moves don't leave their cell, in which case these cancel out
+	for _, removed := range cs.Removed {
+		coords := getAggregatorCoords(removed.X, removed.Y)
+		m.unsafeUpdateForAggregatorCoords(coords, removed.Piece, true)
 	}
-
-	if !moveResult.CapturedPiece.Piece.IsEmpty() {
-		coords := getAggregatorCoords(moveResult.CapturedPiece.X, moveResult.CapturedPiece.Y)
-		m.unsafeUpdateForAggregatorCoords(coords, moveResult.CapturedPiece.Piece.IsWhite, true)
+	for _, placed := range cs.Placed {
+		coords := getAggregatorCoords(placed.X, placed.Y)
+		m.unsafeUpdateForAggregatorCoords(coords, placed.Piece, false)
 	}
 }
 
-func (m *MinimapAggregator) GetLastAggregation() []byte {
-	return m.lastAggregation.Load().([]byte)
+// ok is false for a layer or cell size that we don't serve
+func (m *MinimapAggregator) GetAggregation(layer string, cellSize int) ([]byte, bool) {
+	if !slices.Contains(minimapLayers, layer) || !slices.Contains(minimapCellSizes, cellSize) {
+		return nil, false
+	}
+	aggregation := (*m.aggregations.Load())[minimapKey{layer: layer, cellSize: cellSize}]
+	if aggregation == nil {
+		aggregation = []byte{}
+	}
+	return aggregation, true
 }
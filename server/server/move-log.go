@@ -0,0 +1,629 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"one-million-chessboards/protocol"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Move logs are append-only files of length-prefixed records:
+//
+//	header  magic (8 bytes), format version (uint32)
+//	record  payload length (uint32), CRC of the payload (uint32), payload
+//	payload kind (uint8), seqnum (uint64), timestamp ns (int64), body
+//
+// Everything is little-endian. The body depends on the kind. If we need more
+// fields on a kind we append them to the end of its body; readers treat
+// missing trailing fields as zero so old logs stay readable. Rollbacks and
+// set pieces are the exception: their bodies end with a count and then that
+// many squares, so they can't grow.
+//
+// We write to an "open" segment (moves-ts:<ts>-firstseq:<first>.bin.open)
+// and rename it to moves-ts:<ts>-firstseq:<first>-lastseq:<last>.bin when
+// we rotate. Open segments don't match moves-*.bin, so the backup scripts
+// won't sweep them out from under us. If we crash, the open segment is
+// replayed up to its last intact record and then sealed on the next startup.
+//
+// Logs written before this format existed are a single gob-encoded
+// []boardToDiskRequest; the reader still understands them and
+// ConvertGobMoveLog rewrites them.
+
+const (
+	MOVE_LOG_FORMAT_VERSION   = 1
+	MOVE_LOG_FLUSH_INTERVAL   = time.Second * 1
+	MAX_MOVE_LOG_RECORD_BYTES = 1024 * 1024
+	openMoveLogSuffix         = ".open"
+	compressedMoveLogSuffix   = ".zst"
+)
+
+var moveLogMagic = [8]byte{'O', 'M', 'C', 'B', 'M', 'L', 'O', 'G'}
+
+var ErrCorruptMoveLog = errors.New("corrupt move log")
+
+type moveLogRecordKind uint8
+
+const (
+	moveLogRecordMove        moveLogRecordKind = 1
+	moveLogRecordAdoption    moveLogRecordKind = 2
+	moveLogRecordBulkCapture moveLogRecordKind = 3
+	// Only sent over replication streams (never written to a log) so that
+	// replicas know the primary's seqnum while nothing is happening. It has
+	// no body and decodes to a request with nothing set but its seqnum.
+	moveLogRecordHeartbeat  moveLogRecordKind = 4
+	moveLogRecordRollback   moveLogRecordKind = 5
+	moveLogRecordSetPieces  moveLogRecordKind = 6
+	moveLogRecordRuleChange moveLogRecordKind = 7
+)
+
+func (req boardToDiskRequest) isHeartbeat() bool {
+	return req.Move == nil && req.AdoptionRequest == nil && req.BulkCaptureRequest == nil &&
+		req.RollbackRequest == nil && req.SetPiecesRequest == nil && req.RuleChangeRequest == nil
+}
+
+func appendHeartbeatPayload(b []byte, seqnum uint64, timestampNs int64) []byte {
+	b = append(b, uint8(moveLogRecordHeartbeat))
+	b = binary.LittleEndian.AppendUint64(b, seqnum)
+	return binary.LittleEndian.AppendUint64(b, uint64(timestampNs))
+}
+
+// Frame a payload as a record (length, CRC, payload)
+func appendMoveLogRecord(b []byte, payload []byte) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(payload)))
+	b = binary.LittleEndian.AppendUint32(b, crc32.Checksum(payload, crcTable))
+	return append(b, payload...)
+}
+
+func appendMoveLogHeader(b []byte) []byte {
+	b = append(b, moveLogMagic[:]...)
+	return binary.LittleEndian.AppendUint32(b, MOVE_LOG_FORMAT_VERSION)
+}
+
+func appendRequestPayload(b []byte, req boardToDiskRequest) ([]byte, error) {
+	var kind moveLogRecordKind
+	switch {
+	case req.Move != nil:
+		kind = moveLogRecordMove
+	case req.AdoptionRequest != nil:
+		kind = moveLogRecordAdoption
+	case req.BulkCaptureRequest != nil:
+		kind = moveLogRecordBulkCapture
+	case req.RollbackRequest != nil:
+		kind = moveLogRecordRollback
+	case req.SetPiecesRequest != nil:
+		kind = moveLogRecordSetPieces
+	case req.RuleChangeRequest != nil:
+		kind = moveLogRecordRuleChange
+	default:
+		return b, fmt.Errorf("can't encode request: %s", req.ToString())
+	}
+	b = append(b, uint8(kind))
+	b = binary.LittleEndian.AppendUint64(b, req.Seqnum)
+	b = binary.LittleEndian.AppendUint64(b, uint64(req.TimestampNs))
+	switch kind {
+	case moveLogRecordMove:
+		m := req.Move
+		b = binary.LittleEndian.AppendUint32(b, m.PieceID)
+		b = binary.LittleEndian.AppendUint16(b, m.FromX)
+		b = binary.LittleEndian.AppendUint16(b, m.FromY)
+		b = binary.LittleEndian.AppendUint16(b, m.ToX)
+		b = binary.LittleEndian.AppendUint16(b, m.ToY)
+		b = append(b, uint8(m.MoveType))
+		b = binary.LittleEndian.AppendUint32(b, m.MoveToken)
+		b = appendBool(b, m.ClientIsPlayingWhite)
+		b = binary.LittleEndian.AppendUint64(b, uint64(m.PlayerID))
+		b = append(b, uint8(m.Promotion))
+	case moveLogRecordAdoption:
+		r := req.AdoptionRequest
+		b = binary.LittleEndian.AppendUint16(b, r.BoardX)
+		b = binary.LittleEndian.AppendUint16(b, r.BoardY)
+		b = append(b, uint8(r.Color))
+	case moveLogRecordBulkCapture:
+		r := req.BulkCaptureRequest
+		b = binary.LittleEndian.AppendUint16(b, r.BoardX)
+		b = binary.LittleEndian.AppendUint16(b, r.BoardY)
+		b = append(b, uint8(r.Color))
+	case moveLogRecordRollback:
+		r := req.RollbackRequest
+		b = binary.LittleEndian.AppendUint64(b, r.FromSeqnum)
+		b = binary.LittleEndian.AppendUint64(b, r.ToSeqnum)
+		b = binary.LittleEndian.AppendUint16(b, r.MinX)
+		b = binary.LittleEndian.AppendUint16(b, r.MinY)
+		b = binary.LittleEndian.AppendUint16(b, r.MaxX)
+		b = binary.LittleEndian.AppendUint16(b, r.MaxY)
+		b = appendSquareAssignments(b, r.Squares)
+	case moveLogRecordSetPieces:
+		b = appendSquareAssignments(b, req.SetPiecesRequest.Squares)
+	case moveLogRecordRuleChange:
+		b = append(b, uint8(req.RuleChangeRequest.PromotionRule))
+		b = append(b, uint8(req.RuleChangeRequest.RuleSet))
+	}
+	return b, nil
+}
+
+func appendSquareAssignments(b []byte, squares []squareAssignment) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(squares)))
+	for _, sq := range squares {
+		b = binary.LittleEndian.AppendUint16(b, sq.X)
+		b = binary.LittleEndian.AppendUint16(b, sq.Y)
+		b = binary.LittleEndian.AppendUint64(b, uint64(sq.Piece))
+	}
+	return b
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+// Reads fixed-size little-endian fields off the front of a payload. Reading
+// past the end yields zeroes, which is how we handle fields that were added
+// after a record was written.
+type payloadDecoder struct {
+	b []byte
+}
+
+func (d *payloadDecoder) take(n int) []byte {
+	if len(d.b) < n {
+		d.b = d.b[len(d.b):]
+		return make([]byte, n)
+	}
+	ret := d.b[:n]
+	d.b = d.b[n:]
+	return ret
+}
+
+func (d *payloadDecoder) u8() uint8     { return d.take(1)[0] }
+func (d *payloadDecoder) u16() uint16   { return binary.LittleEndian.Uint16(d.take(2)) }
+func (d *payloadDecoder) u32() uint32   { return binary.LittleEndian.Uint32(d.take(4)) }
+func (d *payloadDecoder) u64() uint64   { return binary.LittleEndian.Uint64(d.take(8)) }
+func (d *payloadDecoder) boolean() bool { return d.u8() != 0 }
+
+const moveLogPayloadPrefixSize = 1 + 8 + 8
+
+func (d *payloadDecoder) squareAssignments() ([]squareAssignment, error) {
+	count := int(d.u32())
+	if count*squareAssignmentSize > len(d.b) {
+		return nil, fmt.Errorf("%w: claims %d squares but has %d bytes left",
+			ErrCorruptMoveLog, count, len(d.b))
+	}
+	squares := make([]squareAssignment, count)
+	for i := range squares {
+		squares[i] = squareAssignment{X: d.u16(), Y: d.u16(), Piece: EncodedPiece(d.u64())}
+	}
+	return squares, nil
+}
+
+const squareAssignmentSize = 2 + 2 + 8
+
+func decodeRequestPayload(payload []byte) (req boardToDiskRequest, err error) {
+	if len(payload) < moveLogPayloadPrefixSize {
+		err = fmt.Errorf("%w: payload is only %d bytes", ErrCorruptMoveLog, len(payload))
+		return
+	}
+	d := payloadDecoder{b: payload}
+	kind := moveLogRecordKind(d.u8())
+	req.Seqnum = d.u64()
+	req.TimestampNs = int64(d.u64())
+	switch kind {
+	case moveLogRecordMove:
+		req.Move = &Move{
+			PieceID:              d.u32(),
+			FromX:                d.u16(),
+			FromY:                d.u16(),
+			ToX:                  d.u16(),
+			ToY:                  d.u16(),
+			MoveType:             protocol.MoveType(d.u8()),
+			MoveToken:            d.u32(),
+			ClientIsPlayingWhite: d.boolean(),
+			PlayerID:             PlayerID(d.u64()),
+			Promotion:            protocol.PieceType(d.u8()),
+		}
+	case moveLogRecordAdoption:
+		req.AdoptionRequest = &adoptionRequest{
+			BoardX: d.u16(),
+			BoardY: d.u16(),
+			Color:  OnlyColor(d.u8()),
+		}
+	case moveLogRecordBulkCapture:
+		req.BulkCaptureRequest = &bulkCaptureRequest{
+			BoardX: d.u16(),
+			BoardY: d.u16(),
+			Color:  OnlyColor(d.u8()),
+		}
+	case moveLogRecordRollback:
+		r := &rollbackRequest{
+			FromSeqnum: d.u64(),
+			ToSeqnum:   d.u64(),
+			MinX:       d.u16(),
+			MinY:       d.u16(),
+			MaxX:       d.u16(),
+			MaxY:       d.u16(),
+		}
+		r.Squares, err = d.squareAssignments()
+		req.RollbackRequest = r
+	case moveLogRecordSetPieces:
+		r := &setPiecesRequest{}
+		r.Squares, err = d.squareAssignments()
+		req.SetPiecesRequest = r
+	case moveLogRecordRuleChange:
+		req.RuleChangeRequest = &ruleChangeRequest{
+			PromotionRule: PromotionRuleID(d.u8()),
+			RuleSet:       RuleSetID(d.u8()),
+		}
+	case moveLogRecordHeartbeat:
+	default:
+		err = fmt.Errorf("%w: unknown record kind %d", ErrCorruptMoveLog, kind)
+	}
+	return
+}
+
+type moveLogWriter struct {
+	file         AppendableObject
+	buf          *bufio.Writer
+	store        StateStore
+	openedAtNs   int64
+	firstSeq     uint64
+	lastSeq      uint64
+	records      int
+	needsFlush   bool
+	scratch      []byte
+	lengthAndCRC [8]byte
+}
+
+func openMoveLogFilename(timestampNs int64, firstSeq uint64) string {
+	return fmt.Sprintf("moves-ts:%d-firstseq:%d.bin%s", timestampNs, firstSeq, openMoveLogSuffix)
+}
+
+func newMoveLogWriter(store StateStore, firstSeq uint64) (*moveLogWriter, error) {
+	now := time.Now().UnixNano()
+	file, err := store.Append(openMoveLogFilename(now, firstSeq))
+	if err != nil {
+		return nil, err
+	}
+	w := &moveLogWriter{
+		file:       file,
+		buf:        bufio.NewWriterSize(file, 1024*1024),
+		store:      store,
+		openedAtNs: now,
+		firstSeq:   firstSeq,
+		scratch:    make([]byte, 0, 64),
+	}
+	if err := binary.Write(w.buf, binary.LittleEndian, moveLogMagic); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := binary.Write(w.buf, binary.LittleEndian, uint32(MOVE_LOG_FORMAT_VERSION)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *moveLogWriter) append(req boardToDiskRequest) error {
+	payload, err := appendRequestPayload(w.scratch[:0], req)
+	if err != nil {
+		return err
+	}
+	w.scratch = payload
+	binary.LittleEndian.PutUint32(w.lengthAndCRC[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(w.lengthAndCRC[4:8], crc32.Checksum(payload, crcTable))
+	if _, err := w.buf.Write(w.lengthAndCRC[:]); err != nil {
+		return err
+	}
+	if _, err := w.buf.Write(payload); err != nil {
+		return err
+	}
+	w.lastSeq = req.Seqnum
+	w.records++
+	w.needsFlush = true
+	return nil
+}
+
+func (w *moveLogWriter) flush() error {
+	if !w.needsFlush {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Flush(); err != nil {
+		return err
+	}
+	w.needsFlush = false
+	return nil
+}
+
+func (w *moveLogWriter) name() string {
+	return openMoveLogFilename(w.openedAtNs, w.firstSeq)
+}
+
+// Flushes, closes, and renames the segment to its final name. A segment with
+// no records is just removed.
+func (w *moveLogWriter) seal() error {
+	flushErr := w.flush()
+	closeErr := w.file.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	if w.records == 0 {
+		return w.store.Delete(w.name())
+	}
+	return w.store.Rename(w.name(), moveLogFilename(w.openedAtNs, w.firstSeq, w.lastSeq))
+}
+
+type MoveLogReader struct {
+	file     io.ReadCloser
+	decoder  *zstd.Decoder
+	reader   *bufio.Reader
+	legacy   []boardToDiskRequest
+	offset   int64
+	payload  []byte
+	header   [8]byte
+	isLegacy bool
+}
+
+// Logs ending in .zst are decompressed as we read them
+func OpenMoveLog(path string) (*MoveLogReader, error) {
+	return openMoveLog(NewLocalStateStore(filepath.Dir(path)), filepath.Base(path))
+}
+
+func openMoveLog(store StateStore, name string) (*MoveLogReader, error) {
+	file, _, err := store.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return newMoveLogReader(file, strings.HasSuffix(name, compressedMoveLogSuffix))
+}
+
+// Takes ownership of file, which needn't be a file (we also read replication
+// streams with this).
+func newMoveLogReader(file io.ReadCloser, compressed bool) (*MoveLogReader, error) {
+	r := &MoveLogReader{file: file}
+	var source io.Reader = file
+	if compressed {
+		dec, err := zstd.NewReader(file, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		r.decoder = dec
+		source = dec
+	}
+	r.reader = bufio.NewReaderSize(source, 4*1024*1024)
+	magic, err := r.reader.Peek(len(moveLogMagic))
+	if err != nil || !bytes.Equal(magic, moveLogMagic[:]) {
+		if err := r.readLegacy(); err != nil {
+			r.Close()
+			return nil, err
+		}
+		return r, nil
+	}
+	r.reader.Discard(len(moveLogMagic))
+	var version uint32
+	if err := binary.Read(r.reader, binary.LittleEndian, &version); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("%w: reading version: %v", ErrCorruptMoveLog, err)
+	}
+	if version > MOVE_LOG_FORMAT_VERSION {
+		r.Close()
+		return nil, fmt.Errorf("unsupported move log version %d", version)
+	}
+	r.offset = int64(len(moveLogMagic)) + 4
+	return r, nil
+}
+
+func (r *MoveLogReader) readLegacy() error {
+	dec := gob.NewDecoder(r.reader)
+	requests := make([]boardToDiskRequest, 0, 128)
+	if err := dec.Decode(&requests); err != nil {
+		return fmt.Errorf("%w: decoding gob: %v", ErrCorruptMoveLog, err)
+	}
+	r.legacy = requests
+	r.isLegacy = true
+	return nil
+}
+
+// Returns io.EOF at the end of the log, or an error wrapping
+// ErrCorruptMoveLog if the next record is torn or fails its checksum.
+func (r *MoveLogReader) Next() (boardToDiskRequest, error) {
+	if r.isLegacy {
+		if len(r.legacy) == 0 {
+			return boardToDiskRequest{}, io.EOF
+		}
+		req := r.legacy[0]
+		r.legacy = r.legacy[1:]
+		return req, nil
+	}
+	_, err := io.ReadFull(r.reader, r.header[:])
+	if err == io.EOF {
+		return boardToDiskRequest{}, io.EOF
+	} else if err != nil {
+		return boardToDiskRequest{}, fmt.Errorf("%w: torn record header at offset %d", ErrCorruptMoveLog, r.offset)
+	}
+	length := binary.LittleEndian.Uint32(r.header[0:4])
+	crc := binary.LittleEndian.Uint32(r.header[4:8])
+	if length > MAX_MOVE_LOG_RECORD_BYTES {
+		return boardToDiskRequest{}, fmt.Errorf("%w: record at offset %d claims %d bytes", ErrCorruptMoveLog, r.offset, length)
+	}
+	if cap(r.payload) < int(length) {
+		r.payload = make([]byte, length)
+	}
+	r.payload = r.payload[:length]
+	if _, err := io.ReadFull(r.reader, r.payload); err != nil {
+		return boardToDiskRequest{}, fmt.Errorf("%w: torn record at offset %d", ErrCorruptMoveLog, r.offset)
+	}
+	if crc32.Checksum(r.payload, crcTable) != crc {
+		return boardToDiskRequest{}, fmt.Errorf("%w: checksum mismatch at offset %d", ErrCorruptMoveLog, r.offset)
+	}
+	req, err := decodeRequestPayload(r.payload)
+	if err != nil {
+		return req, err
+	}
+	r.offset += int64(len(r.header)) + int64(length)
+	return req, nil
+}
+
+// Byte offset just past the last record that Next returned successfully
+func (r *MoveLogReader) Offset() int64 {
+	return r.offset
+}
+
+func (r *MoveLogReader) Close() error {
+	if r.decoder != nil {
+		r.decoder.Close()
+	}
+	return r.file.Close()
+}
+
+func parseOpenMoveLogFilename(path string) (timestamp int64, firstSeq uint64, err error) {
+	name := strings.TrimSuffix(filepath.Base(path), ".bin"+openMoveLogSuffix)
+	parts := strings.Split(name, "-")
+	if len(parts) != 3 || parts[0] != "moves" ||
+		!strings.HasPrefix(parts[1], "ts:") || !strings.HasPrefix(parts[2], "firstseq:") {
+		err = fmt.Errorf("Unexpected format: %s", path)
+		return
+	}
+	timestamp, err = strconv.ParseInt(strings.TrimPrefix(parts[1], "ts:"), 10, 64)
+	if err != nil {
+		return
+	}
+	firstSeq, err = strconv.ParseUint(strings.TrimPrefix(parts[2], "firstseq:"), 10, 64)
+	return
+}
+
+// Open segments are left behind when we crash. Work out how far the intact
+// records go so that we can replay them; we don't touch the file here.
+func scanOpenMoveLog(store StateStore, name string) (ml moveLogFile, validBytes int64, err error) {
+	timestamp, firstSeq, err := parseOpenMoveLogFilename(name)
+	if err != nil {
+		return
+	}
+	r, err := openMoveLog(store, name)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	ml = moveLogFile{Name: name, Timestamp: timestamp, FirstSeq: firstSeq, Open: true}
+	records := 0
+	for {
+		req, nextErr := r.Next()
+		if nextErr == io.EOF {
+			break
+		} else if nextErr != nil {
+			log.Printf("Open move log %s ends with a bad record: %v", name, nextErr)
+			break
+		}
+		ml.LastSeq = req.Seqnum
+		records++
+	}
+	validBytes = r.Offset()
+	if records == 0 {
+		ml.LastSeq = firstSeq - 1
+	}
+	return
+}
+
+// Copy an open segment's intact records to its final name and remove it
+// (or just remove it if it has no records). Not every store can truncate, so
+// we copy; if we die in between, SortedMoveLogs ignores the open segment.
+func sealOpenMoveLog(store StateStore, name string) error {
+	ml, validBytes, err := scanOpenMoveLog(store, name)
+	if err != nil {
+		return err
+	}
+	if ml.LastSeq < ml.FirstSeq {
+		log.Printf("Removing empty open move log %s", name)
+		return store.Delete(name)
+	}
+	final := moveLogFilename(ml.Timestamp, ml.FirstSeq, ml.LastSeq)
+	log.Printf("Sealing open move log %s -> %s", name, final)
+	err = store.Put(final, func(writer io.Writer) error {
+		src, _, err := store.Get(name)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.CopyN(writer, src, validBytes)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return store.Delete(name)
+}
+
+func writeMoveLog(path string, requests []boardToDiskRequest) error {
+	return WriteFileAtomic(path, func(writer io.Writer) error {
+		buf := bufio.NewWriterSize(writer, 2*1024*1024)
+		if err := binary.Write(buf, binary.LittleEndian, moveLogMagic); err != nil {
+			return err
+		}
+		if err := binary.Write(buf, binary.LittleEndian, uint32(MOVE_LOG_FORMAT_VERSION)); err != nil {
+			return err
+		}
+		var lengthAndCRC [8]byte
+		payload := make([]byte, 0, 64)
+		for _, req := range requests {
+			var err error
+			payload, err = appendRequestPayload(payload[:0], req)
+			if err != nil {
+				return err
+			}
+			binary.LittleEndian.PutUint32(lengthAndCRC[0:4], uint32(len(payload)))
+			binary.LittleEndian.PutUint32(lengthAndCRC[4:8], crc32.Checksum(payload, crcTable))
+			if _, err := buf.Write(lengthAndCRC[:]); err != nil {
+				return err
+			}
+			if _, err := buf.Write(payload); err != nil {
+				return err
+			}
+		}
+		return buf.Flush()
+	})
+}
+
+// Rewrite a gob-encoded move log in the current format, in place. The
+// oldest gob logs don't record seqnums, so we fill them in from the filename.
+func ConvertGobMoveLog(path string) error {
+	ml, err := parseMoveLogFilename(path)
+	if err != nil {
+		return err
+	}
+	r, err := OpenMoveLog(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if !r.isLegacy {
+		return fmt.Errorf("%s is not a gob move log", path)
+	}
+	requests := r.legacy
+	if uint64(len(requests)) != ml.LastSeq-ml.FirstSeq+1 {
+		return fmt.Errorf("%s has %d requests but its name claims %d",
+			path, len(requests), ml.LastSeq-ml.FirstSeq+1)
+	}
+	for i := range requests {
+		if requests[i].Seqnum == 0 {
+			requests[i].Seqnum = ml.FirstSeq + uint64(i)
+		}
+	}
+	return writeMoveLog(path, requests)
+}
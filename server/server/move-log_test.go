@@ -0,0 +1,306 @@
+package server
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"one-million-chessboards/protocol"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// One of each kind that we write to logs
+func testRequests() []boardToDiskRequest {
+	squares := []squareAssignment{
+		{X: 1, Y: 2, Piece: 0x0102030405060708},
+		{X: 7999, Y: 0, Piece: 0},
+	}
+	return []boardToDiskRequest{
+		{Seqnum: 1, TimestampNs: 1001, Move: &Move{
+			PieceID: 12345, FromX: 1, FromY: 6, ToX: 1, ToY: 7,
+			MoveType: protocol.MoveType_MOVE_TYPE_NORMAL, MoveToken: 99, ClientIsPlayingWhite: true,
+			PlayerID: 0xDEADBEEF12345678, Promotion: protocol.PieceType_PIECE_TYPE_KNIGHT,
+		}},
+		{Seqnum: 2, TimestampNs: 1002, Move: &Move{
+			PieceID: 6789, FromX: 4, FromY: 0, ToX: 6, ToY: 0,
+			MoveType: protocol.MoveType_MOVE_TYPE_CASTLE,
+		}},
+		{Seqnum: 3, TimestampNs: 1003, AdoptionRequest: &adoptionRequest{BoardX: 5, BoardY: 6, Color: OnlyColorBlack}},
+		{Seqnum: 4, TimestampNs: 1004, BulkCaptureRequest: &bulkCaptureRequest{BoardX: 999, BoardY: 0, Color: OnlyColorEither}},
+		{Seqnum: 5, TimestampNs: 1005, RollbackRequest: &rollbackRequest{
+			FromSeqnum: 1, ToSeqnum: 4, MinX: 0, MinY: 1, MaxX: 15, MaxY: 16, Squares: squares,
+		}},
+		{Seqnum: 6, TimestampNs: 1006, SetPiecesRequest: &setPiecesRequest{Squares: squares}},
+		{Seqnum: 7, TimestampNs: 1007, RuleChangeRequest: &ruleChangeRequest{
+			PromotionRule: PromotionRuleBoardEdge, RuleSet: RuleSetClassical,
+		}},
+	}
+}
+
+func readAllRequests(t *testing.T, store StateStore, name string) []boardToDiskRequest {
+	t.Helper()
+	r, err := openMoveLog(store, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var requests []boardToDiskRequest
+	for {
+		req, err := r.Next()
+		if err == io.EOF {
+			return requests
+		} else if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		requests = append(requests, req)
+	}
+}
+
+func TestMoveLogRoundTrip(t *testing.T) {
+	store := NewMemoryStateStore()
+	w, err := newMoveLogWriter(store, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testRequests()
+	for _, req := range want {
+		if err := w.append(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.seal(); err != nil {
+		t.Fatal(err)
+	}
+	name := moveLogFilename(w.openedAtNs, 1, uint64(len(want)))
+	if got := readAllRequests(t, store, name); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+	if _, _, err := store.Get(w.name()); err == nil {
+		t.Errorf("the open segment is still around after sealing")
+	}
+
+	if err := compressMoveLog(store, name); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAllRequests(t, store, name+compressedMoveLogSuffix); !reflect.DeepEqual(got, want) {
+		t.Errorf("compressed: got  %v\nwant %v", got, want)
+	}
+
+	heartbeat := appendHeartbeatPayload(nil, 42, 4242)
+	req, err := decodeRequestPayload(heartbeat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !req.isHeartbeat() || req.Seqnum != 42 || req.TimestampNs != 4242 {
+		t.Errorf("heartbeat decoded as %+v", req)
+	}
+}
+
+// Readers treat fields missing from the end of a record as zero, so that
+// we can add fields without breaking old logs
+func TestMoveLogMissingTrailingFields(t *testing.T) {
+	full := testRequests()[0]
+	withoutPlayer := full
+	move := *full.Move
+	move.PlayerID = 0
+	move.Promotion = protocol.PieceType_PIECE_TYPE_PAWN
+	withoutPlayer.Move = &move
+	ruleChange := testRequests()[6]
+	withoutRuleSet := ruleChange
+	withoutRuleSet.RuleChangeRequest = &ruleChangeRequest{PromotionRule: PromotionRuleBoardEdge}
+
+	tests := []struct {
+		name    string
+		req     boardToDiskRequest
+		cut     int
+		want    boardToDiskRequest
+		wantErr bool
+	}{
+		{name: "move without player or promotion", req: full, cut: 9, want: withoutPlayer},
+		{name: "rule change without rule set", req: ruleChange, cut: 1, want: withoutRuleSet},
+		{name: "set pieces with a square missing", req: testRequests()[5], cut: 1, wantErr: true},
+		{name: "shorter than the prefix", req: full, cut: 40, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := appendRequestPayload(nil, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decodeRequestPayload(payload[:len(payload)-tt.cut])
+			if tt.wantErr {
+				if !errors.Is(err, ErrCorruptMoveLog) {
+					t.Errorf("got %v, want ErrCorruptMoveLog", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveLogCorruption(t *testing.T) {
+	data := testMoveLogBytes(t, 1, 3)
+	recordSize := (len(data) - 12) / 3
+	secondRecord := 12 + recordSize
+
+	tests := []struct {
+		name    string
+		corrupt func(b []byte) []byte
+		wantErr string
+	}{
+		{"checksum mismatch", func(b []byte) []byte {
+			b[secondRecord+8+10] ^= 0xFF
+			return b
+		}, "checksum mismatch"},
+		{"bad checksum", func(b []byte) []byte {
+			b[secondRecord+4] ^= 0xFF
+			return b
+		}, "checksum mismatch"},
+		{"huge length", func(b []byte) []byte {
+			b[secondRecord+3] = 0xFF
+			return b
+		}, "claims"},
+		{"torn header", func(b []byte) []byte {
+			return b[:secondRecord+5]
+		}, "torn record header"},
+		{"torn payload", func(b []byte) []byte {
+			return b[:secondRecord+recordSize-1]
+		}, "torn record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStateStore()
+			putBytes(t, store, "log.bin", tt.corrupt(bytes.Clone(data)))
+			r, err := openMoveLog(store, "log.bin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			if req, err := r.Next(); err != nil || req.Seqnum != 1 {
+				t.Fatalf("first record: got %+v, %v", req, err)
+			}
+			_, err = r.Next()
+			if !errors.Is(err, ErrCorruptMoveLog) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v, want ErrCorruptMoveLog containing %q", err, tt.wantErr)
+			}
+			if r.Offset() != int64(secondRecord) {
+				t.Errorf("offset is %d after a bad record, want %d", r.Offset(), secondRecord)
+			}
+		})
+	}
+}
+
+// What we find after crashing partway through writing a record
+func TestSealOpenMoveLogWithTornTail(t *testing.T) {
+	store := NewMemoryStateStore()
+	name := openMoveLogFilename(123, 11)
+	data := testMoveLogBytes(t, 11, 15)
+	putBytes(t, store, name, data[:len(data)-3])
+
+	ml, validBytes, err := scanOpenMoveLog(store, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ml.FirstSeq != 11 || ml.LastSeq != 14 || !ml.Open {
+		t.Errorf("scanned %+v, want seqnums 11-14", ml)
+	}
+	intact := testMoveLogBytes(t, 11, 14)
+	if validBytes != int64(len(intact)) {
+		t.Errorf("%d valid bytes, want %d", validBytes, len(intact))
+	}
+
+	if err := sealOpenMoveLog(store, name); err != nil {
+		t.Fatal(err)
+	}
+	sealed := getString(t, store, moveLogFilename(123, 11, 14))
+	if sealed != string(intact) {
+		t.Errorf("sealed log isn't the intact records")
+	}
+	expectNotExist(t, store, name)
+
+	// Nothing intact at all
+	empty := openMoveLogFilename(456, 16)
+	putBytes(t, store, empty, testMoveLogBytes(t, 16, 16)[:15])
+	if err := sealOpenMoveLog(store, empty); err != nil {
+		t.Fatal(err)
+	}
+	names, err := store.List("moves-ts:456*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("sealing an empty log left %v", names)
+	}
+}
+
+func TestConvertGobMoveLog(t *testing.T) {
+	dir := t.TempDir()
+	want := testRequests()
+	// The oldest logs don't have seqnums
+	var gobRequests []boardToDiskRequest
+	for i, req := range want {
+		if i < 3 {
+			req.Seqnum = 0
+		}
+		gobRequests = append(gobRequests, req)
+	}
+	write := func(name string, requests []boardToDiskRequest) string {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(requests); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// Legacy names use startseq for the last seqnum and endseq for the one
+	// after that plus the count
+	n := uint64(len(want))
+	path := write(fmt.Sprintf("moves-ts:1-startseq:%d-endseq:%d.bin", n, 2*n), gobRequests)
+
+	r, err := OpenMoveLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.isLegacy {
+		t.Errorf("didn't read %s as a gob log", path)
+	}
+	r.Close()
+
+	if err := ConvertGobMoveLog(path); err != nil {
+		t.Fatal(err)
+	}
+	r, err = OpenMoveLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.isLegacy {
+		t.Errorf("%s is still a gob log", path)
+	}
+	store := NewLocalStateStore(dir)
+	if got := readAllRequests(t, store, filepath.Base(path)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+
+	if err := ConvertGobMoveLog(path); err == nil {
+		t.Errorf("converted a log that was already converted")
+	}
+	short := write(fmt.Sprintf("moves-ts:2-firstseq:1-lastseq:%d.bin", n+1), gobRequests)
+	if err := ConvertGobMoveLog(short); err == nil || !strings.Contains(err.Error(), "name claims") {
+		t.Errorf("got %v converting a log with fewer requests than its name", err)
+	}
+}
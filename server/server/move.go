@@ -16,6 +16,10 @@ type Move struct {
 	MoveType             protocol.MoveType
 	MoveToken            uint32
 	ClientIsPlayingWhite bool
+	// Who made the move, for their stats. 0 if we don't know.
+	PlayerID PlayerID
+	// What a pawn reaching the far edge asked to become (Pawn if it didn't ask)
+	Promotion protocol.PieceType
 }
 
 func (m *Move) ToString() string {
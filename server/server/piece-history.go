@@ -0,0 +1,749 @@
+package server
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"one-million-chessboards/protocol"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Where has a piece been? Move logs can't answer that on their own (they
+// don't say what a move captured), so whenever the BoardToDiskHandler seals a
+// move log it also writes a history index for it:
+//
+//	piece-history-firstseq:<first>-lastseq:<last>.bin
+//
+// An index is a small header followed by fixed-size entries sorted by piece
+// ID (and then seqnum), so we can binary search each index for a piece
+// without reading the whole thing.
+//
+// Move logs sealed after a crash or before indexes existed don't have an
+// index; IndexPieceHistory replays the logs from a snapshot to fill them in.
+// Until then, lookups say which seqnums they couldn't cover.
+//
+// We write an index every MOVE_SERIALIZATION_INTERVAL and never prune them,
+// so retention merges runs of adjacent indexes into bigger ones (named for
+// the seqnums that they cover, like any other index). A lookup then reads
+// at most about PIECE_HISTORY_MERGE_FANIN indexes per order of magnitude of
+// history rather than one per segment.
+
+const (
+	PIECE_HISTORY_FORMAT_VERSION = 1
+	PIECE_HISTORY_MERGE_FANIN    = 16
+)
+
+var pieceHistoryMagic = [8]byte{'O', 'M', 'C', 'B', 'H', 'I', 'S', 'T'}
+
+type PieceHistoryKind uint8
+
+const (
+	PieceHistoryMoved        PieceHistoryKind = 1
+	PieceHistoryCaptured     PieceHistoryKind = 2
+	PieceHistoryBulkCaptured PieceHistoryKind = 3
+	PieceHistoryRolledBack   PieceHistoryKind = 4
+	PieceHistoryRemoved      PieceHistoryKind = 5
+	PieceHistoryPlaced       PieceHistoryKind = 6
+)
+
+func (k PieceHistoryKind) String() string {
+	switch k {
+	case PieceHistoryMoved:
+		return "MOVED"
+	case PieceHistoryCaptured:
+		return "CAPTURED"
+	case PieceHistoryBulkCaptured:
+		return "BULK_CAPTURED"
+	case PieceHistoryRolledBack:
+		return "ROLLED_BACK"
+	case PieceHistoryRemoved:
+		return "REMOVED"
+	case PieceHistoryPlaced:
+		return "PLACED"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", k)
+	}
+}
+
+// For a move, Other is the piece that it captured (if any). For a capture,
+// Other is the piece that captured it. From and To are the same square for
+// captures, removals and placements, and for a rollback that puts back a
+// captured piece.
+type PieceHistoryEntry struct {
+	PieceID     uint32
+	Kind        PieceHistoryKind
+	MoveType    uint8
+	FromX       uint16
+	FromY       uint16
+	ToX         uint16
+	ToY         uint16
+	Seqnum      uint64
+	TimestampNs int64
+	Other       EncodedPiece
+}
+
+type pieceHistoryHeader struct {
+	Magic         [8]byte
+	FormatVersion uint32
+	EntryCount    uint32
+}
+
+var pieceHistoryEntrySize = int64(binary.Size(PieceHistoryEntry{}))
+var pieceHistoryHeaderSize = int64(binary.Size(pieceHistoryHeader{}))
+
+func (e *PieceHistoryEntry) ToString() string {
+	ts := time.Unix(0, e.TimestampNs).UTC().Format(time.RFC3339Nano)
+	s := fmt.Sprintf("%d %s %s", e.Seqnum, ts, e.Kind)
+	switch e.Kind {
+	case PieceHistoryMoved:
+		s += fmt.Sprintf(" (%d, %d) -> (%d, %d) %s",
+			e.FromX, e.FromY, e.ToX, e.ToY, protocol.MoveType(e.MoveType))
+		if e.Other != EmptyEncodedPiece {
+			other := PieceOfEncodedPiece(e.Other)
+			s += fmt.Sprintf(" capturing %s (%d)", other.Type, other.ID)
+		}
+	case PieceHistoryCaptured:
+		other := PieceOfEncodedPiece(e.Other)
+		s += fmt.Sprintf(" at (%d, %d) by %s (%d)", e.ToX, e.ToY, other.Type, other.ID)
+	case PieceHistoryBulkCaptured:
+		s += fmt.Sprintf(" at (%d, %d)", e.ToX, e.ToY)
+	case PieceHistoryRolledBack:
+		s += fmt.Sprintf(" (%d, %d) -> (%d, %d)", e.FromX, e.FromY, e.ToX, e.ToY)
+	}
+	return s
+}
+
+type pieceHistoryBuilder struct {
+	entries []PieceHistoryEntry
+}
+
+func newPieceHistoryBuilder() *pieceHistoryBuilder {
+	return &pieceHistoryBuilder{
+		entries: make([]PieceHistoryEntry, 0, MAX_MOVES_TO_SERIALIZE*2),
+	}
+}
+
+func (h *pieceHistoryBuilder) recordMove(req boardToDiskRequest, res *MoveResult) {
+	captured := res.CapturedPiece
+	for i, moved := range res.MovedPieces {
+		entry := PieceHistoryEntry{
+			PieceID:     moved.Piece.ID,
+			Kind:        PieceHistoryMoved,
+			MoveType:    uint8(req.Move.MoveType),
+			FromX:       moved.FromX,
+			FromY:       moved.FromY,
+			ToX:         moved.ToX,
+			ToY:         moved.ToY,
+			Seqnum:      res.Seqnum,
+			TimestampNs: req.TimestampNs,
+		}
+		// The first moved piece is the one that was asked to move; the second
+		// (if any) is the rook in a castle.
+		if i == 0 && !captured.Piece.IsEmpty() {
+			entry.Other = captured.Piece.Encode()
+		}
+		h.entries = append(h.entries, entry)
+	}
+	if !captured.Piece.IsEmpty() && len(res.MovedPieces) > 0 {
+		h.entries = append(h.entries, PieceHistoryEntry{
+			PieceID:     captured.Piece.ID,
+			Kind:        PieceHistoryCaptured,
+			FromX:       captured.X,
+			FromY:       captured.Y,
+			ToX:         captured.X,
+			ToY:         captured.Y,
+			Seqnum:      res.Seqnum,
+			TimestampNs: req.TimestampNs,
+			Other:       res.MovedPieces[0].Piece.Encode(),
+		})
+	}
+}
+
+// Call this *before* applying the bulk capture, while the pieces are still
+// on the board.
+func (h *pieceHistoryBuilder) recordBulkCapture(board *Board, req boardToDiskRequest) {
+	r := req.BulkCaptureRequest
+	onlyColor := r.OnlyColor()
+	for y := r.StartingY(); y < r.EndingY(); y++ {
+		for x := r.StartingX(); x < r.EndingX(); x++ {
+			p := PieceOfEncodedPiece(EncodedPiece(board.pieces[y][x]))
+			if p.IsEmpty() {
+				continue
+			}
+			if onlyColor == OnlyColorWhite && !p.IsWhite {
+				continue
+			} else if onlyColor == OnlyColorBlack && p.IsWhite {
+				continue
+			}
+			h.entries = append(h.entries, PieceHistoryEntry{
+				PieceID:     p.ID,
+				Kind:        PieceHistoryBulkCaptured,
+				FromX:       x,
+				FromY:       y,
+				ToX:         x,
+				ToY:         y,
+				Seqnum:      board.seqNum + 1,
+				TimestampNs: req.TimestampNs,
+			})
+		}
+	}
+}
+
+// Like recordBulkCapture, call this before applying the rollback
+func (h *pieceHistoryBuilder) recordRollback(board *Board, req boardToDiskRequest) {
+	r := req.RollbackRequest
+	removedFrom := make(map[uint32]squareAssignment, len(r.Squares))
+	for _, sq := range r.Squares {
+		old := EncodedPiece(board.pieces[sq.Y][sq.X])
+		if !EncodedIsEmpty(old) {
+			removedFrom[PieceOfEncodedPiece(old).ID] = sq
+		}
+	}
+	for _, sq := range r.Squares {
+		if EncodedIsEmpty(sq.Piece) {
+			continue
+		}
+		id := PieceOfEncodedPiece(sq.Piece).ID
+		from, ok := removedFrom[id]
+		if !ok {
+			from = sq
+		}
+		h.entries = append(h.entries, PieceHistoryEntry{
+			PieceID:     id,
+			Kind:        PieceHistoryRolledBack,
+			FromX:       from.X,
+			FromY:       from.Y,
+			ToX:         sq.X,
+			ToY:         sq.Y,
+			Seqnum:      board.seqNum + 1,
+			TimestampNs: req.TimestampNs,
+		})
+	}
+}
+
+// Like recordBulkCapture, call this before setting the pieces. Pieces that
+// are placed are always new, so nothing here moves.
+func (h *pieceHistoryBuilder) recordSetPieces(board *Board, req boardToDiskRequest) {
+	for _, sq := range req.SetPiecesRequest.Squares {
+		old := EncodedPiece(board.pieces[sq.Y][sq.X])
+		if !EncodedIsEmpty(old) {
+			h.entries = append(h.entries, PieceHistoryEntry{
+				PieceID:     PieceOfEncodedPiece(old).ID,
+				Kind:        PieceHistoryRemoved,
+				FromX:       sq.X,
+				FromY:       sq.Y,
+				ToX:         sq.X,
+				ToY:         sq.Y,
+				Seqnum:      board.seqNum + 1,
+				TimestampNs: req.TimestampNs,
+			})
+		}
+		if !EncodedIsEmpty(sq.Piece) {
+			h.entries = append(h.entries, PieceHistoryEntry{
+				PieceID:     PieceOfEncodedPiece(sq.Piece).ID,
+				Kind:        PieceHistoryPlaced,
+				FromX:       sq.X,
+				FromY:       sq.Y,
+				ToX:         sq.X,
+				ToY:         sq.Y,
+				Seqnum:      board.seqNum + 1,
+				TimestampNs: req.TimestampNs,
+			})
+		}
+	}
+}
+
+func pieceHistoryFilename(firstSeq, lastSeq uint64) string {
+	return fmt.Sprintf("piece-history-firstseq:%d-lastseq:%d.bin", firstSeq, lastSeq)
+}
+
+func parsePieceHistoryFilename(path string) (firstSeq, lastSeq uint64, err error) {
+	name := strings.TrimSuffix(filepath.Base(path), ".bin")
+	rest, ok := strings.CutPrefix(name, "piece-history-firstseq:")
+	if !ok {
+		err = fmt.Errorf("Unexpected format: %s", path)
+		return
+	}
+	first, last, ok := strings.Cut(rest, "-lastseq:")
+	if !ok {
+		err = fmt.Errorf("Unexpected format: %s", path)
+		return
+	}
+	firstSeq, err = strconv.ParseUint(first, 10, 64)
+	if err != nil {
+		return
+	}
+	lastSeq, err = strconv.ParseUint(last, 10, 64)
+	return
+}
+
+// Write the index for the move log covering [firstSeq, lastSeq] and forget
+// everything that we've recorded so far.
+func (h *pieceHistoryBuilder) writeIndex(store StateStore, firstSeq, lastSeq uint64) error {
+	return writePieceHistoryIndex(store, firstSeq, lastSeq, h.takeIndex(firstSeq, lastSeq))
+}
+
+// The index entries for the move log covering [firstSeq, lastSeq], sorted by
+// piece. Forgets everything that we've recorded so far.
+func (h *pieceHistoryBuilder) takeIndex(firstSeq, lastSeq uint64) []PieceHistoryEntry {
+	entries := make([]PieceHistoryEntry, 0, len(h.entries))
+	for _, e := range h.entries {
+		if e.Seqnum >= firstSeq && e.Seqnum <= lastSeq {
+			entries = append(entries, e)
+		}
+	}
+	h.entries = h.entries[:0]
+	slices.SortStableFunc(entries, func(a, b PieceHistoryEntry) int {
+		if a.PieceID < b.PieceID {
+			return -1
+		} else if a.PieceID > b.PieceID {
+			return 1
+		}
+		return 0
+	})
+	return entries
+}
+
+func writePieceHistoryIndex(store StateStore, firstSeq, lastSeq uint64, entries []PieceHistoryEntry) error {
+	return store.Put(pieceHistoryFilename(firstSeq, lastSeq), func(writer io.Writer) error {
+		buf := bufio.NewWriterSize(writer, 1024*1024)
+		header := pieceHistoryHeader{
+			Magic:         pieceHistoryMagic,
+			FormatVersion: PIECE_HISTORY_FORMAT_VERSION,
+			EntryCount:    uint32(len(entries)),
+		}
+		if err := binary.Write(buf, binary.LittleEndian, &header); err != nil {
+			return err
+		}
+		if err := binary.Write(buf, binary.LittleEndian, entries); err != nil {
+			return err
+		}
+		return buf.Flush()
+	})
+}
+
+// We binary search with ranged reads rather than fetching the whole index,
+// since the index may not be on local disk.
+func readPieceHistoryIndex(store StateStore, name string, pieceID uint32) ([]PieceHistoryEntry, error) {
+	file := &storeReaderAt{store: store, name: name}
+	var header pieceHistoryHeader
+	if err := binary.Read(io.NewSectionReader(file, 0, pieceHistoryHeaderSize), binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != pieceHistoryMagic {
+		return nil, fmt.Errorf("%s is not a piece history index", name)
+	}
+	if header.FormatVersion > PIECE_HISTORY_FORMAT_VERSION {
+		return nil, fmt.Errorf("unsupported piece history version %d", header.FormatVersion)
+	}
+	var readErr error
+	entryAt := func(i int) (entry PieceHistoryEntry) {
+		section := io.NewSectionReader(file, pieceHistoryHeaderSize+int64(i)*pieceHistoryEntrySize, pieceHistoryEntrySize)
+		if err := binary.Read(section, binary.LittleEndian, &entry); err != nil && readErr == nil {
+			readErr = err
+		}
+		return
+	}
+	count := int(header.EntryCount)
+	start := sort.Search(count, func(i int) bool {
+		return entryAt(i).PieceID >= pieceID
+	})
+	var entries []PieceHistoryEntry
+	for i := start; i < count && readErr == nil; i++ {
+		entry := entryAt(i)
+		if entry.PieceID != pieceID {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, readErr)
+	}
+	return entries, nil
+}
+
+type pieceHistoryIndex struct {
+	Name     string
+	FirstSeq uint64
+	LastSeq  uint64
+}
+
+func sortedPieceHistoryIndexes(store StateStore) ([]pieceHistoryIndex, error) {
+	files, err := store.List("piece-history-*.bin")
+	if err != nil {
+		return nil, err
+	}
+	indexes := make([]pieceHistoryIndex, 0, len(files))
+	for _, f := range files {
+		first, last, err := parsePieceHistoryFilename(f)
+		if err != nil {
+			log.Printf("ERROR? Could not parse piece history filename: %v", err)
+			continue
+		}
+		indexes = append(indexes, pieceHistoryIndex{Name: f, FirstSeq: first, LastSeq: last})
+	}
+	// Widest first among indexes that start together, so that
+	// splitPieceHistoryIndexes sees a merged index before its inputs
+	slices.SortFunc(indexes, func(a, b pieceHistoryIndex) int {
+		if a.FirstSeq < b.FirstSeq {
+			return -1
+		} else if a.FirstSeq > b.FirstSeq {
+			return 1
+		} else if a.LastSeq > b.LastSeq {
+			return -1
+		} else if a.LastSeq < b.LastSeq {
+			return 1
+		}
+		return 0
+	})
+	return indexes, nil
+}
+
+// An index inside another one is an input to a merge that we died before
+// cleaning up after; reading both would list its entries twice.
+func splitPieceHistoryIndexes(sorted []pieceHistoryIndex) (covering, leftover []pieceHistoryIndex) {
+	for _, index := range sorted {
+		if len(covering) > 0 && index.LastSeq <= covering[len(covering)-1].LastSeq {
+			leftover = append(leftover, index)
+			continue
+		}
+		covering = append(covering, index)
+	}
+	return
+}
+
+// Whether a single index covers all of [firstSeq, lastSeq]. covering is
+// from splitPieceHistoryIndexes, so both ends are increasing.
+func pieceHistoryCovers(covering []pieceHistoryIndex, firstSeq, lastSeq uint64) bool {
+	i := sort.Search(len(covering), func(i int) bool {
+		return covering[i].LastSeq >= firstSeq
+	})
+	return i < len(covering) && covering[i].FirstSeq <= firstSeq && covering[i].LastSeq >= lastSeq
+}
+
+type SeqnumRange struct {
+	FirstSeq uint64
+	LastSeq  uint64
+}
+
+// The seqnums of sealed move logs without an index, with adjacent logs
+// joined up. We only look at names, so this doesn't read any logs.
+func unindexedSeqnums(store StateStore, covering []pieceHistoryIndex) ([]SeqnumRange, error) {
+	files, err := store.List("moves-*.bin")
+	if err != nil {
+		return nil, err
+	}
+	compressedFiles, err := store.List("moves-*.bin" + compressedMoveLogSuffix)
+	if err != nil {
+		return nil, err
+	}
+	var missing []SeqnumRange
+	for _, f := range append(files, compressedFiles...) {
+		ml, err := parseMoveLogFilename(f)
+		if err != nil || pieceHistoryCovers(covering, ml.FirstSeq, ml.LastSeq) {
+			continue
+		}
+		missing = append(missing, SeqnumRange{FirstSeq: ml.FirstSeq, LastSeq: ml.LastSeq})
+	}
+	slices.SortFunc(missing, func(a, b SeqnumRange) int {
+		if a.FirstSeq < b.FirstSeq {
+			return -1
+		} else if a.FirstSeq > b.FirstSeq {
+			return 1
+		}
+		return 0
+	})
+	joined := make([]SeqnumRange, 0, len(missing))
+	for _, r := range missing {
+		if n := len(joined); n > 0 && r.FirstSeq <= joined[n-1].LastSeq+1 {
+			joined[n-1].LastSeq = max(joined[n-1].LastSeq, r.LastSeq)
+			continue
+		}
+		joined = append(joined, r)
+	}
+	return joined, nil
+}
+
+func pieceHistoryTier(index pieceHistoryIndex) int {
+	tier := 0
+	for span := index.LastSeq - index.FirstSeq + 1; span >= PIECE_HISTORY_MERGE_FANIN; span /= PIECE_HISTORY_MERGE_FANIN {
+		tier++
+	}
+	return tier
+}
+
+// Groups of PIECE_HISTORY_MERGE_FANIN adjacent indexes (each starting just
+// after the last one ends) to merge, smallest first: we look for runs of
+// indexes of tier 0, then of tier 1 or less, and so on, where an index's
+// tier is the log (base fanin) of how many seqnums it covers. Merging a run
+// moves it up at least one tier. Each index is in at most one group, so
+// bigger merges wait for the next run of retention.
+func planPieceHistoryMerges(covering []pieceHistoryIndex) [][]pieceHistoryIndex {
+	grouped := make([]bool, len(covering))
+	maxTier := 0
+	for _, index := range covering {
+		maxTier = max(maxTier, pieceHistoryTier(index))
+	}
+	var groups [][]pieceHistoryIndex
+	for tier := 0; tier <= maxTier; tier++ {
+		var run []pieceHistoryIndex
+		for i, index := range covering {
+			if grouped[i] || pieceHistoryTier(index) > tier {
+				run = nil
+				continue
+			}
+			if len(run) > 0 && index.FirstSeq != run[len(run)-1].LastSeq+1 {
+				run = nil
+			}
+			run = append(run, index)
+			if len(run) == PIECE_HISTORY_MERGE_FANIN {
+				for j := i - len(run) + 1; j <= i; j++ {
+					grouped[j] = true
+				}
+				groups = append(groups, run)
+				run = nil
+			}
+		}
+	}
+	return groups
+}
+
+type pieceHistoryCursor struct {
+	r *bufio.Reader
+	// Entries that we haven't read yet
+	remaining uint32
+	order     int
+	entry     []byte
+}
+
+func (c *pieceHistoryCursor) pieceID() uint32 {
+	// PieceID is the first field of an entry
+	return binary.LittleEndian.Uint32(c.entry)
+}
+
+func (c *pieceHistoryCursor) next() error {
+	c.remaining--
+	_, err := io.ReadFull(c.r, c.entry)
+	return err
+}
+
+// Ordered by piece and then by which index an entry came from, which keeps
+// each piece's entries in seqnum order
+type pieceHistoryCursorHeap []*pieceHistoryCursor
+
+func (h pieceHistoryCursorHeap) Len() int { return len(h) }
+func (h pieceHistoryCursorHeap) Less(i, j int) bool {
+	if h[i].pieceID() != h[j].pieceID() {
+		return h[i].pieceID() < h[j].pieceID()
+	}
+	return h[i].order < h[j].order
+}
+func (h pieceHistoryCursorHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *pieceHistoryCursorHeap) Push(x any)   { *h = append(*h, x.(*pieceHistoryCursor)) }
+func (h *pieceHistoryCursorHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// Write one index covering a group from planPieceHistoryMerges. Merged
+// indexes can be big, so we stream the inputs rather than loading them.
+// The inputs are left alone; the caller deletes them once we've succeeded.
+func mergePieceHistoryIndexes(store StateStore, group []pieceHistoryIndex) error {
+	cursors := make(pieceHistoryCursorHeap, 0, len(group))
+	var total uint32
+	for i, index := range group {
+		rc, _, err := store.Get(index.Name)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		r := bufio.NewReaderSize(rc, 256*1024)
+		var header pieceHistoryHeader
+		if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+			return fmt.Errorf("reading %s: %w", index.Name, err)
+		}
+		if header.Magic != pieceHistoryMagic {
+			return fmt.Errorf("%s is not a piece history index", index.Name)
+		}
+		if header.FormatVersion != PIECE_HISTORY_FORMAT_VERSION {
+			return fmt.Errorf("unsupported piece history version %d in %s", header.FormatVersion, index.Name)
+		}
+		total += header.EntryCount
+		c := &pieceHistoryCursor{r: r, remaining: header.EntryCount, order: i, entry: make([]byte, pieceHistoryEntrySize)}
+		if c.remaining > 0 {
+			if err := c.next(); err != nil {
+				return fmt.Errorf("reading %s: %w", index.Name, err)
+			}
+			cursors = append(cursors, c)
+		}
+	}
+	heap.Init(&cursors)
+
+	first, last := group[0].FirstSeq, group[len(group)-1].LastSeq
+	return store.Put(pieceHistoryFilename(first, last), func(writer io.Writer) error {
+		buf := bufio.NewWriterSize(writer, 1024*1024)
+		header := pieceHistoryHeader{
+			Magic:         pieceHistoryMagic,
+			FormatVersion: PIECE_HISTORY_FORMAT_VERSION,
+			EntryCount:    total,
+		}
+		if err := binary.Write(buf, binary.LittleEndian, &header); err != nil {
+			return err
+		}
+		for cursors.Len() > 0 {
+			c := cursors[0]
+			if _, err := buf.Write(c.entry); err != nil {
+				return err
+			}
+			if c.remaining == 0 {
+				heap.Pop(&cursors)
+				continue
+			}
+			if err := c.next(); err != nil {
+				return fmt.Errorf("reading %s: %w", group[c.order].Name, err)
+			}
+			heap.Fix(&cursors, 0)
+		}
+		return buf.Flush()
+	})
+}
+
+// Everything that we know about a piece. This only covers sealed move logs,
+// so the last few seconds of a running server are always missing; anything
+// else that's missing is in Unindexed.
+type PieceHistory struct {
+	// Oldest first
+	Entries []PieceHistoryEntry
+	// Seqnums of sealed move logs that have no index, so whatever the piece
+	// did then isn't in Entries. IndexPieceHistory can fill in some of them.
+	Unindexed []SeqnumRange
+}
+
+func ReadPieceHistory(stateDir string, pieceID uint32) (*PieceHistory, error) {
+	store, err := NewStateStore(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	return readPieceHistory(store, pieceID)
+}
+
+func (btd *BoardToDiskHandler) PieceHistory(pieceID uint32) (*PieceHistory, error) {
+	return readPieceHistory(btd.store, pieceID)
+}
+
+func readPieceHistory(store StateStore, pieceID uint32) (*PieceHistory, error) {
+	indexes, err := sortedPieceHistoryIndexes(store)
+	if err != nil {
+		return nil, err
+	}
+	covering, _ := splitPieceHistoryIndexes(indexes)
+	history := &PieceHistory{Entries: make([]PieceHistoryEntry, 0)}
+	for _, index := range covering {
+		entries, err := readPieceHistoryIndex(store, index.Name, pieceID)
+		if err != nil {
+			return nil, err
+		}
+		history.Entries = append(history.Entries, entries...)
+	}
+	history.Unindexed, err = unindexedSeqnums(store, covering)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func PrintPieceHistory(stateDir string, pieceID uint32) error {
+	history, err := ReadPieceHistory(stateDir, pieceID)
+	if err != nil {
+		return err
+	}
+	for _, entry := range history.Entries {
+		fmt.Println(entry.ToString())
+	}
+	for _, r := range history.Unindexed {
+		log.Printf("WARNING: no index for seqnums %d-%d, so anything the piece did then is missing (see -index-piece-history)",
+			r.FirstSeq, r.LastSeq)
+	}
+	return nil
+}
+
+// Build indexes for every move log that doesn't have one. We need the board
+// as it was before each log, so we load our oldest snapshot and replay
+// forward; logs from before that snapshot can't be indexed.
+func IndexPieceHistory(stateDir string) error {
+	store, err := NewStateStore(stateDir)
+	if err != nil {
+		return err
+	}
+	btd := newBoardToDiskHandler(store)
+	btd.readOnly = true
+	files, err := btd.SortedSnapshotFilenames()
+	if err != nil {
+		return err
+	}
+	loaded := false
+	for _, f := range files {
+		log.Printf("Initializing from %s", f)
+		if err := btd.initializeFromStore(f); err != nil {
+			log.Printf("ERROR loading snapshot %s: %v", f, err)
+			continue
+		}
+		loaded = true
+		break
+	}
+	if !loaded {
+		return errors.New("no loadable snapshots")
+	}
+	logs, err := btd.SortedMoveLogs()
+	if err != nil {
+		return err
+	}
+	indexes, err := sortedPieceHistoryIndexes(store)
+	if err != nil {
+		return err
+	}
+	covering, _ := splitPieceHistoryIndexes(indexes)
+	written := 0
+	for _, ml := range logs {
+		expected := btd.board.seqNum + 1
+		if ml.LastSeq < expected {
+			continue
+		}
+		if ml.FirstSeq > expected {
+			return fmt.Errorf("gap in move logs: expected seqnum %d but %s starts at %d",
+				expected, ml.Name, ml.FirstSeq)
+		}
+		// We can only index logs that we replay from the start, and open
+		// logs are still being written.
+		shouldIndex := ml.FirstSeq == expected && !ml.Open &&
+			!pieceHistoryCovers(covering, ml.FirstSeq, ml.LastSeq)
+		if shouldIndex {
+			btd.history = newPieceHistoryBuilder()
+		}
+		_, _, err := btd.replayMoveLog(ml, expected, nil)
+		if err != nil {
+			return err
+		}
+		if shouldIndex {
+			if err := btd.history.writeIndex(store, ml.FirstSeq, ml.LastSeq); err != nil {
+				return err
+			}
+			log.Printf("Indexed %s", ml.Name)
+			written++
+		}
+		btd.history = nil
+	}
+	log.Printf("Wrote %d piece history indexes", written)
+	return nil
+}
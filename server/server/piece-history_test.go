@@ -0,0 +1,181 @@
+package server
+
+import (
+	"io"
+	"slices"
+	"testing"
+)
+
+// Indexes of 10 seqnums each, starting at seqnum 1, with an entry for each
+// of pieces 1-3 at every seqnum
+func writeTestPieceHistoryIndexes(t *testing.T, store StateStore, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		first, last := uint64(i*10+1), uint64(i*10+10)
+		h := newPieceHistoryBuilder()
+		for seq := first; seq <= last; seq++ {
+			for pieceID := uint32(1); pieceID <= 3; pieceID++ {
+				h.entries = append(h.entries, PieceHistoryEntry{
+					PieceID: pieceID,
+					Kind:    PieceHistoryMoved,
+					ToX:     uint16(seq),
+					Seqnum:  seq,
+				})
+			}
+		}
+		if err := h.writeIndex(store, first, last); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func pieceHistorySeqnums(history *PieceHistory) []uint64 {
+	seqs := make([]uint64, 0, len(history.Entries))
+	for _, e := range history.Entries {
+		seqs = append(seqs, e.Seqnum)
+	}
+	return seqs
+}
+
+func TestPlanPieceHistoryMerges(t *testing.T) {
+	index := func(first, last uint64) pieceHistoryIndex {
+		return pieceHistoryIndex{Name: pieceHistoryFilename(first, last), FirstSeq: first, LastSeq: last}
+	}
+	var small []pieceHistoryIndex
+	for i := uint64(0); i < 20; i++ {
+		small = append(small, index(i*10+1, i*10+10))
+	}
+	withGap := slices.Clone(small[:PIECE_HISTORY_MERGE_FANIN])
+	withGap[5] = index(51, 59)
+
+	tests := []struct {
+		name     string
+		covering []pieceHistoryIndex
+		want     [][2]uint64
+	}{
+		{"too few", small[:PIECE_HISTORY_MERGE_FANIN-1], nil},
+		{"one group and some left over", small, [][2]uint64{{1, 160}}},
+		{"gap breaks the run", withGap, nil},
+		{
+			"small indexes don't wait for a big one",
+			append([]pieceHistoryIndex{index(1, 1000)}, func() []pieceHistoryIndex {
+				var rest []pieceHistoryIndex
+				for i := uint64(0); i < PIECE_HISTORY_MERGE_FANIN; i++ {
+					rest = append(rest, index(1001+i*10, 1010+i*10))
+				}
+				return rest
+			}()...),
+			[][2]uint64{{1001, 1160}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][2]uint64
+			for _, group := range planPieceHistoryMerges(tt.covering) {
+				got = append(got, [2]uint64{group[0].FirstSeq, group[len(group)-1].LastSeq})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got groups %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePieceHistoryIndexes(t *testing.T) {
+	store := NewMemoryStateStore()
+	writeTestPieceHistoryIndexes(t, store, PIECE_HISTORY_MERGE_FANIN+2)
+	before, err := readPieceHistory(store, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexes, err := sortedPieceHistoryIndexes(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	covering, leftover := splitPieceHistoryIndexes(indexes)
+	if len(leftover) != 0 {
+		t.Fatalf("unexpected leftover indexes %v", leftover)
+	}
+	groups := planPieceHistoryMerges(covering)
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups, want 1", len(groups))
+	}
+	if err := mergePieceHistoryIndexes(store, groups[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Before we delete the inputs, they're leftovers and we don't read them
+	indexes, err = sortedPieceHistoryIndexes(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	covering, leftover = splitPieceHistoryIndexes(indexes)
+	if len(covering) != 3 || len(leftover) != PIECE_HISTORY_MERGE_FANIN {
+		t.Fatalf("got %d covering and %d leftover indexes, want 3 and %d",
+			len(covering), len(leftover), PIECE_HISTORY_MERGE_FANIN)
+	}
+	if covering[0].FirstSeq != 1 || covering[0].LastSeq != 160 {
+		t.Errorf("merged index covers %d-%d, want 1-160", covering[0].FirstSeq, covering[0].LastSeq)
+	}
+	for _, pieceID := range []uint32{1, 2, 3, 4} {
+		history, err := readPieceHistory(store, pieceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 0
+		if pieceID <= 3 {
+			want = 180
+		}
+		if len(history.Entries) != want {
+			t.Errorf("piece %d has %d entries, want %d", pieceID, len(history.Entries), want)
+		}
+	}
+
+	for _, index := range leftover {
+		if err := store.Delete(index.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	after, err := readPieceHistory(store, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(after.Entries, before.Entries) {
+		t.Errorf("history changed after merging:\ngot  %v\nwant %v", pieceHistorySeqnums(after), pieceHistorySeqnums(before))
+	}
+	for _, e := range after.Entries {
+		if e.PieceID != 2 {
+			t.Fatalf("got an entry for piece %d", e.PieceID)
+		}
+	}
+}
+
+func TestPieceHistoryUnindexed(t *testing.T) {
+	store := NewMemoryStateStore()
+	writeTestPieceHistoryIndexes(t, store, 2)
+	moveLog := func(first, last uint64) {
+		t.Helper()
+		err := store.Put(moveLogFilename(0, first, last), func(io.Writer) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	moveLog(1, 10)
+	moveLog(11, 20)
+	moveLog(21, 30)
+	moveLog(31, 40)
+	moveLog(51, 60)
+
+	history, err := readPieceHistory(store, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SeqnumRange{{21, 40}, {51, 60}}
+	if !slices.Equal(history.Unindexed, want) {
+		t.Errorf("got unindexed %v, want %v", history.Unindexed, want)
+	}
+	if len(history.Entries) != 20 {
+		t.Errorf("got %d entries, want 20", len(history.Entries))
+	}
+}
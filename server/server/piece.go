@@ -31,8 +31,33 @@ const (
 	Queen        = protocol.PieceType_PIECE_TYPE_QUEEN
 	King         = protocol.PieceType_PIECE_TYPE_KING
 	PromotedPawn = protocol.PieceType_PIECE_TYPE_PROMOTED_PAWN
+	// Underpromotions; PromotedPawn is the queen
+	PromotedKnight = protocol.PieceType_PIECE_TYPE_PROMOTED_KNIGHT
+	PromotedBishop = protocol.PieceType_PIECE_TYPE_PROMOTED_BISHOP
+	PromotedRook   = protocol.PieceType_PIECE_TYPE_PROMOTED_ROOK
+	// Fairy pieces
+	Archbishop = protocol.PieceType_PIECE_TYPE_ARCHBISHOP
+	Chancellor = protocol.PieceType_PIECE_TYPE_CHANCELLOR
+	Amazon     = protocol.PieceType_PIECE_TYPE_AMAZON
 )
 
+// What a pawn turns into when a move asks to promote it to requested.
+// Pawn means they didn't ask, which gets them a queen.
+func promotedTypeFor(requested protocol.PieceType) (protocol.PieceType, bool) {
+	switch requested {
+	case Pawn, Queen:
+		return PromotedPawn, true
+	case Knight:
+		return PromotedKnight, true
+	case Bishop:
+		return PromotedBishop, true
+	case Rook:
+		return PromotedRook, true
+	default:
+		return Pawn, false
+	}
+}
+
 const (
 	MaxMoveCount    = 4000 // 2**12 = 4096
 	MaxCaptureCount = 4000 // 2**12 = 4096
@@ -56,11 +81,15 @@ type Piece struct {
 
 type EncodedPiece uint64
 
+// Recorded in snapshots. Bump this if you change the layout below so that we
+// don't misread old snapshots.
+const PIECE_ENCODING_VERSION = 1
+
 const EmptyEncodedPiece = EncodedPiece(0)
 
 const (
 	PieceIdShift                          = 0  // 2**25 = 33,554,432 > 32,000,001
-	PieceTypeShift                        = 25 // 4 bits (7 piece types)
+	PieceTypeShift                        = 25 // 4 bits (13 piece types)
 	IsWhiteShift                          = 29 // only 1 bit ever
 	JustDoubleMovedShift                  = 30 // 1 bit (only needed for pawns)
 	KingKillerShift                       = 31 // 1 bit
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+)
+
+// Player identity. Every client gets a signed player token with its
+// initial state, which it keeps (in localStorage) and passes back when it
+// connects. The token names the player and the color that they're bound
+// to until some time; while that binding holds we ignore DetermineColor
+// (and colorPref) so that refreshing doesn't flip you to the other side.
+//
+// Tokens are just an HMAC over the payload, so the only thing to store is
+// the secret. Without -player-token-secret-file we generate one the first
+// time we start and keep it in our state store next to the snapshots, so
+// tokens (and the player stats keyed by them) survive restarts. A replica
+// has its own store, so to accept tokens on a promoted replica every server
+// needs the same -player-token-secret-file.
+
+var playerTokenSecretFile = flag.String("player-token-secret-file", "", "File containing the secret used to sign player tokens (if empty, we generate one and keep it in the state store)")
+var colorBindingPeriod = flag.Duration("color-binding-period", 24*time.Hour, "How long a player stays on the color that they were given")
+
+const (
+	PLAYER_TOKEN_VERSION     = 1
+	PLAYER_TOKEN_PAYLOAD_LEN = 1 + 8 + 1 + 8
+	MIN_PLAYER_SECRET_LEN    = 16
+	PLAYER_TOKEN_SECRET_NAME = "player-token-secret"
+)
+
+var errBadPlayerToken = errors.New("bad player token")
+
+type PlayerID uint64
+
+type PlayerIdentity struct {
+	ID              PlayerID
+	PlayingWhite    bool
+	ColorBoundUntil time.Time
+}
+
+func (p PlayerIdentity) colorIsBound(now time.Time) bool {
+	return now.Before(p.ColorBoundUntil)
+}
+
+type PlayerTokenIssuer struct {
+	secret []byte
+}
+
+func NewPlayerTokenIssuerFromFlags(store StateStore) (*PlayerTokenIssuer, error) {
+	if *playerTokenSecretFile == "" {
+		secret, err := loadOrCreatePlayerTokenSecret(store)
+		if err != nil {
+			return nil, fmt.Errorf("player token secret in %s: %w", store.Describe(), err)
+		}
+		return NewPlayerTokenIssuer(secret)
+	}
+	secret, err := os.ReadFile(*playerTokenSecretFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlayerTokenIssuer(bytes.TrimSpace(secret))
+}
+
+func loadOrCreatePlayerTokenSecret(store StateStore) ([]byte, error) {
+	r, _, err := store.Get(PLAYER_TOKEN_SECRET_NAME)
+	if err == nil {
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	log.Printf("No player token secret in %s; generating one", store.Describe())
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	err = store.Put(PLAYER_TOKEN_SECRET_NAME, func(w io.Writer) error {
+		_, err := w.Write(secret)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func NewPlayerTokenIssuer(secret []byte) (*PlayerTokenIssuer, error) {
+	if len(secret) < MIN_PLAYER_SECRET_LEN {
+		return nil, fmt.Errorf("player token secret must be at least %d bytes", MIN_PLAYER_SECRET_LEN)
+	}
+	return &PlayerTokenIssuer{secret: secret}, nil
+}
+
+func (pi *PlayerTokenIssuer) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, pi.secret)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// A brand new player, bound to playingWhite from now
+func (pi *PlayerTokenIssuer) NewPlayer(playingWhite bool) PlayerIdentity {
+	var id [8]byte
+	rand.Read(id[:])
+	return PlayerIdentity{
+		ID:              PlayerID(binary.BigEndian.Uint64(id[:])),
+		PlayingWhite:    playingWhite,
+		ColorBoundUntil: time.Now().Add(*colorBindingPeriod).Truncate(time.Second),
+	}
+}
+
+// The same player on a (possibly) different color, once their old binding
+// has run out
+func (pi *PlayerTokenIssuer) Rebind(player PlayerIdentity, playingWhite bool) PlayerIdentity {
+	player.PlayingWhite = playingWhite
+	player.ColorBoundUntil = time.Now().Add(*colorBindingPeriod).Truncate(time.Second)
+	return player
+}
+
+func (pi *PlayerTokenIssuer) Issue(player PlayerIdentity) string {
+	b := make([]byte, 0, PLAYER_TOKEN_PAYLOAD_LEN+sha256.Size)
+	b = append(b, PLAYER_TOKEN_VERSION)
+	b = binary.BigEndian.AppendUint64(b, uint64(player.ID))
+	if player.PlayingWhite {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = binary.BigEndian.AppendUint64(b, uint64(player.ColorBoundUntil.Unix()))
+	b = append(b, pi.mac(b)...)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Tokens whose color binding has expired are still valid; the player keeps
+// their ID but should be rebound.
+func (pi *PlayerTokenIssuer) Verify(token string) (PlayerIdentity, error) {
+	var player PlayerIdentity
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return player, errBadPlayerToken
+	}
+	if len(b) != PLAYER_TOKEN_PAYLOAD_LEN+sha256.Size || b[0] != PLAYER_TOKEN_VERSION {
+		return player, errBadPlayerToken
+	}
+	payload, mac := b[:PLAYER_TOKEN_PAYLOAD_LEN], b[PLAYER_TOKEN_PAYLOAD_LEN:]
+	if !hmac.Equal(mac, pi.mac(payload)) {
+		return player, errBadPlayerToken
+	}
+	player.ID = PlayerID(binary.BigEndian.Uint64(payload[1:9]))
+	player.PlayingWhite = payload[9] == 1
+	player.ColorBoundUntil = time.Unix(int64(binary.BigEndian.Uint64(payload[10:18])), 0)
+	return player, nil
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testPlayerTokenIssuer(t *testing.T, secret string) *PlayerTokenIssuer {
+	t.Helper()
+	pi, err := NewPlayerTokenIssuer([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pi
+}
+
+// The token with change applied to its decoded bytes
+func rewriteToken(t *testing.T, token string, change func(b []byte) []byte) string {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(change(b))
+}
+
+func TestPlayerToken(t *testing.T) {
+	pi := testPlayerTokenIssuer(t, "0123456789abcdef")
+	now := time.Now()
+	player := PlayerIdentity{
+		ID:              0xDEADBEEF12345678,
+		PlayingWhite:    true,
+		ColorBoundUntil: now.Add(time.Hour).Truncate(time.Second),
+	}
+	token := pi.Issue(player)
+
+	got, err := pi.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != player.ID || got.PlayingWhite != player.PlayingWhite || !got.ColorBoundUntil.Equal(player.ColorBoundUntil) {
+		t.Errorf("got %+v, want %+v", got, player)
+	}
+	if !got.colorIsBound(now) {
+		t.Errorf("color isn't bound an hour before the binding runs out")
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"tampered mac", rewriteToken(t, token, func(b []byte) []byte {
+			b[len(b)-1] ^= 1
+			return b
+		})},
+		{"tampered color", rewriteToken(t, token, func(b []byte) []byte {
+			b[9] = 0
+			return b
+		})},
+		{"wrong version", rewriteToken(t, token, func(b []byte) []byte {
+			payload := bytes.Clone(b[:PLAYER_TOKEN_PAYLOAD_LEN])
+			payload[0] = PLAYER_TOKEN_VERSION + 1
+			return append(payload, pi.mac(payload)...)
+		})},
+		{"truncated", token[:len(token)-4]},
+		{"not base64", "!" + token[1:]},
+		{"empty", ""},
+		{"different secret", testPlayerTokenIssuer(t, "fedcba9876543210").Issue(player)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := pi.Verify(tt.token); !errors.Is(err, errBadPlayerToken) {
+				t.Errorf("got %v, want errBadPlayerToken", err)
+			}
+		})
+	}
+}
+
+// An expired binding is still a valid token, for the same player
+func TestExpiredPlayerToken(t *testing.T) {
+	pi := testPlayerTokenIssuer(t, "0123456789abcdef")
+	now := time.Now()
+	player := pi.NewPlayer(false)
+	player.ColorBoundUntil = now.Add(-time.Minute).Truncate(time.Second)
+
+	got, err := pi.Verify(pi.Issue(player))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != player.ID || got.colorIsBound(now) {
+		t.Errorf("got %+v, want player %d with an expired binding", got, player.ID)
+	}
+
+	rebound, err := pi.Verify(pi.Issue(pi.Rebind(got, true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebound.ID != player.ID || !rebound.PlayingWhite || !rebound.colorIsBound(now) {
+		t.Errorf("rebound to %+v, want player %d bound to white", rebound, player.ID)
+	}
+}
+
+func TestPlayerTokenSecret(t *testing.T) {
+	if _, err := NewPlayerTokenIssuer([]byte("too short")); err == nil {
+		t.Errorf("accepted a secret shorter than %d bytes", MIN_PLAYER_SECRET_LEN)
+	}
+
+	// Generated once, then the same across restarts
+	store := NewMemoryStateStore()
+	first, err := loadOrCreatePlayerTokenSecret(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := loadOrCreatePlayerTokenSecret(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) < MIN_PLAYER_SECRET_LEN || !bytes.Equal(first, second) {
+		t.Errorf("got secrets %x and %x, want the same one twice", first, second)
+	}
+}
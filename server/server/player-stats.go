@@ -0,0 +1,200 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+
+	"one-million-chessboards/protocol"
+)
+
+// Per-player stats. Moves carry the ID of the player that made them (see
+// player-identity.go), and both the live board and our BoardToDiskHandler
+// keep a tracker that they update as they apply moves. The BTD's copy is
+// what we persist: full snapshots have a section with every player, and
+// deltas have one with every player whose stats changed since the full
+// snapshot. Replaying move logs on startup rebuilds the rest.
+
+// Room for piece types that we haven't added yet
+const PLAYER_STATS_PIECE_TYPES = 16
+
+const snapshotSectionPlayerStats snapshotSectionKind = 5
+
+type PlayerStats struct {
+	Moves      uint32
+	Promotions uint32
+	// Indexed by the type of the piece that was captured
+	Captures [PLAYER_STATS_PIECE_TYPES]uint32
+}
+
+type playerStatsRecord struct {
+	Player PlayerID
+	Stats  PlayerStats
+}
+
+type PlayerStatsTracker struct {
+	sync.RWMutex
+	stats map[PlayerID]*PlayerStats
+}
+
+func NewPlayerStatsTracker() *PlayerStatsTracker {
+	return &PlayerStatsTracker{stats: make(map[PlayerID]*PlayerStats)}
+}
+
+// Returns the player's updated stats, or false if the move isn't
+// attributed to anyone
+func (t *PlayerStatsTracker) RecordMove(player PlayerID, res *MoveResult) (PlayerStats, bool) {
+	if player == 0 || !res.Valid {
+		return PlayerStats{}, false
+	}
+	t.Lock()
+	defer t.Unlock()
+	stats, ok := t.stats[player]
+	if !ok {
+		stats = &PlayerStats{}
+		t.stats[player] = stats
+	}
+	stats.Moves++
+	if res.Promoted {
+		stats.Promotions++
+	}
+	if captured := res.CapturedPiece.Piece; !captured.IsEmpty() && int(captured.Type) < PLAYER_STATS_PIECE_TYPES {
+		stats.Captures[captured.Type]++
+	}
+	return *stats, true
+}
+
+func (t *PlayerStatsTracker) Get(player PlayerID) PlayerStats {
+	t.RLock()
+	defer t.RUnlock()
+	if stats, ok := t.stats[player]; ok {
+		return *stats
+	}
+	return PlayerStats{}
+}
+
+func (t *PlayerStatsTracker) Count() int {
+	t.RLock()
+	defer t.RUnlock()
+	return len(t.stats)
+}
+
+// Sorted by player. A nil players means everyone.
+func (t *PlayerStatsTracker) records(players map[PlayerID]struct{}) []playerStatsRecord {
+	t.RLock()
+	defer t.RUnlock()
+	var ids []PlayerID
+	if players == nil {
+		ids = slices.Collect(maps.Keys(t.stats))
+	} else {
+		ids = slices.Collect(maps.Keys(players))
+	}
+	slices.Sort(ids)
+	records := make([]playerStatsRecord, 0, len(ids))
+	for _, id := range ids {
+		if stats, ok := t.stats[id]; ok {
+			records = append(records, playerStatsRecord{Player: id, Stats: *stats})
+		}
+	}
+	return records
+}
+
+// Overwrites the stats of every player in records
+func (t *PlayerStatsTracker) load(records []playerStatsRecord) {
+	t.Lock()
+	defer t.Unlock()
+	for _, record := range records {
+		stats := record.Stats
+		t.stats[record.Player] = &stats
+	}
+}
+
+func (t *PlayerStatsTracker) clone() *PlayerStatsTracker {
+	ret := NewPlayerStatsTracker()
+	t.RLock()
+	defer t.RUnlock()
+	for id, stats := range t.stats {
+		copied := *stats
+		ret.stats[id] = &copied
+	}
+	return ret
+}
+
+func readPlayerStatsSection(r *snapshotContainerReader, sh snapshotSectionInfo) ([]playerStatsRecord, error) {
+	recordSize := uint64(binary.Size(playerStatsRecord{}))
+	if sh.Length%recordSize != 0 {
+		return nil, fmt.Errorf("%w: player stats section is %d bytes, not a multiple of %d",
+			ErrCorruptSnapshot, sh.Length, recordSize)
+	}
+	records := make([]playerStatsRecord, sh.Length/recordSize)
+	if err := r.readSectionData(sh, records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (s PlayerStats) TotalCaptures() uint32 {
+	total := uint32(0)
+	for _, count := range s.Captures {
+		total += count
+	}
+	return total
+}
+
+func (s PlayerStats) ToProtocol() *protocol.ServerPlayerStats {
+	ret := &protocol.ServerPlayerStats{
+		Moves:         s.Moves,
+		Captures:      s.TotalCaptures(),
+		KingsCaptured: s.Captures[King],
+		Promotions:    s.Promotions,
+	}
+	for pieceType, count := range s.Captures {
+		if count > 0 {
+			ret.CapturesByType = append(ret.CapturesByType, &protocol.PieceTypeCount{
+				Type:  protocol.PieceType(pieceType),
+				Count: count,
+			})
+		}
+	}
+	return ret
+}
+
+type PlayerStatsResponse struct {
+	Moves          uint32            `json:"moves"`
+	Captures       uint32            `json:"captures"`
+	CapturesByType map[string]uint32 `json:"capturesByType"`
+	KingsCaptured  uint32            `json:"kingsCaptured"`
+	Promotions     uint32            `json:"promotions"`
+}
+
+// GET /api/player-stats?player=<player token>
+func (s *Server) ServePlayerStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	player, err := s.playerTokens.Verify(r.URL.Query().Get("player"))
+	if err != nil {
+		http.Error(w, "Invalid player token", http.StatusBadRequest)
+		return
+	}
+	stats := s.playerStats.Get(player.ID)
+	resp := PlayerStatsResponse{
+		Moves:          stats.Moves,
+		Captures:       stats.TotalCaptures(),
+		CapturesByType: make(map[string]uint32),
+		KingsCaptured:  stats.Captures[King],
+		Promotions:     stats.Promotions,
+	}
+	for pieceType, count := range stats.Captures {
+		if count > 0 {
+			resp.CapturesByType[protocol.PieceType(pieceType).String()] = count
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, max-age=2")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Where pawns promote. The original rule only promotes on the edges of the
+// whole world, which almost nobody will ever reach; the alternative treats
+// every 8x8 board's back ranks as promotion ranks.
+//
+// The rule is part of the board's state, since the same move can mean
+// different things under different rules: we record it in every snapshot
+// header, and changing -promotion-rule takes effect at startup as a
+// ruleChangeRequest, which goes into the move log and out to replicas.
+
+var promotionRuleFlag = flag.String("promotion-rule", "", "Where pawns promote: world-edge or board-edge (empty keeps the rule from our latest snapshot; new boards use world-edge)")
+
+type PromotionRuleID uint8
+
+const (
+	// Zero so that snapshots from before we recorded a rule get this one
+	PromotionRuleWorldEdge PromotionRuleID = iota
+	PromotionRuleBoardEdge
+)
+
+type PromotionRule interface {
+	ID() PromotionRuleID
+	Name() string
+	// Whether a pawn of this color promotes when it lands on row y
+	PromotesAt(isWhite bool, y uint16) bool
+}
+
+type worldEdgePromotion struct{}
+
+func (worldEdgePromotion) ID() PromotionRuleID { return PromotionRuleWorldEdge }
+func (worldEdgePromotion) Name() string        { return "world-edge" }
+
+// white pawns move up, black pawns move down
+func (worldEdgePromotion) PromotesAt(isWhite bool, y uint16) bool {
+	if isWhite {
+		return y == 0
+	}
+	return y == BOARD_SIZE-1
+}
+
+type boardEdgePromotion struct{}
+
+func (boardEdgePromotion) ID() PromotionRuleID { return PromotionRuleBoardEdge }
+func (boardEdgePromotion) Name() string        { return "board-edge" }
+
+func (boardEdgePromotion) PromotesAt(isWhite bool, y uint16) bool {
+	if isWhite {
+		return y%SINGLE_BOARD_SIZE == 0
+	}
+	return y%SINGLE_BOARD_SIZE == SINGLE_BOARD_SIZE-1
+}
+
+// Indexed by ID
+var promotionRules = []PromotionRule{
+	worldEdgePromotion{},
+	boardEdgePromotion{},
+}
+
+func promotionRuleByID(id PromotionRuleID) (PromotionRule, error) {
+	if int(id) >= len(promotionRules) {
+		return nil, fmt.Errorf("unknown promotion rule %d", id)
+	}
+	return promotionRules[id], nil
+}
+
+func promotionRuleByName(name string) (PromotionRule, error) {
+	names := make([]string, 0, len(promotionRules))
+	for _, rule := range promotionRules {
+		if rule.Name() == name {
+			return rule, nil
+		}
+		names = append(names, rule.Name())
+	}
+	return nil, fmt.Errorf("unknown promotion rule %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// See applyRuleFlags
+func applyPromotionRuleFlag(req *ruleChangeRequest) error {
+	if *promotionRuleFlag == "" {
+		return nil
+	}
+	rule, err := promotionRuleByName(*promotionRuleFlag)
+	if err != nil {
+		return err
+	}
+	req.PromotionRule = rule.ID()
+	return nil
+}
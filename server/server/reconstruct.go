@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// Rebuilding the board as it was at some point in the past, for moderation
+// and bug reports. We load the newest snapshot from before the target and
+// replay the move logs up to it. None of this touches the state directory.
+
+// Set exactly one of Seqnum and TimestampNs. A timestamp target includes every
+// request applied at or before it; requests from before we recorded
+// timestamps are always included, since we can't tell when they happened.
+type ReconstructionTarget struct {
+	Seqnum      uint64
+	TimestampNs int64
+}
+
+func (t ReconstructionTarget) snapshotIsEligible(p restorePoint) bool {
+	// A snapshot's filename timestamp is when we started saving it, which is
+	// still after it was taken, so this never picks one that's too new.
+	if t.Seqnum != 0 {
+		return p.SeqNum <= t.Seqnum
+	}
+	return p.Timestamp <= t.TimestampNs
+}
+
+func ReconstructBoard(stateDir string, target ReconstructionTarget) (*BoardToDiskHandler, error) {
+	store, err := NewStateStore(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	return reconstructBoard(store, target)
+}
+
+func reconstructBoard(store StateStore, target ReconstructionTarget) (*BoardToDiskHandler, error) {
+	if (target.Seqnum == 0) == (target.TimestampNs == 0) {
+		return nil, fmt.Errorf("specify exactly one of a seqnum and a timestamp")
+	}
+	btd := newBoardToDiskHandler(store)
+	btd.readOnly = true
+	points, err := btd.sortedRestorePoints()
+	if err != nil {
+		return nil, err
+	}
+	eligible := make([]restorePoint, 0, len(points))
+	for _, p := range points {
+		if target.snapshotIsEligible(p) {
+			eligible = append(eligible, p)
+		}
+	}
+	if len(eligible) == 0 {
+		return nil, fmt.Errorf("no snapshots in %s from before the target", store.Describe())
+	}
+	err = btd.initializeFromNewestValidSnapshot(eligible)
+	if err != nil {
+		return nil, err
+	}
+	if target.Seqnum != 0 && btd.board.seqNum > target.Seqnum {
+		return nil, fmt.Errorf("oldest loadable snapshot is at seqnum %d, after the target", btd.board.seqNum)
+	}
+	err = btd.replayMoveLogsUntil(func(req boardToDiskRequest) bool {
+		if target.Seqnum != 0 {
+			return btd.board.seqNum >= target.Seqnum
+		}
+		return req.TimestampNs > target.TimestampNs
+	})
+	if err != nil {
+		return nil, err
+	}
+	if target.Seqnum != 0 && btd.board.seqNum != target.Seqnum {
+		return nil, fmt.Errorf("move logs only reach seqnum %d", btd.board.seqNum)
+	}
+	log.Printf("Reconstructed board at seqnum %d", btd.board.seqNum)
+	return btd, nil
+}
+
+func (btd *BoardToDiskHandler) SeqNum() uint64 {
+	return btd.board.seqNum
+}
+
+// Always written to local disk, wherever we keep our state
+func (btd *BoardToDiskHandler) SaveSnapshotAs(path string) error {
+	return WriteFileAtomic(path, btd.getSnapshot().writeBuffered)
+}
+
+func fenLetter(piece Piece) byte {
+	var letter byte
+	switch piece.Type {
+	case Pawn:
+		letter = 'p'
+	case Knight, PromotedKnight:
+		letter = 'n'
+	case Bishop, PromotedBishop:
+		letter = 'b'
+	case Rook, PromotedRook:
+		letter = 'r'
+	case Queen, PromotedPawn:
+		letter = 'q'
+	case King:
+		letter = 'k'
+	case Archbishop:
+		letter = 'a'
+	case Chancellor:
+		letter = 'c'
+	case Amazon:
+		letter = 'z'
+	default:
+		letter = '?'
+	}
+	if piece.IsWhite {
+		letter -= 'a' - 'A'
+	}
+	return letter
+}
+
+// The piece placement field of a FEN for a single 8x8 board. Black starts
+// at the top of each board (low y), so that's rank 8.
+func (btd *BoardToDiskHandler) FENPlacement(boardX, boardY uint16) string {
+	var sb strings.Builder
+	for dy := uint16(0); dy < SINGLE_BOARD_SIZE; dy++ {
+		if dy > 0 {
+			sb.WriteByte('/')
+		}
+		empty := 0
+		for dx := uint16(0); dx < SINGLE_BOARD_SIZE; dx++ {
+			x := boardX*SINGLE_BOARD_SIZE + dx
+			y := boardY*SINGLE_BOARD_SIZE + dy
+			piece := PieceOfEncodedPiece(EncodedPiece(btd.board.pieces[y][x]))
+			if piece.IsEmpty() {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			sb.WriteByte(fenLetter(piece))
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+	}
+	return sb.String()
+}
+
+// Print the FEN placement of every 8x8 board in the (inclusive) rectangle
+func (btd *BoardToDiskHandler) PrintRegion(w io.Writer, minBoardX, minBoardY, maxBoardX, maxBoardY uint16) error {
+	if minBoardX > maxBoardX || minBoardY > maxBoardY || maxBoardX >= BOARDS_PER_SIDE || maxBoardY >= BOARDS_PER_SIDE {
+		return fmt.Errorf("bad region: (%d, %d) to (%d, %d)", minBoardX, minBoardY, maxBoardX, maxBoardY)
+	}
+	for boardY := minBoardY; boardY <= maxBoardY; boardY++ {
+		for boardX := minBoardX; boardX <= maxBoardX; boardX++ {
+			_, err := fmt.Fprintf(w, "board (%d, %d) seq %d: %s\n",
+				boardX, boardY, btd.board.seqNum, btd.FENPlacement(boardX, boardY))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Warm standby. A server started with -replica-of streams the primary's
+// requests (see replication.go) and feeds them through processMoves, so both
+// our live board and our BoardToDiskHandler stay in step with the primary.
+// We don't accept players until we're promoted; once we are, we stop
+// streaming and carry on from whatever seqnum we'd reached. Anything the
+// primary applied that we hadn't received yet is lost, which is usually a
+// handful of moves.
+
+var replicaOf = flag.String("replica-of", "", "Run as a warm standby for the primary at this base URL (e.g. http://10.0.0.2:8080)")
+
+const (
+	REPLICA_RECONNECT_INTERVAL = time.Second * 2
+	// If we don't hear anything (not even a heartbeat) for this long we
+	// assume the connection is dead and reconnect.
+	REPLICA_READ_TIMEOUT = time.Second * 10
+)
+
+var errReplicaDiverged = errors.New("replica diverged from primary")
+
+type Replica struct {
+	primaryURL string
+	client     *http.Client
+	requests   chan boardToDiskRequest
+	logger     zerolog.Logger
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+
+	// Only touched by the goroutine running RunForever
+	lastReceivedSeqNum uint64
+
+	appliedSeqNum atomic.Uint64
+	primarySeqNum atomic.Uint64
+	lastHeardNs   atomic.Int64
+	connected     atomic.Bool
+	promoted      atomic.Bool
+	errMutex      sync.Mutex
+	err           error
+	promoteMutex  sync.Mutex
+	diverged      atomic.Bool
+}
+
+func NewReplica(primaryURL string, seqNum uint64) *Replica {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Replica{
+		primaryURL:         strings.TrimSuffix(primaryURL, "/"),
+		client:             &http.Client{},
+		requests:           make(chan boardToDiskRequest, 16384),
+		logger:             NewCoreLogger().With().Str("kind", "replica").Logger(),
+		ctx:                ctx,
+		cancel:             cancel,
+		done:               make(chan struct{}),
+		lastReceivedSeqNum: seqNum,
+	}
+	r.appliedSeqNum.Store(seqNum)
+	r.primarySeqNum.Store(seqNum)
+	return r
+}
+
+func (r *Replica) setError(err error) {
+	r.errMutex.Lock()
+	defer r.errMutex.Unlock()
+	r.err = err
+}
+
+func (r *Replica) getError() error {
+	r.errMutex.Lock()
+	defer r.errMutex.Unlock()
+	return r.err
+}
+
+func (r *Replica) post(ctx context.Context, path string, seqnum uint64) (*http.Response, error) {
+	body, err := json.Marshal(replicationRequest{Pass: *internalPass, Seqnum: seqnum})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.primaryURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &replicationHTTPError{status: resp.StatusCode, detail: string(bytes.TrimSpace(detail))}
+	}
+	return resp, nil
+}
+
+type replicationHTTPError struct {
+	status int
+	detail string
+}
+
+func (e *replicationHTTPError) Error() string {
+	return fmt.Sprintf("primary returned %d: %s", e.status, e.detail)
+}
+
+func (r *Replica) RunForever() {
+	defer close(r.done)
+	for r.ctx.Err() == nil {
+		err := r.streamOnce()
+		r.connected.Store(false)
+		if r.ctx.Err() != nil {
+			return
+		}
+		r.setError(err)
+		r.logger.Error().Str("error_kind", "replication_stream").AnErr("err", err).Send()
+		log.Printf("ERROR replicating from %s: %v", r.primaryURL, err)
+		var httpErr *replicationHTTPError
+		if errors.As(err, &httpErr) && (httpErr.status == http.StatusGone || httpErr.status == http.StatusConflict) {
+			// Reconnecting won't help; we need to be restarted so that we
+			// can bootstrap from a fresh snapshot.
+			log.Printf("Replica can't catch up from the primary's backlog; restart to re-bootstrap")
+			return
+		}
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(REPLICA_RECONNECT_INTERVAL):
+		}
+	}
+}
+
+// The primary always sends a heartbeat at least once a second, so a read that
+// takes much longer than that means the connection is dead.
+type deadlineReader struct {
+	body  io.ReadCloser
+	timer *time.Timer
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	d.timer.Reset(REPLICA_READ_TIMEOUT)
+	return d.body.Read(p)
+}
+
+func (d *deadlineReader) Close() error {
+	d.timer.Stop()
+	return d.body.Close()
+}
+
+func (r *Replica) streamOnce() error {
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+	resp, err := r.post(ctx, "/internal/replication/stream", r.lastReceivedSeqNum+1)
+	if err != nil {
+		return err
+	}
+	body := &deadlineReader{body: resp.Body, timer: time.AfterFunc(REPLICA_READ_TIMEOUT, cancel)}
+	reader, err := newMoveLogReader(body, false)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	r.connected.Store(true)
+	r.setError(nil)
+	log.Printf("Replicating from %s starting at seqnum %d", r.primaryURL, r.lastReceivedSeqNum+1)
+	for {
+		req, err := reader.Next()
+		if err != nil {
+			if err == io.EOF {
+				return errors.New("primary closed the stream")
+			}
+			return err
+		}
+		r.lastHeardNs.Store(time.Now().UnixNano())
+		if req.isHeartbeat() {
+			r.primarySeqNum.Store(req.Seqnum)
+			continue
+		}
+		if req.Seqnum != r.lastReceivedSeqNum+1 {
+			return fmt.Errorf("%w: expected seqnum %d but got %d", errReplicaDiverged, r.lastReceivedSeqNum+1, req.Seqnum)
+		}
+		if req.Seqnum > r.primarySeqNum.Load() {
+			r.primarySeqNum.Store(req.Seqnum)
+		}
+		select {
+		case r.requests <- req:
+		case <-r.ctx.Done():
+			return nil
+		}
+		r.lastReceivedSeqNum = req.Seqnum
+	}
+}
+
+// Called from processMoves with whatever seqnum the live board ended up at
+func (r *Replica) applied(req boardToDiskRequest, seqnum uint64) {
+	if seqnum != req.Seqnum && !r.diverged.Load() {
+		err := fmt.Errorf("%w: applying %s took us to seqnum %d, primary had %d",
+			errReplicaDiverged, req.ToString(), seqnum, req.Seqnum)
+		r.diverged.Store(true)
+		r.setError(err)
+		r.logger.Error().Str("error_kind", "replica_diverged").AnErr("err", err).Send()
+		log.Printf("ERROR %v; no longer replicating", err)
+		r.cancel()
+		return
+	}
+	r.appliedSeqNum.Store(seqnum)
+}
+
+func (r *Replica) status() ReplicationStatus {
+	applied := r.appliedSeqNum.Load()
+	primary := r.primarySeqNum.Load()
+	status := ReplicationStatus{
+		Role:          "replica",
+		Seqnum:        applied,
+		PrimarySeqnum: primary,
+		Connected:     r.connected.Load(),
+	}
+	if primary > applied {
+		status.Lag = primary - applied
+	}
+	if lastHeard := r.lastHeardNs.Load(); lastHeard != 0 {
+		status.MsSinceHeardPrimary = time.Since(time.Unix(0, lastHeard)).Milliseconds()
+	}
+	if err := r.getError(); err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// Stop streaming, wait for processMoves to apply everything that we
+// received, and start taking players.
+func (r *Replica) promote() (uint64, error) {
+	r.promoteMutex.Lock()
+	defer r.promoteMutex.Unlock()
+	if r.promoted.Load() {
+		return r.appliedSeqNum.Load(), nil
+	}
+	r.cancel()
+	<-r.done
+	deadline := time.Now().Add(30 * time.Second)
+	for len(r.requests) > 0 || r.appliedSeqNum.Load() < r.lastReceivedSeqNum {
+		if r.diverged.Load() {
+			break
+		}
+		if time.Now().After(deadline) {
+			return 0, fmt.Errorf("timed out applying replicated requests (at %d of %d)",
+				r.appliedSeqNum.Load(), r.lastReceivedSeqNum)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.promoted.Store(true)
+	seqnum := r.appliedSeqNum.Load()
+	r.logger.Info().Str("action", "promoted").Uint64("seqnum", seqnum).Send()
+	log.Printf("PROMOTED TO PRIMARY at seqnum %d", seqnum)
+	return seqnum, nil
+}
+
+// Before we load our state: if what we have on disk is too old for the
+// primary's backlog (or we have nothing), fetch a snapshot from the primary
+// and save it as our newest. We only look at filenames here, since loading
+// the board twice would be slow.
+func BootstrapReplica(primaryURL, stateDir string) error {
+	store, err := NewStateStore(stateDir)
+	if err != nil {
+		return err
+	}
+	r := NewReplica(primaryURL, 0)
+	defer r.cancel()
+	resp, err := r.post(r.ctx, "/internal/replication/status", 0)
+	if err != nil {
+		return fmt.Errorf("getting primary status: %w", err)
+	}
+	var status ReplicationStatus
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	btd := newBoardToDiskHandler(store)
+	btd.readOnly = true
+	points, err := btd.sortedRestorePoints()
+	if err != nil {
+		return err
+	}
+	if len(points) > 0 {
+		localSeq := points[len(points)-1].SeqNum
+		logs, err := btd.SortedMoveLogs()
+		if err != nil {
+			return err
+		}
+		for _, ml := range logs {
+			if ml.FirstSeq <= localSeq+1 && ml.LastSeq > localSeq {
+				localSeq = ml.LastSeq
+			}
+		}
+		if localSeq+1 >= status.OldestBacklogSeqnum && localSeq <= status.Seqnum {
+			log.Printf("Replica state at seqnum %d can catch up from the primary (backlog starts at %d)",
+				localSeq, status.OldestBacklogSeqnum)
+			return nil
+		}
+		log.Printf("Replica state at seqnum %d can't catch up from the primary (backlog %d-%d)",
+			localSeq, status.OldestBacklogSeqnum, status.Seqnum)
+	}
+
+	log.Printf("Fetching a snapshot from %s", primaryURL)
+	start := time.Now()
+	resp, err = r.post(r.ctx, "/internal/replication/snapshot", 0)
+	if err != nil {
+		return fmt.Errorf("getting snapshot: %w", err)
+	}
+	defer resp.Body.Close()
+	snapshot := &Snapshot{}
+	if err := snapshot.readFrom(bufio.NewReaderSize(resp.Body, 16*1024*1024)); err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	if err := btd.saveToFile(snapshot); err != nil {
+		return err
+	}
+	// We'll write these seqnums again as we stream them, and we don't want
+	// overlapping logs.
+	logs, err := btd.SortedMoveLogs()
+	if err != nil {
+		return err
+	}
+	stale := make([]moveLogFile, 0)
+	for _, ml := range logs {
+		if ml.LastSeq > snapshot.Header.SeqNum {
+			stale = append(stale, ml)
+		}
+	}
+	quarantineMoveLogs(store, stale)
+	log.Printf("Bootstrapped replica at seqnum %d in %s", snapshot.Header.SeqNum, time.Since(start))
+	return nil
+}
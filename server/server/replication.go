@@ -0,0 +1,347 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The primary side of replication. Every request that we hand to our
+// BoardToDiskHandler (we subscribe to board events just ahead of it) is also
+// framed as a move log record and kept in a ring of recent records. Replicas
+// POST to /internal/replication/stream with the seqnum that they want next;
+// we send them everything from the ring from that point and then each new
+// record as it happens, all in the move log format (so a stream reads just
+// like a log, plus heartbeats).
+//
+// A replica that's too far behind for our ring (or that's brand new) fetches
+// a full snapshot from /internal/replication/snapshot and streams from there.
+
+var replicationBacklog = flag.Int("replication-backlog", 100000, "Keep this many recent requests in memory for replicas to catch up from (0 disables serving replicas)")
+
+const (
+	REPLICATION_HEARTBEAT_INTERVAL = time.Second * 1
+	// If a replica can't keep up with this many records we drop it; it
+	// reconnects and catches up from our backlog.
+	REPLICATION_SUBSCRIBER_BUFFER = 16384
+)
+
+var errReplicaTooFarBehind = errors.New("requested seqnum is older than our replication backlog")
+var errReplicaAhead = errors.New("requested seqnum is ahead of us")
+
+type replicationRecord struct {
+	seqnum uint64
+	framed []byte
+}
+
+type replicationSubscriber struct {
+	records chan []byte
+}
+
+type replicationHub struct {
+	sync.Mutex
+	ring        []replicationRecord
+	start       int
+	count       int
+	subscribers map[*replicationSubscriber]struct{}
+	closed      bool
+	lastSeqNum  atomic.Uint64
+	scratch     []byte
+}
+
+func newReplicationHub(backlog int, seqNum uint64) *replicationHub {
+	h := &replicationHub{
+		ring:        make([]replicationRecord, backlog),
+		subscribers: make(map[*replicationSubscriber]struct{}),
+		scratch:     make([]byte, 0, 64),
+	}
+	h.lastSeqNum.Store(seqNum)
+	return h
+}
+
+// Called from processMoves (via the board event bus), so this sees requests
+// in the order that they were applied.
+func (h *replicationHub) publish(req boardToDiskRequest) {
+	payload, err := appendRequestPayload(h.scratch[:0], req)
+	if err != nil {
+		log.Printf("ERROR encoding request for replication: %v", err)
+		return
+	}
+	h.scratch = payload
+	framed := appendMoveLogRecord(make([]byte, 0, len(payload)+8), payload)
+
+	h.Lock()
+	defer h.Unlock()
+	if len(h.ring) > 0 {
+		end := (h.start + h.count) % len(h.ring)
+		h.ring[end] = replicationRecord{seqnum: req.Seqnum, framed: framed}
+		if h.count < len(h.ring) {
+			h.count++
+		} else {
+			h.start = (h.start + 1) % len(h.ring)
+		}
+	}
+	h.lastSeqNum.Store(req.Seqnum)
+	for sub := range h.subscribers {
+		select {
+		case sub.records <- framed:
+		default:
+			log.Printf("Dropping replica that fell %d records behind", REPLICATION_SUBSCRIBER_BUFFER)
+			close(sub.records)
+			delete(h.subscribers, sub)
+		}
+	}
+}
+
+func (h *replicationHub) oldestSeqNum() uint64 {
+	if h.count == 0 {
+		return h.lastSeqNum.Load() + 1
+	}
+	return h.ring[h.start].seqnum
+}
+
+// Returns every record from seqnum onwards that we still have, plus a
+// subscription for everything after that.
+func (h *replicationHub) subscribe(seqnum uint64) (*replicationSubscriber, [][]byte, error) {
+	h.Lock()
+	defer h.Unlock()
+	if h.closed {
+		return nil, nil, errors.New("shutting down")
+	}
+	last := h.lastSeqNum.Load()
+	if seqnum > last+1 {
+		return nil, nil, fmt.Errorf("%w: we're at %d but got asked for %d", errReplicaAhead, last, seqnum)
+	}
+	if seqnum < h.oldestSeqNum() {
+		return nil, nil, fmt.Errorf("%w: oldest is %d but got asked for %d", errReplicaTooFarBehind, h.oldestSeqNum(), seqnum)
+	}
+	backlog := make([][]byte, 0, last+1-seqnum)
+	for i := 0; i < h.count; i++ {
+		record := h.ring[(h.start+i)%len(h.ring)]
+		if record.seqnum >= seqnum {
+			backlog = append(backlog, record.framed)
+		}
+	}
+	sub := &replicationSubscriber{records: make(chan []byte, REPLICATION_SUBSCRIBER_BUFFER)}
+	h.subscribers[sub] = struct{}{}
+	return sub, backlog, nil
+}
+
+func (h *replicationHub) unsubscribe(sub *replicationSubscriber) {
+	h.Lock()
+	defer h.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		close(sub.records)
+		delete(h.subscribers, sub)
+	}
+}
+
+func (h *replicationHub) subscriberCount() int {
+	h.Lock()
+	defer h.Unlock()
+	return len(h.subscribers)
+}
+
+// Ends every stream so that a graceful shutdown isn't held up by replicas
+func (h *replicationHub) close() {
+	h.Lock()
+	defer h.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		close(sub.records)
+		delete(h.subscribers, sub)
+	}
+}
+
+type replicationRequest struct {
+	Pass   string `json:"pass"`
+	Seqnum uint64 `json:"seqnum"`
+}
+
+func (s *Server) decodeReplicationRequest(w http.ResponseWriter, r *http.Request) (replicationRequest, bool) {
+	var req replicationRequest
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return req, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+	if req.Pass != *internalPass {
+		http.Error(w, "no", http.StatusNotFound)
+		return req, false
+	}
+	return req, true
+}
+
+func (s *Server) ServeReplicationStream(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeReplicationRequest(w, r)
+	if !ok {
+		return
+	}
+	hub := s.boardToDiskHandler.replication
+	if hub == nil {
+		http.Error(w, "Replication is disabled", http.StatusServiceUnavailable)
+		return
+	}
+	sub, backlog, err := hub.subscribe(req.Seqnum)
+	if errors.Is(err, errReplicaTooFarBehind) {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	} else if errors.Is(err, errReplicaAhead) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer hub.unsubscribe(sub)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	s.httpLogger.Info().
+		Str("rpc", "ServeReplicationStream").
+		Uint64("from_seqnum", req.Seqnum).
+		Int("backlog", len(backlog)).
+		Send()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	buf := bufio.NewWriterSize(w, 256*1024)
+	flush := func() error {
+		if err := buf.Flush(); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	buf.Write(appendMoveLogHeader(nil))
+	for _, record := range backlog {
+		if _, err := buf.Write(record); err != nil {
+			return
+		}
+	}
+	if err := flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(REPLICATION_HEARTBEAT_INTERVAL)
+	defer heartbeat.Stop()
+	payload := make([]byte, 0, moveLogPayloadPrefixSize)
+	framed := make([]byte, 0, moveLogPayloadPrefixSize+8)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case record, ok := <-sub.records:
+			if !ok {
+				flush()
+				return
+			}
+			if _, err := buf.Write(record); err != nil {
+				return
+			}
+			if len(sub.records) == 0 {
+				if err := flush(); err != nil {
+					return
+				}
+			}
+		case <-heartbeat.C:
+			payload = appendHeartbeatPayload(payload[:0], hub.lastSeqNum.Load(), time.Now().UnixNano())
+			framed = appendMoveLogRecord(framed[:0], payload)
+			if _, err := buf.Write(framed); err != nil {
+				return
+			}
+			if err := flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// A full snapshot for bootstrapping a replica. It comes from our
+// BoardToDiskHandler, so it's usually a little behind the live board; the
+// replica streams the rest from our backlog.
+func (s *Server) ServeReplicationSnapshot(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.decodeReplicationRequest(w, r); !ok {
+		return
+	}
+	s.httpLogger.Info().
+		Str("rpc", "ServeReplicationSnapshot").
+		Send()
+	snapshot, err := s.boardToDiskHandler.requestSnapshot(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := snapshot.writeBuffered(w); err != nil {
+		log.Printf("ERROR sending replication snapshot: %v", err)
+	}
+}
+
+type ReplicationStatus struct {
+	Role string `json:"role"`
+	// The last seqnum that we applied (or, on a primary, handed to replicas)
+	Seqnum uint64 `json:"seqnum"`
+	// Primary only
+	OldestBacklogSeqnum uint64 `json:"oldestBacklogSeqnum,omitempty"`
+	Replicas            int    `json:"replicas"`
+	// Replica only
+	PrimarySeqnum       uint64 `json:"primarySeqnum,omitempty"`
+	Lag                 uint64 `json:"lag"`
+	Connected           bool   `json:"connected"`
+	MsSinceHeardPrimary int64  `json:"msSinceHeardPrimary,omitempty"`
+	Error               string `json:"error,omitempty"`
+}
+
+func (s *Server) ServeReplicationStatus(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.decodeReplicationRequest(w, r); !ok {
+		return
+	}
+	var status ReplicationStatus
+	if s.replica != nil && !s.replica.promoted.Load() {
+		status = s.replica.status()
+	} else {
+		status.Role = "primary"
+	}
+	if hub := s.boardToDiskHandler.replication; hub != nil {
+		hub.Lock()
+		status.OldestBacklogSeqnum = hub.oldestSeqNum()
+		hub.Unlock()
+		status.Replicas = hub.subscriberCount()
+		if status.Role == "primary" {
+			status.Seqnum = hub.lastSeqNum.Load()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (s *Server) ServeReplicationPromote(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.decodeReplicationRequest(w, r); !ok {
+		return
+	}
+	s.httpLogger.Info().
+		Str("rpc", "ServeReplicationPromote").
+		Send()
+	if s.replica == nil || s.replica.promoted.Load() {
+		http.Error(w, "Not a replica", http.StatusBadRequest)
+		return
+	}
+	seqnum, err := s.replica.promote()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]uint64{"seqnum": seqnum})
+}
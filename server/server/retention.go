@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Deciding what we can throw away. We keep the newest N full snapshots and
+// anything needed to restore from any of them: deltas on top of a kept
+// snapshot, and every move log with requests after the oldest kept snapshot.
+// Optionally, sealed move logs get zstd-compressed in place (the .zst is
+// written before the .bin is removed, and the loader reads either).
+//
+// Piece history indexes are small and are the only record of where pieces
+// have been once their move logs are gone, so we never prune them. We do
+// merge runs of them (see piece-history.go), which we do even if we aren't
+// pruning or compressing anything else.
+
+const RETENTION_INTERVAL = time.Minute * 5
+
+var keepSnapshots = flag.Int("keep-snapshots", 0, "Keep this many of the newest full board snapshots and prune older snapshots, deltas and move logs (0 keeps everything)")
+var compressMoveLogs = flag.Bool("compress-move-logs", false, "zstd-compress move logs once they've been sealed")
+
+type retentionPlan struct {
+	DeleteSnapshots  []string
+	DeleteDeltas     []string
+	DeleteMoveLogs   []string
+	CompressMoveLogs []string
+	// Each group is merged into one index and then deleted
+	MergePieceHistory [][]pieceHistoryIndex
+	// Left over from merges that didn't finish
+	DeletePieceHistory []string
+}
+
+func (p *retentionPlan) isEmpty() bool {
+	return len(p.DeleteSnapshots) == 0 && len(p.DeleteDeltas) == 0 &&
+		len(p.DeleteMoveLogs) == 0 && len(p.CompressMoveLogs) == 0 &&
+		len(p.MergePieceHistory) == 0 && len(p.DeletePieceHistory) == 0
+}
+
+func (btd *BoardToDiskHandler) planRetention(keep int, compress bool) (*retentionPlan, error) {
+	plan := &retentionPlan{}
+	indexes, err := sortedPieceHistoryIndexes(btd.store)
+	if err != nil {
+		return nil, err
+	}
+	covering, leftover := splitPieceHistoryIndexes(indexes)
+	plan.MergePieceHistory = planPieceHistoryMerges(covering)
+	for _, index := range leftover {
+		plan.DeletePieceHistory = append(plan.DeletePieceHistory, index.Name)
+	}
+
+	fulls, err := btd.SortedSnapshotFilenames()
+	if err != nil {
+		return nil, err
+	}
+	if len(fulls) == 0 {
+		return plan, nil
+	}
+	kept := fulls
+	if keep > 0 && len(fulls) > keep {
+		kept = fulls[len(fulls)-keep:]
+		plan.DeleteSnapshots = fulls[:len(fulls)-keep]
+	}
+	keptSeqs := make(map[uint64]bool, len(kept))
+	var oldestKeptSeq uint64
+	for i, f := range kept {
+		_, seq, err := parseSnapshotFilename(f)
+		if err != nil {
+			return nil, err
+		}
+		keptSeqs[seq] = true
+		if i == 0 || seq < oldestKeptSeq {
+			oldestKeptSeq = seq
+		}
+	}
+
+	if len(plan.DeleteSnapshots) > 0 {
+		deltas, err := btd.store.List("delta-*.bin")
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range deltas {
+			_, _, baseSeq, err := parseDeltaSnapshotFilename(d)
+			if err != nil || keptSeqs[baseSeq] {
+				continue
+			}
+			plan.DeleteDeltas = append(plan.DeleteDeltas, d)
+		}
+	}
+
+	logs, err := btd.SortedMoveLogs()
+	if err != nil {
+		return nil, err
+	}
+	for _, ml := range logs {
+		if ml.Open {
+			continue
+		}
+		if len(plan.DeleteSnapshots) > 0 && ml.LastSeq <= oldestKeptSeq {
+			plan.DeleteMoveLogs = append(plan.DeleteMoveLogs, ml.Name)
+			continue
+		}
+		if compress && !strings.HasSuffix(ml.Name, compressedMoveLogSuffix) {
+			plan.CompressMoveLogs = append(plan.CompressMoveLogs, ml.Name)
+		}
+	}
+	return plan, nil
+}
+
+func (p *retentionPlan) Print(w io.Writer) {
+	for _, f := range p.DeleteSnapshots {
+		fmt.Fprintf(w, "delete snapshot %s\n", f)
+	}
+	for _, f := range p.DeleteDeltas {
+		fmt.Fprintf(w, "delete delta %s\n", f)
+	}
+	for _, f := range p.DeleteMoveLogs {
+		fmt.Fprintf(w, "delete move log %s\n", f)
+	}
+	for _, f := range p.CompressMoveLogs {
+		fmt.Fprintf(w, "compress move log %s\n", f)
+	}
+	for _, group := range p.MergePieceHistory {
+		fmt.Fprintf(w, "merge %d piece history indexes into %s\n", len(group),
+			pieceHistoryFilename(group[0].FirstSeq, group[len(group)-1].LastSeq))
+	}
+	for _, f := range p.DeletePieceHistory {
+		fmt.Fprintf(w, "delete merged piece history index %s\n", f)
+	}
+}
+
+func compressMoveLog(store StateStore, name string) error {
+	src, _, err := store.Get(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	err = store.Put(name+compressedMoveLogSuffix, func(writer io.Writer) error {
+		buf := bufio.NewWriterSize(writer, 1024*1024)
+		enc, err := zstd.NewWriter(buf, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(enc, src); err != nil {
+			enc.Close()
+			return err
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		return buf.Flush()
+	})
+	if err != nil {
+		return err
+	}
+	return store.Delete(name)
+}
+
+// Deletes snapshots last, so that if we die partway through we never have
+// a snapshot without the deltas and logs that we need to go with it.
+func (btd *BoardToDiskHandler) executeRetention(plan *retentionPlan) {
+	remove := func(kind string, files []string) {
+		for _, f := range files {
+			if err := btd.store.Delete(f); err != nil {
+				btd.logger.Error().Str("error_kind", "retention_delete").Str("file", f).AnErr("err", err).Send()
+				log.Printf("ERROR deleting %s %s: %v", kind, f, err)
+			}
+		}
+	}
+	for _, f := range plan.CompressMoveLogs {
+		if err := compressMoveLog(btd.store, f); err != nil {
+			btd.logger.Error().Str("error_kind", "retention_compress").Str("file", f).AnErr("err", err).Send()
+			log.Printf("ERROR compressing move log %s: %v", f, err)
+		}
+	}
+	mergedIndexes := 0
+	for _, group := range plan.MergePieceHistory {
+		if err := mergePieceHistoryIndexes(btd.store, group); err != nil {
+			btd.logger.Error().Str("error_kind", "retention_merge_piece_history").Str("file", group[0].Name).AnErr("err", err).Send()
+			log.Printf("ERROR merging piece history indexes from %s: %v", group[0].Name, err)
+			continue
+		}
+		names := make([]string, 0, len(group))
+		for _, index := range group {
+			names = append(names, index.Name)
+		}
+		remove("merged piece history index", names)
+		mergedIndexes += len(group)
+	}
+	remove("merged piece history index", plan.DeletePieceHistory)
+	remove("delta", plan.DeleteDeltas)
+	remove("move log", plan.DeleteMoveLogs)
+	remove("snapshot", plan.DeleteSnapshots)
+	btd.logger.Info().
+		Int("deleted_snapshots", len(plan.DeleteSnapshots)).
+		Int("deleted_deltas", len(plan.DeleteDeltas)).
+		Int("deleted_move_logs", len(plan.DeleteMoveLogs)).
+		Int("compressed_move_logs", len(plan.CompressMoveLogs)).
+		Int("merged_piece_history_indexes", mergedIndexes).
+		Send()
+}
+
+// Runs in the background off our main loop; if the last run is still going
+// we skip this one.
+func (btd *BoardToDiskHandler) maybeRunRetention() {
+	if *doNotSaveState {
+		return
+	}
+	if !btd.retentionRunning.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer btd.retentionRunning.Store(false)
+		plan, err := btd.planRetention(*keepSnapshots, *compressMoveLogs)
+		if err != nil {
+			btd.logger.Error().Str("error_kind", "retention_plan").AnErr("err", err).Send()
+			log.Printf("ERROR planning retention: %v", err)
+			return
+		}
+		if !plan.isEmpty() {
+			btd.executeRetention(plan)
+		}
+	}()
+}
+
+// Print what retention would do to stateDir with the current flags
+func PrintRetentionPlan(stateDir string) error {
+	store, err := NewStateStore(stateDir)
+	if err != nil {
+		return err
+	}
+	btd := newBoardToDiskHandler(store)
+	plan, err := btd.planRetention(*keepSnapshots, *compressMoveLogs)
+	if err != nil {
+		return err
+	}
+	if plan.isEmpty() {
+		fmt.Println("Nothing to do")
+		return nil
+	}
+	plan.Print(os.Stdout)
+	return nil
+}
@@ -0,0 +1,22 @@
+package server
+
+import "fmt"
+
+// A resolved rollback: the squares that it changes and what each of them
+// holds afterwards. We resolve against the live board before applying, so
+// replaying this is deterministic and never needs to look at old move logs.
+// The rest is just a record of what was asked for.
+type rollbackRequest struct {
+	FromSeqnum uint64
+	ToSeqnum   uint64
+	MinX       uint16
+	MinY       uint16
+	MaxX       uint16
+	MaxY       uint16
+	Squares    []squareAssignment
+}
+
+func (r *rollbackRequest) ToString() string {
+	return fmt.Sprintf("RB: [%d, %d] -> [%d, %d] seqnums (%d, %d], %d squares",
+		r.MinX, r.MinY, r.MaxX, r.MaxY, r.FromSeqnum, r.ToSeqnum, len(r.Squares))
+}
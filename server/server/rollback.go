@@ -0,0 +1,471 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"one-million-chessboards/protocol"
+)
+
+// Undoing vandalism. An admin names a rectangle and a window of seqnums
+// (fromSeqnum, toSeqnum], and we put every piece that took part in a move
+// touching the rectangle during that window back where it was (and how it
+// was) before its first such move. That includes pieces that those moves
+// captured.
+//
+// Working out what to undo means reading the move logs, which we do off to
+// the side on a board reconstructed at fromSeqnum. Move logs don't say what a
+// move did, so we diff the handful of squares that each move could touch
+// before and after applying it. The result is a plan: each piece, where it
+// should go back to, and where we expect to find it now.
+//
+// processMoves resolves the plan against the live board. A piece that has
+// moved since toSeqnum, or whose old square has been taken, is left alone;
+// everything else becomes a rollbackRequest listing the squares to rewrite,
+// which is what we persist and replicate.
+
+// Keeps a resolved rollback comfortably under MAX_MOVE_LOG_RECORD_BYTES
+const MAX_ROLLBACK_PIECES = 32768
+
+var errRollbackInProgress = errors.New("another rollback is being planned")
+var errRollbackNotPersisted = errors.New("move logs don't reach the end of the window yet")
+
+type rollbackPiece struct {
+	ID uint32
+	// Where the piece was, and what it looked like, before the window
+	Origin squareAssignment
+	// Where the piece was at the end of the window, unless it was captured
+	FinalX   uint16
+	FinalY   uint16
+	Captured bool
+}
+
+type rollbackPlan struct {
+	FromSeqnum uint64
+	ToSeqnum   uint64
+	MinX       uint16
+	MinY       uint16
+	MaxX       uint16
+	MaxY       uint16
+	// In the order that they got involved, which decides who gets a square
+	// that two pieces both started on
+	Pieces []rollbackPiece
+}
+
+func (p *rollbackPlan) contains(x, y uint16) bool {
+	return x >= p.MinX && x <= p.MaxX && y >= p.MinY && y <= p.MaxY
+}
+
+type RollbackOutcome struct {
+	Seqnum   uint64 `json:"seqnum"`
+	Involved int    `json:"involved"`
+	Restored int    `json:"restored"`
+	Skipped  int    `json:"skipped"`
+}
+
+type rollbackCommand struct {
+	plan   *rollbackPlan
+	result chan RollbackOutcome
+}
+
+type rollbackPlanner struct {
+	board    *Board
+	plan     *rollbackPlan
+	involved map[uint32]int
+	// The squares that the request that we're about to apply could touch,
+	// and what was on them beforehand
+	pending []squareAssignment
+	// Only moves get pieces involved; anything else just moves them along
+	pendingIsMove bool
+	err           error
+}
+
+func (rp *rollbackPlanner) watch(x, y uint16) {
+	if x >= BOARD_SIZE || y >= BOARD_SIZE {
+		return
+	}
+	for _, sq := range rp.pending {
+		if sq.X == x && sq.Y == y {
+			return
+		}
+	}
+	rp.pending = append(rp.pending, squareAssignment{X: x, Y: y, Piece: EncodedPiece(rp.board.pieces[y][x])})
+}
+
+func (rp *rollbackPlanner) before(req boardToDiskRequest) {
+	rp.pending = rp.pending[:0]
+	rp.pendingIsMove = false
+	switch {
+	case req.Move != nil:
+		// Its two ends, the pawn that an en passant captures and the rook
+		// in a castle
+		move := req.Move
+		rp.pendingIsMove = true
+		rp.watch(move.FromX, move.FromY)
+		rp.watch(move.ToX, move.ToY)
+		rp.watch(move.ToX, move.FromY)
+		if move.MoveType == protocol.MoveType_MOVE_TYPE_CASTLE {
+			for dx := -4; dx <= 4; dx++ {
+				rp.watch(uint16(int(move.FromX)+dx), move.FromY)
+			}
+		}
+	case req.BulkCaptureRequest != nil:
+		r := req.BulkCaptureRequest
+		for y := r.StartingY(); y < r.EndingY(); y++ {
+			for x := r.StartingX(); x < r.EndingX(); x++ {
+				rp.watch(x, y)
+			}
+		}
+	case req.RollbackRequest != nil:
+		for _, sq := range req.RollbackRequest.Squares {
+			rp.watch(sq.X, sq.Y)
+		}
+	case req.SetPiecesRequest != nil:
+		for _, sq := range req.SetPiecesRequest.Squares {
+			rp.watch(sq.X, sq.Y)
+		}
+	}
+}
+
+func (rp *rollbackPlanner) after() {
+	if len(rp.pending) == 0 {
+		return
+	}
+	touches := false
+	for _, sq := range rp.pending {
+		if EncodedPiece(rp.board.pieces[sq.Y][sq.X]) != sq.Piece && rp.plan.contains(sq.X, sq.Y) {
+			touches = true
+			break
+		}
+	}
+
+	// Anything that left its square is gone unless we find it again below
+	for _, sq := range rp.pending {
+		if EncodedIsEmpty(sq.Piece) || EncodedPiece(rp.board.pieces[sq.Y][sq.X]) == sq.Piece {
+			continue
+		}
+		id := PieceOfEncodedPiece(sq.Piece).ID
+		idx, ok := rp.involved[id]
+		if !ok {
+			if !touches || !rp.pendingIsMove {
+				continue
+			}
+			idx = len(rp.plan.Pieces)
+			rp.involved[id] = idx
+			rp.plan.Pieces = append(rp.plan.Pieces, rollbackPiece{ID: id, Origin: sq})
+		}
+		rp.plan.Pieces[idx].Captured = true
+	}
+	for _, sq := range rp.pending {
+		now := EncodedPiece(rp.board.pieces[sq.Y][sq.X])
+		if EncodedIsEmpty(now) {
+			continue
+		}
+		if idx, ok := rp.involved[PieceOfEncodedPiece(now).ID]; ok {
+			piece := &rp.plan.Pieces[idx]
+			piece.FinalX, piece.FinalY, piece.Captured = sq.X, sq.Y, false
+		}
+	}
+
+	rp.pending = rp.pending[:0]
+	if len(rp.plan.Pieces) > MAX_ROLLBACK_PIECES {
+		rp.err = fmt.Errorf("more than %d pieces to roll back; try a smaller window", MAX_ROLLBACK_PIECES)
+	}
+}
+
+// Slow (it loads a snapshot and replays move logs) and memory hungry (it
+// needs a whole board), so we only plan one rollback at a time.
+func (s *Server) planRollback(plan *rollbackPlan) error {
+	if !s.rollbackPlanning.TryLock() {
+		return errRollbackInProgress
+	}
+	defer s.rollbackPlanning.Unlock()
+
+	start := time.Now()
+	btd, err := reconstructBoard(s.boardToDiskHandler.store, ReconstructionTarget{Seqnum: plan.FromSeqnum})
+	if err != nil {
+		return err
+	}
+	rp := &rollbackPlanner{
+		board:    btd.board,
+		plan:     plan,
+		involved: make(map[uint32]int),
+	}
+	err = btd.replayMoveLogsUntil(func(req boardToDiskRequest) bool {
+		rp.after()
+		if rp.err != nil || btd.board.seqNum >= plan.ToSeqnum {
+			return true
+		}
+		rp.before(req)
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	rp.after()
+	if rp.err != nil {
+		return rp.err
+	}
+	if btd.board.seqNum < plan.ToSeqnum {
+		return fmt.Errorf("%w (they reach seqnum %d)", errRollbackNotPersisted, btd.board.seqNum)
+	}
+
+	// This shouldn't drop anything, but putting back a piece that's still
+	// on the board somewhere would duplicate it.
+	plan.Pieces = slices.DeleteFunc(plan.Pieces, func(piece rollbackPiece) bool {
+		if piece.Captured {
+			return false
+		}
+		now := EncodedPiece(btd.board.pieces[piece.FinalY][piece.FinalX])
+		if EncodedIsEmpty(now) || PieceOfEncodedPiece(now).ID != piece.ID {
+			log.Printf("BUG? Rollback lost track of piece %d", piece.ID)
+			return true
+		}
+		return false
+	})
+	log.Printf("Planned rollback of %d pieces in [%d, %d] -> [%d, %d] for seqnums (%d, %d] in %s",
+		len(plan.Pieces), plan.MinX, plan.MinY, plan.MaxX, plan.MaxY,
+		plan.FromSeqnum, plan.ToSeqnum, time.Since(start))
+	return nil
+}
+
+// Called from processMoves, so nothing else changes the board while we look
+// at it. Returns the request to apply (which may change nothing) and how
+// many pieces it restores.
+func (b *Board) ResolveRollback(plan *rollbackPlan) (*rollbackRequest, int) {
+	b.RLock()
+	defer b.RUnlock()
+
+	pieceAt := func(x, y uint16) EncodedPiece {
+		return EncodedPiece(b.pieces[y][x])
+	}
+	idAt := func(x, y uint16) (uint32, bool) {
+		p := pieceAt(x, y)
+		if EncodedIsEmpty(p) {
+			return 0, false
+		}
+		return PieceOfEncodedPiece(p).ID, true
+	}
+
+	// Pieces that are still where the window left them
+	candidates := make([]*rollbackPiece, 0, len(plan.Pieces))
+	for i := range plan.Pieces {
+		piece := &plan.Pieces[i]
+		if !piece.Captured {
+			if id, ok := idAt(piece.FinalX, piece.FinalY); !ok || id != piece.ID {
+				continue
+			}
+		}
+		candidates = append(candidates, piece)
+	}
+
+	// Dropping a piece means that it stays put, which can block a square
+	// that another piece wanted, so go until nothing changes.
+	for {
+		leaving := make(map[uint32]struct{}, len(candidates))
+		for _, piece := range candidates {
+			if !piece.Captured {
+				leaving[piece.ID] = struct{}{}
+			}
+		}
+		claimed := make(map[uint32]struct{}, len(candidates))
+		kept := candidates[:0:0]
+		for _, piece := range candidates {
+			square := encodeCoords(piece.Origin.X, piece.Origin.Y)
+			if _, ok := claimed[square]; ok {
+				continue
+			}
+			if id, ok := idAt(piece.Origin.X, piece.Origin.Y); ok {
+				if _, isLeaving := leaving[id]; !isLeaving {
+					continue
+				}
+			}
+			claimed[square] = struct{}{}
+			kept = append(kept, piece)
+		}
+		if len(kept) == len(candidates) {
+			break
+		}
+		candidates = kept
+	}
+
+	squares := make(map[uint32]EncodedPiece, len(candidates)*2)
+	for _, piece := range candidates {
+		if !piece.Captured {
+			squares[encodeCoords(piece.FinalX, piece.FinalY)] = EmptyEncodedPiece
+		}
+	}
+	for _, piece := range candidates {
+		squares[encodeCoords(piece.Origin.X, piece.Origin.Y)] = piece.Origin.Piece
+	}
+
+	req := &rollbackRequest{
+		FromSeqnum: plan.FromSeqnum,
+		ToSeqnum:   plan.ToSeqnum,
+		MinX:       plan.MinX,
+		MinY:       plan.MinY,
+		MaxX:       plan.MaxX,
+		MaxY:       plan.MaxY,
+		Squares:    make([]squareAssignment, 0, len(squares)),
+	}
+	for coords, piece := range squares {
+		x, y := decodeCoords(coords)
+		if pieceAt(x, y) == piece {
+			continue
+		}
+		req.Squares = append(req.Squares, squareAssignment{X: x, Y: y, Piece: piece})
+	}
+	slices.SortFunc(req.Squares, func(a, b squareAssignment) int {
+		if a.Y != b.Y {
+			return int(a.Y) - int(b.Y)
+		}
+		return int(a.X) - int(b.X)
+	})
+	return req, len(candidates)
+}
+
+// Clients see a rollback as pieces moving (or reappearing) and, if
+// something went wrong, being captured.
+func rollbackMessage(res *ChangeSet) *protocol.ServerMessage {
+	placed := make(map[uint32]struct{}, len(res.Placed))
+	moves := make([]*protocol.PieceDataForMove, 0, len(res.Placed))
+	for _, p := range res.Placed {
+		placed[p.Piece.ID] = struct{}{}
+		moves = append(moves, &protocol.PieceDataForMove{
+			X:      uint32(p.X),
+			Y:      uint32(p.Y),
+			Seqnum: res.Seqnum,
+			Piece:  p.Piece.ToProtocolAlloc(),
+		})
+	}
+	captures := make([]*protocol.PieceCapture, 0)
+	for _, p := range res.Removed {
+		if _, ok := placed[p.Piece.ID]; !ok {
+			captures = append(captures, &protocol.PieceCapture{
+				CapturedPieceId: p.Piece.ID,
+				Seqnum:          res.Seqnum,
+			})
+		}
+	}
+	return &protocol.ServerMessage{
+		Payload: &protocol.ServerMessage_MovesAndCaptures{
+			MovesAndCaptures: &protocol.ServerMovesAndCaptures{
+				Moves:    moves,
+				Captures: captures,
+			},
+		},
+	}
+}
+
+// Called from processMoves
+func (s *Server) applyRollback(cmd rollbackCommand) {
+	req, restored := s.board.ResolveRollback(cmd.plan)
+	outcome := RollbackOutcome{
+		Seqnum:   s.board.seqNum,
+		Involved: len(cmd.plan.Pieces),
+		Restored: restored,
+		Skipped:  len(cmd.plan.Pieces) - restored,
+	}
+	if len(req.Squares) == 0 {
+		cmd.result <- outcome
+		return
+	}
+	result, err := s.board.ApplyRollback(req)
+	if err != nil {
+		outcome.Restored, outcome.Skipped = 0, len(cmd.plan.Pieces)
+		cmd.result <- outcome
+		return
+	}
+	s.publishBoardEvent(boardToDiskRequest{RollbackRequest: req}, result, nil)
+	outcome.Seqnum = result.Seqnum
+	cmd.result <- outcome
+}
+
+func (s *Server) ServeRollback(w http.ResponseWriter, r *http.Request) {
+	s.httpLogger.Info().
+		Str("rpc", "ServeRollback").
+		Send()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The rectangle is inclusive, in squares
+	type RollbackRequest struct {
+		MinX       uint16 `json:"minX"`
+		MinY       uint16 `json:"minY"`
+		MaxX       uint16 `json:"maxX"`
+		MaxY       uint16 `json:"maxY"`
+		FromSeqnum uint64 `json:"fromSeqnum"`
+		ToSeqnum   uint64 `json:"toSeqnum"`
+		Pass       string `json:"pass"`
+	}
+
+	var req RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Pass != *internalPass {
+		http.Error(w, "no", http.StatusNotFound)
+		return
+	}
+
+	if s.isStandby() {
+		http.Error(w, "Server is a standby", http.StatusServiceUnavailable)
+		return
+	}
+
+	if req.MaxX >= BOARD_SIZE || req.MaxY >= BOARD_SIZE || req.MinX > req.MaxX || req.MinY > req.MaxY {
+		http.Error(w, "Bad rectangle", http.StatusBadRequest)
+		return
+	}
+	if req.FromSeqnum == 0 || req.FromSeqnum >= req.ToSeqnum || req.ToSeqnum > s.board.GetStats().Seqnum {
+		http.Error(w, "Bad seqnums", http.StatusBadRequest)
+		return
+	}
+
+	plan := &rollbackPlan{
+		FromSeqnum: req.FromSeqnum,
+		ToSeqnum:   req.ToSeqnum,
+		MinX:       req.MinX,
+		MinY:       req.MinY,
+		MaxX:       req.MaxX,
+		MaxY:       req.MaxY,
+	}
+	if err := s.planRollback(plan); err != nil {
+		s.httpLogger.Error().Str("error_kind", "planning_rollback").AnErr("err", err).Send()
+		switch {
+		case errors.Is(err, errRollbackInProgress):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, errRollbackNotPersisted):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	cmd := rollbackCommand{plan: plan, result: make(chan RollbackOutcome, 1)}
+	select {
+	case s.rollbackRequests <- cmd:
+	case <-s.processMovesCtx.Done():
+		http.Error(w, "Not accepting changes", http.StatusServiceUnavailable)
+		return
+	}
+	var outcome RollbackOutcome
+	select {
+	case outcome = <-cmd.result:
+	case <-s.processMovesCtx.Done():
+		http.Error(w, "Not accepting changes", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(outcome)
+}
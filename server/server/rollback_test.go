@@ -0,0 +1,112 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"one-million-chessboards/protocol"
+)
+
+func placeTestPiece(b *Board, x, y uint16, pieceType protocol.PieceType, isWhite bool) Piece {
+	piece := b.createPiece(pieceType, isWhite)
+	b.pieces[y][x] = uint64(piece.Encode())
+	return piece
+}
+
+func testMove(piece Piece, fromX, fromY, toX, toY uint16, moveType protocol.MoveType) boardToDiskRequest {
+	return boardToDiskRequest{Move: &Move{
+		PieceID:              piece.ID,
+		FromX:                fromX,
+		FromY:                fromY,
+		ToX:                  toX,
+		ToY:                  toY,
+		MoveType:             moveType,
+		ClientIsPlayingWhite: piece.IsWhite,
+	}}
+}
+
+// The board at (0, 0) gets a capture, an en passant and a castle. Next door
+// a knight moves, which isn't in the rectangle that we roll back.
+func TestRollbackPlanner(t *testing.T) {
+	b := NewBoard(false)
+	normal := protocol.MoveType_MOVE_TYPE_NORMAL
+	whiteKing := placeTestPiece(b, 4, 7, King, true)
+	whiteRook := placeTestPiece(b, 7, 7, Rook, true)
+	whiteKnight := placeTestPiece(b, 1, 7, Knight, true)
+	blackBishop := placeTestPiece(b, 2, 5, Bishop, false)
+	whitePawn := placeTestPiece(b, 3, 4, Pawn, true)
+	blackPawn := placeTestPiece(b, 4, 2, Pawn, false)
+	blackKnight := placeTestPiece(b, 9, 0, Knight, false)
+	var before [8][16]uint64
+	for y := range before {
+		copy(before[y][:], b.pieces[y][:16])
+	}
+
+	plan := &rollbackPlan{FromSeqnum: b.seqNum, MinX: 0, MinY: 0, MaxX: 7, MaxY: 7}
+	rp := &rollbackPlanner{board: b, plan: plan, involved: make(map[uint32]int)}
+	moves := []boardToDiskRequest{
+		testMove(blackPawn, 4, 2, 4, 4, normal),
+		testMove(whitePawn, 3, 4, 4, 3, protocol.MoveType_MOVE_TYPE_EN_PASSANT),
+		testMove(whiteKing, 4, 7, 6, 7, protocol.MoveType_MOVE_TYPE_CASTLE),
+		testMove(whiteKnight, 1, 7, 2, 5, normal),
+		testMove(blackKnight, 9, 0, 10, 2, normal),
+	}
+	for _, req := range moves {
+		rp.before(req)
+		if res := b.ValidateAndApplyMove__NOTTHREADSAFE(*req.Move); !res.Valid {
+			t.Fatalf("%s isn't valid", req.Move.ToString())
+		}
+		rp.after()
+	}
+	if rp.err != nil {
+		t.Fatal(rp.err)
+	}
+	plan.ToSeqnum = b.seqNum
+
+	origin := func(p Piece, x, y uint16) squareAssignment {
+		return squareAssignment{X: x, Y: y, Piece: p.Encode()}
+	}
+	// A captured piece keeps the last square that we saw it on, which
+	// doesn't matter since we don't look there for it
+	want := []rollbackPiece{
+		{ID: blackPawn.ID, Origin: origin(blackPawn, 4, 2), FinalX: 4, FinalY: 4, Captured: true},
+		{ID: whitePawn.ID, Origin: origin(whitePawn, 3, 4), FinalX: 4, FinalY: 3},
+		{ID: whiteKing.ID, Origin: origin(whiteKing, 4, 7), FinalX: 6, FinalY: 7},
+		{ID: whiteRook.ID, Origin: origin(whiteRook, 7, 7), FinalX: 5, FinalY: 7},
+		{ID: whiteKnight.ID, Origin: origin(whiteKnight, 1, 7), FinalX: 2, FinalY: 5},
+		{ID: blackBishop.ID, Origin: origin(blackBishop, 2, 5), Captured: true},
+	}
+	if !reflect.DeepEqual(plan.Pieces, want) {
+		t.Fatalf("got plan\n%+v\nwant\n%+v", plan.Pieces, want)
+	}
+
+	// Putting it all back should leave the board as it was, apart from the
+	// knight that moved outside of the rectangle
+	req, restored := b.ResolveRollback(plan)
+	if restored != len(want) {
+		t.Errorf("restored %d pieces, want %d", restored, len(want))
+	}
+	if _, err := b.ApplyRollback(req); err != nil {
+		t.Fatal(err)
+	}
+	for y := uint16(0); y < 8; y++ {
+		for x := uint16(0); x < 16; x++ {
+			got, wanted := b.pieces[y][x], before[y][x]
+			if x == 9 && y == 0 {
+				wanted = 0
+			} else if x == 10 && y == 2 {
+				wanted = b.pieces[2][10]
+			}
+			if got != wanted {
+				t.Errorf("(%d, %d) has %+v, want %+v", x, y,
+					PieceOfEncodedPiece(EncodedPiece(got)), PieceOfEncodedPiece(EncodedPiece(wanted)))
+			}
+		}
+	}
+	if PieceOfEncodedPiece(EncodedPiece(b.pieces[2][10])).ID != blackKnight.ID {
+		t.Errorf("the knight outside of the rectangle moved")
+	}
+	if n := b.blackPiecesCaptured.Load(); n != 0 {
+		t.Errorf("%d black pieces still captured after rolling back", n)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"log"
+)
+
+// Changing the rules mid-game. A rule change is a request like any other:
+// it gets a seqnum, goes into the move log and out to replicas, and is
+// applied at that seqnum when we replay. So however far back a replay
+// starts (an older snapshot because the newest is corrupt, a reconstruction,
+// a replica that missed the switch), later moves are checked under the rules
+// that they were made under.
+//
+// It lists every rule, not just the one that changed; fields added later
+// decode as zero, which is what those rules were before they existed.
+type ruleChangeRequest struct {
+	PromotionRule PromotionRuleID
+	RuleSet       RuleSetID
+}
+
+func (r *ruleChangeRequest) ToString() string {
+	promotion := fmt.Sprintf("rule %d", r.PromotionRule)
+	if rule, err := promotionRuleByID(r.PromotionRule); err == nil {
+		promotion = rule.Name()
+	}
+	rules := fmt.Sprintf("rule set %d", r.RuleSet)
+	if ruleSet, err := ruleSetByID(r.RuleSet); err == nil {
+		rules = ruleSet.Name()
+	}
+	return fmt.Sprintf("RC: %s rules, pawns promote at the %s", rules, promotion)
+}
+
+// The board's rules as they are now, as a request
+func (b *Board) currentRules() ruleChangeRequest {
+	b.RLock()
+	defer b.RUnlock()
+	return ruleChangeRequest{
+		PromotionRule: b.promotion.ID(),
+		RuleSet:       b.rules.ID(),
+	}
+}
+
+func (b *Board) ChangeRules(req *ruleChangeRequest) (*ChangeSet, error) {
+	promotion, err := promotionRuleByID(req.PromotionRule)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ruleSetByID(req.RuleSet)
+	if err != nil {
+		return nil, err
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.promotion = promotion
+	b.rules = rules
+	b.seqNum++
+	return &ChangeSet{Seqnum: b.seqNum}, nil
+}
+
+// Call from Run before processMoves starts, while the board is still ours.
+// If our flags ask for different rules than the board has, we change them
+// like processMoves would change anything else. Replicas follow their
+// primary's rules and ignore the flags.
+func (s *Server) applyRuleFlags() error {
+	current := s.board.currentRules()
+	req := current
+	if err := applyPromotionRuleFlag(&req); err != nil {
+		return err
+	}
+	if err := applyRuleSetFlag(&req); err != nil {
+		return err
+	}
+	if req == current {
+		return nil
+	}
+	if s.isStandby() {
+		log.Printf("Ignoring rule flags; replicas play by their primary's rules")
+		return nil
+	}
+	result, err := s.board.ChangeRules(&req)
+	if err != nil {
+		return err
+	}
+	log.Printf("Changed rules at seqnum %d (%s)", result.Seqnum, req.ToString())
+	s.publishBoardEvent(boardToDiskRequest{RuleChangeRequest: &req}, result, nil)
+	return nil
+}
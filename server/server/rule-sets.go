@@ -0,0 +1,237 @@
+package server
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"one-million-chessboards/protocol"
+)
+
+// What counts as a legal move, what a move does to the piece that made it,
+// and who's won. Board handles the mechanics (locking, reading squares,
+// castling and en passant bookkeeping, counters) and asks its RuleSet
+// everything else, so a variant event is a new RuleSet rather than a fork
+// of board.go.
+//
+// Like the promotion rule, the rule set is part of the board's state: it's
+// recorded in every snapshot header, and changing -rules takes effect at
+// startup as a ruleChangeRequest.
+//
+// Every method is called from processMoves (or the replay) with at least the
+// board's read lock held, so don't lock it again.
+
+var ruleSetFlag = flag.String("rules", "", "Which rule set to play by: standard, same-board-captures or classical (empty keeps the rules from our latest snapshot; new boards use standard)")
+
+type RuleSetID uint8
+
+const (
+	// Zero so that snapshots from before we recorded a rule set get this one
+	RuleSetStandard RuleSetID = iota
+	RuleSetSameBoardCaptures
+	RuleSetClassical
+)
+
+type RuleSet interface {
+	ID() RuleSetID
+	Name() string
+	// Checks that don't need to look at the board
+	MoveInRange(move Move) bool
+	AllowsMoveType(moveType protocol.MoveType) bool
+	// Whether this player may move this piece at all
+	MayMove(move Move, movedPiece Piece) bool
+	// Whether a normal (not castling or en passant) move is legal.
+	// capturedPiece is empty unless there's an enemy piece on the target.
+	ValidateNormalMove(b *Board, movedPiece Piece, capturedPiece Piece, move Move) bool
+	// Whether a move that's otherwise legal would leave one of the mover's
+	// kings in check once these squares are written
+	LeavesKingInCheck(b *Board, move Move, isWhite bool, writes ...squareWrite) bool
+	// Whether a castle starts in or crosses an attacked square. Landing on
+	// one is LeavesKingInCheck's job.
+	CastlesThroughCheck(b *Board, move Move, isWhite bool) bool
+	// Updates the moved piece for a valid normal move: double move flags,
+	// promotion and so on. Returns false if the move turns out to be invalid
+	// after all (like asking for a promotion that we don't allow).
+	ApplyNormalMove(b *Board, movedPiece *Piece, move Move) (promoted bool, ok bool)
+	// Whether capturing this piece ends the game on the spot.
+	// kingsCaptured is how many of its side's kings are gone, including it.
+	EndsGame(captured Piece, kingsCaptured uint32) bool
+	// "white", "black", "draw", or "" while the game's still going
+	Winner(stats GameStats) string
+	// Whether we work out checkmate and stalemate on every 8x8 board
+	TracksBoardOutcomes() bool
+}
+
+// The rules that we launched with
+type standardRules struct{}
+
+func (standardRules) ID() RuleSetID { return RuleSetStandard }
+func (standardRules) Name() string  { return "standard" }
+
+func (standardRules) MoveInRange(move Move) bool {
+	return !move.ExceedsMaxMoveDistance()
+}
+
+func (standardRules) AllowsMoveType(moveType protocol.MoveType) bool {
+	return moveType == protocol.MoveType_MOVE_TYPE_NORMAL ||
+		moveType == protocol.MoveType_MOVE_TYPE_CASTLE ||
+		moveType == protocol.MoveType_MOVE_TYPE_EN_PASSANT
+}
+
+func (standardRules) MayMove(move Move, movedPiece Piece) bool {
+	return move.ClientIsPlayingWhite == movedPiece.IsWhite || !RESPECT_COLOR_REQUIREMENT
+}
+
+func (standardRules) ValidateNormalMove(b *Board, movedPiece Piece, capturedPiece Piece, move Move) bool {
+	// captures must be on the same board unless the target
+	// has already moved
+	if !capturedPiece.IsEmpty() && capturedPiece.MoveCount == 0 &&
+		!onSameBoard(move.FromX, move.FromY, move.ToX, move.ToY) {
+		return false
+	}
+	return b.satisfiesMoveRules(movedPiece, capturedPiece, move)
+}
+
+func (standardRules) LeavesKingInCheck(b *Board, move Move, isWhite bool, writes ...squareWrite) bool {
+	return false
+}
+
+func (standardRules) CastlesThroughCheck(b *Board, move Move, isWhite bool) bool {
+	return false
+}
+
+// Pawns must handle double move, promotion
+func (standardRules) ApplyNormalMove(b *Board, movedPiece *Piece, move Move) (bool, bool) {
+	if movedPiece.Type != Pawn {
+		return false, true
+	}
+	dy := int32(move.ToY) - int32(move.FromY)
+	movedPiece.JustDoubleMoved = dy == 2 || dy == -2
+
+	if !b.promotion.PromotesAt(movedPiece.IsWhite, move.ToY) {
+		return false, true
+	}
+	promotedType, ok := promotedTypeFor(move.Promotion)
+	if !ok {
+		return false, false
+	}
+	movedPiece.Type = promotedType
+	return true, true
+}
+
+func (standardRules) EndsGame(captured Piece, kingsCaptured uint32) bool {
+	return captured.Type == King && kingsCaptured == TOTAL_KINGS_PER_SIDE
+}
+
+func (standardRules) Winner(stats GameStats) string {
+	noWhiteKings := stats.WhiteKingsRemaining == 0
+	noBlackKings := stats.BlackKingsRemaining == 0
+	onlyWhiteKings := stats.WhitePiecesRemaining == stats.WhiteKingsRemaining
+	onlyBlackKings := stats.BlackPiecesRemaining == stats.BlackKingsRemaining
+
+	switch {
+	case noWhiteKings && noBlackKings:
+		return "draw"
+	case noWhiteKings:
+		return "black"
+	case noBlackKings:
+		return "white"
+	case onlyWhiteKings && onlyBlackKings:
+		return "draw"
+	}
+	return ""
+}
+
+func (standardRules) TracksBoardOutcomes() bool {
+	return false
+}
+
+// For events where every board is its own little fight: nothing can be
+// captured from a neighbouring board, moved or not.
+type sameBoardCaptureRules struct {
+	standardRules
+}
+
+func (sameBoardCaptureRules) ID() RuleSetID { return RuleSetSameBoardCaptures }
+func (sameBoardCaptureRules) Name() string  { return "same-board-captures" }
+
+func (r sameBoardCaptureRules) ValidateNormalMove(b *Board, movedPiece Piece, capturedPiece Piece, move Move) bool {
+	if !capturedPiece.IsEmpty() && !onSameBoard(move.FromX, move.FromY, move.ToX, move.ToY) {
+		return false
+	}
+	return r.standardRules.ValidateNormalMove(b, movedPiece, capturedPiece, move)
+}
+
+// Each 8x8 board plays a little more like a normal game of chess; see
+// classical-boards.go
+type classicalRules struct {
+	standardRules
+}
+
+func (classicalRules) ID() RuleSetID { return RuleSetClassical }
+func (classicalRules) Name() string  { return "classical" }
+
+func (classicalRules) LeavesKingInCheck(b *Board, move Move, isWhite bool, writes ...squareWrite) bool {
+	return b.exposesKing(move, isWhite, writes...)
+}
+
+func (classicalRules) CastlesThroughCheck(b *Board, move Move, isWhite bool) bool {
+	return b.castlesThroughCheck(move, isWhite)
+}
+
+func (classicalRules) TracksBoardOutcomes() bool {
+	return true
+}
+
+// Indexed by ID
+var ruleSets = []RuleSet{
+	standardRules{},
+	sameBoardCaptureRules{},
+	classicalRules{},
+}
+
+func ruleSetByID(id RuleSetID) (RuleSet, error) {
+	if int(id) >= len(ruleSets) {
+		return nil, fmt.Errorf("unknown rule set %d", id)
+	}
+	return ruleSets[id], nil
+}
+
+func ruleSetByName(name string) (RuleSet, error) {
+	names := make([]string, 0, len(ruleSets))
+	for _, rules := range ruleSets {
+		if rules.Name() == name {
+			return rules, nil
+		}
+		names = append(names, rules.Name())
+	}
+	return nil, fmt.Errorf("unknown rule set %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// Sets the board's rules from a snapshot or delta header
+func (b *Board) setRulesFromHeader(header *SnapshotHeader) error {
+	rules, err := ruleSetByID(header.RuleSet)
+	if err != nil {
+		return err
+	}
+	promotion, err := promotionRuleByID(header.PromotionRule)
+	if err != nil {
+		return err
+	}
+	b.rules = rules
+	b.promotion = promotion
+	return nil
+}
+
+// See applyRuleFlags
+func applyRuleSetFlag(req *ruleChangeRequest) error {
+	if *ruleSetFlag == "" {
+		return nil
+	}
+	rules, err := ruleSetByName(*ruleSetFlag)
+	if err != nil {
+		return err
+	}
+	req.RuleSet = rules.ID()
+	return nil
+}
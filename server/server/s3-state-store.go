@@ -0,0 +1,511 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A StateStore backed by an S3-compatible object store (S3 itself, MinIO,
+// R2...). We use path-style URLs and sign requests ourselves (SigV4) so that
+// we don't need to pull in an SDK. Credentials come from the usual
+// AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY / AWS_SESSION_TOKEN variables.
+//
+// Objects can't be appended to, so open move logs are re-uploaded in full
+// now and then, in the background (see s3AppendableObject).
+
+var s3Endpoint = flag.String("s3-endpoint", "", "S3-compatible endpoint, e.g. https://s3.us-east-1.amazonaws.com or http://localhost:9000")
+var s3Bucket = flag.String("s3-bucket", "", "Bucket to keep state in when -state-store=s3")
+var s3Prefix = flag.String("s3-prefix", "", "Key prefix for our state within the bucket")
+var s3Region = flag.String("s3-region", "us-east-1", "Region to sign S3 requests for")
+
+const (
+	// Snapshots are big
+	S3_REQUEST_TIMEOUT = time.Minute * 10
+	// Move log segments aren't, so if uploading one takes this long we'd
+	// rather give up and try again with the next flush
+	S3_MOVE_LOG_REQUEST_TIMEOUT = time.Second * 15
+	// The least time between uploads of an open move log. We seal segments
+	// every MOVE_SERIALIZATION_INTERVAL anyway, so this only narrows how
+	// much of the open one we can lose.
+	S3_MOVE_LOG_UPLOAD_INTERVAL = time.Second * 5
+)
+
+type S3StateStore struct {
+	endpoint     *url.URL
+	bucket       string
+	prefix       string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+func NewS3StateStoreFromFlags() (*S3StateStore, error) {
+	return NewS3StateStore(
+		*s3Endpoint, *s3Bucket, *s3Prefix, *s3Region,
+		os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN"),
+	)
+}
+
+func NewS3StateStore(endpoint, bucket, prefix, region, accessKey, secretKey, sessionToken string) (*S3StateStore, error) {
+	if endpoint == "" || bucket == "" {
+		return nil, errors.New("the s3 state store needs -s3-endpoint and -s3-bucket")
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("the s3 state store needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("bad s3 endpoint %q", endpoint)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &S3StateStore{
+		endpoint:     u,
+		bucket:       bucket,
+		prefix:       prefix,
+		region:       region,
+		accessKey:    accessKey,
+		secretKey:    secretKey,
+		sessionToken: sessionToken,
+		client:       &http.Client{Timeout: S3_REQUEST_TIMEOUT},
+	}, nil
+}
+
+func (s *S3StateStore) Describe() string {
+	return fmt.Sprintf("s3 %s/%s/%s", s.endpoint, s.bucket, s.prefix)
+}
+
+// S3 wants everything but the unreserved characters escaped, which is more
+// than url.PathEscape does (it leaves ':' alone, and our names are full of
+// them).
+func s3Escape(s string, escapeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		unreserved := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~'
+		if unreserved || (c == '/' && !escapeSlash) {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func (s *S3StateStore) key(name string) string {
+	return s.prefix + name
+}
+
+func (s *S3StateStore) objectPath(key string) string {
+	p := "/" + s.bucket
+	if key != "" {
+		p += "/" + key
+	}
+	return p
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+const s3DateTimeFormat = "20060102T150405Z"
+
+// The Authorization header for a request. uri and query must already be
+// escaped the way S3 wants, and headers (keyed by lowercase name) are the
+// ones that we sign, which must include host and x-amz-date.
+func (s *S3StateStore) authorization(method, uri, query string, headers map[string]string, payloadHash string, now time.Time) string {
+	amzDate := now.UTC().Format(s3DateTimeFormat)
+	date := now.UTC().Format("20060102")
+	signedNames := make([]string, 0, len(headers))
+	for k := range headers {
+		signedNames = append(signedNames, k)
+	}
+	slices.Sort(signedNames)
+	var canonicalHeaders strings.Builder
+	for _, k := range signedNames {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(signedNames, ";")
+	canonicalRequest := strings.Join([]string{
+		method,
+		uri,
+		query,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature)
+}
+
+// Build and sign a request for key (or the bucket itself if key is empty).
+// We don't sign payloads; headers are signed if they're host or x-amz-*.
+func (s *S3StateStore) newRequest(method, key string, query url.Values, body io.Reader, headers map[string]string) (*http.Request, error) {
+	u := *s.endpoint
+	objectPath := s.objectPath(key)
+	u.Path = s.endpoint.Path + objectPath
+	u.RawPath = s3Escape(s.endpoint.Path, false) + s3Escape(objectPath, false)
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	queryParts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		queryParts = append(queryParts, s3Escape(k, true)+"="+s3Escape(query.Get(k), true))
+	}
+	u.RawQuery = strings.Join(queryParts, "&")
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	req.Header.Set("x-amz-date", now.Format(s3DateTimeFormat))
+	req.Header.Set("x-amz-content-sha256", "UNSIGNED-PAYLOAD")
+	if s.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.sessionToken)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	signed := map[string]string{"host": u.Host}
+	for k := range req.Header {
+		lower := strings.ToLower(k)
+		if strings.HasPrefix(lower, "x-amz-") {
+			signed[lower] = strings.TrimSpace(req.Header.Get(k))
+		}
+	}
+	req.Header.Set("Authorization", s.authorization(method, u.RawPath, u.RawQuery, signed, "UNSIGNED-PAYLOAD", now))
+	return req, nil
+}
+
+// Sends the request; anything other than a 2xx is an error (404s wrap
+// fs.ErrNotExist). On success the caller owns the response body.
+func (s *S3StateStore) do(req *http.Request, name string) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: strings.ToLower(req.Method), Path: name, Err: fs.ErrNotExist}
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, name, resp.Status, bytes.TrimSpace(detail))
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3StateStore) List(pattern string) ([]string, error) {
+	names := make([]string, 0)
+	continuationToken := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.key(listPrefix(pattern)))
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+		req, err := s.newRequest(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, pattern)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding s3 listing: %w", err)
+		}
+		for _, c := range result.Contents {
+			name := strings.TrimPrefix(c.Key, s.prefix)
+			matched, err := path.Match(pattern, name)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				names = append(names, name)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = result.NextContinuationToken
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+func (s *S3StateStore) Get(name string) (io.ReadCloser, int64, error) {
+	req, err := s.newRequest(http.MethodGet, s.key(name), nil, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := s.do(req, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+func (s *S3StateStore) GetRange(name string, offset, length int64) ([]byte, error) {
+	if length == 0 {
+		return []byte{}, nil
+	}
+	headers := map[string]string{"Range": fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)}
+	req, err := s.newRequest(http.MethodGet, s.key(name), nil, nil, headers)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body := resp.Body
+	if resp.StatusCode != http.StatusPartialContent {
+		// Whoever we're talking to ignored our range and sent everything
+		if _, err := io.CopyN(io.Discard, body, offset); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(body, buf); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf, nil
+}
+
+func (s *S3StateStore) putReader(ctx context.Context, name string, body io.Reader, size int64) error {
+	req, err := s.newRequest(http.MethodPut, s.key(name), nil, body, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = size
+	resp, err := s.do(req, name)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// We need the object's size before we upload it, and snapshots are too big
+// to hold in memory twice, so we stage them in a temp file. S3 only makes an
+// object visible once the whole PUT has succeeded.
+func (s *S3StateStore) Put(name string, writeData func(writer io.Writer) error) error {
+	tmp, err := os.CreateTemp("", "omcb-s3-put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := writeData(tmp); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return s.putReader(context.Background(), name, tmp, size)
+}
+
+func (s *S3StateStore) Delete(name string) error {
+	req, err := s.newRequest(http.MethodDelete, s.key(name), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, name)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// There's no rename in S3, so this is a server-side copy and a delete
+func (s *S3StateStore) Rename(oldName, newName string) error {
+	headers := map[string]string{
+		"x-amz-copy-source": s3Escape(s.objectPath(s.key(oldName)), false),
+	}
+	req, err := s.newRequest(http.MethodPut, s.key(newName), nil, nil, headers)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, oldName)
+	if err != nil {
+		return err
+	}
+	// A copy can fail after we've already got a 200, in which case the
+	// error is in the body
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(body, []byte("<Error>")) {
+		return fmt.Errorf("s3 copy %s -> %s: %s", oldName, newName, bytes.TrimSpace(body))
+	}
+	return s.Delete(oldName)
+}
+
+// Unlike the other stores we don't check that name is new: that'd be a
+// request on the BTD's main loop, and the only thing that appends is the move
+// log, whose names include the time (in ns) that we opened them.
+func (s *S3StateStore) Append(name string) (AppendableObject, error) {
+	o := &s3AppendableObject{
+		store:   s,
+		name:    name,
+		kick:    make(chan struct{}, 1),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go o.uploadInBackground()
+	return o, nil
+}
+
+// Flush doesn't wait for anything: it asks our uploader to PUT everything
+// written so far (at most once every S3_MOVE_LOG_UPLOAD_INTERVAL) and
+// returns the error from the last upload that failed, if there's been one
+// since we last asked. So a move log in S3 is durable as of the last upload
+// that worked, rather than as of the last Flush, and a slow or unreachable
+// endpoint can't hold up the BTD. If we crash, we lose whatever we wrote
+// after that upload (a bit over S3_MOVE_LOG_UPLOAD_INTERVAL of moves, or
+// more if uploads were failing). Close does wait, for a final upload; the
+// BTD seals segments off its main loop.
+type s3AppendableObject struct {
+	store   *S3StateStore
+	name    string
+	kick    chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+
+	mu          sync.Mutex
+	buf         bytes.Buffer
+	uploadedLen int
+	err         error
+}
+
+func (o *s3AppendableObject) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+func (o *s3AppendableObject) Flush() error {
+	select {
+	case o.kick <- struct{}{}:
+	default:
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	err := o.err
+	o.err = nil
+	return err
+}
+
+func (o *s3AppendableObject) uploadInBackground() {
+	defer close(o.done)
+	var lastUpload time.Time
+	for {
+		select {
+		case <-o.kick:
+		case <-o.closing:
+			return
+		}
+		if wait := S3_MOVE_LOG_UPLOAD_INTERVAL - time.Since(lastUpload); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-o.closing:
+				return
+			}
+		}
+		lastUpload = time.Now()
+		o.upload()
+	}
+}
+
+func (o *s3AppendableObject) upload() error {
+	o.mu.Lock()
+	if o.buf.Len() == o.uploadedLen {
+		o.mu.Unlock()
+		return nil
+	}
+	data := slices.Clone(o.buf.Bytes())
+	o.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3_MOVE_LOG_REQUEST_TIMEOUT)
+	defer cancel()
+	err := o.store.putReader(ctx, o.name, bytes.NewReader(data), int64(len(data)))
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err != nil {
+		o.err = err
+	} else {
+		o.uploadedLen = len(data)
+	}
+	return err
+}
+
+func (o *s3AppendableObject) Close() error {
+	close(o.closing)
+	<-o.done
+	return o.upload()
+}
@@ -23,7 +23,6 @@ import (
 	"github.com/puzpuzpuz/xsync/v4"
 	"github.com/rs/zerolog"
 	"golang.org/x/time/rate"
-	"google.golang.org/protobuf/proto"
 )
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
@@ -61,12 +60,21 @@ type Server struct {
 	boardToDiskHandler        *BoardToDiskHandler
 	clientManager             *ClientManager
 	minimapAggregator         *MinimapAggregator
+	boardOutcomes             *BoardOutcomeTracker
+	activityHeatmap           *ActivityHeatmap
 	moveRequests              chan MoveRequest
 	adoptionRequests          chan adoptionRequest
 	bulkCaptureRequests       chan bulkCaptureRequest
+	rollbackRequests          chan rollbackCommand
+	rollbackPlanning          sync.Mutex
+	setPiecesRequests         chan setPiecesCommand
 	upgrader                  websocket.Upgrader
 	currentStats              jsoniter.RawMessage
 	currentStatsMutex         sync.RWMutex
+	leaderboards              *Leaderboards
+	events                    *BoardEventBus
+	currentLeaderboards       jsoniter.RawMessage
+	currentLeaderboardsMutex  sync.RWMutex
 	recentCaptures            *RecentCaptures
 	recentWhiteCapturesResult jsoniter.RawMessage
 	recentBlackCapturesResult jsoniter.RawMessage
@@ -86,13 +94,27 @@ type Server struct {
 	gameOver                  atomic.Bool
 	bannedIpsMutex            sync.RWMutex
 	bannedIps                 map[string]bool
+	replica                   *Replica
+	sessions                  *SessionStore
+	playerTokens              *PlayerTokenIssuer
+	playerStats               *PlayerStatsTracker
+	zoneHistory               *ZoneHistory
 }
 
 func NewServer(stateDir string) *Server {
+	if *replicaOf != "" {
+		if err := BootstrapReplica(*replicaOf, stateDir); err != nil {
+			panic(fmt.Sprintf("Error bootstrapping replica: %s", err))
+		}
+	}
 	boardToDiskHandler, err := NewBoardToDiskHandler(stateDir)
 	if err != nil {
 		panic(fmt.Sprintf("Error getting btd: %s", err))
 	}
+	playerTokens, err := NewPlayerTokenIssuerFromFlags(boardToDiskHandler.store)
+	if err != nil {
+		panic(fmt.Sprintf("Error setting up player tokens: %s", err))
+	}
 	backgroundJobCtx, backgroundJobCancel := context.WithCancel(context.Background())
 	backgroundJobWg := &sync.WaitGroup{}
 
@@ -102,15 +124,20 @@ func NewServer(stateDir string) *Server {
 	processMovesCtx, processMovesCancel := context.WithCancel(backgroundJobCtx)
 
 	board := boardToDiskHandler.GetLiveBoard()
+	activityHeatmap := NewActivityHeatmap()
 	httpLogger := NewCoreLogger().With().Str("kind", "http").Logger()
 	s := &Server{
 		board:               board,
 		boardToDiskHandler:  boardToDiskHandler,
 		clientManager:       NewClientManager(),
-		minimapAggregator:   NewMinimapAggregator(),
+		minimapAggregator:   NewMinimapAggregator(activityHeatmap),
+		boardOutcomes:       NewBoardOutcomeTracker(),
+		activityHeatmap:     activityHeatmap,
 		moveRequests:        make(chan MoveRequest, 1024),
 		adoptionRequests:    make(chan adoptionRequest, 128),
 		bulkCaptureRequests: make(chan bulkCaptureRequest, 16),
+		rollbackRequests:    make(chan rollbackCommand),
+		setPiecesRequests:   make(chan setPiecesCommand),
 		recentCaptures:      NewRecentCaptures(),
 		httpLogger:          httpLogger,
 		coreLogger:          NewCoreLogger(),
@@ -146,20 +173,49 @@ func NewServer(stateDir string) *Server {
 		rootClientCancel:    rootClientCancel,
 		clientWg:            clientWg,
 		gameOver:            atomic.Bool{},
+		sessions:            NewSessionStore(),
+		playerTokens:        playerTokens,
+		playerStats:         boardToDiskHandler.GetLivePlayerStats(),
+		zoneHistory:         NewZoneHistory(*zoneHistorySize, board.seqNum),
+		leaderboards:        NewLeaderboards(),
 	}
 	s.gameOver.Store(false)
+	if *replicationBacklog > 0 {
+		boardToDiskHandler.replication = newReplicationHub(*replicationBacklog, board.seqNum)
+	}
+	if *replicaOf != "" {
+		s.replica = NewReplica(*replicaOf, board.seqNum)
+	}
+	s.events = NewBoardEventBus(board.seqNum)
+	s.subscribeToBoardEvents()
 	return s
 }
 
 func (s *Server) Run() {
 	s.minimapAggregator.Initialize(s.board)
+	s.boardOutcomes.Initialize(s.board, s.board.rules.TracksBoardOutcomes())
+	s.leaderboards.Initialize(s.board, s.playerStats)
+	if err := s.applyRuleFlags(); err != nil {
+		panic(fmt.Sprintf("Error applying rule flags: %s", err))
+	}
 	go s.ClearOldLimits()
 	go s.processMoves()
+	s.refreshActivityPeriodically()
 	go s.refreshMinimapPeriodically()
 	s.refreshStatsPeriodically()
+	s.refreshLeaderboardsPeriodically()
 	s.refreshRecentCapturesPeriodically()
 	go s.boardToDiskHandler.RunForever()
 	go s.refreshBannedIPsPeriodically()
+	if s.replica != nil {
+		go s.replica.RunForever()
+	}
+}
+
+// Replicas don't take players (or internal requests that change the board)
+// until they're promoted
+func (s *Server) isStandby() bool {
+	return s.replica != nil && !s.replica.promoted.Load()
 }
 
 func (s *Server) loadBannedIPOnce() {
@@ -250,10 +306,15 @@ func (s *Server) GracefulShutdown() {
 			log.Printf("GRACEFUL SHUTDOWN: Clients finished")
 		}
 
+		if s.replica != nil {
+			s.replica.cancel()
+		}
 		log.Printf("GRACEFUL SHUTDOWN: Waiting for background jobs to finish")
 		s.backgroundJobCancel()
 		s.backgroundJobWg.Wait()
-		log.Printf("GRACEFUL SHUTDOWN: 	Background jobs finished, shutting down BTD")
+		log.Printf("GRACEFUL SHUTDOWN: 	Background jobs finished, draining board events")
+		s.events.Close()
+		log.Printf("GRACEFUL SHUTDOWN: 	Board events drained, shutting down BTD")
 		s.boardToDiskHandler.GracefulShutdown()
 	}
 }
@@ -282,6 +343,7 @@ func (s *Server) ClearOldLimits() {
 				}
 				return true
 			})
+			s.sessions.ExpireOld()
 		}
 	}
 }
@@ -348,38 +410,25 @@ func (s *Server) refreshMinimapPeriodically() {
 
 func (s *Server) refreshStatsOnce() {
 	type StatsUpdate struct {
-		Type                 string `json:"type"`
-		TotalMoves           uint64 `json:"totalMoves"`
-		WhitePiecesRemaining uint32 `json:"whitePiecesRemaining"`
-		BlackPiecesRemaining uint32 `json:"blackPiecesRemaining"`
-		WhiteKingsRemaining  uint32 `json:"whiteKingsRemaining"`
-		BlackKingsRemaining  uint32 `json:"blackKingsRemaining"`
-		ConnectedUsers       uint32 `json:"connectedUsers"`
-		Seqnum               uint64 `json:"seqnum"`
-		Winner               string `json:"winner"`
+		Type                  string `json:"type"`
+		TotalMoves            uint64 `json:"totalMoves"`
+		WhitePiecesRemaining  uint32 `json:"whitePiecesRemaining"`
+		BlackPiecesRemaining  uint32 `json:"blackPiecesRemaining"`
+		WhiteKingsRemaining   uint32 `json:"whiteKingsRemaining"`
+		BlackKingsRemaining   uint32 `json:"blackKingsRemaining"`
+		ConnectedUsers        uint32 `json:"connectedUsers"`
+		Seqnum                uint64 `json:"seqnum"`
+		Winner                string `json:"winner"`
+		WhiteCheckmatedBoards uint32 `json:"whiteCheckmatedBoards"`
+		BlackCheckmatedBoards uint32 `json:"blackCheckmatedBoards"`
+		WhiteStalematedBoards uint32 `json:"whiteStalematedBoards"`
+		BlackStalematedBoards uint32 `json:"blackStalematedBoards"`
 	}
 
 	boardStats := s.board.GetStats()
-	winner := ""
-	noWhiteKings := boardStats.WhiteKingsRemaining == 0
-	noBlackKings := boardStats.BlackKingsRemaining == 0
-	onlyWhiteKings := boardStats.WhitePiecesRemaining == boardStats.WhiteKingsRemaining
-	onlyBlackKings := boardStats.BlackPiecesRemaining == boardStats.BlackKingsRemaining
-
-	gameOver := false
-	if noWhiteKings && noBlackKings {
-		gameOver = true
-		winner = "draw"
-	} else if noWhiteKings {
-		gameOver = true
-		winner = "black"
-	} else if noBlackKings {
-		gameOver = true
-		winner = "white"
-	} else if onlyWhiteKings && onlyBlackKings {
-		gameOver = true
-		winner = "draw"
-	}
+	s.boardOutcomes.AddCounts(&boardStats)
+	winner := s.board.rules.Winner(boardStats)
+	gameOver := winner != ""
 
 	if gameOver && s.gameOver.CompareAndSwap(false, true) {
 		log.Printf("Detected game over from refreshStatsOnce - winner: %s", winner)
@@ -392,15 +441,19 @@ func (s *Server) refreshStatsOnce() {
 	}
 
 	allStats := StatsUpdate{
-		Type:                 "globalStats",
-		TotalMoves:           boardStats.TotalMoves,
-		WhitePiecesRemaining: boardStats.WhitePiecesRemaining,
-		BlackPiecesRemaining: boardStats.BlackPiecesRemaining,
-		WhiteKingsRemaining:  boardStats.WhiteKingsRemaining,
-		BlackKingsRemaining:  boardStats.BlackKingsRemaining,
-		ConnectedUsers:       uint32(s.clientManager.GetClientCount()),
-		Seqnum:               boardStats.Seqnum,
-		Winner:               winner,
+		Type:                  "globalStats",
+		TotalMoves:            boardStats.TotalMoves,
+		WhitePiecesRemaining:  boardStats.WhitePiecesRemaining,
+		BlackPiecesRemaining:  boardStats.BlackPiecesRemaining,
+		WhiteKingsRemaining:   boardStats.WhiteKingsRemaining,
+		BlackKingsRemaining:   boardStats.BlackKingsRemaining,
+		ConnectedUsers:        uint32(s.clientManager.GetClientCount()),
+		Seqnum:                boardStats.Seqnum,
+		Winner:                winner,
+		WhiteCheckmatedBoards: boardStats.WhiteCheckmatedBoards,
+		BlackCheckmatedBoards: boardStats.BlackCheckmatedBoards,
+		WhiteStalematedBoards: boardStats.WhiteStalematedBoards,
+		BlackStalematedBoards: boardStats.BlackStalematedBoards,
 	}
 
 	serialized, err := json.Marshal(allStats)
@@ -438,6 +491,11 @@ func (s *Server) processMoves() {
 	s.backgroundJobWg.Add(1)
 	defer s.backgroundJobWg.Done()
 
+	var replicatedRequests chan boardToDiskRequest
+	if s.replica != nil {
+		replicatedRequests = s.replica.requests
+	}
+
 	for {
 		select {
 		case <-s.processMovesCtx.Done():
@@ -461,7 +519,7 @@ func (s *Server) processMoves() {
 				s.processMovesCancel()
 			}
 
-			s.boardToDiskHandler.AddMove(&moveReq.Move)
+			s.publishBoardEvent(boardToDiskRequest{Move: &moveReq.Move}, moveResult.ChangeSet(), &moveResult)
 
 			if moveResult.CapturedPiece.Piece.IsEmpty() {
 				moveMetadata := MoveMetadata{
@@ -490,128 +548,91 @@ func (s *Server) processMoves() {
 					moveResult.CapturedPiece.Piece.ID)
 			}
 
-			// CR-someday nroyalty: is there a way we can avoid the overhead of re-serializing a move
-			// for each client here? It's annoying that we might end up doing the same serialization
-			// for 100 different clients if they're looking at the same zones.
-			//
-			// CR-someday nroyalty: I THINK this can't actually matter, but there's a bug here where
-			// you castle queenside and that results in us moving a rook that's on the edge
-			// of your vision, but the king isn't in your vision and so we don't tell you about it
-			// I think this is fine...but I need to think about it some more.
-			//
-			// nroyalty: lol I found a funny client rendering bug (or set of bugs) as a result
-			// of testing this, but I failed to actually test it. Let's not worry about it.
-			//
-			// Ok I think this doesn't matter in practice regardless but since our zones
-			// are slightly bigger than our snapshots it super doesn't matter
-			go func() {
-				numMoved := len(moveResult.MovedPieces)
-				if numMoved < 1 {
-					log.Printf("IMPOSSIBLE? moveResult length < 1")
-					return
-				}
-				s.minimapAggregator.UpdateForMoveResult(moveResult)
-				capturedPiece := moveResult.CapturedPiece
-				movedPiecesProto := make([]*protocol.PieceDataForMove, 0, numMoved)
-				for _, movedPiece := range moveResult.MovedPieces {
-					piece := movedPiece.Piece
-
-					movedPiecesProto = append(movedPiecesProto, &protocol.PieceDataForMove{
-						X:      uint32(movedPiece.ToX),
-						Y:      uint32(movedPiece.ToY),
-						Seqnum: moveResult.Seqnum,
-						Piece:  piece.ToProtocolAlloc(),
-					})
-				}
-
-				var pieceCapture *protocol.PieceCapture = nil
-				if !capturedPiece.Piece.IsEmpty() {
-					s.recentCaptures.AddCapture(&moveResult.CapturedPiece)
-					pieceCapture = &protocol.PieceCapture{
-						CapturedPieceId: capturedPiece.Piece.ID,
-						Seqnum:          moveResult.Seqnum,
-					}
-				}
-				affectedZones := s.clientManager.GetAffectedZones(moveReq.Move)
-				interestedClients := s.clientManager.GetClientsForZones(affectedZones)
-				// Each zone is 50x50 and a client is typically in 9 of them, so we
-				// offer them each move in a 150x150 zone that is not necessarily centered
-				// exactly on where they are. Depending on their position within their
-				// central zone, plenty of moves aren't going to be relevant to them
-				// (outside of their current snapshot window)
-				//
-				// I suppose it depends on access patterns in some way, but I think
-				// in practice checking before sending a move to a client is just gonna
-				// be faster than slamming out every move, given that I think we should
-				// drop a reasonable amount of moves with this check.
-				//
-				// potential bug around castle notification again here?
-				for client := range interestedClients {
-					if client.IsInterestedInMove(moveReq.Move) {
-						client.AddMovesToBuffer(movedPiecesProto, pieceCapture)
-					}
-				}
-				s.clientManager.ReturnClientMap(interestedClients)
-			}()
-
 		case adoptionReq := <-s.adoptionRequests:
 			adoptionResult, err := s.board.Adopt(&adoptionReq)
 			if err != nil || adoptionResult == nil {
 				continue
 			}
-			s.boardToDiskHandler.AddAdoption(&adoptionReq)
-
-			go func() {
-				affectedZones := s.clientManager.AffectedZonesForAdoption(&adoptionReq)
-				interestedClients := s.clientManager.GetClientsForZones(affectedZones)
-				m := &protocol.ServerMessage{
-					Payload: &protocol.ServerMessage_Adoption{
-						Adoption: &protocol.ServerAdoption{
-							AdoptedIds: adoptionResult.AdoptedPieces,
-						},
-					},
-				}
-				message, err := proto.Marshal(m)
-				if err != nil {
-					log.Printf("Error marshalling adoption: %v", err)
-					return
-				}
-
-				for client := range interestedClients {
-					client.SendAdoption(message)
-				}
-				s.clientManager.ReturnClientMap(interestedClients)
-			}()
+			s.publishBoardEvent(boardToDiskRequest{AdoptionRequest: &adoptionReq}, adoptionResult, nil)
 
 		case bulkCaptureReq := <-s.bulkCaptureRequests:
-			bulkCaptureMsg, err := s.board.DoBulkCapture(&bulkCaptureReq)
-			if err != nil || bulkCaptureMsg == nil {
+			bulkCaptureResult, err := s.board.DoBulkCapture(&bulkCaptureReq)
+			if err != nil || bulkCaptureResult == nil {
 				continue
 			}
-			s.boardToDiskHandler.AddBulkCapture(&bulkCaptureReq)
+			s.publishBoardEvent(boardToDiskRequest{BulkCaptureRequest: &bulkCaptureReq}, bulkCaptureResult, nil)
 
-			go func() {
-				m := &protocol.ServerMessage{
-					Payload: &protocol.ServerMessage_BulkCapture{
-						BulkCapture: bulkCaptureMsg,
-					},
-				}
-				message, err := proto.Marshal(m)
-				if err != nil {
-					log.Printf("Error marshalling bulk capture: %v", err)
-					return
-				}
-				affectedZones := s.clientManager.AffectedZonesForBulkCapture(&bulkCaptureReq)
-				interestedClients := s.clientManager.GetClientsForZones(affectedZones)
-				for client := range interestedClients {
-					client.SendBulkCapture(message)
-				}
-				s.clientManager.ReturnClientMap(interestedClients)
-			}()
+		case cmd := <-s.rollbackRequests:
+			s.applyRollback(cmd)
+
+		case cmd := <-s.setPiecesRequests:
+			s.applySetPieces(cmd)
+
+		case req := <-replicatedRequests:
+			s.applyReplicatedRequest(req)
 		}
 	}
 }
 
+// We don't have any clients while we're a replica, but everything else that
+// subscribes to board events (persistence, the minimap, recent captures and
+// so on) stays up to date for when we're promoted.
+func (s *Server) applyReplicatedRequest(req boardToDiskRequest) {
+	if s.replica.diverged.Load() {
+		return
+	}
+	seqnum := s.board.seqNum
+	switch {
+	case req.Move != nil:
+		moveResult := s.board.ValidateAndApplyMove__NOTTHREADSAFE(*req.Move)
+		if !moveResult.Valid {
+			break
+		}
+		seqnum = moveResult.Seqnum
+		if moveResult.WinningMove {
+			log.Printf("Replicated the winning move!")
+			s.gameOver.Store(true)
+		}
+		s.publishBoardEvent(req, moveResult.ChangeSet(), &moveResult)
+	case req.AdoptionRequest != nil:
+		adoptionResult, err := s.board.Adopt(req.AdoptionRequest)
+		if err != nil || adoptionResult == nil {
+			break
+		}
+		seqnum = adoptionResult.Seqnum
+		s.publishBoardEvent(req, adoptionResult, nil)
+	case req.BulkCaptureRequest != nil:
+		bulkCaptureResult, err := s.board.DoBulkCapture(req.BulkCaptureRequest)
+		if err != nil || bulkCaptureResult == nil {
+			break
+		}
+		seqnum = bulkCaptureResult.Seqnum
+		s.publishBoardEvent(req, bulkCaptureResult, nil)
+	case req.RollbackRequest != nil:
+		rollbackResult, err := s.board.ApplyRollback(req.RollbackRequest)
+		if err != nil {
+			break
+		}
+		seqnum = rollbackResult.Seqnum
+		s.publishBoardEvent(req, rollbackResult, nil)
+	case req.SetPiecesRequest != nil:
+		setPiecesResult, err := s.board.SetPieces(req.SetPiecesRequest)
+		if err != nil {
+			break
+		}
+		seqnum = setPiecesResult.Seqnum
+		s.publishBoardEvent(req, setPiecesResult, nil)
+	case req.RuleChangeRequest != nil:
+		ruleChangeResult, err := s.board.ChangeRules(req.RuleChangeRequest)
+		if err != nil {
+			break
+		}
+		seqnum = ruleChangeResult.Seqnum
+		s.publishBoardEvent(req, ruleChangeResult, nil)
+	}
+	s.replica.applied(req, seqnum)
+}
+
 func applyColorPref(colorPref ColorPreference) bool {
 	switch colorPref {
 	case ColorPreferenceWhite:
@@ -652,6 +673,23 @@ func (s *Server) DetermineColor(colorPref ColorPreference) bool {
 	return applyColorPref(colorPref)
 }
 
+// A player with a valid token stays on their color until their binding
+// runs out; everyone else gets whatever DetermineColor picks.
+func (s *Server) DeterminePlayer(playerToken string, colorPref ColorPreference) PlayerIdentity {
+	if playerToken == "" {
+		return s.playerTokens.NewPlayer(s.DetermineColor(colorPref))
+	}
+	player, err := s.playerTokens.Verify(playerToken)
+	if err != nil {
+		s.coreLogger.Info().Str("reject", "player-token").Send()
+		return s.playerTokens.NewPlayer(s.DetermineColor(colorPref))
+	}
+	if player.colorIsBound(time.Now()) {
+		return player
+	}
+	return s.playerTokens.Rebind(player, s.DetermineColor(colorPref))
+}
+
 // nroyalty: I *think* doing this is actually a bad idea, since these zones
 // would get cleared out quickly over time. Let's just rely on active client
 // positions (which we could also serve up as an endpoint?)
@@ -685,6 +723,14 @@ func IncrOrDecrPosition(n uint16) uint16 {
 }
 
 func (s *Server) GetDefaultCoords(playingWhite bool) Position {
+	if *spawnNearActivity {
+		if pos, ok := s.activityHeatmap.RandomHotspot(); ok {
+			pos.X = IncrOrDecrPosition(pos.X)
+			pos.Y = IncrOrDecrPosition(pos.Y)
+			return pos
+		}
+	}
+
 	if pos, ok := s.clientManager.GetRandomActiveClientPosition(); ok {
 		pos.X = IncrOrDecrPosition(pos.X)
 		pos.Y = IncrOrDecrPosition(pos.Y)
@@ -811,6 +857,10 @@ func (s *Server) ServeWs(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
 		return
 	}
+	if s.isStandby() {
+		http.Error(w, "Server is a standby", http.StatusServiceUnavailable)
+		return
+	}
 
 	ipString, ipv6 := s.GetIPString(r)
 	limitResult := s.maybeAddNewIp(ipString, ipv6)
@@ -849,6 +899,14 @@ func (s *Server) ServeWs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// A reconnecting client passes back the session token from its initial
+	// state along with the last seqnum that it saw
+	sessionToken := r.URL.Query().Get("session")
+	resumeAfterSeqnum, err := strconv.ParseUint(r.URL.Query().Get("seqnum"), 10, 64)
+	if err != nil {
+		resumeAfterSeqnum = 0
+	}
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
@@ -857,17 +915,41 @@ func (s *Server) ServeWs(w http.ResponseWriter, r *http.Request) {
 
 	softLimited := limitResult == AddIpResultSoftLimitExceeded
 	client := NewClient(conn, s, ipString, softLimited, s.clientWg, s.rootClientCtx)
-	playingWhite := s.DetermineColor(colorPref)
+	sess, resumed := s.sessions.Lookup(sessionToken)
+	if !resumed {
+		player := s.DeterminePlayer(r.URL.Query().Get("player"), colorPref)
+		sessionToken, sess = s.sessions.Create(player, s.playerTokens.Issue(player))
+		resumeAfterSeqnum = 0
+	}
+	client.AttachSession(sessionToken, sess)
+	playingWhite := sess.player.PlayingWhite
 	pos := s.GetMaybeRequestedCoords(requestedXCoord, requestedYCoord, playingWhite)
 	s.clientManager.RegisterClient(client, pos, playingWhite)
-	go client.Run(playingWhite, pos)
+	go client.Run(playingWhite, pos, resumeAfterSeqnum)
 }
 
 func (s *Server) ServeMinimap(w http.ResponseWriter, r *http.Request) {
 	s.httpLogger.Info().
 		Str("rpc", "ServeMinimap").
 		Send()
-	aggregation := s.minimapAggregator.GetLastAggregation()
+	layer := MinimapLayerBalance
+	cellSize := CELL_SIZE
+	if l := r.URL.Query().Get("layer"); l != "" {
+		layer = l
+	}
+	if c := r.URL.Query().Get("cellSize"); c != "" {
+		parsed, err := strconv.Atoi(c)
+		if err != nil {
+			http.Error(w, "Invalid cellSize", http.StatusBadRequest)
+			return
+		}
+		cellSize = parsed
+	}
+	aggregation, ok := s.minimapAggregator.GetAggregation(layer, cellSize)
+	if !ok {
+		http.Error(w, "Unknown layer or cellSize", http.StatusBadRequest)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Content-Encoding", "zstd")
 	w.Header().Set("Cache-Control", "public, max-age=2, s-maxage=25")
@@ -925,6 +1007,11 @@ func (s *Server) ServeAdoption(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.isStandby() {
+		http.Error(w, "Server is a standby", http.StatusServiceUnavailable)
+		return
+	}
+
 	oc := OnlyColorFromString(req.OnlyColor)
 
 	if req.X >= BOARD_SIZE || req.Y >= BOARD_SIZE {
@@ -965,6 +1052,11 @@ func (s *Server) ServeBulkCapture(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.isStandby() {
+		http.Error(w, "Server is a standby", http.StatusServiceUnavailable)
+		return
+	}
+
 	if req.X >= BOARD_SIZE || req.Y >= BOARD_SIZE {
 		http.Error(w, "Coordinates out of bounds", http.StatusBadRequest)
 		return
@@ -978,6 +1070,106 @@ func (s *Server) ServeBulkCapture(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func (s *Server) ServePieceHistory(w http.ResponseWriter, r *http.Request) {
+	s.httpLogger.Info().
+		Str("rpc", "ServePieceHistory").
+		Send()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type PieceHistoryRequest struct {
+		PieceID uint32 `json:"pieceId"`
+		Pass    string `json:"pass"`
+	}
+
+	var req PieceHistoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Pass != *internalPass {
+		http.Error(w, "no", http.StatusNotFound)
+		return
+	}
+
+	history, err := s.boardToDiskHandler.PieceHistory(req.PieceID)
+	if err != nil {
+		s.httpLogger.Error().Str("error_kind", "reading_piece_history").AnErr("err", err).Send()
+		http.Error(w, "Error reading history", http.StatusInternalServerError)
+		return
+	}
+
+	type Capture struct {
+		ID      uint32 `json:"id"`
+		Type    string `json:"type"`
+		IsWhite bool   `json:"isWhite"`
+	}
+	type PieceHistoryEntry struct {
+		Seqnum      uint64   `json:"seqnum"`
+		TimestampNs int64    `json:"timestampNs"`
+		Kind        string   `json:"kind"`
+		MoveType    string   `json:"moveType,omitempty"`
+		FromX       uint16   `json:"fromX"`
+		FromY       uint16   `json:"fromY"`
+		ToX         uint16   `json:"toX"`
+		ToY         uint16   `json:"toY"`
+		Captured    *Capture `json:"captured,omitempty"`
+		CapturedBy  *Capture `json:"capturedBy,omitempty"`
+	}
+
+	entries := make([]PieceHistoryEntry, 0, len(history.Entries))
+	for _, h := range history.Entries {
+		entry := PieceHistoryEntry{
+			Seqnum:      h.Seqnum,
+			TimestampNs: h.TimestampNs,
+			Kind:        h.Kind.String(),
+			FromX:       h.FromX,
+			FromY:       h.FromY,
+			ToX:         h.ToX,
+			ToY:         h.ToY,
+		}
+		var other *Capture
+		if h.Other != EmptyEncodedPiece {
+			p := PieceOfEncodedPiece(h.Other)
+			other = &Capture{ID: p.ID, Type: p.Type.String(), IsWhite: p.IsWhite}
+		}
+		switch h.Kind {
+		case PieceHistoryMoved:
+			entry.MoveType = protocol.MoveType(h.MoveType).String()
+			entry.Captured = other
+		case PieceHistoryCaptured:
+			entry.CapturedBy = other
+		}
+		entries = append(entries, entry)
+	}
+
+	type SeqnumRange struct {
+		FirstSeq uint64 `json:"firstSeq"`
+		LastSeq  uint64 `json:"lastSeq"`
+	}
+	// Incomplete means that we have sealed move logs without an index, so
+	// entries may be missing from the ranges in unindexed
+	type PieceHistoryResponse struct {
+		Entries    []PieceHistoryEntry `json:"entries"`
+		Incomplete bool                `json:"incomplete"`
+		Unindexed  []SeqnumRange       `json:"unindexed"`
+	}
+	resp := PieceHistoryResponse{
+		Entries:    entries,
+		Incomplete: len(history.Unindexed) > 0,
+		Unindexed:  make([]SeqnumRange, 0, len(history.Unindexed)),
+	}
+	for _, r := range history.Unindexed {
+		resp.Unindexed = append(resp.Unindexed, SeqnumRange{FirstSeq: r.FirstSeq, LastSeq: r.LastSeq})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request, staticDir string) {
 	if s.shutdownBegan.Load() {
 		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
@@ -999,12 +1191,42 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request, staticDir str
 	} else if r.URL.Path == "/api/recently-captured/black" {
 		s.ServeRecentCaptures(w, r, false)
 		return
+	} else if r.URL.Path == "/api/activity" {
+		s.ServeActivity(w, r)
+		return
+	} else if r.URL.Path == "/api/player-stats" {
+		s.ServePlayerStats(w, r)
+		return
+	} else if r.URL.Path == "/api/leaderboards" {
+		s.ServeLeaderboards(w, r)
+		return
 	} else if r.URL.Path == "/internal/adoption" {
 		s.ServeAdoption(w, r)
 		return
 	} else if r.URL.Path == "/internal/bulk-capture" {
 		s.ServeBulkCapture(w, r)
 		return
+	} else if r.URL.Path == "/internal/rollback" {
+		s.ServeRollback(w, r)
+		return
+	} else if r.URL.Path == "/internal/set-pieces" {
+		s.ServeSetPieces(w, r)
+		return
+	} else if r.URL.Path == "/internal/piece-history" {
+		s.ServePieceHistory(w, r)
+		return
+	} else if r.URL.Path == "/internal/replication/stream" {
+		s.ServeReplicationStream(w, r)
+		return
+	} else if r.URL.Path == "/internal/replication/snapshot" {
+		s.ServeReplicationSnapshot(w, r)
+		return
+	} else if r.URL.Path == "/internal/replication/status" {
+		s.ServeReplicationStatus(w, r)
+		return
+	} else if r.URL.Path == "/internal/replication/promote" {
+		s.ServeReplicationPromote(w, r)
+		return
 	}
 
 	switch r.URL.Path {
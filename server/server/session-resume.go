@@ -0,0 +1,223 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"one-million-chessboards/protocol"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// Reconnecting clients. Every client gets a session token with its initial
+// state. If its websocket drops and it reconnects with that token (and the
+// last seqnum that it saw) it stays the same player, and if we still have
+// every move around it since that seqnum we replay those moves instead of
+// sending a whole new snapshot.
+//
+// Sessions only live in memory, so a restart (or a promoted replica) sends
+// everyone a snapshot and a fresh token, which is fine.
+
+var sessionTTL = flag.Duration("session-ttl", 10*time.Minute, "Forget a session this long after its last connection closes")
+var zoneHistorySize = flag.Int("zone-history", 64, "Keep this many recent moves per zone for resuming clients (0 always sends resuming clients a snapshot)")
+
+// Past this it's cheaper to just send a snapshot
+const MAX_RESUME_EVENTS = 1024
+
+type session struct {
+	player      PlayerIdentity
+	playerToken string
+	connections atomic.Int32
+	lastSeenNs  atomic.Int64
+}
+
+func (sess *session) connect() {
+	sess.connections.Add(1)
+	sess.lastSeenNs.Store(time.Now().UnixNano())
+}
+
+func (sess *session) disconnect() {
+	sess.connections.Add(-1)
+	sess.lastSeenNs.Store(time.Now().UnixNano())
+}
+
+func (sess *session) expired(cutoffNs int64) bool {
+	return sess.connections.Load() <= 0 && sess.lastSeenNs.Load() < cutoffNs
+}
+
+type SessionStore struct {
+	sessions *xsync.Map[string, *session]
+}
+
+func NewSessionStore() *SessionStore {
+	return &SessionStore{sessions: xsync.NewMap[string, *session]()}
+}
+
+func newSessionToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (ss *SessionStore) Create(player PlayerIdentity, playerToken string) (string, *session) {
+	token := newSessionToken()
+	sess := &session{player: player, playerToken: playerToken}
+	sess.lastSeenNs.Store(time.Now().UnixNano())
+	ss.sessions.Store(token, sess)
+	return token, sess
+}
+
+func (ss *SessionStore) Lookup(token string) (*session, bool) {
+	if token == "" {
+		return nil, false
+	}
+	sess, ok := ss.sessions.Load(token)
+	if !ok || sess.expired(time.Now().Add(-*sessionTTL).UnixNano()) {
+		return nil, false
+	}
+	return sess, true
+}
+
+func (ss *SessionStore) ExpireOld() {
+	cutoff := time.Now().Add(-*sessionTTL).UnixNano()
+	ss.sessions.Range(func(token string, sess *session) bool {
+		if sess.expired(cutoff) {
+			ss.sessions.Delete(token)
+		}
+		return true
+	})
+}
+
+type zoneHistoryEvent struct {
+	seqnum  uint64
+	move    Move
+	moves   []*protocol.PieceDataForMove
+	capture *protocol.PieceCapture
+}
+
+type zoneHistory struct {
+	sync.Mutex
+	// A ring, allocated the first time that something happens in this zone
+	events []zoneHistoryEvent
+	start  int
+	// We have every event in this zone with a seqnum after floor
+	floor uint64
+}
+
+// Moves are recorded from the goroutines that broadcast them, before they
+// look up interested clients. A resuming client is registered before it
+// reads from here, so every move either made it into the history before
+// we looked or gets broadcast to the client directly (the client ignores
+// anything that it sees twice).
+//
+// Those goroutines can race each other, so events aren't necessarily in
+// seqnum order; anything older than a zone's floor is just dropped.
+type ZoneHistory struct {
+	size  int
+	zones [ZONE_COUNT][ZONE_COUNT]zoneHistory
+}
+
+func NewZoneHistory(size int, seqNum uint64) *ZoneHistory {
+	h := &ZoneHistory{size: size}
+	for x := range ZONE_COUNT {
+		for y := range ZONE_COUNT {
+			h.zones[x][y].floor = seqNum
+		}
+	}
+	return h
+}
+
+func (h *ZoneHistory) RecordMove(
+	zones map[ZoneCoord]struct{},
+	move Move,
+	seqnum uint64,
+	moves []*protocol.PieceDataForMove,
+	capture *protocol.PieceCapture,
+) {
+	if h.size <= 0 {
+		return
+	}
+	event := zoneHistoryEvent{seqnum: seqnum, move: move, moves: moves, capture: capture}
+	for zone := range zones {
+		z := &h.zones[zone.X][zone.Y]
+		z.Lock()
+		if seqnum > z.floor {
+			if z.events == nil {
+				z.events = make([]zoneHistoryEvent, 0, h.size)
+			}
+			if len(z.events) < h.size {
+				z.events = append(z.events, event)
+			} else {
+				z.floor = max(z.floor, z.events[z.start].seqnum)
+				z.events[z.start] = event
+				z.start = (z.start + 1) % h.size
+			}
+		}
+		z.Unlock()
+	}
+}
+
+// For things that we don't replay (adoptions and bulk captures): anyone
+// who missed this needs a snapshot.
+func (h *ZoneHistory) Invalidate(zones map[ZoneCoord]struct{}, seqnum uint64) {
+	for zone := range zones {
+		z := &h.zones[zone.X][zone.Y]
+		z.Lock()
+		z.floor = max(z.floor, seqnum)
+		z.Unlock()
+	}
+}
+
+// Everything that a client at pos missed after seqnum, or false if we
+// don't have all of it.
+func (h *ZoneHistory) Since(pos Position, seqnum uint64) ([]*protocol.PieceDataForMove, []*protocol.PieceCapture, bool) {
+	if h.size <= 0 {
+		return nil, nil, false
+	}
+	events := make([]zoneHistoryEvent, 0)
+	for zone := range GetRelevantZones(pos) {
+		z := &h.zones[zone.X][zone.Y]
+		z.Lock()
+		if z.floor > seqnum {
+			z.Unlock()
+			return nil, nil, false
+		}
+		for _, event := range z.events {
+			if event.seqnum > seqnum && isMoveNearPosition(event.move, pos) {
+				events = append(events, event)
+			}
+		}
+		z.Unlock()
+		if len(events) > MAX_RESUME_EVENTS {
+			return nil, nil, false
+		}
+	}
+
+	// A move between two zones is in both of them
+	slices.SortFunc(events, func(a, b zoneHistoryEvent) int {
+		if a.seqnum < b.seqnum {
+			return -1
+		} else if a.seqnum > b.seqnum {
+			return 1
+		}
+		return 0
+	})
+	events = slices.CompactFunc(events, func(a, b zoneHistoryEvent) bool {
+		return a.seqnum == b.seqnum
+	})
+
+	moves := make([]*protocol.PieceDataForMove, 0, len(events))
+	captures := make([]*protocol.PieceCapture, 0)
+	for _, event := range events {
+		moves = append(moves, event.moves...)
+		if event.capture != nil {
+			captures = append(captures, event.capture)
+		}
+	}
+	return moves, captures, true
+}
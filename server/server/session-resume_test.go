@@ -0,0 +1,110 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"one-million-chessboards/protocol"
+)
+
+// Records a move in the zones of both of its squares, the way that we
+// broadcast it
+func recordTestMove(h *ZoneHistory, seqnum uint64, fromX, fromY, toX, toY uint16, captured uint32) {
+	zones := map[ZoneCoord]struct{}{
+		GetZoneCoord(fromX, fromY): {},
+		GetZoneCoord(toX, toY):     {},
+	}
+	move := Move{FromX: fromX, FromY: fromY, ToX: toX, ToY: toY}
+	moves := []*protocol.PieceDataForMove{{X: uint32(toX), Y: uint32(toY), Seqnum: seqnum}}
+	var capture *protocol.PieceCapture
+	if captured != 0 {
+		capture = &protocol.PieceCapture{CapturedPieceId: captured, Seqnum: seqnum}
+	}
+	h.RecordMove(zones, move, seqnum, moves, capture)
+}
+
+// The seqnums of the moves and captures since seqnum, or nil if we'd need
+// a snapshot
+func testSince(h *ZoneHistory, pos Position, seqnum uint64) ([]uint64, []uint64) {
+	moves, captures, ok := h.Since(pos, seqnum)
+	if !ok {
+		return nil, nil
+	}
+	moveSeqnums, captureSeqnums := []uint64{}, []uint64{}
+	for _, m := range moves {
+		moveSeqnums = append(moveSeqnums, m.Seqnum)
+	}
+	for _, c := range captures {
+		captureSeqnums = append(captureSeqnums, c.Seqnum)
+	}
+	return moveSeqnums, captureSeqnums
+}
+
+func checkSince(t *testing.T, h *ZoneHistory, seqnum uint64, wantMoves, wantCaptures []uint64) {
+	t.Helper()
+	moves, captures := testSince(h, Position{X: 125, Y: 125}, seqnum)
+	if !reflect.DeepEqual(moves, wantMoves) || !reflect.DeepEqual(captures, wantCaptures) {
+		t.Errorf("since %d: got moves %v and captures %v, want %v and %v",
+			seqnum, moves, captures, wantMoves, wantCaptures)
+	}
+}
+
+func TestZoneHistoryWraparound(t *testing.T) {
+	h := NewZoneHistory(3, 10)
+	recordTestMove(h, 11, 120, 120, 121, 121, 0)
+	recordTestMove(h, 12, 121, 121, 122, 122, 7)
+	// In a zone next door, but too far away for a client at (125, 125) to
+	// care about
+	recordTestMove(h, 13, 198, 198, 199, 199, 0)
+	recordTestMove(h, 14, 122, 122, 123, 123, 0)
+	checkSince(t, h, 10, []uint64{11, 12, 14}, []uint64{12})
+	checkSince(t, h, 14, []uint64{}, []uint64{})
+	checkSince(t, h, 9, nil, nil)
+
+	// Each move past the ring's size pushes the floor up to the one that it
+	// replaces, and events come back in seqnum order even though the ring
+	// no longer starts at the oldest
+	recordTestMove(h, 15, 123, 123, 124, 124, 0)
+	checkSince(t, h, 10, nil, nil)
+	checkSince(t, h, 11, []uint64{12, 14, 15}, []uint64{12})
+	recordTestMove(h, 16, 124, 124, 125, 125, 0)
+	checkSince(t, h, 11, nil, nil)
+	checkSince(t, h, 12, []uint64{14, 15, 16}, []uint64{})
+
+	// Older than the floor, so it's dropped
+	recordTestMove(h, 12, 125, 125, 126, 126, 0)
+	checkSince(t, h, 12, []uint64{14, 15, 16}, []uint64{})
+}
+
+// Moves between zones are in both of them, but we only replay them once
+func TestZoneHistoryCrossZoneMoves(t *testing.T) {
+	h := NewZoneHistory(8, 10)
+	recordTestMove(h, 11, 149, 125, 150, 125, 9)
+	recordTestMove(h, 12, 150, 125, 149, 125, 0)
+	recordTestMove(h, 13, 149, 125, 149, 124, 0)
+	checkSince(t, h, 10, []uint64{11, 12, 13}, []uint64{11})
+}
+
+func TestZoneHistoryInvalidate(t *testing.T) {
+	h := NewZoneHistory(8, 10)
+	recordTestMove(h, 11, 120, 120, 121, 121, 0)
+
+	// Out of sight of (125, 125)
+	h.Invalidate(map[ZoneCoord]struct{}{{X: 50, Y: 50}: {}}, 20)
+	checkSince(t, h, 10, []uint64{11}, []uint64{})
+
+	// Next door
+	h.Invalidate(map[ZoneCoord]struct{}{{X: 3, Y: 2}: {}}, 20)
+	checkSince(t, h, 19, nil, nil)
+	checkSince(t, h, 20, []uint64{}, []uint64{})
+	recordTestMove(h, 21, 150, 125, 151, 125, 0)
+	checkSince(t, h, 20, []uint64{21}, []uint64{})
+}
+
+func TestZoneHistoryDisabled(t *testing.T) {
+	h := NewZoneHistory(0, 10)
+	recordTestMove(h, 11, 120, 120, 121, 121, 0)
+	if _, _, ok := h.Since(Position{X: 125, Y: 125}, 10); ok {
+		t.Errorf("resumed without a history")
+	}
+}
@@ -0,0 +1,19 @@
+package server
+
+import "fmt"
+
+// Like a rollbackRequest, this is already resolved: new pieces have their
+// IDs, so replaying it hands out the same ones.
+type setPiecesRequest struct {
+	Squares []squareAssignment
+}
+
+func (r *setPiecesRequest) ToString() string {
+	placed := 0
+	for _, sq := range r.Squares {
+		if !EncodedIsEmpty(sq.Piece) {
+			placed++
+		}
+	}
+	return fmt.Sprintf("SP: %d squares, %d pieces placed", len(r.Squares), placed)
+}
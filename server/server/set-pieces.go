@@ -0,0 +1,390 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"one-million-chessboards/protocol"
+)
+
+// Putting arbitrary pieces on (or taking them off) arbitrary squares, for
+// when adoption and bulk capture aren't enough. An admin sends a list of
+// squares, each with a piece or nothing, and a list of 8x8 boards to reset
+// to a starting layout. processMoves turns that into a
+// setPiecesRequest (handing out IDs for new pieces as it goes), applies it
+// and persists it like anything else.
+
+// Keeps a request comfortably under MAX_MOVE_LOG_RECORD_BYTES
+const MAX_SET_PIECES_SQUARES = 65536
+
+var errOutOfPieceIDs = errors.New("out of piece IDs")
+var errTooManyPieces = errors.New("placing more pieces of a color (or kings) than have been captured")
+
+type setPiecesSquare struct {
+	X uint16
+	Y uint16
+	// The ID is ignored; anything placed is a brand new piece. An empty
+	// piece clears the square.
+	Piece Piece
+}
+
+type setPiecesBoard struct {
+	BoardX uint16
+	BoardY uint16
+	Color  OnlyColor
+	Layout StartingLayout
+}
+
+// Boards are reset first, in order, and then squares are set in order; if
+// something sets a square twice, the last one wins.
+type setPiecesSpec struct {
+	Boards  []setPiecesBoard
+	Squares []setPiecesSquare
+}
+
+type setPiecesCommand struct {
+	spec   *setPiecesSpec
+	result chan setPiecesOutcome
+}
+
+type setPiecesOutcome struct {
+	result *ChangeSet
+	err    error
+}
+
+// Called from processMoves. Nothing else hands out IDs (or changes the
+// board) while we're in here, but we take the lock anyway since nextID
+// isn't otherwise protected.
+func (b *Board) ResolveSetPieces(spec *setPiecesSpec) (*setPiecesRequest, error) {
+	b.Lock()
+	defer b.Unlock()
+	firstID := b.nextID
+
+	newPiece := func(p Piece) (EncodedPiece, error) {
+		if b.nextID > idMask {
+			return EmptyEncodedPiece, errOutOfPieceIDs
+		}
+		p.ID = b.nextID
+		b.nextID++
+		return p.Encode(), nil
+	}
+
+	squares := make(map[uint32]EncodedPiece)
+	for _, board := range spec.Boards {
+		baseX := board.BoardX * SINGLE_BOARD_SIZE
+		baseY := board.BoardY * SINGLE_BOARD_SIZE
+		for y := baseY; y < baseY+SINGLE_BOARD_SIZE; y++ {
+			for x := baseX; x < baseX+SINGLE_BOARD_SIZE; x++ {
+				raw := EncodedPiece(b.pieces[y][x])
+				if existing, ok := squares[encodeCoords(x, y)]; ok {
+					raw = existing
+				}
+				if EncodedIsEmpty(raw) {
+					continue
+				}
+				p := PieceOfEncodedPiece(raw)
+				if board.Color == OnlyColorWhite && !p.IsWhite {
+					continue
+				} else if board.Color == OnlyColorBlack && p.IsWhite {
+					continue
+				}
+				squares[encodeCoords(x, y)] = EmptyEncodedPiece
+			}
+		}
+		for _, isWhite := range []bool{true, false} {
+			if board.Color == OnlyColorWhite && !isWhite || board.Color == OnlyColorBlack && isWhite {
+				continue
+			}
+			if b.nextID+16 > idMask {
+				return nil, errOutOfPieceIDs
+			}
+			for _, sq := range b.startingPiecesForColor(board.BoardX, board.BoardY, isWhite, board.Layout) {
+				squares[encodeCoords(sq.X, sq.Y)] = sq.Piece
+			}
+		}
+	}
+	for _, sq := range spec.Squares {
+		if sq.Piece.IsEmpty() {
+			squares[encodeCoords(sq.X, sq.Y)] = EmptyEncodedPiece
+			continue
+		}
+		piece, err := newPiece(sq.Piece)
+		if err != nil {
+			return nil, err
+		}
+		squares[encodeCoords(sq.X, sq.Y)] = piece
+	}
+
+	req := &setPiecesRequest{Squares: make([]squareAssignment, 0, len(squares))}
+	for coords, piece := range squares {
+		x, y := decodeCoords(coords)
+		if EncodedPiece(b.pieces[y][x]) == piece {
+			continue
+		}
+		req.Squares = append(req.Squares, squareAssignment{X: x, Y: y, Piece: piece})
+	}
+	if !b.capturedCountsCover(req.Squares) {
+		b.nextID = firstID
+		return nil, errTooManyPieces
+	}
+	slices.SortFunc(req.Squares, func(a, b squareAssignment) int {
+		if a.Y != b.Y {
+			return int(a.Y) - int(b.Y)
+		}
+		return int(a.X) - int(b.X)
+	})
+	return req, nil
+}
+
+// Placing a piece uncaptures one (see assignSquares), so we can't place
+// more pieces of a color, or more kings, than are captured once the squares
+// that we're overwriting are cleared; our counts would wrap around.
+// Call with the lock held.
+func (b *Board) capturedCountsCover(squares []squareAssignment) bool {
+	var whitePieces, blackPieces, whiteKings, blackKings int64
+	count := func(p Piece, delta int64) {
+		if p.IsWhite {
+			whitePieces += delta
+			if p.Type == King {
+				whiteKings += delta
+			}
+		} else {
+			blackPieces += delta
+			if p.Type == King {
+				blackKings += delta
+			}
+		}
+	}
+	for _, sq := range squares {
+		if old := EncodedPiece(b.pieces[sq.Y][sq.X]); !EncodedIsEmpty(old) {
+			count(PieceOfEncodedPiece(old), 1)
+		}
+		if !EncodedIsEmpty(sq.Piece) {
+			count(PieceOfEncodedPiece(sq.Piece), -1)
+		}
+	}
+	return int64(b.whitePiecesCaptured.Load())+whitePieces >= 0 &&
+		int64(b.blackPiecesCaptured.Load())+blackPieces >= 0 &&
+		int64(b.whiteKingsCaptured.Load())+whiteKings >= 0 &&
+		int64(b.blackKingsCaptured.Load())+blackKings >= 0
+}
+
+func affectedZonesForSquares(squares []squareAssignment) map[ZoneCoord]struct{} {
+	zones := make(map[ZoneCoord]struct{})
+	for _, sq := range squares {
+		zones[GetZoneCoord(sq.X, sq.Y)] = struct{}{}
+	}
+	return zones
+}
+
+func setPiecesMessage(res *ChangeSet) *protocol.ServerMessage {
+	placed := make([]*protocol.PieceDataForMove, 0, len(res.Placed))
+	for _, p := range res.Placed {
+		placed = append(placed, &protocol.PieceDataForMove{
+			X:      uint32(p.X),
+			Y:      uint32(p.Y),
+			Seqnum: res.Seqnum,
+			Piece:  p.Piece.ToProtocolAlloc(),
+		})
+	}
+	removedIDs := make([]uint32, 0, len(res.Removed))
+	for _, p := range res.Removed {
+		removedIDs = append(removedIDs, p.Piece.ID)
+	}
+	return &protocol.ServerMessage{
+		Payload: &protocol.ServerMessage_SetPieces{
+			SetPieces: &protocol.ServerSetPieces{
+				Seqnum:     res.Seqnum,
+				Placed:     placed,
+				RemovedIds: removedIDs,
+			},
+		},
+	}
+}
+
+// Called from processMoves
+func (s *Server) applySetPieces(cmd setPiecesCommand) {
+	req, err := s.board.ResolveSetPieces(cmd.spec)
+	if err != nil {
+		cmd.result <- setPiecesOutcome{err: err}
+		return
+	}
+	if len(req.Squares) == 0 {
+		cmd.result <- setPiecesOutcome{result: &ChangeSet{Seqnum: s.board.seqNum}}
+		return
+	}
+	result, err := s.board.SetPieces(req)
+	if err != nil {
+		cmd.result <- setPiecesOutcome{err: err}
+		return
+	}
+	s.publishBoardEvent(boardToDiskRequest{SetPiecesRequest: req}, result, nil)
+	cmd.result <- setPiecesOutcome{result: result}
+}
+
+func (s *Server) ServeSetPieces(w http.ResponseWriter, r *http.Request) {
+	s.httpLogger.Info().
+		Str("rpc", "ServeSetPieces").
+		Send()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Type is a protocol.PieceType name, like PIECE_TYPE_QUEEN
+	type PieceSpec struct {
+		Type                             string `json:"type"`
+		IsWhite                          bool   `json:"isWhite"`
+		JustDoubleMoved                  bool   `json:"justDoubleMoved"`
+		KingKiller                       bool   `json:"kingKiller"`
+		KingPawner                       bool   `json:"kingPawner"`
+		QueenKiller                      bool   `json:"queenKiller"`
+		QueenPawner                      bool   `json:"queenPawner"`
+		AdoptedKiller                    bool   `json:"adoptedKiller"`
+		Adopted                          bool   `json:"adopted"`
+		HasCapturedPieceTypeOtherThanOwn bool   `json:"hasCapturedPieceTypeOtherThanOwn"`
+		MoveCount                        uint16 `json:"moveCount"`
+		CaptureCount                     uint16 `json:"captureCount"`
+	}
+	// A null piece clears the square
+	type SquareSpec struct {
+		X     uint16     `json:"x"`
+		Y     uint16     `json:"y"`
+		Piece *PieceSpec `json:"piece"`
+	}
+	// Any square on the board will do, like adoption and bulk capture.
+	// Layout is a StartingLayout name, like capablanca; empty means classic.
+	type StartingPositionSpec struct {
+		X         uint16 `json:"x"`
+		Y         uint16 `json:"y"`
+		OnlyColor string `json:"onlyColor"`
+		Layout    string `json:"layout"`
+	}
+	type SetPiecesRequest struct {
+		Squares           []SquareSpec           `json:"squares"`
+		StartingPositions []StartingPositionSpec `json:"startingPositions"`
+		Pass              string                 `json:"pass"`
+	}
+
+	var req SetPiecesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Pass != *internalPass {
+		http.Error(w, "no", http.StatusNotFound)
+		return
+	}
+
+	if s.isStandby() {
+		http.Error(w, "Server is a standby", http.StatusServiceUnavailable)
+		return
+	}
+
+	if len(req.Squares)+len(req.StartingPositions)*SINGLE_BOARD_SIZE*SINGLE_BOARD_SIZE > MAX_SET_PIECES_SQUARES {
+		http.Error(w, "Too many squares", http.StatusBadRequest)
+		return
+	}
+
+	spec := &setPiecesSpec{
+		Boards:  make([]setPiecesBoard, 0, len(req.StartingPositions)),
+		Squares: make([]setPiecesSquare, 0, len(req.Squares)),
+	}
+	for _, sp := range req.StartingPositions {
+		if sp.X >= BOARD_SIZE || sp.Y >= BOARD_SIZE {
+			http.Error(w, "Coordinates out of bounds", http.StatusBadRequest)
+			return
+		}
+		layout, err := StartingLayoutFromString(sp.Layout)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		spec.Boards = append(spec.Boards, setPiecesBoard{
+			BoardX: sp.X / SINGLE_BOARD_SIZE,
+			BoardY: sp.Y / SINGLE_BOARD_SIZE,
+			Color:  OnlyColorFromString(sp.OnlyColor),
+			Layout: layout,
+		})
+	}
+	for _, sq := range req.Squares {
+		if sq.X >= BOARD_SIZE || sq.Y >= BOARD_SIZE {
+			http.Error(w, "Coordinates out of bounds", http.StatusBadRequest)
+			return
+		}
+		square := setPiecesSquare{X: sq.X, Y: sq.Y}
+		if p := sq.Piece; p != nil {
+			pieceType, ok := protocol.PieceType_value[p.Type]
+			if !ok {
+				http.Error(w, fmt.Sprintf("Unknown piece type %q", p.Type), http.StatusBadRequest)
+				return
+			}
+			if p.MoveCount > MaxMoveCount || p.CaptureCount > MaxCaptureCount {
+				http.Error(w, "Counts out of range", http.StatusBadRequest)
+				return
+			}
+			square.Piece = Piece{
+				// Anything non-zero; the real ID is handed out later
+				ID:                               1,
+				Type:                             protocol.PieceType(pieceType),
+				IsWhite:                          p.IsWhite,
+				JustDoubleMoved:                  p.JustDoubleMoved,
+				KingKiller:                       p.KingKiller,
+				KingPawner:                       p.KingPawner,
+				QueenKiller:                      p.QueenKiller,
+				QueenPawner:                      p.QueenPawner,
+				AdoptedKiller:                    p.AdoptedKiller,
+				Adopted:                          p.Adopted,
+				HasCapturedPieceTypeOtherThanOwn: p.HasCapturedPieceTypeOtherThanOwn,
+				MoveCount:                        p.MoveCount,
+				CaptureCount:                     p.CaptureCount,
+			}
+		}
+		spec.Squares = append(spec.Squares, square)
+	}
+
+	cmd := setPiecesCommand{spec: spec, result: make(chan setPiecesOutcome, 1)}
+	select {
+	case s.setPiecesRequests <- cmd:
+	case <-s.processMovesCtx.Done():
+		http.Error(w, "Not accepting changes", http.StatusServiceUnavailable)
+		return
+	}
+	var outcome setPiecesOutcome
+	select {
+	case outcome = <-cmd.result:
+	case <-s.processMovesCtx.Done():
+		http.Error(w, "Not accepting changes", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(outcome.err, errTooManyPieces) {
+		http.Error(w, outcome.err.Error(), http.StatusBadRequest)
+		return
+	} else if outcome.err != nil {
+		s.httpLogger.Error().Str("error_kind", "setting_pieces").AnErr("err", outcome.err).Send()
+		http.Error(w, outcome.err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type SetPiecesResponse struct {
+		Seqnum     uint64   `json:"seqnum"`
+		PlacedIDs  []uint32 `json:"placedIds"`
+		RemovedIDs []uint32 `json:"removedIds"`
+	}
+	resp := SetPiecesResponse{
+		Seqnum:     outcome.result.Seqnum,
+		PlacedIDs:  make([]uint32, 0, len(outcome.result.Placed)),
+		RemovedIDs: make([]uint32, 0, len(outcome.result.Removed)),
+	}
+	for _, p := range outcome.result.Placed {
+		resp.PlacedIDs = append(resp.PlacedIDs, p.Piece.ID)
+	}
+	for _, p := range outcome.result.Removed {
+		resp.RemovedIDs = append(resp.RemovedIDs, p.Piece.ID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
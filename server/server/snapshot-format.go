@@ -0,0 +1,291 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// Snapshots used to be a raw SnapshotHeader followed by []PieceAndCoords, which
+// meant that a truncated or bit-flipped file would happily load. Now they're a
+// small container:
+//
+//	preamble  magic, format version, piece encoding version, board dimensions,
+//	          when the snapshot was taken, section count, CRC of the preamble
+//	sections  kind (uint32), length in bytes (uint64), data, CRC of the data
+//
+// Everything is little-endian. Readers skip section kinds that they don't
+// know about, so we can add sections without bumping the format version.
+// The header section can also grow: we append fields to SnapshotHeader and
+// read shorter headers with the missing fields zeroed. Bump the format
+// version if you change the meaning of an existing section.
+//
+// Files without the magic are assumed to be in the old raw format.
+
+const SNAPSHOT_FORMAT_VERSION = 1
+
+var snapshotMagic = [8]byte{'O', 'M', 'C', 'B', 'S', 'N', 'A', 'P'}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var ErrCorruptSnapshot = errors.New("corrupt snapshot")
+
+type snapshotSectionKind uint32
+
+const (
+	snapshotSectionHeader snapshotSectionKind = 1
+	snapshotSectionPieces snapshotSectionKind = 2
+)
+
+type snapshotPreamble struct {
+	Magic                [8]byte
+	FormatVersion        uint32
+	PieceEncodingVersion uint32
+	BoardSize            uint32
+	SingleBoardSize      uint32
+	TakenAtNs            int64
+	SectionCount         uint32
+	PreambleCRC          uint32
+}
+
+type snapshotSectionInfo struct {
+	Kind   snapshotSectionKind
+	Length uint64
+}
+
+func (p *snapshotPreamble) computeCRC() uint32 {
+	withoutCRC := *p
+	withoutCRC.PreambleCRC = 0
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &withoutCRC)
+	return crc32.Checksum(buf.Bytes(), crcTable)
+}
+
+type snapshotSection struct {
+	Kind snapshotSectionKind
+	Data any
+}
+
+func writeSnapshotContainer(writer io.Writer, takenAtNs int64, sections []snapshotSection) error {
+	preamble := snapshotPreamble{
+		Magic:                snapshotMagic,
+		FormatVersion:        SNAPSHOT_FORMAT_VERSION,
+		PieceEncodingVersion: PIECE_ENCODING_VERSION,
+		BoardSize:            BOARD_SIZE,
+		SingleBoardSize:      SINGLE_BOARD_SIZE,
+		TakenAtNs:            takenAtNs,
+		SectionCount:         uint32(len(sections)),
+	}
+	preamble.PreambleCRC = preamble.computeCRC()
+	if err := binary.Write(writer, binary.LittleEndian, &preamble); err != nil {
+		return err
+	}
+	crc := crc32.New(crcTable)
+	for _, section := range sections {
+		size := binary.Size(section.Data)
+		if size < 0 {
+			return fmt.Errorf("can't determine size of snapshot section %d", section.Kind)
+		}
+		sh := snapshotSectionInfo{Kind: section.Kind, Length: uint64(size)}
+		if err := binary.Write(writer, binary.LittleEndian, &sh); err != nil {
+			return err
+		}
+		crc.Reset()
+		if err := binary.Write(io.MultiWriter(writer, crc), binary.LittleEndian, section.Data); err != nil {
+			return err
+		}
+		if err := binary.Write(writer, binary.LittleEndian, crc.Sum32()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type snapshotContainerReader struct {
+	reader   *bufio.Reader
+	preamble snapshotPreamble
+	crc      hash.Hash32
+	read     uint32
+}
+
+func newSnapshotContainerReader(reader *bufio.Reader) (*snapshotContainerReader, error) {
+	r := &snapshotContainerReader{reader: reader, crc: crc32.New(crcTable)}
+	if err := binary.Read(reader, binary.LittleEndian, &r.preamble); err != nil {
+		return nil, fmt.Errorf("%w: reading preamble: %v", ErrCorruptSnapshot, err)
+	}
+	p := &r.preamble
+	switch {
+	case p.Magic != snapshotMagic:
+		return nil, fmt.Errorf("%w: bad magic", ErrCorruptSnapshot)
+	case p.PreambleCRC != p.computeCRC():
+		return nil, fmt.Errorf("%w: preamble checksum mismatch", ErrCorruptSnapshot)
+	case p.FormatVersion > SNAPSHOT_FORMAT_VERSION:
+		return nil, fmt.Errorf("unsupported snapshot format version %d", p.FormatVersion)
+	case p.PieceEncodingVersion != PIECE_ENCODING_VERSION:
+		return nil, fmt.Errorf("unsupported piece encoding version %d", p.PieceEncodingVersion)
+	case p.BoardSize != BOARD_SIZE || p.SingleBoardSize != SINGLE_BOARD_SIZE:
+		return nil, fmt.Errorf("snapshot is for a %d/%d board, we're %d/%d",
+			p.BoardSize, p.SingleBoardSize, BOARD_SIZE, SINGLE_BOARD_SIZE)
+	}
+	return r, nil
+}
+
+// Returns io.EOF once every section has been read. Call readSectionData or
+// skipSectionData before asking for the next section.
+func (r *snapshotContainerReader) nextSection() (snapshotSectionInfo, error) {
+	var sh snapshotSectionInfo
+	if r.read == r.preamble.SectionCount {
+		return sh, io.EOF
+	}
+	if err := binary.Read(r.reader, binary.LittleEndian, &sh); err != nil {
+		return sh, fmt.Errorf("%w: reading section header: %v", ErrCorruptSnapshot, err)
+	}
+	r.read++
+	return sh, nil
+}
+
+func (r *snapshotContainerReader) readSectionData(sh snapshotSectionInfo, data any) error {
+	if size := binary.Size(data); size < 0 || uint64(size) != sh.Length {
+		return fmt.Errorf("%w: section %d is %d bytes, expected %d",
+			ErrCorruptSnapshot, sh.Kind, sh.Length, size)
+	}
+	r.crc.Reset()
+	if err := binary.Read(io.TeeReader(r.reader, r.crc), binary.LittleEndian, data); err != nil {
+		return fmt.Errorf("%w: reading section %d: %v", ErrCorruptSnapshot, sh.Kind, err)
+	}
+	return r.checkSectionCRC(sh)
+}
+
+// For the header, which has had fields appended to it since we started
+// writing this format
+func (r *snapshotContainerReader) readGrowableSectionData(sh snapshotSectionInfo, data any) error {
+	size := binary.Size(data)
+	if size < 0 || sh.Length > uint64(size) {
+		return fmt.Errorf("%w: section %d is %d bytes, expected at most %d",
+			ErrCorruptSnapshot, sh.Kind, sh.Length, size)
+	}
+	buf := make([]byte, size)
+	r.crc.Reset()
+	if _, err := io.ReadFull(io.TeeReader(r.reader, r.crc), buf[:sh.Length]); err != nil {
+		return fmt.Errorf("%w: reading section %d: %v", ErrCorruptSnapshot, sh.Kind, err)
+	}
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, data); err != nil {
+		return fmt.Errorf("%w: decoding section %d: %v", ErrCorruptSnapshot, sh.Kind, err)
+	}
+	return r.checkSectionCRC(sh)
+}
+
+func (r *snapshotContainerReader) skipSectionData(sh snapshotSectionInfo) error {
+	r.crc.Reset()
+	if _, err := io.CopyN(r.crc, r.reader, int64(sh.Length)); err != nil {
+		return fmt.Errorf("%w: skipping section %d: %v", ErrCorruptSnapshot, sh.Kind, err)
+	}
+	return r.checkSectionCRC(sh)
+}
+
+func (r *snapshotContainerReader) checkSectionCRC(sh snapshotSectionInfo) error {
+	var stored uint32
+	if err := binary.Read(r.reader, binary.LittleEndian, &stored); err != nil {
+		return fmt.Errorf("%w: reading checksum for section %d: %v", ErrCorruptSnapshot, sh.Kind, err)
+	}
+	if stored != r.crc.Sum32() {
+		return fmt.Errorf("%w: checksum mismatch in section %d", ErrCorruptSnapshot, sh.Kind)
+	}
+	return nil
+}
+
+func (s *Snapshot) writeTo(writer io.Writer) error {
+	return writeSnapshotContainer(writer, s.TakenAtNs, []snapshotSection{
+		{Kind: snapshotSectionHeader, Data: &s.Header},
+		{Kind: snapshotSectionPieces, Data: s.PiecesAndCoords},
+		{Kind: snapshotSectionPlayerStats, Data: s.PlayerStats},
+	})
+}
+
+func (s *Snapshot) readFrom(reader *bufio.Reader) error {
+	r, err := newSnapshotContainerReader(reader)
+	if err != nil {
+		return err
+	}
+	s.TakenAtNs = r.preamble.TakenAtNs
+	haveHeader := false
+	havePieces := false
+	for {
+		sh, err := r.nextSection()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		switch sh.Kind {
+		case snapshotSectionHeader:
+			err = r.readGrowableSectionData(sh, &s.Header)
+			haveHeader = err == nil
+		case snapshotSectionPieces:
+			if !haveHeader {
+				return fmt.Errorf("%w: pieces before header", ErrCorruptSnapshot)
+			}
+			s.PiecesAndCoords = make([]PieceAndCoords, s.Header.PieceCount)
+			err = r.readSectionData(sh, s.PiecesAndCoords)
+			havePieces = err == nil
+		case snapshotSectionPlayerStats:
+			s.PlayerStats, err = readPlayerStatsSection(r, sh)
+		default:
+			err = r.skipSectionData(sh)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !haveHeader || !havePieces {
+		return fmt.Errorf("%w: missing header or pieces", ErrCorruptSnapshot)
+	}
+	return nil
+}
+
+// The header that the old format starts with: SnapshotHeader as it was
+// before we appended anything to it
+const legacySnapshotHeaderSize = 4 + 8 + 8 + 4 + 4 + 4 + 4 + 4
+
+// The old format has no checksums, but we can at least notice truncation
+func (s *Snapshot) readLegacyFrom(reader *bufio.Reader, fileSize int64) error {
+	buf := make([]byte, binary.Size(&s.Header))
+	_, err := io.ReadFull(reader, buf[:legacySnapshotHeaderSize])
+	if err == nil {
+		err = binary.Read(bytes.NewReader(buf), binary.LittleEndian, &s.Header)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: reading legacy header: %v", ErrCorruptSnapshot, err)
+	}
+	expectedSize := int64(legacySnapshotHeaderSize) +
+		int64(s.Header.PieceCount)*int64(binary.Size(PieceAndCoords{}))
+	if expectedSize != fileSize {
+		return fmt.Errorf("%w: legacy snapshot should be %d bytes but is %d",
+			ErrCorruptSnapshot, expectedSize, fileSize)
+	}
+	s.PiecesAndCoords = make([]PieceAndCoords, s.Header.PieceCount)
+	err = binary.Read(reader, binary.LittleEndian, &s.PiecesAndCoords)
+	if err != nil {
+		return fmt.Errorf("%w: reading legacy pieces: %v", ErrCorruptSnapshot, err)
+	}
+	return nil
+}
+
+func (s *Snapshot) initializeFromStore(store StateStore, name string) error {
+	file, size, err := store.Get(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReaderSize(file, 128*1024*1024)
+	magic, err := reader.Peek(len(snapshotMagic))
+	if err == nil && bytes.Equal(magic, snapshotMagic[:]) {
+		return s.readFrom(reader)
+	}
+	return s.readLegacyFrom(reader, size)
+}
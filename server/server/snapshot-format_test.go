@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testSnapshot() *Snapshot {
+	s := &Snapshot{
+		Header: SnapshotHeader{
+			NextID:              1234,
+			SeqNum:              5678,
+			TotalMoves:          910,
+			WhitePiecesCaptured: 11,
+			BlackPiecesCaptured: 12,
+			WhiteKingsCaptured:  1,
+			BlackKingsCaptured:  2,
+			PromotionRule:       PromotionRuleBoardEdge,
+			RuleSet:             RuleSetClassical,
+		},
+		PiecesAndCoords: []PieceAndCoords{
+			{Piece: 0x0102030405060708, Coords: encodeCoords(1, 2)},
+			{Piece: 0x1112131415161718, Coords: encodeCoords(300, 4000)},
+			{Piece: 0x2122232425262728, Coords: encodeCoords(7999, 7999)},
+		},
+		PlayerStats: []playerStatsRecord{
+			{Player: 42, Stats: PlayerStats{Moves: 10, Promotions: 1}},
+			{Player: 43, Stats: PlayerStats{Moves: 20}},
+		},
+		TakenAtNs: 1700000000123456789,
+	}
+	s.Header.PieceCount = uint32(len(s.PiecesAndCoords))
+	s.PlayerStats[1].Stats.Captures[Queen] = 3
+	return s
+}
+
+func snapshotBytes(t *testing.T, s *Snapshot) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := s.writeTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Through the store, the way that we load snapshots on startup
+func loadTestSnapshot(t *testing.T, data []byte) (*Snapshot, error) {
+	t.Helper()
+	store := NewMemoryStateStore()
+	putBytes(t, store, "snapshot.bin", data)
+	s := &Snapshot{}
+	return s, s.initializeFromStore(store, "snapshot.bin")
+}
+
+func readTestSnapshot(data []byte) (*Snapshot, error) {
+	s := &Snapshot{}
+	return s, s.readFrom(bufio.NewReader(bytes.NewReader(data)))
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	want := testSnapshot()
+	got, err := loadTestSnapshot(t, snapshotBytes(t, want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSnapshotCorruption(t *testing.T) {
+	data := snapshotBytes(t, testSnapshot())
+	preambleSize := binary.Size(snapshotPreamble{})
+	sectionInfoSize := binary.Size(snapshotSectionInfo{})
+
+	// Every byte of the preamble, and of each section's data and CRC. We
+	// don't checksum section kinds and lengths, but getting those wrong
+	// breaks the sections after them.
+	var offsets []int
+	for i := 0; i < preambleSize; i++ {
+		offsets = append(offsets, i)
+	}
+	for pos := preambleSize; pos < len(data); {
+		length := int(binary.LittleEndian.Uint64(data[pos+4:]))
+		pos += sectionInfoSize
+		for i := 0; i < length+4; i++ {
+			offsets = append(offsets, pos+i)
+		}
+		pos += length + 4
+	}
+	for _, offset := range offsets {
+		flipped := bytes.Clone(data)
+		flipped[offset] ^= 0xFF
+		if _, err := readTestSnapshot(flipped); !errors.Is(err, ErrCorruptSnapshot) {
+			t.Errorf("flipped byte %d: got %v, want ErrCorruptSnapshot", offset, err)
+		}
+	}
+
+	for length := 0; length < len(data); length++ {
+		if _, err := readTestSnapshot(data[:length]); !errors.Is(err, ErrCorruptSnapshot) {
+			t.Errorf("truncated to %d bytes: got %v, want ErrCorruptSnapshot", length, err)
+		}
+	}
+	if _, err := loadTestSnapshot(t, data[:len(data)/2]); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Errorf("truncated through the store: got %v, want ErrCorruptSnapshot", err)
+	}
+}
+
+func TestSnapshotSkipsUnknownSections(t *testing.T) {
+	want := testSnapshot()
+	unknown := []uint32{1, 2, 3}
+	var buf bytes.Buffer
+	err := writeSnapshotContainer(&buf, want.TakenAtNs, []snapshotSection{
+		{Kind: 99, Data: unknown},
+		{Kind: snapshotSectionHeader, Data: &want.Header},
+		{Kind: 100, Data: unknown},
+		{Kind: snapshotSectionPieces, Data: want.PiecesAndCoords},
+		{Kind: snapshotSectionPlayerStats, Data: want.PlayerStats},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readTestSnapshot(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+
+	// We still check the CRCs of sections that we skip
+	flipped := bytes.Clone(buf.Bytes())
+	flipped[binary.Size(snapshotPreamble{})+binary.Size(snapshotSectionInfo{})] ^= 0xFF
+	if _, err := readTestSnapshot(flipped); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Errorf("flipped byte in an unknown section: got %v, want ErrCorruptSnapshot", err)
+	}
+}
+
+// SnapshotHeader as it was before we appended anything to it
+type legacySnapshotHeader struct {
+	NextID              uint32
+	SeqNum              uint64
+	TotalMoves          uint64
+	WhitePiecesCaptured uint32
+	BlackPiecesCaptured uint32
+	WhiteKingsCaptured  uint32
+	BlackKingsCaptured  uint32
+	PieceCount          uint32
+}
+
+func legacyHeaderOf(h SnapshotHeader) legacySnapshotHeader {
+	return legacySnapshotHeader{
+		NextID:              h.NextID,
+		SeqNum:              h.SeqNum,
+		TotalMoves:          h.TotalMoves,
+		WhitePiecesCaptured: h.WhitePiecesCaptured,
+		BlackPiecesCaptured: h.BlackPiecesCaptured,
+		WhiteKingsCaptured:  h.WhiteKingsCaptured,
+		BlackKingsCaptured:  h.BlackKingsCaptured,
+		PieceCount:          h.PieceCount,
+	}
+}
+
+func TestSnapshotShortHeader(t *testing.T) {
+	want := testSnapshot()
+	legacyHeader := legacyHeaderOf(want.Header)
+	var buf bytes.Buffer
+	err := writeSnapshotContainer(&buf, want.TakenAtNs, []snapshotSection{
+		{Kind: snapshotSectionHeader, Data: &legacyHeader},
+		{Kind: snapshotSectionPieces, Data: want.PiecesAndCoords},
+		{Kind: snapshotSectionPlayerStats, Data: want.PlayerStats},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readTestSnapshot(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Header.PromotionRule = PromotionRuleWorldEdge
+	want.Header.RuleSet = RuleSetStandard
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestLegacySnapshot(t *testing.T) {
+	want := testSnapshot()
+	var buf bytes.Buffer
+	legacyHeader := legacyHeaderOf(want.Header)
+	if err := binary.Write(&buf, binary.LittleEndian, &legacyHeader); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != legacySnapshotHeaderSize {
+		t.Fatalf("legacy header is %d bytes, want %d", buf.Len(), legacySnapshotHeaderSize)
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, want.PiecesAndCoords); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadTestSnapshot(t, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The old format has none of these
+	want.Header.PromotionRule = PromotionRuleWorldEdge
+	want.Header.RuleSet = RuleSetStandard
+	want.PlayerStats = nil
+	want.TakenAtNs = 0
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+
+	for _, size := range []int{buf.Len() - 1, buf.Len() + 1} {
+		data := make([]byte, size)
+		copy(data, buf.Bytes())
+		if _, err := loadTestSnapshot(t, data); !errors.Is(err, ErrCorruptSnapshot) {
+			t.Errorf("legacy snapshot of %d bytes: got %v, want ErrCorruptSnapshot", size, err)
+		}
+	}
+}
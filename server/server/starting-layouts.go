@@ -0,0 +1,67 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"one-million-chessboards/protocol"
+)
+
+// What goes on an 8x8 board's back rank when we set it up. Everything but
+// the classic layout is for special events, where an admin resets some
+// boards with fairy pieces via set-pieces. The king and rooks stay where
+// castling expects them in every layout.
+
+type StartingLayout uint8
+
+const (
+	LayoutClassic StartingLayout = iota
+	// An archbishop and a chancellor in place of the queenside bishop and
+	// the kingside bishop, like Capablanca chess squeezed onto 8 files
+	LayoutCapablanca
+	// The queen is an amazon
+	LayoutAmazon
+	// Every minor piece is a fairy piece
+	LayoutFairy
+)
+
+type startingLayout struct {
+	Name     string
+	BackRank [8]protocol.PieceType
+}
+
+var startingLayouts = map[StartingLayout]startingLayout{
+	LayoutClassic: {
+		Name:     "classic",
+		BackRank: [8]protocol.PieceType{Rook, Knight, Bishop, Queen, King, Bishop, Knight, Rook},
+	},
+	LayoutCapablanca: {
+		Name:     "capablanca",
+		BackRank: [8]protocol.PieceType{Rook, Knight, Archbishop, Queen, King, Chancellor, Knight, Rook},
+	},
+	LayoutAmazon: {
+		Name:     "amazon",
+		BackRank: [8]protocol.PieceType{Rook, Knight, Bishop, Amazon, King, Bishop, Knight, Rook},
+	},
+	LayoutFairy: {
+		Name:     "fairy",
+		BackRank: [8]protocol.PieceType{Rook, Chancellor, Archbishop, Amazon, King, Archbishop, Chancellor, Rook},
+	},
+}
+
+// Empty means classic
+func StartingLayoutFromString(name string) (StartingLayout, error) {
+	if name == "" {
+		return LayoutClassic, nil
+	}
+	names := make([]string, 0, len(startingLayouts))
+	for layout, l := range startingLayouts {
+		if l.Name == name {
+			return layout, nil
+		}
+		names = append(names, l.Name)
+	}
+	sort.Strings(names)
+	return LayoutClassic, fmt.Errorf("unknown layout %q (want one of %s)", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,303 @@
+package server
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Everything that the BoardToDiskHandler persists (snapshots, deltas, move
+// logs and piece history indexes) goes through a StateStore. Names are flat
+// (no directories) and are the same names that we'd use on local disk.
+
+var stateStoreKind = flag.String("state-store", "local", "Where to keep state: local (the -state dir), s3, or memory (lost on exit)")
+
+type StateStore interface {
+	// Names of every object that matches pattern (path.Match syntax), sorted
+	List(pattern string) ([]string, error)
+	// The object's contents and its size in bytes
+	Get(name string) (io.ReadCloser, int64, error)
+	GetRange(name string, offset, length int64) ([]byte, error)
+	// Readers see either the old object or the whole new one, never part of it
+	Put(name string, writeData func(writer io.Writer) error) error
+	Delete(name string) error
+	Rename(oldName, newName string) error
+	// Create a new object that we write incrementally
+	Append(name string) (AppendableObject, error)
+	// For log messages
+	Describe() string
+}
+
+type AppendableObject interface {
+	io.Writer
+	// Pushes everything written so far towards the store. On local disk and
+	// in memory it's durable once Flush returns; on S3 Flush only starts an
+	// upload, so see s3AppendableObject for how much we can lose.
+	Flush() error
+	Close() error
+}
+
+// Pick a store based on our flags. stateDir is only used by the local store.
+func NewStateStore(stateDir string) (StateStore, error) {
+	switch *stateStoreKind {
+	case "local":
+		return NewLocalStateStore(stateDir), nil
+	case "s3":
+		return NewS3StateStoreFromFlags()
+	case "memory":
+		return NewMemoryStateStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown state store %q", *stateStoreKind)
+	}
+}
+
+func listPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?[\\"); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+type LocalStateStore struct {
+	dir string
+}
+
+func NewLocalStateStore(dir string) *LocalStateStore {
+	return &LocalStateStore{dir: dir}
+}
+
+func (s *LocalStateStore) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *LocalStateStore) Describe() string {
+	return s.dir
+}
+
+func (s *LocalStateStore) List(pattern string) ([]string, error) {
+	files, err := filepath.Glob(s.path(pattern))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (s *LocalStateStore) Get(name string) (io.ReadCloser, int64, error) {
+	file, err := os.Open(s.path(name))
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+func (s *LocalStateStore) GetRange(name string, offset, length int64) ([]byte, error) {
+	file, err := os.Open(s.path(name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	buf := make([]byte, length)
+	_, err = file.ReadAt(buf, offset)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (s *LocalStateStore) Put(name string, writeData func(writer io.Writer) error) error {
+	return WriteFileAtomic(s.path(name), writeData)
+}
+
+func (s *LocalStateStore) Delete(name string) error {
+	return os.Remove(s.path(name))
+}
+
+func (s *LocalStateStore) Rename(oldName, newName string) error {
+	return os.Rename(s.path(oldName), s.path(newName))
+}
+
+func (s *LocalStateStore) Append(name string) (AppendableObject, error) {
+	f, err := os.OpenFile(s.path(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return localAppendableObject{f}, nil
+}
+
+type localAppendableObject struct {
+	*os.File
+}
+
+func (o localAppendableObject) Flush() error {
+	return o.Sync()
+}
+
+// Keeps everything in memory; handy for trying things out locally
+type MemoryStateStore struct {
+	sync.RWMutex
+	objects map[string][]byte
+}
+
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{objects: make(map[string][]byte)}
+}
+
+func (s *MemoryStateStore) Describe() string {
+	return "memory"
+}
+
+func (s *MemoryStateStore) List(pattern string) ([]string, error) {
+	s.RLock()
+	defer s.RUnlock()
+	names := make([]string, 0)
+	for name := range s.objects {
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (s *MemoryStateStore) get(name string) ([]byte, error) {
+	s.RLock()
+	defer s.RUnlock()
+	data, ok := s.objects[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "get", Path: name, Err: fs.ErrNotExist}
+	}
+	return data, nil
+}
+
+func (s *MemoryStateStore) Get(name string) (io.ReadCloser, int64, error) {
+	data, err := s.get(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+func (s *MemoryStateStore) GetRange(name string, offset, length int64) ([]byte, error) {
+	data, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || offset+length > int64(len(data)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return slices.Clone(data[offset : offset+length]), nil
+}
+
+func (s *MemoryStateStore) Put(name string, writeData func(writer io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := writeData(&buf); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.objects[name] = buf.Bytes()
+	return nil
+}
+
+func (s *MemoryStateStore) Delete(name string) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.objects[name]; !ok {
+		return &fs.PathError{Op: "delete", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(s.objects, name)
+	return nil
+}
+
+func (s *MemoryStateStore) Rename(oldName, newName string) error {
+	s.Lock()
+	defer s.Unlock()
+	data, ok := s.objects[oldName]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrNotExist}
+	}
+	delete(s.objects, oldName)
+	s.objects[newName] = data
+	return nil
+}
+
+func (s *MemoryStateStore) Append(name string) (AppendableObject, error) {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.objects[name]; ok {
+		return nil, &fs.PathError{Op: "append", Path: name, Err: fs.ErrExist}
+	}
+	s.objects[name] = nil
+	return &bufferedAppendableObject{
+		publish: func(data []byte) error {
+			s.Lock()
+			defer s.Unlock()
+			s.objects[name] = slices.Clone(data)
+			return nil
+		},
+	}, nil
+}
+
+// For stores that can't append: we hold the whole object in memory and
+// re-publish it on every Flush. That's fine for move logs, which we rotate
+// long before they get big.
+type bufferedAppendableObject struct {
+	buf     bytes.Buffer
+	dirty   bool
+	publish func(data []byte) error
+}
+
+func (o *bufferedAppendableObject) Write(p []byte) (int, error) {
+	o.dirty = true
+	return o.buf.Write(p)
+}
+
+func (o *bufferedAppendableObject) Flush() error {
+	if !o.dirty {
+		return nil
+	}
+	if err := o.publish(o.buf.Bytes()); err != nil {
+		return err
+	}
+	o.dirty = false
+	return nil
+}
+
+func (o *bufferedAppendableObject) Close() error {
+	return o.Flush()
+}
+
+type storeReaderAt struct {
+	store StateStore
+	name  string
+}
+
+func (r *storeReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	data, err := r.store.GetRange(r.name, offset, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	return copy(p, data), nil
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"testing"
+	"time"
+)
+
+func putString(t *testing.T, store StateStore, name, data string) {
+	t.Helper()
+	err := store.Put(name, func(writer io.Writer) error {
+		_, err := io.WriteString(writer, data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("put %s: %v", name, err)
+	}
+}
+
+func getString(t *testing.T, store StateStore, name string) string {
+	t.Helper()
+	rc, size, err := store.Get(name)
+	if err != nil {
+		t.Fatalf("get %s: %v", name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("get %s: %v", name, err)
+	}
+	if size != int64(len(data)) {
+		t.Errorf("get %s: size is %d but got %d bytes", name, size, len(data))
+	}
+	return string(data)
+}
+
+func expectNotExist(t *testing.T, store StateStore, name string) {
+	t.Helper()
+	rc, _, err := store.Get(name)
+	if err == nil {
+		rc.Close()
+		t.Fatalf("get %s: expected it not to exist", name)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("get %s: got %v, want fs.ErrNotExist", name, err)
+	}
+}
+
+// What everything in board-to-disk-handler.go and move-log.go relies on,
+// whichever store we're using. Names have colons in them like ours do.
+func testStateStoreRoundTrip(t *testing.T, store StateStore) {
+	first := "moves-ts:1-firstseq:1-lastseq:10.bin"
+	second := "moves-ts:2-firstseq:11-lastseq:20.bin"
+	third := "moves-ts:3-firstseq:21-lastseq:30.bin"
+	putString(t, store, first, "hello world")
+	putString(t, store, second, "second")
+	putString(t, store, third, "third")
+	putString(t, store, "full-snapshot-ts:1-seqnum:10.bin", "snapshot")
+
+	names, err := store.List("moves-*.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{first, second, third}; !slices.Equal(names, want) {
+		t.Errorf("list: got %v, want %v", names, want)
+	}
+	names, err = store.List("nothing-*.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("list: got %v, want nothing", names)
+	}
+
+	if got := getString(t, store, first); got != "hello world" {
+		t.Errorf("get: got %q", got)
+	}
+	part, err := store.GetRange(first, 6, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(part) != "world" {
+		t.Errorf("get range: got %q, want %q", part, "world")
+	}
+	expectNotExist(t, store, "missing.bin")
+
+	// A failed put leaves the old object alone
+	err = store.Put(first, func(writer io.Writer) error {
+		io.WriteString(writer, "partial")
+		return errors.New("failed")
+	})
+	if err == nil {
+		t.Errorf("put: expected an error")
+	}
+	if got := getString(t, store, first); got != "hello world" {
+		t.Errorf("get after failed put: got %q", got)
+	}
+
+	renamed := "orphaned-" + second
+	if err := store.Rename(second, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if got := getString(t, store, renamed); got != "second" {
+		t.Errorf("get after rename: got %q", got)
+	}
+	expectNotExist(t, store, second)
+
+	if err := store.Delete(renamed); err != nil {
+		t.Fatal(err)
+	}
+	expectNotExist(t, store, renamed)
+
+	open := "moves-ts:4-firstseq:31.bin.open"
+	o, err := store.Append(open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(o, "abc")
+	if err := o.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// On S3, Flush only starts an upload
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rc, _, err := store.Get(open)
+		if err == nil {
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			if string(data) == "abc" {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("append: never saw what we flushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	io.WriteString(o, "def")
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := getString(t, store, open); got != "abcdef" {
+		t.Errorf("get after append: got %q", got)
+	}
+}
+
+func TestMemoryStateStore(t *testing.T) {
+	testStateStoreRoundTrip(t, NewMemoryStateStore())
+}
+
+func TestLocalStateStore(t *testing.T) {
+	testStateStoreRoundTrip(t, NewLocalStateStore(t.TempDir()))
+}
@@ -4,6 +4,10 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"one-million-chessboards/server"
 )
@@ -11,8 +15,98 @@ import (
 var (
 	requestsFile      = flag.String("read-requests", "", "Requests to read")
 	boardFileForStats = flag.String("board-file-for-stats", "", "Board file for most moves and captures")
+	convertGobLogs    = flag.String("convert-gob-logs", "", "Move log (or directory of move logs) to rewrite from gob into the current format")
+
+	pieceHistoryStateDir = flag.String("piece-history-state-dir", "", "State dir to read piece history from")
+	pieceHistoryID       = flag.Uint("piece-history-id", 0, "Print the history of this piece ID")
+	indexPieceHistory    = flag.String("index-piece-history", "", "State dir to build missing piece history indexes for")
+
+	retentionDryRun = flag.String("retention-dry-run", "", "State dir to print the retention plan for (uses -keep-snapshots and -compress-move-logs)")
+
+	reconstructStateDir = flag.String("reconstruct-state-dir", "", "State dir to reconstruct a past board from")
+	reconstructSeq      = flag.Uint64("reconstruct-seq", 0, "Reconstruct the board as of this seqnum")
+	reconstructTime     = flag.String("reconstruct-time", "", "Reconstruct the board as of this time (RFC3339)")
+	reconstructOut      = flag.String("reconstruct-snapshot-out", "", "Write the reconstructed board to this snapshot file")
+	reconstructRegion   = flag.String("reconstruct-region", "", "Print the FEN for each 8x8 board in this region: minBoardX,minBoardY,maxBoardX,maxBoardY")
 )
 
+func parseRegion(region string) (coords [4]uint16, err error) {
+	parts := strings.Split(region, ",")
+	if len(parts) != 4 {
+		err = fmt.Errorf("expected minBoardX,minBoardY,maxBoardX,maxBoardY, got %s", region)
+		return
+	}
+	for i, part := range parts {
+		var v uint64
+		v, err = strconv.ParseUint(strings.TrimSpace(part), 10, 16)
+		if err != nil {
+			return
+		}
+		coords[i] = uint16(v)
+	}
+	return
+}
+
+func reconstruct() error {
+	target := server.ReconstructionTarget{Seqnum: *reconstructSeq}
+	if *reconstructTime != "" {
+		t, err := time.Parse(time.RFC3339Nano, *reconstructTime)
+		if err != nil {
+			return err
+		}
+		target.TimestampNs = t.UnixNano()
+	}
+	if *reconstructOut == "" && *reconstructRegion == "" {
+		return fmt.Errorf("pass -reconstruct-snapshot-out and/or -reconstruct-region")
+	}
+	var region [4]uint16
+	if *reconstructRegion != "" {
+		var err error
+		region, err = parseRegion(*reconstructRegion)
+		if err != nil {
+			return err
+		}
+	}
+	btd, err := server.ReconstructBoard(*reconstructStateDir, target)
+	if err != nil {
+		return err
+	}
+	if *reconstructOut != "" {
+		err := btd.SaveSnapshotAs(*reconstructOut)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote snapshot at seqnum %d to %s\n", btd.SeqNum(), *reconstructOut)
+	}
+	if *reconstructRegion != "" {
+		return btd.PrintRegion(os.Stdout, region[0], region[1], region[2], region[3])
+	}
+	return nil
+}
+
+func convertGobMoveLogs(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "moves-*.bin"))
+		if err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		err := server.ConvertGobMoveLog(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", f, err)
+			continue
+		}
+		fmt.Printf("Converted %s\n", f)
+	}
+	return nil
+}
+
 func main() {
 	flag.Parse()
 	switch {
@@ -22,6 +116,36 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
 			os.Exit(1)
 		}
+	case *pieceHistoryStateDir != "":
+		err := server.PrintPieceHistory(*pieceHistoryStateDir, uint32(*pieceHistoryID))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading piece history: %v\n", err)
+			os.Exit(1)
+		}
+	case *indexPieceHistory != "":
+		err := server.IndexPieceHistory(*indexPieceHistory)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error indexing piece history: %v\n", err)
+			os.Exit(1)
+		}
+	case *retentionDryRun != "":
+		err := server.PrintRetentionPlan(*retentionDryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error planning retention: %v\n", err)
+			os.Exit(1)
+		}
+	case *reconstructStateDir != "":
+		err := reconstruct()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reconstructing board: %v\n", err)
+			os.Exit(1)
+		}
+	case *convertGobLogs != "":
+		err := convertGobMoveLogs(*convertGobLogs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error converting move logs: %v\n", err)
+			os.Exit(1)
+		}
 	case *boardFileForStats != "":
 		err := server.PrintLivePieceStats(*boardFileForStats)
 		if err != nil {
@@ -29,7 +153,7 @@ func main() {
 			os.Exit(1)
 		}
 	default:
-		fmt.Println("%s", flag.ErrHelp)
+		fmt.Println(flag.ErrHelp)
 		os.Exit(1)
 	}
 }